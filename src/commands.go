@@ -0,0 +1,262 @@
+package main
+
+import (
+	"flag"
+	"flhansen/fitter-login-service/src/loginservice"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		return runApplication()
+	}
+
+	switch args[0] {
+	case "serve":
+		return runApplication()
+	case "export-account":
+		return runExportAccount(args[1:], os.Stdout)
+	case "set-role":
+		return runSetRole(args[1:])
+	case "import-accounts":
+		return runImportAccounts(args[1:], os.Stdin, os.Stdout)
+	case "export-accounts":
+		return runExportAccounts(args[1:], os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n", args[0])
+		return 1
+	}
+}
+
+func runExportAccount(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("export-account", flag.ContinueOnError)
+	id := flags.Int("id", 0, "id of the account to export")
+	username := flags.String("username", "", "username of the account to export")
+	organization := flags.String("organization", "", "slug of the organization whose tenant is searched for -username")
+	format := flags.String("format", loginservice.ExportFormatJson, "export format (json or zip)")
+	output := flags.String("output", "", "file to write the export to (defaults to stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if *id == 0 && *username == "" {
+		fmt.Fprintln(os.Stderr, "Either -id or -username is required")
+		return 1
+	}
+
+	serviceConfig, databaseConfig, err := createConfigFromEnvironment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating configuration: %v\n", err)
+		return 1
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	service, err := createService(serviceConfig, databaseConfig, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating the service: %v\n", err)
+		return 1
+	}
+
+	if *id == 0 {
+		accountId, err := service.AccountIdByUsername(*organization, *username)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not find account '%s': %v\n", *username, err)
+			return 1
+		}
+
+		*id = accountId
+	}
+
+	export, err := service.ExportAccount(*id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not export account %d: %v\n", *id, err)
+		return 1
+	}
+
+	writer := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create output file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		writer = file
+	}
+
+	if err := loginservice.WriteAccountExport(writer, export, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write export: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func runSetRole(args []string) int {
+	flags := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	username := flags.String("username", "", "username of the account")
+	role := flags.String("role", "", "role to assign (user or admin)")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if *username == "" || (*role != security.RoleUser && *role != security.RoleAdmin) {
+		fmt.Fprintln(os.Stderr, "A -username and a -role of 'user' or 'admin' are required")
+		return 1
+	}
+
+	_, databaseConfig, err := createConfigFromEnvironment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating configuration: %v\n", err)
+		return 1
+	}
+
+	accountRepo := repository.NewAccountRepository(databaseConfig)
+	account, err := accountRepo.GetAccountByUsername(0, *username)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not find account '%s': %v\n", *username, err)
+		return 1
+	}
+
+	if err := accountRepo.UpdateAccountRole(account.Id, *role); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not update role of account '%s': %v\n", *username, err)
+		return 1
+	}
+
+	return 0
+}
+
+func runImportAccounts(args []string, stdin io.Reader, stdout io.Writer) int {
+	flags := flag.NewFlagSet("import-accounts", flag.ContinueOnError)
+	input := flags.String("input", "", "file to read the accounts from (defaults to stdin)")
+	format := flags.String("format", loginservice.BulkFormatCsv, "import format (csv or ndjson)")
+	organization := flags.String("organization", "", "slug of the organization to import the accounts into")
+	batchSize := flags.Int("batch-size", 100, "number of accounts inserted per transaction")
+	dryRun := flags.Bool("dry-run", false, "validate the accounts without importing them")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if !loginservice.IsBulkFormat(*format) || *batchSize < 1 {
+		fmt.Fprintln(os.Stderr, "A -format of 'csv' or 'ndjson' and a positive -batch-size are required")
+		return 1
+	}
+
+	serviceConfig, databaseConfig, err := createConfigFromEnvironment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating configuration: %v\n", err)
+		return 1
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	service, err := createService(serviceConfig, databaseConfig, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating the service: %v\n", err)
+		return 1
+	}
+
+	reader := stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open input file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	summary, err := service.ImportAccounts(reader, loginservice.ImportOptions{
+		Format:       *format,
+		Organization: *organization,
+		BatchSize:    *batchSize,
+		DryRun:       *dryRun,
+	})
+	writeImportSummary(stdout, summary)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not import accounts: %v\n", err)
+		return 1
+	}
+
+	if summary.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func writeImportSummary(w io.Writer, summary loginservice.ImportSummary) {
+	for _, rowError := range summary.Errors {
+		fmt.Fprintf(w, "Row %d (%s): %s\n", rowError.Row, rowError.Username, rowError.Error)
+	}
+
+	fmt.Fprintf(w, "%d accounts processed, %d imported, %d failed\n", summary.Total, summary.Imported, summary.Failed)
+}
+
+func runExportAccounts(args []string, stdout io.Writer) int {
+	flags := flag.NewFlagSet("export-accounts", flag.ContinueOnError)
+	format := flags.String("format", loginservice.BulkFormatCsv, "export format (csv or ndjson)")
+	organization := flags.String("organization", "", "slug of the organization whose members are exported")
+	withPasswordHashes := flags.Bool("with-password-hashes", false, "include password hashes in the export")
+	output := flags.String("output", "", "file to write the export to (defaults to stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if !loginservice.IsBulkFormat(*format) {
+		fmt.Fprintln(os.Stderr, "A -format of 'csv' or 'ndjson' is required")
+		return 1
+	}
+
+	serviceConfig, databaseConfig, err := createConfigFromEnvironment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating configuration: %v\n", err)
+		return 1
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	service, err := createService(serviceConfig, databaseConfig, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "An error occured while creating the service: %v\n", err)
+		return 1
+	}
+
+	writer := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create output file: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+
+		writer = file
+	}
+
+	count, err := service.ExportAccounts(writer, loginservice.ExportOptions{
+		Format:                *format,
+		Organization:          *organization,
+		IncludePasswordHashes: *withPasswordHashes,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not export accounts: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%d accounts exported\n", count)
+	return 0
+}
@@ -0,0 +1,198 @@
+package directory
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrUserNotFound       = errors.New("directory user not found")
+	ErrAmbiguousUser      = errors.New("directory user is ambiguous")
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+)
+
+type Config struct {
+	Url               string
+	StartTls          bool
+	BindDn            string
+	BindPassword      string
+	BaseDn            string
+	UserFilter        string
+	UsernameAttribute string
+	EmailAttribute    string
+	GroupAttribute    string
+	IdAttribute       string
+	Timeout           time.Duration
+}
+
+type Entry struct {
+	Id       string
+	Dn       string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+type Directory interface {
+	Authenticate(username string, password string) (Entry, error)
+}
+
+type ldapDirectory struct {
+	config Config
+}
+
+func NewLdapDirectory(config Config) Directory {
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &ldapDirectory{
+		config: config,
+	}
+}
+
+func (directory *ldapDirectory) Authenticate(username string, password string) (Entry, error) {
+	conn, err := directory.connect()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer conn.Close()
+
+	if directory.config.BindDn != "" {
+		if err := conn.Bind(directory.config.BindDn, directory.config.BindPassword); err != nil {
+			return Entry{}, fmt.Errorf("bind service account: %w", err)
+		}
+	}
+
+	attributes := []string{directory.config.UsernameAttribute, directory.config.EmailAttribute, directory.config.GroupAttribute}
+	if directory.config.IdAttribute != "" {
+		attributes = append(attributes, directory.config.IdAttribute)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		directory.config.BaseDn,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(directory.config.Timeout.Seconds()), false,
+		directory.userFilter(username),
+		attributes,
+		nil))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return Entry{}, ErrAmbiguousUser
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if len(result.Entries) == 0 {
+		return Entry{}, ErrUserNotFound
+	}
+
+	if len(result.Entries) > 1 {
+		return Entry{}, ErrAmbiguousUser
+	}
+
+	ldapEntry := result.Entries[0]
+	if password == "" {
+		return directory.newEntry(ldapEntry), ErrInvalidCredentials
+	}
+
+	if err := conn.Bind(ldapEntry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return directory.newEntry(ldapEntry), ErrInvalidCredentials
+		}
+
+		return Entry{}, err
+	}
+
+	return directory.newEntry(ldapEntry), nil
+}
+
+func (directory *ldapDirectory) connect() (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: directory.config.Timeout}
+	conn, err := ldap.DialURL(directory.config.Url, ldap.DialWithDialer(dialer))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(directory.config.Timeout)
+
+	if directory.config.StartTls {
+		serverUrl, err := url.Parse(directory.config.Url)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: serverUrl.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (directory *ldapDirectory) userFilter(username string) string {
+	filter := fmt.Sprintf("(%s=%s)", directory.config.UsernameAttribute, ldap.EscapeFilter(username))
+	if directory.config.UserFilter == "" {
+		return filter
+	}
+
+	return "(&" + directory.config.UserFilter + filter + ")"
+}
+
+func (directory *ldapDirectory) newEntry(ldapEntry *ldap.Entry) Entry {
+	entry := Entry{
+		Id:       ldapEntry.DN,
+		Dn:       ldapEntry.DN,
+		Username: ldapEntry.GetAttributeValue(directory.config.UsernameAttribute),
+		Email:    ldapEntry.GetAttributeValue(directory.config.EmailAttribute),
+		Groups:   ldapEntry.GetAttributeValues(directory.config.GroupAttribute),
+	}
+
+	if directory.config.IdAttribute != "" {
+		if id := ldapEntry.GetRawAttributeValue(directory.config.IdAttribute); len(id) > 0 {
+			entry.Id = string(id)
+			if !utf8.Valid(id) {
+				entry.Id = hex.EncodeToString(id)
+			}
+		}
+	}
+
+	return entry
+}
+
+func GroupMatches(group string, name string) bool {
+	if strings.EqualFold(group, name) {
+		return true
+	}
+
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 {
+		return false
+	}
+
+	for _, attribute := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attribute.Type, "cn") && strings.EqualFold(attribute.Value, name) {
+			return true
+		}
+	}
+
+	return false
+}
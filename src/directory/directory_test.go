@@ -0,0 +1,170 @@
+package directory
+
+import (
+	"errors"
+	"flhansen/fitter-login-service/src/testhelper"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDirectory(t *testing.T, config Config, entries ...testhelper.LdapEntry) (*testhelper.LdapServer, Directory) {
+	server, err := testhelper.NewLdapServer("cn=service,dc=fitter,dc=test", "service-secret", entries...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	config.Url = server.Url()
+	config.BindDn = "cn=service,dc=fitter,dc=test"
+	config.BindPassword = "service-secret"
+	config.BaseDn = "ou=people,dc=fitter,dc=test"
+
+	return server, NewLdapDirectory(config)
+}
+
+func aliceEntry() testhelper.LdapEntry {
+	return testhelper.LdapEntry{
+		Dn:       "uid=alice,ou=people,dc=fitter,dc=test",
+		Password: "alice-secret",
+		Attributes: map[string][]string{
+			"uid":         {"alice"},
+			"mail":        {"alice@fitter.test"},
+			"memberOf":    {"cn=admins,ou=groups,dc=fitter,dc=test", "cn=users,ou=groups,dc=fitter,dc=test"},
+			"objectClass": {"person"},
+			"entryUUID":   {"0f2a6c1e-9d43-4c55-9a0b-6a1d2b3c4d5e"},
+		},
+	}
+}
+
+func TestAuthenticateShouldReturnMappedEntry(t *testing.T) {
+	_, directory := newTestDirectory(t, Config{}, aliceEntry())
+
+	entry, err := directory.Authenticate("alice", "alice-secret")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Entry{
+		Id:       "uid=alice,ou=people,dc=fitter,dc=test",
+		Dn:       "uid=alice,ou=people,dc=fitter,dc=test",
+		Username: "alice",
+		Email:    "alice@fitter.test",
+		Groups:   []string{"cn=admins,ou=groups,dc=fitter,dc=test", "cn=users,ou=groups,dc=fitter,dc=test"},
+	}, entry)
+}
+
+func TestAuthenticateShouldUseConfiguredAttributes(t *testing.T) {
+	entry := aliceEntry()
+	entry.Attributes["sAMAccountName"] = []string{"ALICE"}
+	entry.Attributes["userPrincipalName"] = []string{"alice@corp.test"}
+	entry.Attributes["groups"] = []string{"fitter-admins"}
+	_, directory := newTestDirectory(t, Config{
+		UserFilter:        "(objectClass=person)",
+		UsernameAttribute: "sAMAccountName",
+		EmailAttribute:    "userPrincipalName",
+		GroupAttribute:    "groups",
+		IdAttribute:       "entryUUID",
+	}, entry)
+
+	result, err := directory.Authenticate("alice", "alice-secret")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0f2a6c1e-9d43-4c55-9a0b-6a1d2b3c4d5e", result.Id)
+	assert.Equal(t, "ALICE", result.Username)
+	assert.Equal(t, "alice@corp.test", result.Email)
+	assert.Equal(t, []string{"fitter-admins"}, result.Groups)
+}
+
+func TestAuthenticateShouldApplyUserFilter(t *testing.T) {
+	_, directory := newTestDirectory(t, Config{UserFilter: "(objectClass=inetOrgPerson)"}, aliceEntry())
+
+	_, err := directory.Authenticate("alice", "alice-secret")
+
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestAuthenticateShouldEscapeUsername(t *testing.T) {
+	_, directory := newTestDirectory(t, Config{}, aliceEntry())
+
+	_, err := directory.Authenticate("*", "alice-secret")
+
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestAuthenticateShouldRejectWrongPassword(t *testing.T) {
+	server, directory := newTestDirectory(t, Config{}, aliceEntry())
+
+	entry, err := directory.Authenticate("alice", "wrong")
+
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	assert.Equal(t, "uid=alice,ou=people,dc=fitter,dc=test", entry.Id)
+	assert.Equal(t, []string{"cn=service,dc=fitter,dc=test", "uid=alice,ou=people,dc=fitter,dc=test"}, server.Binds())
+}
+
+func TestAuthenticateShouldRejectEmptyPassword(t *testing.T) {
+	server, directory := newTestDirectory(t, Config{}, aliceEntry())
+
+	_, err := directory.Authenticate("alice", "")
+
+	assert.True(t, errors.Is(err, ErrInvalidCredentials))
+	assert.Equal(t, []string{"cn=service,dc=fitter,dc=test"}, server.Binds())
+}
+
+func TestAuthenticateShouldReturnNotFoundForUnknownUser(t *testing.T) {
+	_, directory := newTestDirectory(t, Config{}, aliceEntry())
+
+	_, err := directory.Authenticate("bob", "secret")
+
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestAuthenticateShouldRejectAmbiguousUser(t *testing.T) {
+	other := aliceEntry()
+	other.Dn = "uid=alice,ou=contractors,ou=people,dc=fitter,dc=test"
+	_, directory := newTestDirectory(t, Config{}, aliceEntry(), other)
+
+	_, err := directory.Authenticate("alice", "alice-secret")
+
+	assert.True(t, errors.Is(err, ErrAmbiguousUser))
+}
+
+func TestAuthenticateShouldFailWithInvalidServiceAccount(t *testing.T) {
+	server, err := testhelper.NewLdapServer("cn=service,dc=fitter,dc=test", "service-secret", aliceEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	directory := NewLdapDirectory(Config{
+		Url:          server.Url(),
+		BindDn:       "cn=service,dc=fitter,dc=test",
+		BindPassword: "wrong",
+		BaseDn:       "dc=fitter,dc=test",
+	})
+
+	_, err = directory.Authenticate("alice", "alice-secret")
+
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInvalidCredentials))
+}
+
+func TestAuthenticateShouldFailWhenServerIsUnreachable(t *testing.T) {
+	server, err := testhelper.NewLdapServer("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := server.Url()
+	server.Close()
+
+	_, err = NewLdapDirectory(Config{Url: url}).Authenticate("alice", "alice-secret")
+
+	assert.Error(t, err)
+}
+
+func TestGroupMatches(t *testing.T) {
+	assert.True(t, GroupMatches("cn=admins,ou=groups,dc=fitter,dc=test", "admins"))
+	assert.True(t, GroupMatches("CN=Admins,OU=Groups,DC=fitter,DC=test", "admins"))
+	assert.True(t, GroupMatches("cn=admins,ou=groups,dc=fitter,dc=test", "CN=Admins,ou=groups,dc=fitter,dc=test"))
+	assert.True(t, GroupMatches("fitter-admins", "fitter-admins"))
+	assert.False(t, GroupMatches("cn=users,ou=groups,dc=fitter,dc=test", "admins"))
+	assert.False(t, GroupMatches("ou=admins,dc=fitter,dc=test", "admins"))
+}
@@ -0,0 +1,435 @@
+package federation
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// minKeyRefreshInterval limits how often an unknown key id makes the
+	// provider fetch the key set again.
+	minKeyRefreshInterval = time.Minute
+)
+
+var (
+	ErrInvalidIdToken    = errors.New("invalid id token")
+	ErrNonceMismatch     = errors.New("nonce mismatch")
+	ErrMissingSubject    = errors.New("missing subject")
+	ErrUnknownSigningKey = errors.New("unknown signing key")
+)
+
+type ProviderConfig struct {
+	Name             string
+	IssuerUrl        string
+	ClientId         string
+	ClientSecret     string
+	RedirectUrl      string
+	Scopes           []string
+	AuthorizationUrl string
+	TokenUrl         string
+	UserInfoUrl      string
+	Timeout          time.Duration
+}
+
+type Identity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type Provider interface {
+	Name() string
+	AuthorizationUrl(state string, nonce string) (string, error)
+	Exchange(code string, nonce string) (Identity, error)
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IdToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type oidcProvider struct {
+	config    ProviderConfig
+	client    *http.Client
+	mutex     sync.Mutex
+	endpoints *discoveryDocument
+	keys      map[string]*rsa.PublicKey
+	keysFetch time.Time
+}
+
+func NewOidcProvider(config ProviderConfig) Provider {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &oidcProvider{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (provider *oidcProvider) Name() string {
+	return provider.config.Name
+}
+
+func (provider *oidcProvider) AuthorizationUrl(state string, nonce string) (string, error) {
+	endpoints, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	scopes := provider.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	authorizationUrl, err := url.Parse(endpoints.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authorizationUrl.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientId)
+	query.Set("redirect_uri", provider.config.RedirectUrl)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	authorizationUrl.RawQuery = query.Encode()
+
+	return authorizationUrl.String(), nil
+}
+
+func (provider *oidcProvider) Exchange(code string, nonce string) (Identity, error) {
+	endpoints, err := provider.discover()
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := provider.requestToken(endpoints.TokenEndpoint, code)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{Provider: provider.config.Name}
+	if endpoints.JwksUri != "" {
+		claims, err := provider.verifyIdToken(endpoints, token.IdToken, nonce)
+		if err != nil {
+			return Identity{}, err
+		}
+
+		identity.merge(claims)
+	}
+
+	if endpoints.UserInfoEndpoint != "" && token.AccessToken != "" && (endpoints.JwksUri == "" || identity.Email == "") {
+		claims, err := provider.requestUserInfo(endpoints.UserInfoEndpoint, token.AccessToken)
+		if err != nil {
+			return Identity{}, err
+		}
+
+		if subject := subjectClaim(claims); identity.Subject != "" && subject != identity.Subject {
+			return Identity{}, fmt.Errorf("userinfo subject %q does not match id token subject", subject)
+		}
+
+		identity.merge(claims)
+	}
+
+	if identity.Subject == "" {
+		return Identity{}, ErrMissingSubject
+	}
+
+	return identity, nil
+}
+
+func (provider *oidcProvider) discover() (discoveryDocument, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.endpoints != nil {
+		return *provider.endpoints, nil
+	}
+
+	endpoints := discoveryDocument{
+		Issuer:                provider.config.IssuerUrl,
+		AuthorizationEndpoint: provider.config.AuthorizationUrl,
+		TokenEndpoint:         provider.config.TokenUrl,
+		UserInfoEndpoint:      provider.config.UserInfoUrl,
+	}
+
+	if provider.config.IssuerUrl != "" {
+		var document discoveryDocument
+		if err := provider.getJson(strings.TrimSuffix(provider.config.IssuerUrl, "/")+discoveryPath, "", &document); err != nil {
+			return discoveryDocument{}, err
+		}
+
+		if document.Issuer != provider.config.IssuerUrl {
+			return discoveryDocument{}, fmt.Errorf("discovered issuer %q does not match %q", document.Issuer, provider.config.IssuerUrl)
+		}
+
+		endpoints.JwksUri = document.JwksUri
+		if endpoints.AuthorizationEndpoint == "" {
+			endpoints.AuthorizationEndpoint = document.AuthorizationEndpoint
+		}
+		if endpoints.TokenEndpoint == "" {
+			endpoints.TokenEndpoint = document.TokenEndpoint
+		}
+		if endpoints.UserInfoEndpoint == "" {
+			endpoints.UserInfoEndpoint = document.UserInfoEndpoint
+		}
+	}
+
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" {
+		return discoveryDocument{}, fmt.Errorf("provider %q has no authorization or token endpoint", provider.config.Name)
+	}
+
+	provider.endpoints = &endpoints
+	return endpoints, nil
+}
+
+func (provider *oidcProvider) requestToken(tokenUrl string, code string) (tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.config.RedirectUrl)
+	form.Set("client_id", provider.config.ClientId)
+	form.Set("client_secret", provider.config.ClientSecret)
+
+	req, err := http.NewRequest(http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return tokenResponse{}, fmt.Errorf("token endpoint responded with status %d", res.StatusCode)
+	}
+
+	if token.Error != "" {
+		return tokenResponse{}, fmt.Errorf("token endpoint responded with error %q: %s", token.Error, token.ErrorDescription)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return tokenResponse{}, fmt.Errorf("token endpoint responded with status %d", res.StatusCode)
+	}
+
+	return token, nil
+}
+
+func (provider *oidcProvider) requestUserInfo(userInfoUrl string, accessToken string) (map[string]interface{}, error) {
+	claims := map[string]interface{}{}
+	err := provider.getJson(userInfoUrl, accessToken, &claims)
+	return claims, err
+}
+
+func (provider *oidcProvider) verifyIdToken(endpoints discoveryDocument, rawToken string, nonce string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, ErrInvalidIdToken
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		return provider.signingKey(endpoints.JwksUri, kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdToken, err)
+	}
+
+	if !claims.VerifyIssuer(endpoints.Issuer, true) || !claims.VerifyAudience(provider.config.ClientId, true) ||
+		!claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrInvalidIdToken
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
+
+func (provider *oidcProvider) signingKey(jwksUri string, kid string) (*rsa.PublicKey, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key := provider.cachedKey(kid); key != nil {
+		return key, nil
+	}
+
+	if jwksUri == "" || time.Since(provider.keysFetch) < minKeyRefreshInterval {
+		return nil, ErrUnknownSigningKey
+	}
+
+	provider.keysFetch = time.Now()
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := provider.getJson(jwksUri, "", &keySet); err != nil {
+		return nil, err
+	}
+
+	provider.keys = map[string]*rsa.PublicKey{}
+	for _, webKey := range keySet.Keys {
+		if key, err := webKey.publicKey(); err == nil {
+			provider.keys[webKey.Kid] = key
+		}
+	}
+
+	if key := provider.cachedKey(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, ErrUnknownSigningKey
+}
+
+func (provider *oidcProvider) cachedKey(kid string) *rsa.PublicKey {
+	if key, ok := provider.keys[kid]; ok {
+		return key
+	}
+
+	if kid == "" && len(provider.keys) == 1 {
+		for _, key := range provider.keys {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func (provider *oidcProvider) getJson(resourceUrl string, accessToken string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, resourceUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", resourceUrl, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+func (webKey jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	if webKey.Kty != "RSA" {
+		return nil, ErrUnknownSigningKey
+	}
+
+	modulus, err := base64.RawURLEncoding.DecodeString(webKey.N)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent, err := base64.RawURLEncoding.DecodeString(webKey.E)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
+
+func (identity *Identity) merge(claims map[string]interface{}) {
+	if identity.Subject == "" {
+		identity.Subject = subjectClaim(claims)
+	}
+
+	if email, _ := claims["email"].(string); identity.Email == "" && email != "" {
+		identity.Email = email
+		identity.EmailVerified = boolClaim(claims["email_verified"])
+	}
+
+	if name, _ := claims["name"].(string); identity.Name == "" {
+		identity.Name = name
+	}
+
+	if identity.PreferredUsername == "" {
+		identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	}
+
+	if identity.PreferredUsername == "" {
+		identity.PreferredUsername, _ = claims["login"].(string)
+	}
+}
+
+func subjectClaim(claims map[string]interface{}) string {
+	if subject, ok := claims["sub"].(string); ok && subject != "" {
+		return subject
+	}
+
+	switch id := claims["id"].(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+
+	return ""
+}
+
+func boolClaim(value interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case string:
+		verified, _ := strconv.ParseBool(value)
+		return verified
+	}
+
+	return false
+}
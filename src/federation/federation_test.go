@@ -0,0 +1,177 @@
+package federation
+
+import (
+	"errors"
+	"flhansen/fitter-login-service/src/testhelper"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProvider(t *testing.T) (*testhelper.OidcProvider, Provider) {
+	server, err := testhelper.NewOidcProvider("fitter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	provider := NewOidcProvider(ProviderConfig{
+		Name:         "test",
+		IssuerUrl:    server.Issuer(),
+		ClientId:     "fitter",
+		ClientSecret: "secret",
+		RedirectUrl:  "http://localhost/api/auth/federation/test/callback",
+	})
+
+	return server, provider
+}
+
+func TestAuthorizationUrlShouldUseDiscoveredEndpoint(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	authorizationUrl, err := provider.AuthorizationUrl("state-value", "nonce-value")
+
+	assert.NoError(t, err)
+	parsed, err := url.Parse(authorizationUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, server.Issuer()+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "code", parsed.Query().Get("response_type"))
+	assert.Equal(t, "fitter", parsed.Query().Get("client_id"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, "state-value", parsed.Query().Get("state"))
+	assert.Equal(t, "nonce-value", parsed.Query().Get("nonce"))
+}
+
+func TestAuthorizationUrlShouldFailOnIssuerMismatch(t *testing.T) {
+	server, err := testhelper.NewOidcProvider("fitter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	provider := NewOidcProvider(ProviderConfig{Name: "test", IssuerUrl: server.Issuer() + "/", ClientId: "fitter"})
+
+	_, err = provider.AuthorizationUrl("state", "nonce")
+
+	assert.Error(t, err)
+}
+
+func TestExchangeShouldReturnIdentityFromIdToken(t *testing.T) {
+	server, provider := newTestProvider(t)
+	code := server.IssueCode(map[string]interface{}{
+		"sub":                "subject-1",
+		"email":              "alice@test.com",
+		"email_verified":     true,
+		"name":               "Alice",
+		"preferred_username": "alice",
+		"nonce":              "nonce-value",
+	})
+
+	identity, err := provider.Exchange(code, "nonce-value")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{
+		Provider:          "test",
+		Subject:           "subject-1",
+		Email:             "alice@test.com",
+		EmailVerified:     true,
+		Name:              "Alice",
+		PreferredUsername: "alice",
+	}, identity)
+}
+
+func TestExchangeShouldRejectNonceMismatch(t *testing.T) {
+	server, provider := newTestProvider(t)
+	code := server.IssueCode(map[string]interface{}{"sub": "subject-1", "nonce": "other"})
+
+	_, err := provider.Exchange(code, "nonce-value")
+
+	assert.True(t, errors.Is(err, ErrNonceMismatch))
+}
+
+func TestExchangeShouldRejectInvalidIdTokens(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"audience": {"sub": "subject-1", "nonce": "nonce-value", "aud": "other-client"},
+		"issuer":   {"sub": "subject-1", "nonce": "nonce-value", "iss": "https://evil.test"},
+		"expired":  {"sub": "subject-1", "nonce": "nonce-value", "exp": time.Now().Add(-time.Minute).Unix()},
+	}
+
+	for name, claims := range cases {
+		t.Run(name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+
+			_, err := provider.Exchange(server.IssueCode(claims), "nonce-value")
+
+			assert.True(t, errors.Is(err, ErrInvalidIdToken))
+		})
+	}
+}
+
+func TestExchangeShouldRejectInvalidSignatures(t *testing.T) {
+	server, provider := newTestProvider(t)
+	other, err := testhelper.NewOidcProvider("fitter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	_, err = provider.Exchange(server.IssueCode(map[string]interface{}{"sub": "subject-1", "nonce": "nonce-value"}), "nonce-value")
+	assert.NoError(t, err)
+
+	server.Key = other.Key
+	_, err = provider.Exchange(server.IssueCode(map[string]interface{}{"sub": "subject-1", "nonce": "nonce-value"}), "nonce-value")
+
+	assert.True(t, errors.Is(err, ErrInvalidIdToken))
+}
+
+func TestExchangeShouldLimitKeySetRefreshes(t *testing.T) {
+	server, provider := newTestProvider(t)
+	_, err := provider.Exchange(server.IssueCode(map[string]interface{}{"sub": "subject-1", "nonce": "nonce-value"}), "nonce-value")
+	assert.NoError(t, err)
+
+	server.KeyId = "unknown-key"
+	for i := 0; i < 3; i++ {
+		_, err = provider.Exchange(server.IssueCode(map[string]interface{}{"sub": "subject-1", "nonce": "nonce-value"}), "nonce-value")
+		assert.True(t, errors.Is(err, ErrInvalidIdToken))
+	}
+
+	assert.Equal(t, 1, server.JwksRequests())
+}
+
+func TestExchangeShouldFailOnInvalidCode(t *testing.T) {
+	_, provider := newTestProvider(t)
+
+	_, err := provider.Exchange("unknown", "nonce-value")
+
+	assert.Error(t, err)
+}
+
+func TestExchangeShouldUseUserInfoForOAuthProviders(t *testing.T) {
+	server, err := testhelper.NewOidcProvider("fitter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	provider := NewOidcProvider(ProviderConfig{
+		Name:             "github",
+		ClientId:         "fitter",
+		ClientSecret:     "secret",
+		AuthorizationUrl: server.Issuer() + "/authorize",
+		TokenUrl:         server.Issuer() + "/token",
+		UserInfoUrl:      server.Issuer() + "/userinfo",
+	})
+	code := server.IssueCode(map[string]interface{}{"id": 4711, "login": "octocat", "email": "octocat@test.com"})
+
+	identity, err := provider.Exchange(code, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Identity{
+		Provider:          "github",
+		Subject:           "4711",
+		Email:             "octocat@test.com",
+		PreferredUsername: "octocat",
+	}, identity)
+}
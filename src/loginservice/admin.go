@@ -0,0 +1,203 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultAccountPageSize = 20
+	maxAccountPageSize     = 100
+)
+
+type AdminAccountResponse struct {
+	ProfileResponse
+	Role                  string     `json:"role"`
+	StatusReason          string     `json:"statusReason,omitempty"`
+	StatusChangedDate     *time.Time `json:"statusChangedDate,omitempty"`
+	PasswordResetRequired bool       `json:"passwordResetRequired"`
+	FailedLoginAttempts   int        `json:"failedLoginAttempts"`
+	LockedUntil           *time.Time `json:"lockedUntil,omitempty"`
+}
+
+func newAdminAccountResponse(account repository.Account) AdminAccountResponse {
+	return AdminAccountResponse{
+		ProfileResponse:       newProfileResponse(account),
+		Role:                  account.Role,
+		StatusReason:          account.StatusReason,
+		StatusChangedDate:     account.StatusChangedDate,
+		PasswordResetRequired: account.PasswordResetRequired,
+		FailedLoginAttempts:   account.FailedLoginAttempts,
+		LockedUntil:           account.LockedUntil,
+	}
+}
+
+func queryInt(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func accountFilterFromRequest(r *http.Request) (repository.AccountFilter, int, bool) {
+	query := r.URL.Query()
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		return repository.AccountFilter{}, 0, false
+	}
+
+	pageSize, err := queryInt(r, "pageSize", defaultAccountPageSize)
+	if err != nil || pageSize < 1 || pageSize > maxAccountPageSize {
+		return repository.AccountFilter{}, 0, false
+	}
+
+	filter := repository.AccountFilter{
+		Search: query.Get("search"),
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+		Limit:  pageSize,
+		Offset: (page - 1) * pageSize,
+	}
+
+	if filter.Status != "" && !isAccountStatus(filter.Status) {
+		return repository.AccountFilter{}, 0, false
+	}
+
+	filter.SortBy = query.Get("sort")
+	if strings.HasPrefix(filter.SortBy, "-") {
+		filter.SortBy = strings.TrimPrefix(filter.SortBy, "-")
+		filter.Descending = true
+	}
+
+	if !repository.IsValidAccountSortField(filter.SortBy) {
+		return repository.AccountFilter{}, 0, false
+	}
+
+	return filter, page, true
+}
+
+func (service *LoginService) accountFromParams(w http.ResponseWriter, p httprouter.Params) (repository.Account, bool) {
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid account id.")
+		return repository.Account{}, false
+	}
+
+	account, err := service.accountRepo.GetAccountById(id)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return repository.Account{}, false
+	}
+
+	return account, true
+}
+
+func (service *LoginService) AdminAccountsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	filter, page, ok := accountFilterFromRequest(r)
+	if !ok {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid query parameters.")
+		return
+	}
+
+	accounts, err := service.accountRepo.GetAccounts(filter)
+	if err != nil {
+		service.logger.Errorf("(%s) list accounts failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not list accounts.")
+		return
+	}
+
+	total, err := service.accountRepo.CountAccounts(filter)
+	if err != nil {
+		service.logger.Errorf("(%s) count accounts failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not list accounts.")
+		return
+	}
+
+	response := []AdminAccountResponse{}
+	for _, account := range accounts {
+		response = append(response, newAdminAccountResponse(account))
+	}
+
+	sendResponse(w, http.StatusOK, "Accounts found.", map[string]interface{}{
+		"accounts": response,
+		"page":     page,
+		"pageSize": filter.Limit,
+		"total":    total,
+	})
+}
+
+func (service *LoginService) AdminAccountHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Account found.", map[string]interface{}{
+		"account": newAdminAccountResponse(account),
+	})
+}
+
+func (service *LoginService) AdminDisableAccountHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	service.adminChangeAccountStatus(w, r, p, repository.AccountStatusSuspended)
+}
+
+func (service *LoginService) AdminEnableAccountHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	service.adminChangeAccountStatus(w, r, p, repository.AccountStatusActive)
+}
+
+func (service *LoginService) AdminPasswordResetHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountPasswordResetRequired(account.Id, true); err != nil {
+		service.logger.Errorf("(%s) force password reset of account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not force password reset.")
+		return
+	}
+
+	mailSent := true
+	if err := service.sendPasswordResetMail(account); err != nil {
+		service.logger.Errorf("(%s) send password reset email to account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		mailSent = false
+	}
+
+	service.logger.Infof("(%s) password reset of account %d forced by '%s'", r.RemoteAddr, account.Id, claims.Username)
+	sendResponse(w, http.StatusOK, "Password reset required.", map[string]interface{}{
+		"mailSent": mailSent,
+	})
+}
+
+func (service *LoginService) AdminDeleteAccountHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	if account.Id == claims.UserId {
+		sendSimpleResponse(w, http.StatusBadRequest, "Administrators cannot delete their own account.")
+		return
+	}
+
+	if err := service.deleteAccount(account, "deleted by administrator", claims.Username); err != nil {
+		service.logger.Errorf("(%s) delete account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not delete account.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d deleted by '%s'", r.RemoteAddr, account.Id, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "Account deleted.")
+}
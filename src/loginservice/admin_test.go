@@ -0,0 +1,216 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func adminRequest(t *testing.T, method string, path string) *http.Request {
+	request, _ := http.NewRequest(method, path, http.NoBody)
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	return request
+}
+
+func TestAdminAccountsHandlerShouldPassFilter(t *testing.T) {
+	// given
+	expectedFilter := repository.AccountFilter{Search: "test", Status: repository.AccountStatusSuspended, SortBy: "creationDate", Descending: true, Limit: 10, Offset: 10}
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccounts", expectedFilter).
+		Return([]repository.Account{{Id: 2, Username: "testuser", Password: "hash"}}, nil).
+		On("CountAccounts", expectedFilter).
+		Return(11, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/accounts?search=test&status=suspended&sort=-creationDate&page=2&pageSize=10"))
+
+	body := responseWriter.Body.String()
+	var response struct {
+		Accounts []map[string]interface{} `json:"accounts"`
+		Total    int                      `json:"total"`
+		Page     int                      `json:"page"`
+	}
+	err := json.Unmarshal([]byte(body), &response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotContains(t, body, "hash")
+	assert.Len(t, response.Accounts, 1)
+	assert.Equal(t, "testuser", response.Accounts[0]["username"])
+	assert.Equal(t, 11, response.Total)
+	assert.Equal(t, 2, response.Page)
+}
+
+func TestAdminAccountsHandlerShouldRejectInvalidQuery(t *testing.T) {
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger))
+
+	for _, query := range []string{"page=0", "pageSize=1000", "sort=password", "status=unknown"} {
+		responseWriter := httptest.NewRecorder()
+		service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/accounts?"+query))
+
+		assert.Equal(t, http.StatusBadRequest, responseWriter.Code, query)
+	}
+}
+
+func TestAdminAccountHandlerShouldReturnNotFound(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{}, assert.AnError)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/accounts/2"))
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+}
+
+func TestAdminDisableAccountHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountStatus", mock.MatchedBy(func(transition repository.AccountStatusTransition) bool {
+			return transition.AccountId == 2 && transition.ToStatus == repository.AccountStatusSuspended && transition.Actor == "admin"
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodPost, "/api/admin/accounts/2/disable"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountStatus", mock.Anything)
+}
+
+func TestAdminDisableAccountHandlerShouldRejectOwnAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "admin", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodPost, "/api/admin/accounts/1/disable"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountStatus", mock.Anything)
+}
+
+func TestAdminEnableAccountHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusSuspended}, nil).
+		On("UpdateAccountStatus", mock.MatchedBy(func(transition repository.AccountStatusTransition) bool {
+			return transition.FromStatus == repository.AccountStatusSuspended && transition.ToStatus == repository.AccountStatusActive
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodPost, "/api/admin/accounts/2/enable"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+}
+
+func TestAdminPasswordResetHandlerShouldSendMail(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Email: "test@test.com"}, nil).
+		On("UpdateAccountPasswordResetRequired", 2, true).
+		Return(nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedMailer.
+		On("SendMail", mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{PasswordResetUrl: "https://fitter.app/reset"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithMailer(mockedMailer))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodPost, "/api/admin/accounts/2/password-reset"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedMailer.AssertCalled(t, "SendMail", mock.MatchedBy(func(mail messaging.Mail) bool {
+		return mail.To == "test@test.com" && strings.Contains(mail.Body, "https://fitter.app/reset?token=")
+	}))
+}
+
+func TestAdminDeleteAccountHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusActive}, nil).
+		On("DeleteAccount", 2, mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodDelete, "/api/admin/accounts/2"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccount", 2, mock.MatchedBy(func(transition *repository.AccountStatusTransition) bool {
+		return transition.AccountId == 2 && transition.ToStatus == repository.AccountStatusDeleted && transition.Actor == "admin"
+	}))
+}
@@ -0,0 +1,214 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxApiKeyNameLength = 255
+	apiKeyHintLength    = 4
+)
+
+var apiKeyScopes = map[string]bool{
+	security.ScopeRead:  true,
+	security.ScopeWrite: true,
+	security.ScopeAdmin: true,
+}
+
+type ApiKeyRequest struct {
+	Name           string     `json:"name"`
+	Scopes         []string   `json:"scopes"`
+	ExpirationDate *time.Time `json:"expirationDate,omitempty"`
+}
+
+type ApiKeyResponse struct {
+	Id             int        `json:"id"`
+	Name           string     `json:"name"`
+	Hint           string     `json:"hint"`
+	Scopes         []string   `json:"scopes"`
+	CreationDate   time.Time  `json:"creationDate"`
+	ExpirationDate *time.Time `json:"expirationDate,omitempty"`
+	LastUsedDate   *time.Time `json:"lastUsedDate,omitempty"`
+}
+
+func WithApiKeyRepository(apiKeyRepo repository.ApiKeyRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.apiKeyRepo = apiKeyRepo
+	}
+}
+
+func newApiKeyResponse(key repository.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		Id:             key.Id,
+		Name:           key.Name,
+		Hint:           key.Hint,
+		Scopes:         key.Scopes,
+		CreationDate:   key.CreationDate,
+		ExpirationDate: key.ExpirationDate,
+		LastUsedDate:   key.LastUsedDate,
+	}
+}
+
+func normalizeApiKeyScopes(scopes []string) ([]string, bool) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			return nil, false
+		}
+
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	return normalized, len(normalized) > 0
+}
+
+func (service *LoginService) apiKeyExpirationDate(requested *time.Time, now time.Time) (*time.Time, bool) {
+	maxLifetime := service.config.ApiKeys.MaxLifetime
+	if requested == nil {
+		if maxLifetime <= 0 {
+			return nil, true
+		}
+
+		expirationDate := now.Add(maxLifetime)
+		return &expirationDate, true
+	}
+
+	if !requested.After(now) || (maxLifetime > 0 && requested.After(now.Add(maxLifetime))) {
+		return nil, false
+	}
+
+	return requested, true
+}
+
+func (service *LoginService) ApiKeysHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.apiKeyRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "API keys are not available.")
+		return
+	}
+
+	keys, err := service.apiKeyRepo.GetApiKeys(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get api keys of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get API keys.")
+		return
+	}
+
+	response := []ApiKeyResponse{}
+	for _, key := range keys {
+		response = append(response, newApiKeyResponse(key))
+	}
+
+	sendResponse(w, http.StatusOK, "API keys.", map[string]interface{}{
+		"apiKeys": response,
+	})
+}
+
+func (service *LoginService) CreateApiKeyHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.apiKeyRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "API keys are not available.")
+		return
+	}
+
+	var request ApiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > maxApiKeyNameLength {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid API key name.")
+		return
+	}
+
+	scopes, ok := normalizeApiKeyScopes(request.Scopes)
+	if !ok {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid API key scopes.")
+		return
+	}
+
+	for _, scope := range scopes {
+		if scope == security.ScopeAdmin && claims.Role != security.RoleAdmin {
+			service.logger.Warnf("(%s) user '%s' without role '%s' rejected", r.RemoteAddr, claims.Username, security.RoleAdmin)
+			sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+			return
+		}
+	}
+
+	now := time.Now()
+	expirationDate, ok := service.apiKeyExpirationDate(request.ExpirationDate, now)
+	if !ok {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid expiration date.")
+		return
+	}
+
+	rawKey, err := security.GenerateApiKey()
+	if err != nil {
+		service.logger.Errorf("(%s) generate api key for user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not create API key.")
+		return
+	}
+
+	key := repository.ApiKey{
+		AccountId:      claims.UserId,
+		Name:           request.Name,
+		KeyHash:        security.HashApiKey(rawKey),
+		Hint:           rawKey[:len(security.ApiKeyPrefix)+apiKeyHintLength],
+		Scopes:         scopes,
+		CreationDate:   now,
+		ExpirationDate: expirationDate,
+	}
+	key.Id, err = service.apiKeyRepo.CreateApiKey(key)
+	if err != nil {
+		service.logger.Errorf("(%s) create api key for user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not create API key.")
+		return
+	}
+
+	service.logger.Infof("(%s) api key %d created by '%s'", r.RemoteAddr, key.Id, claims.Username)
+	sendResponse(w, http.StatusOK, "API key created.", map[string]interface{}{
+		"key":    rawKey,
+		"apiKey": newApiKeyResponse(key),
+	})
+}
+
+func (service *LoginService) RevokeApiKeyHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.apiKeyRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "API keys are not available.")
+		return
+	}
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid API key id.")
+		return
+	}
+
+	if err := service.apiKeyRepo.DeleteApiKey(claims.UserId, id); err != nil {
+		service.logger.Warnf("(%s) revoke api key %d of user '%s' failed: %s", r.RemoteAddr, id, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "API key not found.")
+		return
+	}
+
+	service.logger.Infof("(%s) api key %d revoked by '%s'", r.RemoteAddr, id, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "API key revoked.")
+}
@@ -0,0 +1,331 @@
+package loginservice
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testApiKey = security.ApiKeyPrefix + "testkey"
+
+func apiKeyService(account repository.Account, scopes []string) (*LoginService, *mocks.ApiKeyRepository, *mocks.Logger) {
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", account.Id).
+		Return(account, nil)
+	mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+	mockedApiKeyRepo.
+		On("GetApiKeyByHash", security.HashApiKey(testApiKey)).
+		Return(repository.ApiKey{Id: 4, AccountId: account.Id, Scopes: scopes}, nil).
+		On("GetApiKeyByHash", mock.Anything).
+		Return(repository.ApiKey{}, sql.ErrNoRows).
+		On("UpdateApiKeyLastUsed", 4, mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything).
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithApiKeyRepository(mockedApiKeyRepo))
+
+	return service, mockedApiKeyRepo, mockedLogger
+}
+
+func TestAuthenticatedShouldAcceptApiKey(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "testuser", Role: security.RoleUser, Status: repository.AccountStatusActive}
+	service, mockedApiKeyRepo, _ := apiKeyService(account, []string{security.ScopeRead})
+
+	var claims *security.JwtClaims
+	handle := service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		claims = claimsFromRequest(r)
+	})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	handle(httptest.NewRecorder(), request, nil)
+
+	// then
+	assert.NotNil(t, claims)
+	assert.Equal(t, 1, claims.UserId)
+	assert.Equal(t, 4, claims.ApiKeyId)
+	assert.Equal(t, []string{security.AuthMethodApiKey}, claims.AuthMethods)
+	mockedApiKeyRepo.AssertCalled(t, "UpdateApiKeyLastUsed", 4, mock.Anything)
+}
+
+func TestAuthenticatedShouldRejectUnknownApiKey(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}
+	service, _, mockedLogger := apiKeyService(account, []string{security.ScopeRead})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	request.Header.Set("Authorization", "Bearer "+security.ApiKeyPrefix+"unknown")
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) authentication failed: %s", mock.Anything, errInvalidApiKey.Error())
+}
+
+func TestAuthenticatedShouldRejectApiKeyOfSuspendedAccount(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusSuspended}
+	service, mockedApiKeyRepo, _ := apiKeyService(account, []string{security.ScopeRead})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedApiKeyRepo.AssertNotCalled(t, "UpdateApiKeyLastUsed", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticatedShouldRequireWriteScopeForChanges(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}
+	service, _, _ := apiKeyService(account, []string{security.ScopeRead})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "displayName": "Test" }`))
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestRequireRoleShouldRequireAdminScopeForApiKey(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "admin", Role: security.RoleAdmin, Status: repository.AccountStatusActive}
+	service, _, _ := apiKeyService(account, []string{security.ScopeRead})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/admin/accounts", nil)
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestCreateApiKeyHandlerShouldRejectApiKeyAuthentication(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}
+	service, mockedApiKeyRepo, _ := apiKeyService(account, []string{security.ScopeRead, security.ScopeWrite})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/api-keys", bytes.NewBufferString(`{ "name": "ci", "scopes": ["read"] }`))
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	mockedApiKeyRepo.AssertNotCalled(t, "CreateApiKey", mock.Anything)
+}
+
+func TestAccountSecurityRoutesShouldRejectApiKeyAuthentication(t *testing.T) {
+	account := repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}
+	routes := map[string]string{
+		"/api/auth/me":           http.MethodPatch,
+		"/api/auth/phone":        http.MethodPost,
+		"/api/auth/phone/verify": http.MethodPost,
+		"/api/auth/devices/3":    http.MethodDelete,
+	}
+
+	for path, method := range routes {
+		t.Run(method+" "+path, func(t *testing.T) {
+			// given
+			service, _, _ := apiKeyService(account, []string{security.ScopeRead, security.ScopeWrite})
+
+			// when
+			request, _ := http.NewRequest(method, path, bytes.NewBufferString(`{}`))
+			request.Header.Set("Authorization", "Bearer "+testApiKey)
+			responseWriter := httptest.NewRecorder()
+			service.handler.ServeHTTP(responseWriter, request)
+
+			// then
+			assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+			assert.Contains(t, responseWriter.Body.String(), "API keys are not allowed for this request.")
+		})
+	}
+}
+
+func TestCreateApiKeyHandlerShouldShowKeyOnce(t *testing.T) {
+	// given
+	var stored repository.ApiKey
+	mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+	mockedApiKeyRepo.
+		On("CreateApiKey", mock.MatchedBy(func(key repository.ApiKey) bool {
+			stored = key
+			return true
+		})).
+		Return(9, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:     security.JwtConfig{SignKey: "secret"},
+		ApiKeys: ApiKeyConfig{MaxLifetime: 24 * time.Hour},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithApiKeyRepository(mockedApiKeyRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/api-keys", bytes.NewBufferString(`{ "name": " ci ", "scopes": ["read", "write", "read"] }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response struct {
+		Key    string         `json:"key"`
+		ApiKey ApiKeyResponse `json:"apiKey"`
+	}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.True(t, strings.HasPrefix(response.Key, security.ApiKeyPrefix))
+	assert.Equal(t, 9, response.ApiKey.Id)
+	assert.Equal(t, "ci", response.ApiKey.Name)
+	assert.Equal(t, []string{security.ScopeRead, security.ScopeWrite}, response.ApiKey.Scopes)
+	assert.True(t, strings.HasPrefix(response.Key, response.ApiKey.Hint))
+	assert.NotNil(t, response.ApiKey.ExpirationDate)
+	assert.Equal(t, 1, stored.AccountId)
+	assert.Equal(t, security.HashApiKey(response.Key), stored.KeyHash)
+	assert.NotContains(t, stored.KeyHash, response.Key)
+}
+
+func TestCreateApiKeyHandlerShouldValidateRequest(t *testing.T) {
+	cases := map[string]struct {
+		body   string
+		status int
+	}{
+		"missing name":      {body: `{ "name": " ", "scopes": ["read"] }`, status: http.StatusBadRequest},
+		"missing scopes":    {body: `{ "name": "ci", "scopes": [] }`, status: http.StatusBadRequest},
+		"unknown scope":     {body: `{ "name": "ci", "scopes": ["delete"] }`, status: http.StatusBadRequest},
+		"admin scope":       {body: `{ "name": "ci", "scopes": ["admin"] }`, status: http.StatusForbidden},
+		"expired":           {body: `{ "name": "ci", "scopes": ["read"], "expirationDate": "2000-01-01T00:00:00Z" }`, status: http.StatusBadRequest},
+		"exceeded lifetime": {body: `{ "name": "ci", "scopes": ["read"], "expirationDate": "2999-01-01T00:00:00Z" }`, status: http.StatusBadRequest},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			// given
+			mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+			mockedLogger := new(mocks.Logger)
+			mockedLogger.
+				On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			service := NewService(LoginServiceConfig{
+				Jwt:     security.JwtConfig{SignKey: "secret"},
+				ApiKeys: ApiKeyConfig{MaxLifetime: 24 * time.Hour},
+			}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+				WithApiKeyRepository(mockedApiKeyRepo))
+
+			// when
+			request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/api-keys", bytes.NewBufferString(testCase.body))
+			request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+			responseWriter := httptest.NewRecorder()
+			service.handler.ServeHTTP(responseWriter, request)
+
+			// then
+			assert.Equal(t, testCase.status, responseWriter.Code)
+			mockedApiKeyRepo.AssertNotCalled(t, "CreateApiKey", mock.Anything)
+		})
+	}
+}
+
+func TestApiKeysHandlerShouldListKeysWithoutSecrets(t *testing.T) {
+	// given
+	mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+	mockedApiKeyRepo.
+		On("GetApiKeys", 1).
+		Return([]repository.ApiKey{{Id: 4, AccountId: 1, Name: "ci", KeyHash: "hash", Hint: "fitter_abcd", Scopes: []string{security.ScopeRead}}}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithApiKeyRepository(mockedApiKeyRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me/api-keys", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"hint":"fitter_abcd"`)
+	assert.NotContains(t, responseWriter.Body.String(), "hash")
+}
+
+func TestRevokeApiKeyHandlerSucceeded(t *testing.T) {
+	// given
+	mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+	mockedApiKeyRepo.
+		On("DeleteApiKey", 1, 4).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithApiKeyRepository(mockedApiKeyRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/api-keys/4", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedApiKeyRepo.AssertCalled(t, "DeleteApiKey", 1, 4)
+}
+
+func TestRevokeApiKeyHandlerShouldReturnNotFound(t *testing.T) {
+	// given
+	mockedApiKeyRepo := new(mocks.ApiKeyRepository)
+	mockedApiKeyRepo.
+		On("DeleteApiKey", 1, 4).
+		Return(sql.ErrNoRows)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithApiKeyRepository(mockedApiKeyRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/api-keys/4", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+}
@@ -0,0 +1,77 @@
+package loginservice
+
+import (
+	_ "embed"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const attributeSchemaUrl = "attributes.json"
+
+var (
+	errInvalidAttributes = errors.New("invalid_attributes")
+
+	//go:embed attribute_schema.json
+	bundledAttributeSchema string
+)
+
+func CompileAttributeSchema(schema string) (*jsonschema.Schema, error) {
+	if strings.TrimSpace(schema) == "" {
+		schema = bundledAttributeSchema
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	if err := compiler.AddResource(attributeSchemaUrl, strings.NewReader(schema)); err != nil {
+		return nil, err
+	}
+
+	return compiler.Compile(attributeSchemaUrl)
+}
+
+func mergeAttributes(current map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range current {
+		merged[key] = value
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+func (service *LoginService) validateAttributes(attributes map[string]interface{}) error {
+	if service.attributeSchema == nil {
+		return errInvalidAttributes
+	}
+
+	return service.attributeSchema.Validate(attributes)
+}
+
+func (service *LoginService) claimAttributes(account repository.Account) map[string]interface{} {
+	var claims map[string]interface{}
+	for _, key := range service.config.Attributes.Claims {
+		value, ok := account.Attributes[key]
+		if !ok {
+			continue
+		}
+
+		if claims == nil {
+			claims = map[string]interface{}{}
+		}
+
+		claims[key] = value
+	}
+
+	return claims
+}
@@ -0,0 +1,113 @@
+package loginservice
+
+import (
+	"bytes"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCompileAttributeSchemaShouldUseBundledSchema(t *testing.T) {
+	schema, err := CompileAttributeSchema("")
+
+	assert.NoError(t, err)
+	assert.NoError(t, schema.Validate(map[string]interface{}{"locale": "de-DE"}))
+	assert.Error(t, schema.Validate(map[string]interface{}{"unknown": true}))
+}
+
+func TestCompileAttributeSchemaShouldRejectInvalidSchema(t *testing.T) {
+	_, err := CompileAttributeSchema(`{ "type": 42 }`)
+
+	assert.Error(t, err)
+}
+
+func TestMergeAttributesShouldRemoveNullValues(t *testing.T) {
+	current := map[string]interface{}{"locale": "de-DE", "avatarUrl": "https://fitter.test/a.png"}
+
+	merged := mergeAttributes(current, map[string]interface{}{"locale": "en-US", "avatarUrl": nil})
+
+	assert.Equal(t, map[string]interface{}{"locale": "en-US"}, merged)
+	assert.Equal(t, "de-DE", current["locale"])
+}
+
+func TestProfileUpdateHandlerShouldUpdateAttributes(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", Attributes: map[string]interface{}{"locale": "de-DE"}, Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountProfile", 1, "test@test.com", "", false, map[string]interface{}{"locale": "de-DE", "fitnessGoals": []interface{}{"strength"}}).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "attributes": { "fitnessGoals": ["strength"] } }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"fitnessGoals":["strength"]`)
+	mockedAccountRepo.AssertExpectations(t)
+}
+
+func TestProfileUpdateHandlerShouldRejectAttributesViolatingSchema(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:        security.JwtConfig{SignKey: "secret"},
+		Attributes: AttributesConfig{Schema: `{ "type": "object", "properties": { "height": { "type": "integer", "minimum": 50 } } }`},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "attributes": { "height": 10 } }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLoginHandlerShouldMapSelectedAttributesIntoClaims(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{
+			Id:         1,
+			Username:   "testuser",
+			Password:   hashedTestPassword(t),
+			Status:     repository.AccountStatusActive,
+			Attributes: map[string]interface{}{"locale": "de-DE", "avatarUrl": "https://fitter.test/a.png"},
+		}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt:        security.JwtConfig{SignKey: "secret"},
+		Attributes: AttributesConfig{Claims: []string{"locale", "timezone"}},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+	claims := tokenClaimsFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, map[string]interface{}{"locale": "de-DE"}, claims.Attributes)
+}
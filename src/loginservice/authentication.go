@@ -0,0 +1,170 @@
+package loginservice
+
+import (
+	"context"
+	"errors"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
+var (
+	errMissingBearerToken = errors.New("missing bearer token")
+	errInvalidApiKey      = errors.New("invalid api key")
+)
+
+func (service *LoginService) authenticated(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		claims, err := service.authenticateRequest(r)
+		if err != nil {
+			service.logger.Warnf("(%s) authentication failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusUnauthorized, "Authentication required.")
+			return
+		}
+
+		if scope := requiredScope(r.Method); !claims.HasScope(scope) {
+			service.logger.Warnf("(%s) api key %d without scope '%s' rejected", r.RemoteAddr, claims.ApiKeyId, scope)
+			sendSimpleResponse(w, http.StatusForbidden, "Insufficient scope.")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		handle(w, r.WithContext(ctx), p)
+	}
+}
+
+func (service *LoginService) requireRole(role string, handle httprouter.Handle) httprouter.Handle {
+	return service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		claims := claimsFromRequest(r)
+		if claims.Role != role {
+			service.logger.Warnf("(%s) user '%s' without role '%s' rejected", r.RemoteAddr, claims.Username, role)
+			sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+			return
+		}
+
+		if !claims.HasScope(security.ScopeAdmin) {
+			service.logger.Warnf("(%s) api key %d without scope '%s' rejected", r.RemoteAddr, claims.ApiKeyId, security.ScopeAdmin)
+			sendSimpleResponse(w, http.StatusForbidden, "Insufficient scope.")
+			return
+		}
+
+		handle(w, r, p)
+	})
+}
+
+func (service *LoginService) sessionAuthenticated(handle httprouter.Handle) httprouter.Handle {
+	return service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		claims := claimsFromRequest(r)
+		if claims.ApiKeyId != 0 {
+			service.logger.Warnf("(%s) api key %d of user '%s' rejected for session request", r.RemoteAddr, claims.ApiKeyId, claims.Username)
+			sendSimpleResponse(w, http.StatusForbidden, "API keys are not allowed for this request.")
+			return
+		}
+
+		handle(w, r, p)
+	})
+}
+
+func (service *LoginService) authenticateRequest(r *http.Request) (*security.JwtClaims, error) {
+	tokenString, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(tokenString, security.ApiKeyPrefix) {
+		return service.authenticateApiKey(r, tokenString)
+	}
+
+	claims, err := security.ParseToken(tokenString, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != "" {
+		return nil, security.ErrInvalidToken
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAccountStatus(account); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (service *LoginService) authenticateApiKey(r *http.Request, rawKey string) (*security.JwtClaims, error) {
+	if service.apiKeyRepo == nil {
+		return nil, errInvalidApiKey
+	}
+
+	key, err := service.apiKeyRepo.GetApiKeyByHash(security.HashApiKey(rawKey))
+	if err != nil {
+		return nil, errInvalidApiKey
+	}
+
+	account, err := service.accountRepo.GetAccountById(key.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAccountStatus(account); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := service.apiKeyRepo.UpdateApiKeyLastUsed(key.Id, now); err != nil {
+		service.logger.Warnf("(%s) update last usage of api key %d failed: %s", r.RemoteAddr, key.Id, err.Error())
+	}
+
+	methods := []string{security.AuthMethodApiKey}
+	claims := &security.JwtClaims{
+		UserId:      account.Id,
+		Username:    account.Username,
+		AuthMethods: methods,
+		AuthContext: security.AuthContextForMethods(methods),
+		AuthTime:    now.Unix(),
+		Role:        account.Role,
+		Attributes:  service.claimAttributes(account),
+		Scopes:      key.Scopes,
+		ApiKeyId:    key.Id,
+	}
+	if key.ExpirationDate != nil {
+		claims.ExpiresAt = key.ExpirationDate.Unix()
+	}
+
+	return claims, nil
+}
+
+func requiredScope(method string) string {
+	if method == http.MethodGet || method == http.MethodHead {
+		return security.ScopeRead
+	}
+
+	return security.ScopeWrite
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", errMissingBearerToken
+	}
+
+	return strings.TrimPrefix(header, "Bearer "), nil
+}
+
+func claimsFromRequest(r *http.Request) *security.JwtClaims {
+	claims, _ := r.Context().Value(claimsContextKey).(*security.JwtClaims)
+	return claims
+}
@@ -0,0 +1,202 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func bearerHeader(t *testing.T, id int, username string, key string) string {
+	token, err := security.GenerateToken(id, username, jwt.SigningMethodHS256, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func roleBearerHeader(t *testing.T, id int, username string, role string, key string) string {
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:   id,
+		Username: username,
+		Role:     role,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func sessionAccountRepository(id int) *mocks.AccountRepository {
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", id).
+		Return(repository.Account{Id: id, Status: repository.AccountStatusActive}, nil)
+
+	return mockedAccountRepo
+}
+
+func TestAuthenticatedShouldPassClaimsToHandler(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger)
+
+	var claims *security.JwtClaims
+	handle := service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		claims = claimsFromRequest(r)
+	})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	handle(httptest.NewRecorder(), request, nil)
+
+	// then
+	assert.NotNil(t, claims)
+	assert.Equal(t, 1, claims.UserId)
+	assert.Equal(t, "testuser", claims.Username)
+}
+
+func TestAuthenticatedShouldRejectMissingToken(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	called := false
+	handle := service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, request, nil)
+
+	// then
+	assert.False(t, called)
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) authentication failed: %s", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticatedShouldRejectPurposeToken(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	called := false
+	handle := service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+mfaToken)
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, request, nil)
+
+	// then
+	assert.False(t, called)
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+}
+
+func TestAuthenticatedShouldRejectTokenOfInactiveAccount(t *testing.T) {
+	for _, status := range []string{repository.AccountStatusSuspended, repository.AccountStatusDeleted} {
+		t.Run(status, func(t *testing.T) {
+			// given
+			mockedAccountRepo := new(mocks.AccountRepository)
+			mockedAccountRepo.
+				On("GetAccountById", 1).
+				Return(repository.Account{Id: 1, Username: "testuser", Status: status}, nil)
+			mockedLogger := new(mocks.Logger)
+			mockedLogger.
+				On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+			service := NewService(LoginServiceConfig{
+				Jwt: security.JwtConfig{SignKey: "secret"},
+			}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+			called := false
+			handle := service.authenticated(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+				called = true
+			})
+
+			// when
+			request, _ := http.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+			responseWriter := httptest.NewRecorder()
+			handle(responseWriter, request, nil)
+
+			// then
+			assert.False(t, called)
+			assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+		})
+	}
+}
+
+func TestRequireRoleShouldRejectMissingRole(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger)
+
+	called := false
+	handle := service.requireRole(security.RoleAdmin, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "testuser", security.RoleUser, "secret"))
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, request, nil)
+
+	// then
+	assert.False(t, called)
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestRequireRoleShouldPassMatchingRole(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger))
+
+	called := false
+	handle := service.requireRole(security.RoleAdmin, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	handle(httptest.NewRecorder(), request, nil)
+
+	// then
+	assert.True(t, called)
+}
@@ -0,0 +1,332 @@
+package loginservice
+
+import (
+	"errors"
+	"flhansen/fitter-login-service/src/directory"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	localAuthenticatorName = "local"
+	ldapAuthenticatorName  = "ldap"
+	httpAuthenticatorName  = "http"
+)
+
+var (
+	defaultAuthenticatorChain = []string{localAuthenticatorName, ldapAuthenticatorName, httpAuthenticatorName}
+
+	errCredentialsNotVerified  = errors.New("credentials not verified by any authenticator")
+	errCredentialsRejected     = errors.New("credentials rejected by authenticator")
+	errExternalAccountMismatch = errors.New("external identity is linked to another account")
+	errExternalEmailMissing    = errors.New("external account has no email address")
+)
+
+type AuthenticationDecision int
+
+const (
+	AuthenticationPassed AuthenticationDecision = iota
+	AuthenticationAccepted
+	AuthenticationRejected
+)
+
+type AuthenticationRequest struct {
+	Username string
+	Password string
+	TenantId int
+	Account  *repository.Account
+}
+
+type AuthenticationResult struct {
+	Decision      AuthenticationDecision
+	Authenticator string
+	Account       repository.Account
+	FailureReason string
+	Err           error
+}
+
+type Authenticator interface {
+	Name() string
+	Authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult
+}
+
+type externalUser struct {
+	Id       string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+type localAuthenticator struct {
+}
+
+func WithAuthenticator(authenticator Authenticator) ServiceOption {
+	return func(service *LoginService) {
+		service.authenticators[authenticator.Name()] = authenticator
+	}
+}
+
+func PassAuthentication() AuthenticationResult {
+	return AuthenticationResult{Decision: AuthenticationPassed}
+}
+
+func AcceptAuthentication(account repository.Account) AuthenticationResult {
+	return AuthenticationResult{Decision: AuthenticationAccepted, Account: account}
+}
+
+func RejectAuthentication(failureReason string, err error) AuthenticationResult {
+	return AuthenticationResult{Decision: AuthenticationRejected, FailureReason: failureReason, Err: err}
+}
+
+func (authenticator localAuthenticator) Name() string {
+	return localAuthenticatorName
+}
+
+func (authenticator localAuthenticator) Authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult {
+	if request.Account == nil || request.Account.Password == "" {
+		return PassAuthentication()
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(request.Account.Password), []byte(request.Password)); err != nil {
+		return RejectAuthentication(loginFailureWrongPassword, err)
+	}
+
+	return AcceptAuthentication(*request.Account)
+}
+
+func (service *LoginService) newAuthenticatorChain() []Authenticator {
+	names := service.config.Authentication.Chain
+	if len(names) == 0 {
+		names = defaultAuthenticatorChain
+	}
+
+	chain := []Authenticator{}
+	for _, name := range names {
+		authenticator, ok := service.authenticators[name]
+		if !ok {
+			service.logger.Errorf("unknown authenticator '%s' in authentication chain", name)
+			continue
+		}
+
+		chain = append(chain, authenticator)
+	}
+
+	return chain
+}
+
+func (service *LoginService) authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult {
+	for _, authenticator := range service.authenticatorChain {
+		result := authenticator.Authenticate(r, request)
+		if result.Decision == AuthenticationPassed {
+			continue
+		}
+
+		result.Authenticator = authenticator.Name()
+		if result.Decision == AuthenticationRejected && result.Err == nil {
+			result.Err = errCredentialsRejected
+		}
+		if result.Decision == AuthenticationRejected && result.FailureReason == "" {
+			result.FailureReason = loginFailureWrongPassword
+		}
+		if result.Decision == AuthenticationRejected && result.Account.Id == 0 && request.Account != nil {
+			result.Account = *request.Account
+		}
+
+		return result
+	}
+
+	if request.Account != nil {
+		return RejectAuthentication(loginFailureWrongPassword, errCredentialsNotVerified)
+	}
+
+	return PassAuthentication()
+}
+
+func (service *LoginService) verifyPassword(r *http.Request, account *repository.Account, password string) error {
+	result := service.authenticate(r, AuthenticationRequest{
+		Username: account.Username,
+		Password: password,
+		TenantId: account.TenantId,
+		Account:  account,
+	})
+
+	if result.Decision != AuthenticationAccepted {
+		return result.Err
+	}
+
+	*account = result.Account
+	return nil
+}
+
+func externalRole(groups []string, adminGroups []string) string {
+	for _, group := range groups {
+		for _, adminGroup := range adminGroups {
+			if directory.GroupMatches(group, adminGroup) {
+				return security.RoleAdmin
+			}
+		}
+	}
+
+	return security.RoleUser
+}
+
+func (service *LoginService) syncExternalRole(r *http.Request, account *repository.Account, groups []string, adminGroups []string) {
+	if len(adminGroups) == 0 {
+		return
+	}
+
+	role := externalRole(groups, adminGroups)
+	if account.Role == role {
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountRole(account.Id, role); err != nil {
+		service.logger.Errorf("(%s) update role of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		return
+	}
+
+	service.logger.Infof("(%s) role of user '%s' changed to '%s' by external groups", r.RemoteAddr, account.Username, role)
+	account.Role = role
+}
+
+func (service *LoginService) externalAccount(r *http.Request, provider string, request AuthenticationRequest, user externalUser, adminGroups []string) (repository.Account, error) {
+	linked, err := service.linkedIdentityRepo.GetLinkedIdentity(provider, user.Id)
+	if request.Account != nil {
+		if err != nil || linked.AccountId != request.Account.Id {
+			return repository.Account{}, errExternalAccountMismatch
+		}
+
+		account := *request.Account
+		service.syncExternalRole(r, &account, user.Groups, adminGroups)
+		return account, nil
+	}
+
+	if err != nil {
+		return service.createExternalAccount(r, provider, request.Username, user, adminGroups)
+	}
+
+	account, err := service.accountRepo.GetAccountById(linked.AccountId)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	service.syncExternalRole(r, &account, user.Groups, adminGroups)
+	return account, nil
+}
+
+func (service *LoginService) linkedAccount(provider string, subject string) (repository.Account, bool) {
+	linked, err := service.linkedIdentityRepo.GetLinkedIdentity(provider, subject)
+	if err != nil {
+		return repository.Account{}, false
+	}
+
+	account, err := service.accountRepo.GetAccountById(linked.AccountId)
+	return account, err == nil
+}
+
+func (service *LoginService) createExternalAccount(r *http.Request, provider string, username string, user externalUser, adminGroups []string) (repository.Account, error) {
+	if user.Username != "" {
+		username = user.Username
+	}
+
+	username, err := NormalizeUsername(username)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	skeleton := UsernameSkeleton(username)
+	if _, err := service.accountRepo.GetAccountByUsernameSkeleton(0, skeleton); err == nil {
+		return repository.Account{}, errUsernameTaken
+	}
+
+	if user.Email == "" {
+		return repository.Account{}, errExternalEmailMissing
+	}
+
+	email, err := NormalizeEmail(user.Email)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	if _, err := service.accountRepo.GetAccountByEmail(0, email); err == nil {
+		return repository.Account{}, errFederatedEmailTaken
+	}
+
+	now := time.Now()
+	account := repository.Account{
+		Username:         username,
+		UsernameSkeleton: skeleton,
+		Email:            email,
+		CreationDate:     now,
+		Status:           repository.AccountStatusActive,
+		Role:             externalRole(user.Groups, adminGroups),
+	}
+
+	account.Id, err = service.accountRepo.CreateAccount(account)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	if err := service.accountRepo.UpdateAccountEmailVerified(account.Id, now); err != nil {
+		service.logger.Errorf("(%s) mark email of user '%s' as verified failed: %s", r.RemoteAddr, account.Username, err.Error())
+	} else {
+		account.EmailVerified = true
+		account.EmailVerifiedDate = &now
+	}
+
+	if account.Role != security.RoleUser {
+		if err := service.accountRepo.UpdateAccountRole(account.Id, account.Role); err != nil {
+			service.logger.Errorf("(%s) update role of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+			account.Role = security.RoleUser
+		}
+	}
+
+	if _, err := service.linkedIdentityRepo.CreateLinkedIdentity(repository.LinkedIdentity{
+		AccountId:    account.Id,
+		Provider:     provider,
+		Subject:      user.Id,
+		Email:        email,
+		CreationDate: now,
+	}); err != nil {
+		if err := service.accountRepo.DeleteAccountById(account.Id); err != nil {
+			service.logger.Errorf("(%s) delete user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		}
+
+		return repository.Account{}, err
+	}
+
+	service.logger.Infof("(%s) user '%s' provisioned by authenticator '%s'", r.RemoteAddr, account.Username, provider)
+	return account, nil
+}
+
+func externalLoginFailureReason(err error) string {
+	if errors.Is(err, directory.ErrInvalidCredentials) || errors.Is(err, errExternalInvalidCredentials) || err == errExternalAccountMismatch {
+		return loginFailureWrongPassword
+	}
+
+	return loginFailureAuthenticator
+}
+
+func sendAuthenticationResponse(w http.ResponseWriter, result AuthenticationResult) {
+	if result.FailureReason != loginFailureAuthenticator {
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	switch {
+	case errors.Is(result.Err, directory.ErrAmbiguousUser):
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+	case result.Err == errFederatedEmailTaken:
+		sendSimpleResponse(w, http.StatusConflict, "An account with this email address already exists.")
+	case result.Err == errUsernameTaken, result.Err == errInvalidUsername:
+		sendSimpleResponse(w, http.StatusConflict, "Username is not available.")
+	case result.Err == errExternalEmailMissing:
+		sendSimpleResponse(w, http.StatusForbidden, "Account has no email address.")
+	default:
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not sign in.")
+	}
+}
@@ -0,0 +1,212 @@
+package loginservice
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type stubAuthenticator struct {
+	name     string
+	result   AuthenticationResult
+	requests []AuthenticationRequest
+}
+
+func (authenticator *stubAuthenticator) Name() string {
+	return authenticator.name
+}
+
+func (authenticator *stubAuthenticator) Authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult {
+	authenticator.requests = append(authenticator.requests, request)
+	return authenticator.result
+}
+
+func chainServiceConfig(chain ...string) LoginServiceConfig {
+	return LoginServiceConfig{
+		Jwt:            security.JwtConfig{SignKey: "secret"},
+		Authentication: AuthenticationConfig{Chain: chain},
+	}
+}
+
+func unknownAccountRepository(username string) *mocks.AccountRepository {
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, username).
+		Return(repository.Account{}, sql.ErrNoRows)
+
+	return mockedAccountRepo
+}
+
+func TestLoginHandlerShouldIssueTokenNamingAcceptingAuthenticator(t *testing.T) {
+	// given
+	corp := &stubAuthenticator{name: "corp", result: AcceptAuthentication(repository.Account{Id: 4, Username: "alice", Status: repository.AccountStatusActive})}
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(chainServiceConfig("corp"), unknownAccountRepository("alice"), new(mocks.HashEngine), mockedLogger,
+		WithAuthenticator(corp))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 4, claims.UserId)
+	assert.Equal(t, "corp", claims.Authenticator)
+	assert.Equal(t, []AuthenticationRequest{{Username: "alice", Password: "secret"}}, corp.requests)
+	mockedLogger.AssertCalled(t, "Infof", "(%s) user '%s' authenticated by '%s'", mock.Anything, "alice", "corp")
+}
+
+func TestLoginHandlerShouldTryNextAuthenticatorOnPass(t *testing.T) {
+	// given
+	first := &stubAuthenticator{name: "first", result: PassAuthentication()}
+	second := &stubAuthenticator{name: "second", result: AcceptAuthentication(repository.Account{Id: 4, Username: "alice", Status: repository.AccountStatusActive})}
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(chainServiceConfig("first", "second"), unknownAccountRepository("alice"), new(mocks.HashEngine), mockedLogger,
+		WithAuthenticator(first),
+		WithAuthenticator(second))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "second", tokenClaimsFromResponse(t, responseWriter).Authenticator)
+	assert.Len(t, first.requests, 1)
+}
+
+func TestLoginHandlerShouldStopChainOnRejection(t *testing.T) {
+	// given
+	first := &stubAuthenticator{name: "first", result: RejectAuthentication("", nil)}
+	second := &stubAuthenticator{name: "second", result: AcceptAuthentication(repository.Account{Id: 4, Username: "alice", Status: repository.AccountStatusActive})}
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(chainServiceConfig("first", "second"), unknownAccountRepository("alice"), new(mocks.HashEngine), mockedLogger,
+		WithAuthenticator(first),
+		WithAuthenticator(second))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	assert.Empty(t, second.requests)
+	assert.Equal(t, 1, service.unknownLogins.entries[loginAttemptKey{username: "alice"}].attempts)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) login of user '%s' rejected by authenticator '%s': %s", mock.Anything, "alice", "first", errCredentialsRejected.Error())
+}
+
+func TestLoginHandlerShouldTreatUnknownUserWhenAllAuthenticatorsPass(t *testing.T) {
+	// given
+	first := &stubAuthenticator{name: "first", result: PassAuthentication()}
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(chainServiceConfig("first"), unknownAccountRepository("alice"), new(mocks.HashEngine), mockedLogger,
+		WithAuthenticator(first))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) login of user '%s' failed", mock.Anything, "alice")
+}
+
+func TestLoginHandlerShouldPassKnownAccountToAuthenticators(t *testing.T) {
+	// given
+	account := repository.Account{Id: 4, Username: "alice", Status: repository.AccountStatusActive}
+	corp := &stubAuthenticator{name: "corp", result: AcceptAuthentication(account)}
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(account, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(chainServiceConfig("local", "corp"), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithAuthenticator(corp))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Len(t, corp.requests, 1)
+	assert.Equal(t, &account, corp.requests[0].Account)
+}
+
+func TestLoginHandlerShouldNameLocalAuthenticatorInToken(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("secret"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{Id: 4, Username: "alice", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
+	service := NewService(chainServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, localAuthenticatorName, tokenClaimsFromResponse(t, responseWriter).Authenticator)
+}
+
+func TestNewServiceShouldSkipUnknownAuthenticators(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything)
+
+	// when
+	service := NewService(chainServiceConfig("local", "radius"), new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// then
+	assert.Len(t, service.authenticatorChain, 1)
+	mockedLogger.AssertCalled(t, "Errorf", "unknown authenticator '%s' in authentication chain", "radius")
+}
+
+func TestVerifyPasswordShouldUseAuthenticatorChain(t *testing.T) {
+	// given
+	account := repository.Account{Id: 4, Username: "alice", Role: security.RoleUser}
+	corp := &stubAuthenticator{name: "corp", result: AcceptAuthentication(repository.Account{Id: 4, Username: "alice", Role: security.RoleAdmin})}
+	service := NewService(chainServiceConfig("local", "corp"), new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithAuthenticator(corp))
+
+	// when
+	err := service.verifyPassword(httptest.NewRequest(http.MethodPost, "/api/auth/step-up", nil), &account, "secret")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, security.RoleAdmin, account.Role)
+}
+
+func TestVerifyPasswordShouldFailWhenNoAuthenticatorAccepts(t *testing.T) {
+	// given
+	account := repository.Account{Id: 4, Username: "alice"}
+	service := NewService(chainServiceConfig(), new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	err := service.verifyPassword(httptest.NewRequest(http.MethodPost, "/api/auth/step-up", nil), &account, "secret")
+
+	// then
+	assert.Equal(t, errCredentialsNotVerified, err)
+}
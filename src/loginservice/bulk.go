@@ -0,0 +1,606 @@
+package loginservice
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	BulkFormatCsv    = "csv"
+	BulkFormatNdjson = "ndjson"
+
+	defaultImportBatchSize = 100
+	exportPageSize         = 100
+	maxNdjsonLineLength    = 1024 * 1024
+)
+
+var (
+	bulkCsvColumns = []string{"username", "email", "password", "passwordHash", "role", "status", "displayName",
+		"phoneNumber", "emailVerified", "attributes"}
+
+	errDuplicateUsername   = errors.New("duplicate username in import")
+	errDuplicateEmail      = errors.New("duplicate email in import")
+	errUsernameTaken       = errors.New("username already exists")
+	errEmailTaken          = errors.New("email already exists")
+	errPasswordMissing     = errors.New("either password or passwordHash is required")
+	errPasswordAmbiguous   = errors.New("only one of password or passwordHash may be set")
+	errInvalidPasswordHash = errors.New("invalid password hash")
+	errInvalidRole         = errors.New("invalid role")
+	errInvalidStatus       = errors.New("invalid status")
+	errInvalidDisplayName  = errors.New("invalid display name")
+	errInvalidPhoneNumber  = errors.New("invalid phone number")
+)
+
+type BulkAccount struct {
+	Username      string                 `json:"username"`
+	Email         string                 `json:"email"`
+	Password      string                 `json:"password,omitempty"`
+	PasswordHash  string                 `json:"passwordHash,omitempty"`
+	Role          string                 `json:"role,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	DisplayName   string                 `json:"displayName,omitempty"`
+	PhoneNumber   string                 `json:"phoneNumber,omitempty"`
+	EmailVerified bool                   `json:"emailVerified,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type ImportOptions struct {
+	Format       string
+	Organization string
+	BatchSize    int
+	DryRun       bool
+}
+
+type ImportRowError struct {
+	Row      int    `json:"row"`
+	Username string `json:"username,omitempty"`
+	Error    string `json:"error"`
+}
+
+type ImportSummary struct {
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+type ExportOptions struct {
+	Format                string
+	Organization          string
+	IncludePasswordHashes bool
+}
+
+type bulkRow struct {
+	row     int
+	account BulkAccount
+	err     error
+}
+
+type pendingImport struct {
+	row     int
+	account repository.Account
+}
+
+func IsBulkFormat(format string) bool {
+	return format == BulkFormatCsv || format == BulkFormatNdjson
+}
+
+func readBulkAccounts(r io.Reader, format string) ([]bulkRow, error) {
+	switch format {
+	case BulkFormatCsv:
+		return readCsvAccounts(r)
+	case BulkFormatNdjson:
+		return readNdjsonAccounts(r)
+	default:
+		return nil, fmt.Errorf("unknown bulk format '%s'", format)
+	}
+}
+
+func readCsvAccounts(r io.Reader) ([]bulkRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !isBulkCsvColumn(name) {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+
+		columns[name] = i
+	}
+
+	rows := []bulkRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, bulkRow{row: line, err: fmt.Errorf("expected %d fields but got %d", len(header), len(record))})
+			continue
+		}
+
+		account, err := bulkAccountFromCsv(record, columns)
+		rows = append(rows, bulkRow{row: line, account: account, err: err})
+	}
+
+	return rows, nil
+}
+
+func isBulkCsvColumn(name string) bool {
+	for _, column := range bulkCsvColumns {
+		if column == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bulkAccountFromCsv(record []string, columns map[string]int) (BulkAccount, error) {
+	value := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	account := BulkAccount{
+		Username:     value("username"),
+		Email:        value("email"),
+		Password:     value("password"),
+		PasswordHash: value("passwordHash"),
+		Role:         value("role"),
+		Status:       value("status"),
+		DisplayName:  value("displayName"),
+		PhoneNumber:  value("phoneNumber"),
+	}
+
+	if emailVerified := value("emailVerified"); emailVerified != "" {
+		verified, err := strconv.ParseBool(emailVerified)
+		if err != nil {
+			return account, fmt.Errorf("invalid emailVerified value '%s'", emailVerified)
+		}
+
+		account.EmailVerified = verified
+	}
+
+	if attributes := value("attributes"); attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &account.Attributes); err != nil {
+			return account, fmt.Errorf("invalid attributes: %w", err)
+		}
+	}
+
+	return account, nil
+}
+
+func readNdjsonAccounts(r io.Reader) ([]bulkRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNdjsonLineLength)
+
+	rows := []bulkRow{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var account BulkAccount
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&account)
+		rows = append(rows, bulkRow{row: line, account: account, err: err})
+	}
+
+	return rows, scanner.Err()
+}
+
+func (service *LoginService) prepareImportAccount(account BulkAccount, tenantId int, now time.Time) (repository.Account, error) {
+	username, err := NormalizeUsername(account.Username)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	email, err := NormalizeEmail(account.Email)
+	if err != nil || !validateEmail(email) {
+		return repository.Account{}, errInvalidEmail
+	}
+
+	role := account.Role
+	if role == "" {
+		role = security.RoleUser
+	}
+
+	if role != security.RoleUser && role != security.RoleAdmin {
+		return repository.Account{}, errInvalidRole
+	}
+
+	status := account.Status
+	if status == "" {
+		status = repository.AccountStatusActive
+	}
+
+	if !isAccountStatus(status) || status == repository.AccountStatusDeleted {
+		return repository.Account{}, errInvalidStatus
+	}
+
+	displayName := strings.TrimSpace(account.DisplayName)
+	if !validateDisplayName(displayName) {
+		return repository.Account{}, errInvalidDisplayName
+	}
+
+	if account.PhoneNumber != "" && !phoneNumberPattern.MatchString(account.PhoneNumber) {
+		return repository.Account{}, errInvalidPhoneNumber
+	}
+
+	if account.Attributes != nil {
+		if err := service.validateAttributes(account.Attributes); err != nil {
+			return repository.Account{}, errInvalidAttributes
+		}
+	}
+
+	var passwordHash string
+	switch {
+	case account.Password != "" && account.PasswordHash != "":
+		return repository.Account{}, errPasswordAmbiguous
+	case account.Password != "":
+		hash, err := service.hashEngine.HashPassword([]byte(account.Password))
+		if err != nil {
+			return repository.Account{}, err
+		}
+
+		passwordHash = string(hash)
+	case account.PasswordHash != "":
+		if _, err := bcrypt.Cost([]byte(account.PasswordHash)); err != nil {
+			return repository.Account{}, errInvalidPasswordHash
+		}
+
+		passwordHash = account.PasswordHash
+	default:
+		return repository.Account{}, errPasswordMissing
+	}
+
+	var emailVerifiedDate *time.Time
+	if account.EmailVerified {
+		emailVerifiedDate = &now
+	}
+
+	return repository.Account{
+		TenantId:          tenantId,
+		Username:          username,
+		UsernameSkeleton:  UsernameSkeleton(username),
+		Password:          passwordHash,
+		Email:             email,
+		EmailVerified:     account.EmailVerified,
+		EmailVerifiedDate: emailVerifiedDate,
+		DisplayName:       displayName,
+		PhoneNumber:       account.PhoneNumber,
+		Role:              role,
+		Status:            status,
+		Attributes:        account.Attributes,
+		CreationDate:      now,
+	}, nil
+}
+
+func (service *LoginService) checkImportConflicts(account repository.Account) error {
+	if _, err := service.accountRepo.GetAccountByUsername(account.TenantId, account.Username); err == nil {
+		return errUsernameTaken
+	}
+
+	if _, err := service.accountRepo.GetAccountByUsernameSkeleton(account.TenantId, account.UsernameSkeleton); err == nil {
+		return errUsernameConfusable
+	}
+
+	if _, err := service.accountRepo.GetAccountByEmail(account.TenantId, account.Email); err == nil {
+		return errEmailTaken
+	}
+
+	return nil
+}
+
+func (summary *ImportSummary) fail(row int, username string, err error) {
+	summary.Failed++
+	summary.Errors = append(summary.Errors, ImportRowError{Row: row, Username: username, Error: err.Error()})
+}
+
+func (service *LoginService) importBatch(batch []pendingImport, organization *repository.Organization, options ImportOptions, summary *ImportSummary) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if options.DryRun {
+		summary.Imported += len(batch)
+		return nil
+	}
+
+	accounts := make([]repository.Account, len(batch))
+	for i, pending := range batch {
+		accounts[i] = pending.account
+	}
+
+	organizationId := 0
+	if organization != nil {
+		organizationId = organization.Id
+	}
+
+	results, err := service.accountRepo.CreateAccounts(accounts, organizationId)
+	if err != nil {
+		for _, pending := range batch {
+			summary.fail(pending.row, pending.account.Username, err)
+		}
+
+		return err
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			summary.fail(batch[i].row, batch[i].account.Username, result.Err)
+			continue
+		}
+
+		summary.Imported++
+	}
+
+	return nil
+}
+
+func (service *LoginService) ImportAccounts(r io.Reader, options ImportOptions) (ImportSummary, error) {
+	summary := ImportSummary{Errors: []ImportRowError{}}
+
+	rows, err := readBulkAccounts(r, options.Format)
+	if err != nil {
+		return summary, err
+	}
+
+	organization, err := service.organizationBySlug(options.Organization)
+	if err != nil {
+		return summary, err
+	}
+
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	tenantId := tenantIdOf(organization)
+	seenUsernames := map[string]bool{}
+	seenSkeletons := map[string]bool{}
+	seenEmails := map[string]bool{}
+	batch := []pendingImport{}
+	now := time.Now()
+
+	summary.Total = len(rows)
+	for _, row := range rows {
+		if row.err != nil {
+			summary.fail(row.row, row.account.Username, row.err)
+			continue
+		}
+
+		account, err := service.prepareImportAccount(row.account, tenantId, now)
+		if err != nil {
+			summary.fail(row.row, row.account.Username, err)
+			continue
+		}
+
+		if seenUsernames[account.Username] || seenSkeletons[account.UsernameSkeleton] {
+			summary.fail(row.row, account.Username, errDuplicateUsername)
+			continue
+		}
+
+		if seenEmails[account.Email] {
+			summary.fail(row.row, account.Username, errDuplicateEmail)
+			continue
+		}
+
+		seenUsernames[account.Username] = true
+		seenSkeletons[account.UsernameSkeleton] = true
+		seenEmails[account.Email] = true
+
+		if err := service.checkImportConflicts(account); err != nil {
+			summary.fail(row.row, account.Username, err)
+			continue
+		}
+
+		batch = append(batch, pendingImport{row: row.row, account: account})
+		if len(batch) >= batchSize {
+			if err := service.importBatch(batch, organization, options, &summary); err != nil {
+				return summary, err
+			}
+
+			batch = []pendingImport{}
+		}
+	}
+
+	if err := service.importBatch(batch, organization, options, &summary); err != nil {
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+func newBulkAccount(account repository.Account, includePasswordHash bool) BulkAccount {
+	bulkAccount := BulkAccount{
+		Username:      account.Username,
+		Email:         account.Email,
+		Role:          account.Role,
+		Status:        account.Status,
+		DisplayName:   account.DisplayName,
+		PhoneNumber:   account.PhoneNumber,
+		EmailVerified: account.EmailVerified,
+	}
+
+	if len(account.Attributes) > 0 {
+		bulkAccount.Attributes = account.Attributes
+	}
+
+	if includePasswordHash {
+		bulkAccount.PasswordHash = account.Password
+	}
+
+	return bulkAccount
+}
+
+type bulkWriter struct {
+	format        string
+	csv           *csv.Writer
+	json          *json.Encoder
+	headerWritten bool
+}
+
+func newBulkWriter(w io.Writer, format string) (*bulkWriter, error) {
+	switch format {
+	case BulkFormatCsv:
+		return &bulkWriter{format: format, csv: csv.NewWriter(w)}, nil
+	case BulkFormatNdjson:
+		return &bulkWriter{format: format, json: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown bulk format '%s'", format)
+	}
+}
+
+func (writer *bulkWriter) Write(account BulkAccount) error {
+	if writer.format == BulkFormatNdjson {
+		return writer.json.Encode(account)
+	}
+
+	if !writer.headerWritten {
+		if err := writer.csv.Write(bulkCsvColumns); err != nil {
+			return err
+		}
+
+		writer.headerWritten = true
+	}
+
+	attributes := ""
+	if account.Attributes != nil {
+		encoded, err := json.Marshal(account.Attributes)
+		if err != nil {
+			return err
+		}
+
+		attributes = string(encoded)
+	}
+
+	return writer.csv.Write([]string{
+		account.Username,
+		account.Email,
+		account.Password,
+		account.PasswordHash,
+		account.Role,
+		account.Status,
+		account.DisplayName,
+		account.PhoneNumber,
+		strconv.FormatBool(account.EmailVerified),
+		attributes,
+	})
+}
+
+func (writer *bulkWriter) Flush() error {
+	if writer.format != BulkFormatCsv {
+		return nil
+	}
+
+	if !writer.headerWritten {
+		if err := writer.csv.Write(bulkCsvColumns); err != nil {
+			return err
+		}
+	}
+
+	writer.csv.Flush()
+	return writer.csv.Error()
+}
+
+func (service *LoginService) exportableAccounts(options ExportOptions, visit func(account repository.Account) error) error {
+	if options.Organization != "" {
+		organization, err := service.organizationBySlug(options.Organization)
+		if err != nil {
+			return err
+		}
+
+		memberships, err := service.orgRepo.GetMembershipsByOrganization(organization.Id)
+		if err != nil {
+			return err
+		}
+
+		for _, membership := range memberships {
+			account, err := service.accountRepo.GetAccountById(membership.AccountId)
+			if err != nil {
+				return err
+			}
+
+			if err := visit(account); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	filter := repository.AccountFilter{Limit: exportPageSize}
+	for {
+		accounts, err := service.accountRepo.GetAccounts(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, account := range accounts {
+			if err := visit(account); err != nil {
+				return err
+			}
+		}
+
+		if len(accounts) < filter.Limit {
+			return nil
+		}
+
+		filter.Offset += filter.Limit
+	}
+}
+
+func (service *LoginService) ExportAccounts(w io.Writer, options ExportOptions) (int, error) {
+	writer, err := newBulkWriter(w, options.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = service.exportableAccounts(options, func(account repository.Account) error {
+		if account.Status == repository.AccountStatusDeleted {
+			return nil
+		}
+
+		count++
+		return writer.Write(newBulkAccount(account, options.IncludePasswordHashes))
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.Flush()
+}
@@ -0,0 +1,311 @@
+package loginservice
+
+import (
+	"bytes"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func importAccountRepository() *mocks.AccountRepository {
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByEmail", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found"))
+
+	return mockedAccountRepo
+}
+
+func TestReadCsvAccountsShouldMapColumnsByHeader(t *testing.T) {
+	input := "email,username,emailVerified,attributes\n" +
+		"alice@test.com,alice,true,\"{\"\"locale\"\":\"\"de-DE\"\"}\"\n" +
+		"bob@test.com,bob,maybe,\n" +
+		"carol@test.com\n"
+
+	rows, err := readCsvAccounts(strings.NewReader(input))
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.NoError(t, rows[0].err)
+	assert.Equal(t, 2, rows[0].row)
+	assert.Equal(t, BulkAccount{
+		Username:      "alice",
+		Email:         "alice@test.com",
+		EmailVerified: true,
+		Attributes:    map[string]interface{}{"locale": "de-DE"},
+	}, rows[0].account)
+	assert.Error(t, rows[1].err)
+	assert.Equal(t, 4, rows[2].row)
+	assert.Error(t, rows[2].err)
+}
+
+func TestReadCsvAccountsShouldRejectUnknownColumns(t *testing.T) {
+	_, err := readCsvAccounts(strings.NewReader("username,shoeSize\nalice,42\n"))
+
+	assert.Error(t, err)
+}
+
+func TestReadNdjsonAccountsShouldReportInvalidLines(t *testing.T) {
+	input := `{"username":"alice","email":"alice@test.com"}` + "\n\n" +
+		`{"username":"bob","shoeSize":42}` + "\n" +
+		`{"username":` + "\n"
+
+	rows, err := readNdjsonAccounts(strings.NewReader(input))
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.NoError(t, rows[0].err)
+	assert.Equal(t, "alice", rows[0].account.Username)
+	assert.Equal(t, 3, rows[1].row)
+	assert.Error(t, rows[1].err)
+	assert.Equal(t, 4, rows[2].row)
+	assert.Error(t, rows[2].err)
+}
+
+func TestImportAccountsShouldValidateAndDeduplicateRows(t *testing.T) {
+	// given
+	mockedAccountRepo := importAccountRepository()
+	mockedAccountRepo.
+		On("CreateAccounts", mock.MatchedBy(func(accounts []repository.Account) bool {
+			return len(accounts) == 1 && accounts[0].Username == "alice" && accounts[0].Password == "hashed"
+		}), 0).
+		Return([]repository.AccountBatchResult{{Id: 1}}, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", []byte("secret")).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	input := "username,email,password,passwordHash,role\n" +
+		"Alice,alice@test.com,secret,,\n" +
+		"ALICE,other@test.com,secret,,\n" +
+		"bob,ALICE@test.com,secret,,\n" +
+		"carol,carol@test.com,,,\n" +
+		"dave,dave@test.com,,not-a-hash,\n" +
+		"erin,erin@test.com,secret,,superuser\n"
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader(input), ImportOptions{Format: BulkFormatCsv})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 6, summary.Total)
+	assert.Equal(t, 1, summary.Imported)
+	assert.Equal(t, 5, summary.Failed)
+	assert.Equal(t, []ImportRowError{
+		{Row: 3, Username: "alice", Error: errDuplicateUsername.Error()},
+		{Row: 4, Username: "bob", Error: errDuplicateEmail.Error()},
+		{Row: 5, Username: "carol", Error: errPasswordMissing.Error()},
+		{Row: 6, Username: "dave", Error: errInvalidPasswordHash.Error()},
+		{Row: 7, Username: "erin", Error: errInvalidRole.Error()},
+	}, summary.Errors)
+}
+
+func TestImportAccountsShouldKeepPreHashedPasswords(t *testing.T) {
+	// given
+	passwordHash := hashedTestPassword(t)
+	mockedAccountRepo := importAccountRepository()
+	mockedAccountRepo.
+		On("CreateAccounts", mock.MatchedBy(func(accounts []repository.Account) bool {
+			return len(accounts) == 1 && accounts[0].Password == passwordHash && accounts[0].EmailVerifiedDate != nil
+		}), 0).
+		Return([]repository.AccountBatchResult{{Id: 1}}, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	input := `{"username":"alice","email":"alice@test.com","passwordHash":"` + passwordHash + `","emailVerified":true}`
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader(input), ImportOptions{Format: BulkFormatNdjson})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Imported)
+	mockedHashEngine.AssertNotCalled(t, "HashPassword", mock.Anything)
+}
+
+func TestImportAccountsShouldReportExistingAccounts(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{Id: 1, Username: "alice"}, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader("username,email,password\nalice,alice@test.com,secret\n"), ImportOptions{Format: BulkFormatCsv})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportRowError{{Row: 2, Username: "alice", Error: errUsernameTaken.Error()}}, summary.Errors)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccounts", mock.Anything, mock.Anything)
+}
+
+func TestImportAccountsShouldInsertInBatchesAndReportFailedRows(t *testing.T) {
+	// given
+	mockedAccountRepo := importAccountRepository()
+	mockedAccountRepo.
+		On("CreateAccounts", mock.MatchedBy(func(accounts []repository.Account) bool { return len(accounts) == 2 }), 0).
+		Return([]repository.AccountBatchResult{{Id: 1}, {Err: errors.New("constraint violation")}}, nil).
+		On("CreateAccounts", mock.MatchedBy(func(accounts []repository.Account) bool { return len(accounts) == 1 }), 0).
+		Return([]repository.AccountBatchResult{{Id: 3}}, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	input := "username,email,password\n" +
+		"alice,alice@test.com,secret\n" +
+		"bob,bob@test.com,secret\n" +
+		"carol,carol@test.com,secret\n"
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader(input), ImportOptions{Format: BulkFormatCsv, BatchSize: 2})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Imported)
+	assert.Equal(t, []ImportRowError{{Row: 3, Username: "bob", Error: "constraint violation"}}, summary.Errors)
+	mockedAccountRepo.AssertNumberOfCalls(t, "CreateAccounts", 2)
+}
+
+func TestImportAccountsShouldReportBatchAsFailedIfInsertFails(t *testing.T) {
+	// given
+	mockedAccountRepo := importAccountRepository()
+	mockedAccountRepo.
+		On("CreateAccounts", mock.Anything, mock.Anything).
+		Return(nil, errors.New("connection lost"))
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	input := "username,email,password\n" +
+		"alice,alice@test.com,secret\n" +
+		"bob,bob@test.com,secret\n"
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader(input), ImportOptions{Format: BulkFormatCsv})
+
+	// then
+	assert.Error(t, err)
+	assert.Equal(t, 0, summary.Imported)
+	assert.Equal(t, 2, summary.Failed)
+	assert.Equal(t, []ImportRowError{
+		{Row: 2, Username: "alice", Error: "connection lost"},
+		{Row: 3, Username: "bob", Error: "connection lost"},
+	}, summary.Errors)
+}
+
+func TestImportAccountsShouldCreateMembershipsWithAccounts(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 7, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByEmail", 7, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccounts", mock.Anything, 7).
+		Return([]repository.AccountBatchResult{{Id: 1}, {Id: -1, Err: errors.New("membership failed: insert failed")}}, nil)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	input := "username,email,password\n" +
+		"alice,alice@test.com,secret\n" +
+		"bob,bob@test.com,secret\n"
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader(input), ImportOptions{Format: BulkFormatCsv, Organization: "acme"})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Imported)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, []ImportRowError{{Row: 3, Username: "bob", Error: "membership failed: insert failed"}}, summary.Errors)
+	mockedOrgRepo.AssertNotCalled(t, "CreateMembership", mock.Anything)
+}
+
+func TestImportAccountsShouldNotWriteOnDryRun(t *testing.T) {
+	// given
+	mockedAccountRepo := importAccountRepository()
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("hashed"), nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	summary, err := service.ImportAccounts(strings.NewReader("username,email,password\nalice,alice@test.com,secret\n"), ImportOptions{Format: BulkFormatCsv, DryRun: true})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Imported)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccounts", mock.Anything, mock.Anything)
+}
+
+func TestExportAccountsShouldWriteCsvWithoutPasswordHashes(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccounts", repository.AccountFilter{Limit: exportPageSize}).
+		Return([]repository.Account{
+			{Id: 1, Username: "alice", Email: "alice@test.com", Password: "hash", Role: "user", Status: repository.AccountStatusActive, EmailVerified: true},
+			{Id: 2, Username: "deleted-2", Status: repository.AccountStatusDeleted},
+		}, nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	var output bytes.Buffer
+	count, err := service.ExportAccounts(&output, ExportOptions{Format: BulkFormatCsv})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "username,email,password,passwordHash,role,status,displayName,phoneNumber,emailVerified,attributes\n"+
+		"alice,alice@test.com,,,user,active,,,true,\n", output.String())
+}
+
+func TestExportAccountsShouldWriteNdjsonWithPasswordHashes(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccounts", repository.AccountFilter{Limit: exportPageSize}).
+		Return([]repository.Account{
+			{Id: 1, Username: "alice", Email: "alice@test.com", Password: "hash", Attributes: map[string]interface{}{"locale": "de-DE"}},
+		}, nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	var output bytes.Buffer
+	count, err := service.ExportAccounts(&output, ExportOptions{Format: BulkFormatNdjson, IncludePasswordHashes: true})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, `{"username":"alice","email":"alice@test.com","passwordHash":"hash","attributes":{"locale":"de-DE"}}`+"\n", output.String())
+}
@@ -0,0 +1,332 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxLegalDocumentVersionLength = 64
+	maxLegalDocumentUrlLength     = 512
+)
+
+var (
+	errConsentRequired         = errors.New("consent_required")
+	errLegalDocumentNotCurrent = errors.New("legal document is not the current version")
+)
+
+type LegalDocumentAcceptance struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+type ConsentRequest struct {
+	Documents []LegalDocumentAcceptance `json:"documents"`
+}
+
+type LegalDocumentRequest struct {
+	Type          string     `json:"type"`
+	Version       string     `json:"version"`
+	Url           string     `json:"url"`
+	PublishedDate *time.Time `json:"publishedDate,omitempty"`
+}
+
+type LegalDocumentResponse struct {
+	Id            int       `json:"id"`
+	Type          string    `json:"type"`
+	Version       string    `json:"version"`
+	Url           string    `json:"url"`
+	PublishedDate time.Time `json:"publishedDate"`
+}
+
+type ConsentResponse struct {
+	Type         string    `json:"type"`
+	Version      string    `json:"version"`
+	IpAddress    string    `json:"ipAddress"`
+	CreationDate time.Time `json:"creationDate"`
+}
+
+func WithConsentRepository(consentRepo repository.ConsentRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.consentRepo = consentRepo
+	}
+}
+
+func isLegalDocumentType(documentType string) bool {
+	return documentType == repository.LegalDocumentTermsOfService || documentType == repository.LegalDocumentPrivacyPolicy
+}
+
+func newLegalDocumentResponse(document repository.LegalDocument) LegalDocumentResponse {
+	return LegalDocumentResponse{
+		Id:            document.Id,
+		Type:          document.Type,
+		Version:       document.Version,
+		Url:           document.Url,
+		PublishedDate: document.PublishedDate,
+	}
+}
+
+func newLegalDocumentResponses(documents []repository.LegalDocument) []LegalDocumentResponse {
+	response := []LegalDocumentResponse{}
+	for _, document := range documents {
+		response = append(response, newLegalDocumentResponse(document))
+	}
+
+	return response
+}
+
+func newConsentResponse(consent repository.Consent) ConsentResponse {
+	return ConsentResponse{
+		Type:         consent.DocumentType,
+		Version:      consent.DocumentVersion,
+		IpAddress:    consent.IpAddress,
+		CreationDate: consent.CreationDate,
+	}
+}
+
+func acceptedLegalDocuments(current []repository.LegalDocument, accepted []LegalDocumentAcceptance) ([]repository.LegalDocument, []repository.LegalDocument) {
+	acceptedVersions := map[string]string{}
+	for _, acceptance := range accepted {
+		acceptedVersions[acceptance.Type] = acceptance.Version
+	}
+
+	documents := []repository.LegalDocument{}
+	missing := []repository.LegalDocument{}
+	for _, document := range current {
+		if version, ok := acceptedVersions[document.Type]; ok && version == document.Version {
+			documents = append(documents, document)
+		} else {
+			missing = append(missing, document)
+		}
+	}
+
+	return documents, missing
+}
+
+func (service *LoginService) recordConsents(r *http.Request, accountId int, documents []repository.LegalDocument) error {
+	if service.consentRepo == nil || len(documents) == 0 {
+		return nil
+	}
+
+	consents := []repository.Consent{}
+	for _, document := range documents {
+		consents = append(consents, repository.Consent{
+			AccountId:    accountId,
+			DocumentId:   document.Id,
+			IpAddress:    service.clientIp(r),
+			CreationDate: time.Now(),
+		})
+	}
+
+	return service.consentRepo.CreateConsents(consents)
+}
+
+func (service *LoginService) pendingLegalDocuments(accountId int) ([]repository.LegalDocument, error) {
+	if service.consentRepo == nil {
+		return []repository.LegalDocument{}, nil
+	}
+
+	return service.consentRepo.GetPendingLegalDocuments(accountId, time.Now())
+}
+
+func (service *LoginService) addConsentProps(r *http.Request, accountId int, props map[string]interface{}) {
+	pending, err := service.pendingLegalDocuments(accountId)
+	if err != nil {
+		service.logger.Errorf("(%s) get pending legal documents of account %d failed: %s", r.RemoteAddr, accountId, err.Error())
+		return
+	}
+
+	if len(pending) > 0 {
+		props["consentRequired"] = true
+		props["pendingDocuments"] = newLegalDocumentResponses(pending)
+	}
+}
+
+func sendConsentRequiredResponse(w http.ResponseWriter, missing []repository.LegalDocument) {
+	sendResponse(w, http.StatusBadRequest, "Acceptance of the current legal documents required.", map[string]interface{}{
+		"code":      errConsentRequired.Error(),
+		"documents": newLegalDocumentResponses(missing),
+	})
+}
+
+func (service *LoginService) LegalDocumentsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	documents := []repository.LegalDocument{}
+	if service.consentRepo != nil {
+		current, err := service.consentRepo.GetCurrentLegalDocuments(time.Now())
+		if err != nil {
+			service.logger.Errorf("(%s) get current legal documents failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not get legal documents.")
+			return
+		}
+
+		documents = current
+	}
+
+	sendResponse(w, http.StatusOK, "Legal documents found.", map[string]interface{}{
+		"documents": newLegalDocumentResponses(documents),
+	})
+}
+
+func (service *LoginService) ConsentsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.consentRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Consents are not available.")
+		return
+	}
+
+	consents, err := service.consentRepo.GetConsentsByAccount(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get consents of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get consents.")
+		return
+	}
+
+	pending, err := service.pendingLegalDocuments(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get pending legal documents of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get consents.")
+		return
+	}
+
+	response := []ConsentResponse{}
+	for _, consent := range consents {
+		response = append(response, newConsentResponse(consent))
+	}
+
+	sendResponse(w, http.StatusOK, "Consents found.", map[string]interface{}{
+		"consents":         response,
+		"pendingDocuments": newLegalDocumentResponses(pending),
+	})
+}
+
+func (service *LoginService) AcceptConsentHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request ConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Documents) == 0 {
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if service.consentRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Consents are not available.")
+		return
+	}
+
+	current, err := service.consentRepo.GetCurrentLegalDocuments(time.Now())
+	if err != nil {
+		service.logger.Errorf("(%s) get current legal documents failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not accept legal documents.")
+		return
+	}
+
+	documents, _ := acceptedLegalDocuments(current, request.Documents)
+	if len(documents) != len(request.Documents) {
+		service.logger.Warnf("(%s) consent of user '%s' rejected: %s", r.RemoteAddr, claims.Username, errLegalDocumentNotCurrent.Error())
+		sendResponse(w, http.StatusBadRequest, "Only the current versions of legal documents can be accepted.", map[string]interface{}{
+			"documents": newLegalDocumentResponses(current),
+		})
+		return
+	}
+
+	if err := service.recordConsents(r, claims.UserId, documents); err != nil {
+		service.logger.Errorf("(%s) record consents of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not accept legal documents.")
+		return
+	}
+
+	props := map[string]interface{}{
+		"consentRequired":  false,
+		"pendingDocuments": []LegalDocumentResponse{},
+	}
+	service.addConsentProps(r, claims.UserId, props)
+
+	service.logger.Infof("(%s) user '%s' accepted %d legal documents", r.RemoteAddr, claims.Username, len(documents))
+	sendResponse(w, http.StatusOK, "Legal documents accepted.", props)
+}
+
+func (service *LoginService) AdminLegalDocumentsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if service.consentRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Consents are not available.")
+		return
+	}
+
+	documents, err := service.consentRepo.GetLegalDocuments()
+	if err != nil {
+		service.logger.Errorf("(%s) list legal documents failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not list legal documents.")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Legal documents found.", map[string]interface{}{
+		"documents": newLegalDocumentResponses(documents),
+	})
+}
+
+func (service *LoginService) AdminPublishLegalDocumentHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request LegalDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	request.Version = strings.TrimSpace(request.Version)
+	documentUrl, err := url.ParseRequestURI(request.Url)
+	if !isLegalDocumentType(request.Type) || request.Version == "" || len(request.Version) > maxLegalDocumentVersionLength ||
+		err != nil || documentUrl.Host == "" || len(request.Url) > maxLegalDocumentUrlLength {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid legal document.")
+		return
+	}
+
+	if service.consentRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Consents are not available.")
+		return
+	}
+
+	documents, err := service.consentRepo.GetLegalDocuments()
+	if err != nil {
+		service.logger.Errorf("(%s) list legal documents failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not publish legal document.")
+		return
+	}
+
+	for _, existing := range documents {
+		if existing.Type == request.Type && existing.Version == request.Version {
+			sendSimpleResponse(w, http.StatusConflict, "Legal document version already exists.")
+			return
+		}
+	}
+
+	document := repository.LegalDocument{
+		Type:          request.Type,
+		Version:       request.Version,
+		Url:           request.Url,
+		PublishedDate: time.Now(),
+	}
+	if request.PublishedDate != nil {
+		document.PublishedDate = *request.PublishedDate
+	}
+
+	document.Id, err = service.consentRepo.CreateLegalDocument(document)
+	if err != nil {
+		service.logger.Errorf("(%s) publish legal document '%s' version '%s' failed: %s", r.RemoteAddr, request.Type, request.Version, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not publish legal document.")
+		return
+	}
+
+	service.logger.Infof("(%s) legal document '%s' version '%s' published by '%s'", r.RemoteAddr, document.Type, document.Version, claims.Username)
+	sendResponse(w, http.StatusOK, "Legal document published.", map[string]interface{}{
+		"document": newLegalDocumentResponse(document),
+	})
+}
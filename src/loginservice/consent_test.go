@@ -0,0 +1,305 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var currentLegalDocuments = []repository.LegalDocument{
+	{Id: 1, Type: repository.LegalDocumentPrivacyPolicy, Version: "2022-06", Url: "https://fitter.test/privacy"},
+	{Id: 2, Type: repository.LegalDocumentTermsOfService, Version: "3", Url: "https://fitter.test/tos"},
+}
+
+func consentRegisterRequest(t *testing.T, accepted []LegalDocumentAcceptance) *http.Request {
+	body, _ := json.Marshal(UserRegisterRequest{
+		Username:          "testuser",
+		Password:          "testpass",
+		Email:             "test@test.com",
+		AcceptedDocuments: accepted,
+	})
+
+	request, err := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+func TestRegisterHandlerShouldRequireCurrentConsents(t *testing.T) {
+	// given
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetCurrentLegalDocuments", mock.Anything).
+		Return(currentLegalDocuments, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request := consentRegisterRequest(t, []LegalDocumentAcceptance{
+		{Type: repository.LegalDocumentPrivacyPolicy, Version: "2022-06"},
+		{Type: repository.LegalDocumentTermsOfService, Version: "2"},
+	})
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"version":"3"`)
+	assert.NotContains(t, responseWriter.Body.String(), `"version":"2022-06"`)
+	assert.Equal(t, "consent_required", registrationErrorCode(t, responseWriter))
+}
+
+func TestRegisterHandlerShouldRecordConsents(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(5, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetCurrentLegalDocuments", mock.Anything).
+		Return(currentLegalDocuments, nil).
+		On("CreateConsents", mock.MatchedBy(func(consents []repository.Consent) bool {
+			return len(consents) == 2 && consents[0].AccountId == 5 && consents[0].DocumentId == 1 &&
+				consents[1].DocumentId == 2 && consents[1].IpAddress == "10.0.0.1"
+		})).
+		Return(nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request := consentRegisterRequest(t, []LegalDocumentAcceptance{
+		{Type: repository.LegalDocumentTermsOfService, Version: "3"},
+		{Type: repository.LegalDocumentPrivacyPolicy, Version: "2022-06"},
+	})
+	request.RemoteAddr = "10.0.0.1:1234"
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedConsentRepo.AssertExpectations(t)
+}
+
+func TestRegisterHandlerShouldDeleteAccountIfConsentsCannotBeRecorded(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(5, nil).
+		On("DeleteAccountById", 5).
+		Return(nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetCurrentLegalDocuments", mock.Anything).
+		Return(currentLegalDocuments, nil).
+		On("CreateConsents", mock.Anything).
+		Return(errors.New("insert failed"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request := consentRegisterRequest(t, []LegalDocumentAcceptance{
+		{Type: repository.LegalDocumentTermsOfService, Version: "3"},
+		{Type: repository.LegalDocumentPrivacyPolicy, Version: "2022-06"},
+	})
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccountById", 5)
+}
+
+func TestLoginHandlerShouldSignalPendingConsents(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetPendingLegalDocuments", 1, mock.Anything).
+		Return(currentLegalDocuments[1:], nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	var response map[string]interface{}
+	json.Unmarshal(responseWriter.Body.Bytes(), &response)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotNil(t, response["token"])
+	assert.Equal(t, true, response["consentRequired"])
+	assert.Len(t, response["pendingDocuments"], 1)
+}
+
+func TestLoginHandlerShouldNotSignalWithoutPendingConsents(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetPendingLegalDocuments", 1, mock.Anything).
+		Return([]repository.LegalDocument{}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotContains(t, responseWriter.Body.String(), "consentRequired")
+}
+
+func TestAcceptConsentHandlerShouldRejectOutdatedVersion(t *testing.T) {
+	// given
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetCurrentLegalDocuments", mock.Anything).
+		Return(currentLegalDocuments, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/consents", bytes.NewBufferString(`{ "documents": [{ "type": "tos", "version": "2" }] }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedConsentRepo.AssertNotCalled(t, "CreateConsents", mock.Anything)
+}
+
+func TestAcceptConsentHandlerShouldRecordConsent(t *testing.T) {
+	// given
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetCurrentLegalDocuments", mock.Anything).
+		Return(currentLegalDocuments, nil).
+		On("CreateConsents", mock.MatchedBy(func(consents []repository.Consent) bool {
+			return len(consents) == 1 && consents[0].AccountId == 1 && consents[0].DocumentId == 2
+		})).
+		Return(nil).
+		On("GetPendingLegalDocuments", 1, mock.Anything).
+		Return([]repository.LegalDocument{}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/consents", bytes.NewBufferString(`{ "documents": [{ "type": "tos", "version": "3" }] }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"consentRequired":false`)
+}
+
+func TestAdminPublishLegalDocumentHandlerShouldRejectDuplicateVersion(t *testing.T) {
+	// given
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetLegalDocuments").
+		Return(currentLegalDocuments, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/legal-documents", bytes.NewBufferString(`{ "type": "tos", "version": "3", "url": "https://fitter.test/tos" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	mockedConsentRepo.AssertNotCalled(t, "CreateLegalDocument", mock.Anything)
+}
+
+func TestAdminPublishLegalDocumentHandlerShouldPublishVersion(t *testing.T) {
+	// given
+	mockedConsentRepo := new(mocks.ConsentRepository)
+	mockedConsentRepo.
+		On("GetLegalDocuments").
+		Return(currentLegalDocuments, nil).
+		On("CreateLegalDocument", mock.MatchedBy(func(document repository.LegalDocument) bool {
+			return document.Type == repository.LegalDocumentTermsOfService && document.Version == "4" && !document.PublishedDate.IsZero()
+		})).
+		Return(3, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithConsentRepository(mockedConsentRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/legal-documents", bytes.NewBufferString(`{ "type": "tos", "version": "4", "url": "https://fitter.test/tos-4" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"id":3`)
+}
@@ -0,0 +1,168 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultDeletionGracePeriod = 30 * 24 * time.Hour
+	defaultPurgeInterval       = time.Hour
+)
+
+type AccountDeletionRequest struct {
+	Password string `json:"password"`
+}
+
+func (cfg DeletionConfig) gracePeriod() time.Duration {
+	if cfg.GracePeriod <= 0 {
+		return defaultDeletionGracePeriod
+	}
+
+	return cfg.GracePeriod
+}
+
+func (cfg DeletionConfig) purgeInterval() time.Duration {
+	if cfg.PurgeInterval <= 0 {
+		return defaultPurgeInterval
+	}
+
+	return cfg.PurgeInterval
+}
+
+func (service *LoginService) AccountDeletionHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request AccountDeletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	if err := service.verifyPassword(r, &account, request.Password); err != nil {
+		service.logger.Warnf("(%s) wrong password while deleting account of user '%s'", r.RemoteAddr, account.Username)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	if account.DeletionScheduledDate != nil {
+		sendResponse(w, http.StatusOK, "Account deletion already scheduled.", map[string]interface{}{
+			"deletionScheduledDate": account.DeletionScheduledDate,
+		})
+		return
+	}
+
+	requestedDate := time.Now()
+	scheduledDate := requestedDate.Add(service.config.Deletion.gracePeriod())
+	if err := service.accountRepo.ScheduleAccountDeletion(account.Id, requestedDate, scheduledDate); err != nil {
+		service.logger.Errorf("(%s) schedule deletion of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not schedule account deletion.")
+		return
+	}
+
+	service.logger.Infof("(%s) deletion of user '%s' scheduled for %s", r.RemoteAddr, account.Username, scheduledDate.Format(time.RFC3339))
+
+	sendResponse(w, http.StatusOK, "Account deletion scheduled.", map[string]interface{}{
+		"deletionScheduledDate": scheduledDate,
+	})
+}
+
+func (service *LoginService) CancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	if account.DeletionScheduledDate == nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "No account deletion scheduled.")
+		return
+	}
+
+	if err := service.accountRepo.CancelAccountDeletion(account.Id); err != nil {
+		service.logger.Errorf("(%s) cancel deletion of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not cancel account deletion.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Account deletion cancelled.")
+}
+
+func (service *LoginService) deleteAccount(account repository.Account, reason string, actor string) error {
+	var transition *repository.AccountStatusTransition
+	if account.Status != repository.AccountStatusDeleted {
+		deleted, err := accountStatusTransition(account, repository.AccountStatusDeleted, reason, actor)
+		if err != nil {
+			return err
+		}
+
+		transition = &deleted
+	}
+
+	return service.accountRepo.DeleteAccount(account.Id, transition)
+}
+
+func (service *LoginService) PurgeAccounts(now time.Time) error {
+	accounts, err := service.accountRepo.GetAccountsScheduledForDeletion(now)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if service.config.Deletion.Anonymize {
+			err = service.accountRepo.AnonymizeAccountById(account.Id)
+			if err == nil {
+				err = service.changeAccountStatus(account, repository.AccountStatusDeleted, "deletion grace period expired", statusActorSystem)
+			}
+		} else {
+			err = service.deleteAccount(account, "deletion grace period expired", statusActorSystem)
+		}
+
+		if err != nil {
+			service.logger.Errorf("purge of account %d failed: %s", account.Id, err.Error())
+			continue
+		}
+
+		service.logger.Infof("purged account %d", account.Id)
+	}
+
+	return nil
+}
+
+func (service *LoginService) StartAccountPurge() func() {
+	ticker := time.NewTicker(service.config.Deletion.purgeInterval())
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := service.PurgeAccounts(now); err != nil {
+					service.logger.Errorf("purge of accounts failed: %s", err.Error())
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
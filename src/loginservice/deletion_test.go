@@ -0,0 +1,203 @@
+package loginservice
+
+import (
+	"bytes"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountDeletionHandlerShouldScheduleDeletion(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil).
+		On("ScheduleAccountDeletion", 1, mock.Anything, mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:      security.JwtConfig{SignKey: "secret"},
+		Deletion: DeletionConfig{GracePeriod: 24 * time.Hour},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/deletion", bytes.NewBufferString(`{ "password": "testpass" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "ScheduleAccountDeletion", 1, mock.Anything, mock.MatchedBy(func(scheduledDate time.Time) bool {
+		return scheduledDate.After(time.Now().Add(23*time.Hour)) && scheduledDate.Before(time.Now().Add(25*time.Hour))
+	}))
+}
+
+func TestAccountDeletionHandlerShouldRejectWrongPassword(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword)}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/deletion", bytes.NewBufferString(`{ "password": "wrongpass" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "ScheduleAccountDeletion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCancelAccountDeletionHandlerSucceeded(t *testing.T) {
+	// given
+	scheduledDate := time.Now().Add(time.Hour)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", DeletionScheduledDate: &scheduledDate, Status: repository.AccountStatusActive}, nil).
+		On("CancelAccountDeletion", 1).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/deletion", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "CancelAccountDeletion", 1)
+}
+
+func TestCancelAccountDeletionHandlerShouldRejectIfNothingScheduled(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/deletion", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestPurgeAccountsShouldDeleteDueAccounts(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountsScheduledForDeletion", mock.Anything).
+		Return([]repository.Account{{Id: 1, Status: repository.AccountStatusActive}, {Id: 2, Status: repository.AccountStatusActive}}, nil).
+		On("DeleteAccount", 1, mock.Anything).
+		Return(errors.New("could not delete")).
+		On("DeleteAccount", 2, mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything).
+		On("Infof", mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	err := service.PurgeAccounts(time.Now())
+
+	// then
+	assert.NoError(t, err)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccount", 2, mock.MatchedBy(func(transition *repository.AccountStatusTransition) bool {
+		return transition.AccountId == 2 && transition.ToStatus == repository.AccountStatusDeleted && transition.Actor == statusActorSystem
+	}))
+	mockedAccountRepo.AssertCalled(t, "DeleteAccount", 1, mock.Anything)
+	mockedLogger.AssertCalled(t, "Errorf", "purge of account %d failed: %s", 1, "could not delete")
+	mockedLogger.AssertCalled(t, "Infof", "purged account %d", 2)
+}
+
+func TestPurgeAccountsShouldAnonymizeIfConfigured(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountsScheduledForDeletion", mock.Anything).
+		Return([]repository.Account{{Id: 1, Status: repository.AccountStatusActive}}, nil).
+		On("AnonymizeAccountById", 1).
+		Return(nil).
+		On("UpdateAccountStatus", mock.MatchedBy(func(transition repository.AccountStatusTransition) bool {
+			return transition.AccountId == 1 && transition.ToStatus == repository.AccountStatusDeleted
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Deletion: DeletionConfig{Anonymize: true},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	err := service.PurgeAccounts(time.Now())
+
+	// then
+	assert.NoError(t, err)
+	mockedAccountRepo.AssertCalled(t, "AnonymizeAccountById", 1)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountStatus", mock.Anything)
+	mockedAccountRepo.AssertNotCalled(t, "DeleteAccount", mock.Anything, mock.Anything)
+}
+
+func TestStartAccountPurgeShouldRunPeriodically(t *testing.T) {
+	// given
+	purged := make(chan struct{}, 1)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountsScheduledForDeletion", mock.Anything).
+		Run(func(args mock.Arguments) {
+			select {
+			case purged <- struct{}{}:
+			default:
+			}
+		}).
+		Return([]repository.Account{}, nil)
+	service := NewService(LoginServiceConfig{
+		Deletion: DeletionConfig{PurgeInterval: 10 * time.Millisecond},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	stop := service.StartAccountPurge()
+	defer stop()
+
+	// then
+	select {
+	case <-purged:
+	case <-time.After(time.Second):
+		t.Fatal("Purge did not run")
+	}
+}
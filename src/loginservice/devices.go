@@ -0,0 +1,172 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	trustedDeviceCookieName      = "fitter_trusted_device"
+	defaultTrustedDeviceLifetime = 30 * 24 * time.Hour
+)
+
+type TrustedDeviceResponse struct {
+	Id             int        `json:"id"`
+	Name           string     `json:"name"`
+	CreationDate   time.Time  `json:"creationDate"`
+	ExpirationDate time.Time  `json:"expirationDate"`
+	LastUsedDate   *time.Time `json:"lastUsedDate,omitempty"`
+}
+
+func newTrustedDeviceResponse(device repository.TrustedDevice) TrustedDeviceResponse {
+	return TrustedDeviceResponse{
+		Id:             device.Id,
+		Name:           device.Name,
+		CreationDate:   device.CreationDate,
+		ExpirationDate: device.ExpirationDate,
+		LastUsedDate:   device.LastUsedDate,
+	}
+}
+
+func (service *LoginService) trustedDeviceLifetime() time.Duration {
+	if service.config.Mfa.TrustedDeviceLifetime <= 0 {
+		return defaultTrustedDeviceLifetime
+	}
+
+	return service.config.Mfa.TrustedDeviceLifetime
+}
+
+func (service *LoginService) trustDevice(w http.ResponseWriter, accountId int, username string, name string) (string, error) {
+	if service.deviceRepo == nil {
+		return "", errTrustedDevicesUnavailable
+	}
+
+	deviceId, err := security.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expirationDate := now.Add(service.trustedDeviceLifetime())
+	_, err = service.deviceRepo.CreateTrustedDevice(repository.TrustedDevice{
+		AccountId:      accountId,
+		DeviceId:       deviceId,
+		Name:           name,
+		CreationDate:   now,
+		ExpirationDate: expirationDate,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	deviceToken, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:   accountId,
+		Username: username,
+		Purpose:  security.TokenPurposeTrustedDevice,
+		StandardClaims: jwt.StandardClaims{
+			Id:        deviceId,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expirationDate.Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     trustedDeviceCookieName,
+		Value:    deviceToken,
+		Path:     "/api/auth",
+		Expires:  expirationDate,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return deviceToken, nil
+}
+
+func (service *LoginService) isTrustedDevice(r *http.Request, deviceToken string, accountId int) bool {
+	if service.deviceRepo == nil {
+		return false
+	}
+
+	if deviceToken == "" {
+		cookie, err := r.Cookie(trustedDeviceCookieName)
+		if err != nil {
+			return false
+		}
+
+		deviceToken = cookie.Value
+	}
+
+	claims, err := security.ParseToken(deviceToken, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil || claims.Purpose != security.TokenPurposeTrustedDevice || claims.UserId != accountId {
+		return false
+	}
+
+	device, err := service.deviceRepo.GetTrustedDevice(accountId, claims.Id)
+	if err != nil {
+		return false
+	}
+
+	if err := service.deviceRepo.UpdateTrustedDeviceLastUsed(device.Id, time.Now()); err != nil {
+		service.logger.Warnf("(%s) update last usage of trusted device %d failed: %s", r.RemoteAddr, device.Id, err.Error())
+	}
+
+	return true
+}
+
+func (service *LoginService) TrustedDevicesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.deviceRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Trusted devices are not available.")
+		return
+	}
+
+	devices, err := service.deviceRepo.GetTrustedDevices(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get trusted devices of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get trusted devices.")
+		return
+	}
+
+	response := []TrustedDeviceResponse{}
+	for _, device := range devices {
+		response = append(response, newTrustedDeviceResponse(device))
+	}
+
+	sendResponse(w, http.StatusOK, "Trusted devices.", map[string]interface{}{
+		"devices": response,
+	})
+}
+
+func (service *LoginService) RevokeTrustedDeviceHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.deviceRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Trusted devices are not available.")
+		return
+	}
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid device id.")
+		return
+	}
+
+	if err := service.deviceRepo.DeleteTrustedDevice(claims.UserId, id); err != nil {
+		service.logger.Warnf("(%s) revoke trusted device %d of user '%s' failed: %s", r.RemoteAddr, id, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "Trusted device not found.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Trusted device revoked.")
+}
@@ -0,0 +1,239 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func deviceToken(t *testing.T, id int, deviceId string, key string) string {
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:  id,
+		Purpose: security.TokenPurposeTrustedDevice,
+		StandardClaims: jwt.StandardClaims{
+			Id:        deviceId,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestLoginHandlerShouldSkipSecondFactorOnTrustedDevice(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("GetTrustedDevice", 1, "device").
+		Return(repository.TrustedDevice{Id: 3, AccountId: 1, DeviceId: "device"}, nil).
+		On("UpdateTrustedDeviceLastUsed", 3, mock.Anything).
+		Return(nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{ "username": "testuser", "password": "testpass" }`))
+	request.AddCookie(&http.Cookie{Name: trustedDeviceCookieName, Value: deviceToken(t, 1, "device", "secret")})
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotNil(t, response["token"])
+	assert.Nil(t, response["mfaRequired"])
+	mockedDeviceRepo.AssertCalled(t, "UpdateTrustedDeviceLastUsed", 3, mock.Anything)
+	mockedOtpRepo.AssertNotCalled(t, "CreateOneTimePassword", mock.Anything)
+}
+
+func TestLoginHandlerShouldRequireSecondFactorForDeviceOfOtherAccount(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("codehash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 1, otpPurposeLogin, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("CreateOneTimePassword", mock.Anything).
+		Return(1, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedSender.
+		On("SendMessage", mock.Anything).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithTrustedDeviceRepository(mockedDeviceRepo),
+		WithMessageSender(mockedSender))
+
+	body, _ := json.Marshal(UserLoginRequest{Username: "testuser", Password: "testpass", DeviceToken: deviceToken(t, 2, "device", "secret")})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["mfaRequired"])
+	assert.Nil(t, response["token"])
+	mockedDeviceRepo.AssertNotCalled(t, "GetTrustedDevice", mock.Anything, mock.Anything)
+}
+
+func TestLoginOtpHandlerShouldRememberDevice(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeLogin).
+		Return(nil)
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("CreateTrustedDevice", mock.MatchedBy(func(device repository.TrustedDevice) bool {
+			return device.AccountId == 1 && device.Name == "laptop" && device.DeviceId != ""
+		})).
+		Return(1, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456", RememberDevice: true, DeviceName: "laptop"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotNil(t, response["token"])
+	assert.NotNil(t, response["deviceToken"])
+	assert.Contains(t, responseWriter.Header().Get("Set-Cookie"), trustedDeviceCookieName)
+}
+
+func TestTrustedDevicesHandlerSucceeded(t *testing.T) {
+	// given
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("GetTrustedDevices", 1).
+		Return([]repository.TrustedDevice{{Id: 1, Name: "laptop"}, {Id: 2, Name: "phone"}}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/devices", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Len(t, response["devices"], 2)
+}
+
+func TestRevokeTrustedDeviceHandlerSucceeded(t *testing.T) {
+	// given
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("DeleteTrustedDevice", 1, 5).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/devices/5", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedDeviceRepo.AssertCalled(t, "DeleteTrustedDevice", 1, 5)
+}
+
+func TestRevokeTrustedDeviceHandlerShouldReturnNotFound(t *testing.T) {
+	// given
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("DeleteTrustedDevice", 1, 5).
+		Return(errors.New("no rows"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/devices/5", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+}
@@ -0,0 +1,169 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultVerificationLifetime = 48 * time.Hour
+	defaultResendInterval       = 5 * time.Minute
+	defaultVerificationUrl      = "/api/auth/email/confirm"
+)
+
+var (
+	errMailerUnavailable = errors.New("mailer is not configured")
+)
+
+type EmailResendRequest struct {
+	Email        string `json:"email"`
+	Organization string `json:"organization,omitempty"`
+}
+
+func (cfg EmailConfig) verificationLifetime() time.Duration {
+	if cfg.VerificationLifetime <= 0 {
+		return defaultVerificationLifetime
+	}
+
+	return cfg.VerificationLifetime
+}
+
+func (cfg EmailConfig) resendInterval() time.Duration {
+	if cfg.ResendInterval <= 0 {
+		return defaultResendInterval
+	}
+
+	return cfg.ResendInterval
+}
+
+func (cfg EmailConfig) verificationLink(token string) string {
+	verificationUrl := cfg.VerificationUrl
+	if verificationUrl == "" {
+		verificationUrl = defaultVerificationUrl
+	}
+
+	return fmt.Sprintf("%s?token=%s", verificationUrl, url.QueryEscape(token))
+}
+
+func (service *LoginService) sendVerificationMail(account repository.Account) error {
+	if service.mailer == nil {
+		return errMailerUnavailable
+	}
+
+	now := time.Now()
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:   account.Id,
+		Username: account.Username,
+		Email:    account.Email,
+		Purpose:  security.TokenPurposeEmailVerify,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(service.config.Email.verificationLifetime()).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil {
+		return err
+	}
+
+	err = service.mailer.SendMail(messaging.Mail{
+		To:      account.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease confirm your email address by opening the following link:\n\n%s\n",
+			account.Username, service.config.Email.verificationLink(token)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return service.accountRepo.UpdateAccountVerificationSentDate(account.Id, now)
+}
+
+func (service *LoginService) EmailConfirmHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims, err := security.ParseToken(r.URL.Query().Get("token"), jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil || claims.Purpose != security.TokenPurposeEmailVerify {
+		service.logger.Warnf("(%s) invalid email verification token", r.RemoteAddr)
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired verification link.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil || account.Email != claims.Email {
+		service.logger.Warnf("(%s) email verification of user %d does not match account", r.RemoteAddr, claims.UserId)
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired verification link.")
+		return
+	}
+
+	if account.EmailVerified {
+		sendSimpleResponse(w, http.StatusOK, "Email address already verified.")
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountEmailVerified(account.Id, time.Now()); err != nil {
+		service.logger.Errorf("(%s) verify email of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not verify email address.")
+		return
+	}
+
+	if account.Status == repository.AccountStatusPending {
+		if err := service.changeAccountStatus(account, repository.AccountStatusActive, "email address verified", statusActorSystem); err != nil {
+			service.logger.Errorf("(%s) activate user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not verify email address.")
+			return
+		}
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Email address verified.")
+}
+
+func (service *LoginService) EmailResendHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request EmailResendRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	message := "If the address belongs to an unverified account, a verification email has been sent."
+
+	organization, err := service.organizationBySlug(request.Organization)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusOK, message)
+		return
+	}
+
+	email, err := NormalizeEmail(request.Email)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusOK, message)
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountByEmail(tenantIdOf(organization), email)
+	if err != nil || account.EmailVerified {
+		sendSimpleResponse(w, http.StatusOK, message)
+		return
+	}
+
+	if account.VerificationSentDate != nil && time.Since(*account.VerificationSentDate) < service.config.Email.resendInterval() {
+		service.logger.Warnf("(%s) verification email to user '%s' throttled", r.RemoteAddr, account.Username)
+		sendSimpleResponse(w, http.StatusOK, message)
+		return
+	}
+
+	// A failed send is only logged, as an error response would reveal that
+	// the address belongs to an unverified account.
+	if err := service.sendVerificationMail(account); err != nil {
+		service.logger.Errorf("(%s) send verification email to user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+	}
+
+	sendSimpleResponse(w, http.StatusOK, message)
+}
@@ -0,0 +1,253 @@
+package loginservice
+
+import (
+	"bytes"
+	"errors"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func verificationToken(t *testing.T, id int, email string, key string) string {
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:  id,
+		Email:   email,
+		Purpose: security.TokenPurposeEmailVerify,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestRegisterHandlerShouldSendVerificationMail(t *testing.T) {
+	// given
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "testmail@test.com" }`)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(1, nil).
+		On("UpdateAccountVerificationSentDate", 1, mock.Anything).
+		Return(nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedMailer.
+		On("SendMail", mock.Anything).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{VerificationUrl: "https://fitter.app/verify"},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithMailer(mockedMailer))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedMailer.AssertCalled(t, "SendMail", mock.MatchedBy(func(mail messaging.Mail) bool {
+		return mail.To == "testmail@test.com" && strings.Contains(mail.Body, "https://fitter.app/verify?token=")
+	}))
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountVerificationSentDate", 1, mock.Anything)
+}
+
+func TestEmailConfirmHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com"}, nil).
+		On("UpdateAccountEmailVerified", 1, mock.Anything).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	token := verificationToken(t, 1, "test@test.com", "secret")
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/email/confirm?token="+url.QueryEscape(token), nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountEmailVerified", 1, mock.Anything)
+}
+
+func TestEmailConfirmHandlerShouldRejectChangedEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "new@test.com"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	token := verificationToken(t, 1, "test@test.com", "secret")
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/email/confirm?token="+url.QueryEscape(token), nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountEmailVerified", mock.Anything, mock.Anything)
+}
+
+func TestEmailConfirmHandlerShouldRejectInvalidToken(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/email/confirm?token="+url.QueryEscape(bearerHeader(t, 1, "testuser", "secret")), nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) invalid email verification token", mock.Anything)
+}
+
+func TestEmailResendHandlerSucceeded(t *testing.T) {
+	// given
+	sentDate := time.Now().Add(-time.Hour)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "test@test.com").
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", VerificationSentDate: &sentDate}, nil).
+		On("UpdateAccountVerificationSentDate", 1, mock.Anything).
+		Return(nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedMailer.
+		On("SendMail", mock.Anything).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithMailer(mockedMailer))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/email/resend", bytes.NewBufferString(`{ "email": "test@test.com" }`))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedMailer.AssertNumberOfCalls(t, "SendMail", 1)
+}
+
+func TestEmailResendHandlerShouldHideFailedSend(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "test@test.com").
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com"}, nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedMailer.
+		On("SendMail", mock.Anything).
+		Return(errors.New("connection refused"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithMailer(mockedMailer))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/email/resend", bytes.NewBufferString(`{ "email": "test@test.com" }`))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), "If the address belongs to an unverified account")
+	mockedLogger.AssertCalled(t, "Errorf", "(%s) send verification email to user '%s' failed: %s", mock.Anything, "testuser", "connection refused")
+}
+
+func TestEmailResendHandlerShouldThrottle(t *testing.T) {
+	// given
+	sentDate := time.Now().Add(-time.Minute)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "test@test.com").
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", VerificationSentDate: &sentDate}, nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{ResendInterval: 5 * time.Minute},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithMailer(mockedMailer))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/email/resend", bytes.NewBufferString(`{ "email": "test@test.com" }`))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedMailer.AssertNotCalled(t, "SendMail", mock.Anything)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) verification email to user '%s' throttled", mock.Anything, "testuser")
+}
+
+func TestLoginHandlerShouldRejectUnverifiedEmail(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{RequireVerification: true},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{ "username": "testuser", "password": "testpass" }`))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) login of unverified user '%s' rejected", mock.Anything, "testuser")
+}
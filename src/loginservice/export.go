@@ -0,0 +1,212 @@
+package loginservice
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/repository"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	ExportFormatJson = "json"
+	ExportFormatZip  = "zip"
+)
+
+type SmsFactorExport struct {
+	PhoneNumber string `json:"phoneNumber"`
+	Verified    bool   `json:"verified"`
+}
+
+type MfaExport struct {
+	Sms *SmsFactorExport `json:"sms,omitempty"`
+}
+
+type AccountExport struct {
+	ExportDate     time.Time                `json:"exportDate"`
+	Account        ProfileResponse          `json:"account"`
+	Mfa            MfaExport                `json:"mfa"`
+	TrustedDevices []TrustedDeviceResponse  `json:"trustedDevices"`
+	Memberships    []MembershipResponse     `json:"memberships"`
+	LoginHistory   []LoginEventResponse     `json:"loginHistory"`
+	Consents       []ConsentResponse        `json:"consents"`
+	Identities     []LinkedIdentityResponse `json:"identities"`
+	ApiKeys        []ApiKeyResponse         `json:"apiKeys"`
+}
+
+func (service *LoginService) AccountIdByUsername(organizationSlug string, username string) (int, error) {
+	organization, err := service.organizationBySlug(organizationSlug)
+	if err != nil {
+		return -1, err
+	}
+
+	account, err := service.accountRepo.GetAccountByUsername(tenantIdOf(organization), username)
+	if err != nil {
+		return -1, err
+	}
+
+	return account.Id, nil
+}
+
+func (service *LoginService) ExportAccount(id int) (AccountExport, error) {
+	account, err := service.accountRepo.GetAccountById(id)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	export := AccountExport{
+		ExportDate:     time.Now(),
+		Account:        newProfileResponse(account),
+		TrustedDevices: []TrustedDeviceResponse{},
+		Memberships:    []MembershipResponse{},
+		LoginHistory:   []LoginEventResponse{},
+		Consents:       []ConsentResponse{},
+		Identities:     []LinkedIdentityResponse{},
+		ApiKeys:        []ApiKeyResponse{},
+	}
+
+	if account.PhoneNumber != "" {
+		export.Mfa.Sms = &SmsFactorExport{
+			PhoneNumber: account.PhoneNumber,
+			Verified:    account.PhoneVerified,
+		}
+	}
+
+	if service.deviceRepo != nil {
+		devices, err := service.deviceRepo.GetTrustedDevices(id)
+		if err != nil {
+			return AccountExport{}, err
+		}
+
+		for _, device := range devices {
+			export.TrustedDevices = append(export.TrustedDevices, newTrustedDeviceResponse(device))
+		}
+	}
+
+	if service.orgRepo != nil {
+		memberships, err := service.orgRepo.GetMembershipsByAccount(id)
+		if err != nil {
+			return AccountExport{}, err
+		}
+
+		for _, membership := range memberships {
+			export.Memberships = append(export.Memberships, newMembershipResponse(membership))
+		}
+	}
+
+	if service.loginEventRepo != nil {
+		filter := repository.LoginEventFilter{AccountId: id, Limit: maxLoginEventPageSize}
+		for {
+			events, err := service.loginEventRepo.GetLoginEvents(filter)
+			if err != nil {
+				return AccountExport{}, err
+			}
+
+			for _, event := range events {
+				export.LoginHistory = append(export.LoginHistory, newLoginEventResponse(event))
+			}
+
+			if len(events) < filter.Limit {
+				break
+			}
+
+			filter.Offset += filter.Limit
+		}
+	}
+
+	if service.consentRepo != nil {
+		consents, err := service.consentRepo.GetConsentsByAccount(id)
+		if err != nil {
+			return AccountExport{}, err
+		}
+
+		for _, consent := range consents {
+			export.Consents = append(export.Consents, newConsentResponse(consent))
+		}
+	}
+
+	if service.linkedIdentityRepo != nil {
+		identities, err := service.linkedIdentityRepo.GetLinkedIdentitiesByAccount(id)
+		if err != nil {
+			return AccountExport{}, err
+		}
+
+		for _, identity := range identities {
+			export.Identities = append(export.Identities, newLinkedIdentityResponse(identity))
+		}
+	}
+
+	if service.apiKeyRepo != nil {
+		keys, err := service.apiKeyRepo.GetApiKeys(id)
+		if err != nil {
+			return AccountExport{}, err
+		}
+
+		for _, key := range keys {
+			export.ApiKeys = append(export.ApiKeys, newApiKeyResponse(key))
+		}
+	}
+
+	return export, nil
+}
+
+func WriteAccountExport(w io.Writer, export AccountExport, format string) error {
+	switch format {
+	case ExportFormatJson, "":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export)
+	case ExportFormatZip:
+		archive := zip.NewWriter(w)
+		file, err := archive.Create("account.json")
+		if err != nil {
+			return err
+		}
+
+		if err := WriteAccountExport(file, export, ExportFormatJson); err != nil {
+			return err
+		}
+
+		return archive.Close()
+	default:
+		return fmt.Errorf("unknown export format '%s'", format)
+	}
+}
+
+func (service *LoginService) AccountExportHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportFormatJson
+	}
+
+	if format != ExportFormatJson && format != ExportFormatZip {
+		sendSimpleResponse(w, http.StatusBadRequest, "Unknown export format.")
+		return
+	}
+
+	export, err := service.ExportAccount(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) export of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not export account.")
+		return
+	}
+
+	contentType := "application/json"
+	if format == ExportFormatZip {
+		contentType = "application/zip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"account-%d.%s\"", claims.UserId, format))
+	w.WriteHeader(http.StatusOK)
+
+	if err := WriteAccountExport(w, export, format); err != nil {
+		service.logger.Errorf("(%s) write export of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package loginservice
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccountIdByUsernameShouldSearchOrganizationTenant(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "alice").
+		Return(repository.Account{Id: 3, TenantId: 7, Username: "alice"}, nil)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	id, err := service.AccountIdByUsername("acme", "alice")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+}
+
+func TestAccountIdByUsernameShouldRejectUnknownOrganization(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{}, errors.New("not found"))
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	_, err := service.AccountIdByUsername("acme", "alice")
+
+	// then
+	assert.ErrorIs(t, err, errOrganizationNotFound)
+	mockedAccountRepo.AssertNotCalled(t, "GetAccountByUsername", mock.Anything, mock.Anything)
+}
+
+func TestAccountExportHandlerShouldExportJson(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: "hash", Email: "test@test.com", PhoneNumber: "+4912345", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedDeviceRepo := new(mocks.TrustedDeviceRepository)
+	mockedDeviceRepo.
+		On("GetTrustedDevices", 1).
+		Return([]repository.TrustedDevice{{Id: 1, Name: "laptop"}}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithTrustedDeviceRepository(mockedDeviceRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me/export", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	body := responseWriter.Body.String()
+	var export AccountExport
+	err := json.Unmarshal([]byte(body), &export)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "application/json", responseWriter.Header().Get("Content-Type"))
+	assert.NotContains(t, body, "hash")
+	assert.Equal(t, "testuser", export.Account.Username)
+	assert.Equal(t, "+4912345", export.Mfa.Sms.PhoneNumber)
+	assert.Len(t, export.TrustedDevices, 1)
+}
+
+func TestAccountExportHandlerShouldExportZip(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me/export?format=zip", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	body := responseWriter.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "application/zip", responseWriter.Header().Get("Content-Type"))
+	assert.Len(t, archive.File, 1)
+	assert.Equal(t, "account.json", archive.File[0].Name)
+
+	file, err := archive.File[0].Open()
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(file)
+	assert.Contains(t, string(content), "testuser")
+}
+
+func TestAccountExportHandlerShouldRejectUnknownFormat(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me/export?format=xml", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
@@ -0,0 +1,536 @@
+package loginservice
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flhansen/fitter-login-service/src/federation"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultFederationStateLifetime = 10 * time.Minute
+	federationNonceLength          = 32
+	federationCookieName           = "federation_nonce"
+	federationCookiePath           = "/api/auth"
+	maxFederatedUsernameAttempts   = 10
+)
+
+var (
+	errInvalidFederationState = errors.New("invalid federation state")
+	errFederatedEmailMissing  = errors.New("identity provider did not share an email address")
+	errFederatedEmailTaken    = errors.New("email address belongs to an existing account")
+)
+
+type federationState struct {
+	Purpose   string `json:"purpose"`
+	Provider  string `json:"provider"`
+	Nonce     string `json:"nonce"`
+	AccountId int    `json:"accountId,omitempty"`
+	jwt.StandardClaims
+}
+
+type LinkedIdentityResponse struct {
+	Provider     string    `json:"provider"`
+	Email        string    `json:"email"`
+	CreationDate time.Time `json:"creationDate"`
+}
+
+func WithLinkedIdentityRepository(linkedIdentityRepo repository.LinkedIdentityRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.linkedIdentityRepo = linkedIdentityRepo
+	}
+}
+
+func WithFederationProvider(provider federation.Provider) ServiceOption {
+	return func(service *LoginService) {
+		service.federationProviders[provider.Name()] = provider
+	}
+}
+
+func newFederationProviders(configs []federation.ProviderConfig) map[string]federation.Provider {
+	providers := map[string]federation.Provider{}
+	for _, config := range configs {
+		providers[config.Name] = federation.NewOidcProvider(config)
+	}
+
+	return providers
+}
+
+func (cfg FederationConfig) stateLifetime() time.Duration {
+	if cfg.StateLifetime <= 0 {
+		return defaultFederationStateLifetime
+	}
+
+	return cfg.StateLifetime
+}
+
+func newLinkedIdentityResponse(identity repository.LinkedIdentity) LinkedIdentityResponse {
+	return LinkedIdentityResponse{
+		Provider:     identity.Provider,
+		Email:        identity.Email,
+		CreationDate: identity.CreationDate,
+	}
+}
+
+func (service *LoginService) federationProvider(w http.ResponseWriter, name string) (federation.Provider, bool) {
+	if service.linkedIdentityRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Federated login is not available.")
+		return nil, false
+	}
+
+	provider, ok := service.federationProviders[name]
+	if !ok {
+		sendSimpleResponse(w, http.StatusNotFound, "Identity provider not found.")
+		return nil, false
+	}
+
+	return provider, true
+}
+
+func (service *LoginService) startFederation(w http.ResponseWriter, r *http.Request, provider federation.Provider, accountId int) (string, error) {
+	nonce, err := security.GenerateRandomString(federationNonceLength)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := service.signFederationState(provider.Name(), nonce, accountId)
+	if err != nil {
+		return "", err
+	}
+
+	authorizationUrl, err := provider.AuthorizationUrl(state, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationCookieName,
+		Value:    nonce,
+		Path:     federationCookiePath,
+		MaxAge:   int(service.config.Federation.stateLifetime().Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return authorizationUrl, nil
+}
+
+func (service *LoginService) signFederationState(providerName string, nonce string, accountId int) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, federationState{
+		Purpose:   security.TokenPurposeFederation,
+		Provider:  providerName,
+		Nonce:     nonce,
+		AccountId: accountId,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(service.config.Federation.stateLifetime()).Unix(),
+		},
+	}).SignedString([]byte(service.config.Jwt.SignKey))
+}
+
+func (service *LoginService) parseFederationState(r *http.Request, rawState string, providerName string) (federationState, error) {
+	state, err := service.parseFederationStateToken(rawState, providerName)
+	if err != nil {
+		return federationState{}, err
+	}
+
+	cookie, err := r.Cookie(federationCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		return federationState{}, errInvalidFederationState
+	}
+
+	return state, nil
+}
+
+func (service *LoginService) parseFederationStateToken(rawState string, providerName string) (federationState, error) {
+	var state federationState
+	token, err := jwt.ParseWithClaims(rawState, &state, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errInvalidFederationState
+		}
+
+		return []byte(service.config.Jwt.SignKey), nil
+	})
+	if err != nil || !token.Valid || state.Purpose != security.TokenPurposeFederation || state.Provider != providerName {
+		return federationState{}, errInvalidFederationState
+	}
+
+	return state, nil
+}
+
+func clearFederationCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationCookieName,
+		Path:     federationCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func (service *LoginService) federatedUsername(identity federation.Identity, email string) (string, string, error) {
+	candidate := identity.PreferredUsername
+	if candidate == "" {
+		candidate = email[:strings.LastIndex(email, "@")]
+	}
+
+	base, err := NormalizeUsername(candidate)
+	if err != nil {
+		if base, err = NormalizeUsername(identity.Provider + "-" + identity.Subject); err != nil {
+			return "", "", err
+		}
+	}
+
+	for attempt := 1; attempt <= maxFederatedUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 1 {
+			username = fmt.Sprintf("%s%d", base, attempt)
+		}
+
+		if _, err := service.accountRepo.GetAccountByUsername(0, username); err == nil {
+			continue
+		}
+
+		skeleton := UsernameSkeleton(username)
+		if _, err := service.accountRepo.GetAccountByUsernameSkeleton(0, skeleton); err == nil {
+			continue
+		}
+
+		return username, skeleton, nil
+	}
+
+	return "", "", errUsernameTaken
+}
+
+func (service *LoginService) linkIdentity(accountId int, identity federation.Identity) (repository.LinkedIdentity, error) {
+	linked := repository.LinkedIdentity{
+		AccountId:    accountId,
+		Provider:     identity.Provider,
+		Subject:      identity.Subject,
+		Email:        identity.Email,
+		CreationDate: time.Now(),
+	}
+
+	id, err := service.linkedIdentityRepo.CreateLinkedIdentity(linked)
+	linked.Id = id
+	return linked, err
+}
+
+func (service *LoginService) createFederatedAccount(r *http.Request, identity federation.Identity, email string) (repository.Account, error) {
+	if err := service.checkRegistrationPolicy(email, false); err != nil {
+		return repository.Account{}, err
+	}
+
+	username, skeleton, err := service.federatedUsername(identity, email)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	status := repository.AccountStatusActive
+	if service.config.Email.RequireVerification && !identity.EmailVerified {
+		status = repository.AccountStatusPending
+	}
+
+	now := time.Now()
+	account := repository.Account{
+		Username:         username,
+		UsernameSkeleton: skeleton,
+		Email:            email,
+		CreationDate:     now,
+		Status:           status,
+		Role:             security.RoleUser,
+	}
+
+	account.Id, err = service.accountRepo.CreateAccount(account)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	if identity.EmailVerified {
+		if err := service.accountRepo.UpdateAccountEmailVerified(account.Id, now); err != nil {
+			service.logger.Errorf("(%s) mark email of user '%s' as verified failed: %s", r.RemoteAddr, account.Username, err.Error())
+		} else {
+			account.EmailVerified = true
+			account.EmailVerifiedDate = &now
+		}
+	} else if service.mailer != nil {
+		if err := service.sendVerificationMail(account); err != nil {
+			service.logger.Errorf("(%s) send verification email to user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		}
+	}
+
+	if _, err := service.linkIdentity(account.Id, identity); err != nil {
+		if err := service.accountRepo.DeleteAccountById(account.Id); err != nil {
+			service.logger.Errorf("(%s) delete user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		}
+
+		return repository.Account{}, err
+	}
+
+	service.logger.Infof("(%s) user '%s' registered through identity provider '%s'", r.RemoteAddr, account.Username, identity.Provider)
+	return account, nil
+}
+
+func (service *LoginService) federatedAccount(r *http.Request, identity federation.Identity) (repository.Account, error) {
+	if linked, err := service.linkedIdentityRepo.GetLinkedIdentity(identity.Provider, identity.Subject); err == nil {
+		return service.accountRepo.GetAccountById(linked.AccountId)
+	}
+
+	if identity.Email == "" {
+		return repository.Account{}, errFederatedEmailMissing
+	}
+
+	email, err := NormalizeEmail(identity.Email)
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	existing, err := service.accountRepo.GetAccountByEmail(0, email)
+	if err != nil {
+		return service.createFederatedAccount(r, identity, email)
+	}
+
+	if !service.config.Federation.LinkVerifiedEmail || !identity.EmailVerified || !existing.EmailVerified {
+		return repository.Account{}, errFederatedEmailTaken
+	}
+
+	if _, err := service.linkIdentity(existing.Id, identity); err != nil {
+		return repository.Account{}, err
+	}
+
+	service.logger.Infof("(%s) identity of provider '%s' linked to user '%s' by verified email", r.RemoteAddr, identity.Provider, existing.Username)
+	return existing, nil
+}
+
+func sendFederationResponse(w http.ResponseWriter, err error) {
+	switch err {
+	case errFederatedEmailMissing:
+		sendSimpleResponse(w, http.StatusBadRequest, "Identity provider did not share an email address.")
+	case errFederatedEmailTaken:
+		sendSimpleResponse(w, http.StatusConflict, "An account with this email address already exists. Sign in and link the identity instead.")
+	case errRegistrationClosed, errInvitationRequired, errInvalidEmail, errEmailDomainNotAllowed, errDisposableEmail, errInvalidUsername:
+		sendRegistrationPolicyResponse(w, err)
+	default:
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not sign in with identity provider.")
+	}
+}
+
+func (service *LoginService) FederationProvidersHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	providers := []string{}
+	if service.linkedIdentityRepo != nil {
+		for name := range service.federationProviders {
+			providers = append(providers, name)
+		}
+	}
+	sort.Strings(providers)
+
+	sendResponse(w, http.StatusOK, "Identity providers found.", map[string]interface{}{
+		"providers": providers,
+	})
+}
+
+func (service *LoginService) FederationLoginHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	provider, ok := service.federationProvider(w, p.ByName("provider"))
+	if !ok {
+		return
+	}
+
+	authorizationUrl, err := service.startFederation(w, r, provider, 0)
+	if err != nil {
+		service.logger.Errorf("(%s) start login with identity provider '%s' failed: %s", r.RemoteAddr, provider.Name(), err.Error())
+		sendSimpleResponse(w, http.StatusBadGateway, "Identity provider not reachable.")
+		return
+	}
+
+	http.Redirect(w, r, authorizationUrl, http.StatusFound)
+}
+
+func (service *LoginService) FederationCallbackHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	provider, ok := service.federationProvider(w, p.ByName("provider"))
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	state, err := service.parseFederationState(r, query.Get("state"), provider.Name())
+	clearFederationCookie(w)
+	if err != nil {
+		service.logger.Warnf("(%s) callback of identity provider '%s' rejected: %s", r.RemoteAddr, provider.Name(), err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid federation state.")
+		return
+	}
+
+	methods := []string{security.AuthMethodFederated}
+	if upstreamError := query.Get("error"); upstreamError != "" {
+		service.logger.Warnf("(%s) identity provider '%s' returned error '%s'", r.RemoteAddr, provider.Name(), upstreamError)
+		service.recordLogin(r, state.AccountId, "", methods, loginFailureFederation)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Federated login failed.")
+		return
+	}
+
+	identity, err := provider.Exchange(query.Get("code"), state.Nonce)
+	if err != nil {
+		service.logger.Warnf("(%s) code exchange with identity provider '%s' failed: %s", r.RemoteAddr, provider.Name(), err.Error())
+		service.recordLogin(r, state.AccountId, "", methods, loginFailureFederation)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Federated login failed.")
+		return
+	}
+
+	if state.AccountId != 0 {
+		service.completeIdentityLink(w, r, state.AccountId, identity)
+		return
+	}
+
+	account, err := service.federatedAccount(r, identity)
+	if err != nil {
+		service.logger.Warnf("(%s) login with identity provider '%s' failed: %s", r.RemoteAddr, provider.Name(), err.Error())
+		sendFederationResponse(w, err)
+		return
+	}
+
+	service.completeLogin(w, r, account, loginCompletion{Methods: methods})
+}
+
+func (service *LoginService) completeIdentityLink(w http.ResponseWriter, r *http.Request, accountId int, identity federation.Identity) {
+	if linked, err := service.linkedIdentityRepo.GetLinkedIdentity(identity.Provider, identity.Subject); err == nil {
+		if linked.AccountId != accountId {
+			service.logger.Warnf("(%s) link of identity of provider '%s' to account %d rejected: linked to another account", r.RemoteAddr, identity.Provider, accountId)
+			sendSimpleResponse(w, http.StatusConflict, "Identity is already linked to another account.")
+			return
+		}
+
+		sendResponse(w, http.StatusOK, "Identity linked.", map[string]interface{}{
+			"identity": newLinkedIdentityResponse(linked),
+		})
+		return
+	}
+
+	identities, err := service.linkedIdentityRepo.GetLinkedIdentitiesByAccount(accountId)
+	if err != nil {
+		service.logger.Errorf("(%s) get linked identities of account %d failed: %s", r.RemoteAddr, accountId, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not link identity.")
+		return
+	}
+
+	for _, existing := range identities {
+		if existing.Provider == identity.Provider {
+			sendSimpleResponse(w, http.StatusConflict, "Another identity of this provider is already linked.")
+			return
+		}
+	}
+
+	linked, err := service.linkIdentity(accountId, identity)
+	if err != nil {
+		service.logger.Errorf("(%s) link identity of provider '%s' to account %d failed: %s", r.RemoteAddr, identity.Provider, accountId, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not link identity.")
+		return
+	}
+
+	service.logger.Infof("(%s) identity of provider '%s' linked to account %d", r.RemoteAddr, identity.Provider, accountId)
+	sendResponse(w, http.StatusOK, "Identity linked.", map[string]interface{}{
+		"identity": newLinkedIdentityResponse(linked),
+	})
+}
+
+func (service *LoginService) LinkedIdentitiesHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	if service.linkedIdentityRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Federated login is not available.")
+		return
+	}
+
+	identities, err := service.linkedIdentityRepo.GetLinkedIdentitiesByAccount(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get linked identities of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get linked identities.")
+		return
+	}
+
+	response := []LinkedIdentityResponse{}
+	for _, identity := range identities {
+		response = append(response, newLinkedIdentityResponse(identity))
+	}
+
+	sendResponse(w, http.StatusOK, "Linked identities found.", map[string]interface{}{
+		"identities": response,
+	})
+}
+
+func (service *LoginService) LinkIdentityHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	provider, ok := service.federationProvider(w, p.ByName("provider"))
+	if !ok {
+		return
+	}
+
+	authorizationUrl, err := service.startFederation(w, r, provider, claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) start link of user '%s' with identity provider '%s' failed: %s", r.RemoteAddr, claims.Username, provider.Name(), err.Error())
+		sendSimpleResponse(w, http.StatusBadGateway, "Identity provider not reachable.")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Continue at identity provider.", map[string]interface{}{
+		"authorizationUrl": authorizationUrl,
+	})
+}
+
+func (service *LoginService) UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+	providerName := p.ByName("provider")
+
+	if service.linkedIdentityRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Federated login is not available.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not unlink identity.")
+		return
+	}
+
+	identities, err := service.linkedIdentityRepo.GetLinkedIdentitiesByAccount(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get linked identities of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not unlink identity.")
+		return
+	}
+
+	linked := false
+	for _, identity := range identities {
+		if identity.Provider == providerName {
+			linked = true
+		}
+	}
+
+	if !linked {
+		sendSimpleResponse(w, http.StatusNotFound, "Linked identity not found.")
+		return
+	}
+
+	if account.Password == "" && len(identities) == 1 {
+		sendSimpleResponse(w, http.StatusBadRequest, "Cannot unlink the only sign-in method.")
+		return
+	}
+
+	if err := service.linkedIdentityRepo.DeleteLinkedIdentity(claims.UserId, providerName); err != nil {
+		service.logger.Errorf("(%s) unlink identity of provider '%s' from user '%s' failed: %s", r.RemoteAddr, providerName, claims.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not unlink identity.")
+		return
+	}
+
+	service.logger.Infof("(%s) user '%s' unlinked identity of provider '%s'", r.RemoteAddr, claims.Username, providerName)
+	sendSimpleResponse(w, http.StatusOK, "Identity unlinked.")
+}
@@ -0,0 +1,618 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/federation"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"flhansen/fitter-login-service/src/testhelper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var googleIdentity = federation.Identity{
+	Provider:          "google",
+	Subject:           "1234",
+	Email:             "alice@test.com",
+	EmailVerified:     true,
+	PreferredUsername: "alice",
+}
+
+func mockedFederationProvider(identity federation.Identity, err error) *mocks.Provider {
+	mockedProvider := new(mocks.Provider)
+	mockedProvider.
+		On("Name").
+		Return("google").
+		On("AuthorizationUrl", mock.Anything, mock.Anything).
+		Return("https://idp.test/authorize", nil).
+		On("Exchange", "code", "test-nonce").
+		Return(identity, err)
+
+	return mockedProvider
+}
+
+func authorizationUrlArguments(t *testing.T, mockedProvider *mocks.Provider) mock.Arguments {
+	for _, call := range mockedProvider.Calls {
+		if call.Method == "AuthorizationUrl" {
+			return call.Arguments
+		}
+	}
+
+	t.Fatal("authorization url was not requested")
+	return nil
+}
+
+func federationCallbackRequest(t *testing.T, accountId int, cookieNonce string) *http.Request {
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, federationState{
+		Purpose:   security.TokenPurposeFederation,
+		Provider:  "google",
+		Nonce:     "test-nonce",
+		AccountId: accountId,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "/api/auth/federation/google/callback?code=code&state="+url.QueryEscape(state), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookieNonce != "" {
+		request.AddCookie(&http.Cookie{Name: federationCookieName, Value: cookieNonce})
+	}
+
+	return request
+}
+
+func TestFederationProvidersHandlerShouldListConfiguredProviders(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/federation", nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"providers":["google"]`)
+}
+
+func TestFederationLoginHandlerShouldRedirectWithNonceCookie(t *testing.T) {
+	// given
+	mockedProvider := mockedFederationProvider(googleIdentity, nil)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithFederationProvider(mockedProvider))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/federation/google/login", nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusFound, responseWriter.Code)
+	assert.Equal(t, "https://idp.test/authorize", responseWriter.Header().Get("Location"))
+
+	cookies := responseWriter.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, federationCookieName, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	arguments := authorizationUrlArguments(t, mockedProvider)
+	parsed, err := service.parseFederationState(&http.Request{Header: http.Header{"Cookie": {cookies[0].String()}}}, arguments.String(0), "google")
+	assert.NoError(t, err)
+	assert.Equal(t, arguments.String(1), parsed.Nonce)
+	assert.Equal(t, 0, parsed.AccountId)
+}
+
+func TestFederationLoginHandlerShouldRejectUnknownProvider(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/federation/unknown/login", nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+}
+
+func TestFederationCallbackHandlerShouldRejectStateWithoutMatchingCookie(t *testing.T) {
+	// given
+	mockedProvider := mockedFederationProvider(googleIdentity, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithFederationProvider(mockedProvider))
+
+	for _, cookieNonce := range []string{"", "other-nonce"} {
+		// when
+		responseWriter := httptest.NewRecorder()
+		service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, cookieNonce))
+
+		// then
+		assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	}
+	mockedProvider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything)
+}
+
+func TestFederationCallbackHandlerShouldRejectFailedExchange(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithFederationProvider(mockedFederationProvider(federation.Identity{}, federation.ErrNonceMismatch)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+}
+
+func TestFederationCallbackHandlerShouldLoginLinkedAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", Role: security.RoleUser, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 3, Provider: "google", Subject: "1234"}, nil)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 3, claims.UserId)
+	assert.Equal(t, []string{security.AuthMethodFederated}, claims.AuthMethods)
+	mockedLinkedIdentityRepo.AssertNotCalled(t, "CreateLinkedIdentity", mock.Anything)
+}
+
+func TestFederationCallbackHandlerShouldRequireSecondFactorIfPhoneVerified(t *testing.T) {
+	// given
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("codehash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 3, Provider: "google", Subject: "1234"}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 3, otpPurposeLogin, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 3, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("CreateOneTimePassword", mock.Anything).
+		Return(1, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedSender.
+		On("SendMessage", mock.Anything).
+		Return(nil)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithMessageSender(mockedSender),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, true, response["mfaRequired"])
+	assert.Nil(t, response["token"])
+	claims, err := security.ParseToken(response["mfaToken"].(string), jwt.SigningMethodHS256, []byte("secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{security.AuthMethodFederated}, claims.AuthMethods)
+}
+
+func TestFederationCallbackHandlerShouldRequirePasswordReset(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", PasswordResetRequired: true, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 3}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) login of user '%s' requires password reset", mock.Anything, "alice")
+}
+
+func TestFederationCallbackHandlerShouldRejectSuspendedAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", Status: repository.AccountStatusSuspended}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 3}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestFederationCallbackHandlerShouldProvisionNewAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{Id: 1, Username: "alice"}, nil).
+		On("GetAccountByUsername", 0, "alice2").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "alice2").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "alice2" && account.Email == "alice@test.com" && account.Password == "" && account.Status == repository.AccountStatusActive
+		})).
+		Return(7, nil).
+		On("UpdateAccountEmailVerified", 7, mock.Anything).
+		Return(nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{}, errors.New("not found")).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(identity repository.LinkedIdentity) bool {
+			return identity.AccountId == 7 && identity.Provider == "google" && identity.Subject == "1234"
+		})).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 7, claims.UserId)
+	assert.Equal(t, "alice2", claims.Username)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountEmailVerified", 7, mock.Anything)
+}
+
+func TestFederationCallbackHandlerShouldRespectRegistrationPolicy(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{}, errors.New("user not found"))
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{}, errors.New("not found"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := LoginServiceConfig{
+		Jwt:          security.JwtConfig{SignKey: "secret"},
+		Registration: RegistrationConfig{Mode: RegistrationModeClosed},
+	}
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, "registration_closed", registrationErrorCode(t, responseWriter))
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestFederationCallbackHandlerShouldNotLinkExistingEmailByDefault(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{Id: 3, Username: "alice", EmailVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{}, errors.New("not found"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	mockedLinkedIdentityRepo.AssertNotCalled(t, "CreateLinkedIdentity", mock.Anything)
+}
+
+func TestFederationCallbackHandlerShouldLinkVerifiedEmailWhenEnabled(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{Id: 3, Username: "alice", EmailVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{}, errors.New("not found")).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(identity repository.LinkedIdentity) bool { return identity.AccountId == 3 })).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := LoginServiceConfig{
+		Jwt:        security.JwtConfig{SignKey: "secret"},
+		Federation: FederationConfig{LinkVerifiedEmail: true},
+	}
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 0, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, 3, tokenClaimsFromResponse(t, responseWriter).UserId)
+}
+
+func TestFederationCallbackHandlerShouldLinkIdentityToRequestingAccount(t *testing.T) {
+	// given
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{}, errors.New("not found")).
+		On("GetLinkedIdentitiesByAccount", 3).
+		Return([]repository.LinkedIdentity{{Provider: "github"}}, nil).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(identity repository.LinkedIdentity) bool { return identity.AccountId == 3 })).
+		Return(2, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 3, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"provider":"google"`)
+	assert.NotContains(t, responseWriter.Body.String(), `"token"`)
+}
+
+func TestFederationCallbackHandlerShouldRejectIdentityLinkedToAnotherAccount(t *testing.T) {
+	// given
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "google", "1234").
+		Return(repository.LinkedIdentity{AccountId: 4, Provider: "google", Subject: "1234"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithFederationProvider(mockedFederationProvider(googleIdentity, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, federationCallbackRequest(t, 3, "test-nonce"))
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	mockedLinkedIdentityRepo.AssertNotCalled(t, "CreateLinkedIdentity", mock.Anything)
+}
+
+func TestLinkIdentityHandlerShouldReturnAuthorizationUrl(t *testing.T) {
+	// given
+	mockedProvider := mockedFederationProvider(googleIdentity, nil)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, sessionAccountRepository(3), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithFederationProvider(mockedProvider))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/me/identities/google", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 3, "alice", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"authorizationUrl":"https://idp.test/authorize"`)
+
+	cookies := responseWriter.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	state, err := service.parseFederationState(&http.Request{Header: http.Header{"Cookie": {cookies[0].String()}}}, authorizationUrlArguments(t, mockedProvider).String(0), "google")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, state.AccountId)
+}
+
+func TestUnlinkIdentityHandlerShouldKeepLastSignInMethod(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentitiesByAccount", 3).
+		Return([]repository.LinkedIdentity{{AccountId: 3, Provider: "google"}}, nil)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/identities/google", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 3, "alice", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedLinkedIdentityRepo.AssertNotCalled(t, "DeleteLinkedIdentity", mock.Anything, mock.Anything)
+}
+
+func TestUnlinkIdentityHandlerShouldRemoveIdentity(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", Password: "hash", Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentitiesByAccount", 3).
+		Return([]repository.LinkedIdentity{{AccountId: 3, Provider: "google"}}, nil).
+		On("DeleteLinkedIdentity", 3, "google").
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{Jwt: security.JwtConfig{SignKey: "secret"}}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodDelete, "/api/auth/me/identities/google", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 3, "alice", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedLinkedIdentityRepo.AssertCalled(t, "DeleteLinkedIdentity", 3, "google")
+}
+
+func TestFederationShouldLoginAgainstOidcProvider(t *testing.T) {
+	// given
+	oidcProvider, err := testhelper.NewOidcProvider("fitter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oidcProvider.Close()
+	oidcProvider.SetUser(map[string]interface{}{"sub": "1234", "email": "alice@test.com", "email_verified": true})
+
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 3).
+		Return(repository.Account{Id: 3, Username: "alice", Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", "mock", "1234").
+		Return(repository.LinkedIdentity{AccountId: 3, Provider: "mock", Subject: "1234"}, nil)
+	config := LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+		Federation: FederationConfig{
+			Providers: []federation.ProviderConfig{{
+				Name:         "mock",
+				IssuerUrl:    oidcProvider.Issuer(),
+				ClientId:     "fitter",
+				ClientSecret: "secret",
+				RedirectUrl:  "http://localhost/api/auth/federation/mock/callback",
+			}},
+		},
+	}
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/federation/mock/login", nil)
+	loginWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(loginWriter, request)
+
+	authorizeResponse, err := client.Get(loginWriter.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizeResponse.Body.Close()
+
+	callbackUrl, _ := url.Parse(authorizeResponse.Header.Get("Location"))
+	callback, _ := http.NewRequest(http.MethodGet, callbackUrl.RequestURI(), nil)
+	for _, cookie := range loginWriter.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	callbackWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(callbackWriter, callback)
+
+	// then
+	assert.Equal(t, http.StatusFound, loginWriter.Code)
+	assert.Equal(t, http.StatusOK, callbackWriter.Code)
+	assert.Equal(t, 3, tokenClaimsFromResponse(t, callbackWriter).UserId)
+}
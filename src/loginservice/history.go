@@ -0,0 +1,276 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/repository"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultLoginEventPageSize = 20
+	maxLoginEventPageSize     = 100
+	maxUserAgentLength        = 512
+	maxLoginUsernameLength    = 255
+
+	loginFailureUnknownUser           = "unknown_user"
+	loginFailureWrongPassword         = "wrong_password"
+	loginFailureLocked                = "locked"
+	loginFailurePasswordResetRequired = "password_reset_required"
+	loginFailureEmailNotVerified      = "email_not_verified"
+	loginFailureInvalidOtp            = "invalid_otp"
+	loginFailureFederation            = "federation_failed"
+	loginFailureAuthenticator         = "authenticator_failed"
+)
+
+type LoginEventResponse struct {
+	Id            int       `json:"id"`
+	AccountId     int       `json:"accountId,omitempty"`
+	Username      string    `json:"username"`
+	Success       bool      `json:"success"`
+	Method        string    `json:"method"`
+	FailureReason string    `json:"failureReason,omitempty"`
+	IpAddress     string    `json:"ipAddress"`
+	UserAgent     string    `json:"userAgent"`
+	CreationDate  time.Time `json:"creationDate"`
+}
+
+func newLoginEventResponse(event repository.LoginEvent) LoginEventResponse {
+	return LoginEventResponse{
+		Id:            event.Id,
+		AccountId:     event.AccountId,
+		Username:      event.Username,
+		Success:       event.Success,
+		Method:        event.Method,
+		FailureReason: event.FailureReason,
+		IpAddress:     event.IpAddress,
+		UserAgent:     event.UserAgent,
+		CreationDate:  event.CreationDate,
+	}
+}
+
+func loginFailureReason(err error) string {
+	switch err {
+	case errAccountPending:
+		return "account_pending"
+	case errAccountSuspended:
+		return "account_suspended"
+	case errAccountDeleted:
+		return "account_deleted"
+	case errOrganizationNotFound:
+		return "organization_not_found"
+	case errNotOrganizationMember:
+		return "not_organization_member"
+	default:
+		return "error"
+	}
+}
+
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	proxies := []*net.IPNet{}
+	for _, value := range values {
+		cidr := value
+		if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+			cidr += "/32"
+		} else if ip != nil {
+			cidr += "/128"
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s'", value)
+		}
+
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+func (service *LoginService) isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range service.config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (service *LoginService) clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !service.isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		host = hop
+		if !service.isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return host
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+
+	return string(runes[:length])
+}
+
+func (service *LoginService) recordLogin(r *http.Request, accountId int, username string, methods []string, failureReason string) {
+	if service.loginEventRepo == nil {
+		return
+	}
+
+	_, err := service.loginEventRepo.CreateLoginEvent(repository.LoginEvent{
+		AccountId:     accountId,
+		Username:      truncate(username, maxLoginUsernameLength),
+		Success:       failureReason == "",
+		Method:        strings.Join(methods, "+"),
+		FailureReason: failureReason,
+		IpAddress:     service.clientIp(r),
+		UserAgent:     truncate(r.UserAgent(), maxUserAgentLength),
+		CreationDate:  time.Now(),
+	})
+	if err != nil {
+		service.logger.Errorf("(%s) record login of user '%s' failed: %s", r.RemoteAddr, username, err.Error())
+	}
+}
+
+func queryTime(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
+
+func loginEventFilterFromRequest(r *http.Request) (repository.LoginEventFilter, int, bool) {
+	query := r.URL.Query()
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil || page < 1 {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	pageSize, err := queryInt(r, "pageSize", defaultLoginEventPageSize)
+	if err != nil || pageSize < 1 || pageSize > maxLoginEventPageSize {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	accountId, err := queryInt(r, "accountId", 0)
+	if err != nil || accountId < 0 {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	from, err := queryTime(r, "from")
+	if err != nil {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	to, err := queryTime(r, "to")
+	if err != nil {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	filter := repository.LoginEventFilter{
+		AccountId: accountId,
+		Search:    query.Get("search"),
+		Result:    query.Get("result"),
+		From:      from,
+		To:        to,
+		Limit:     pageSize,
+		Offset:    (page - 1) * pageSize,
+	}
+
+	if filter.Result != "" && filter.Result != repository.LoginEventResultSuccess && filter.Result != repository.LoginEventResultFailure {
+		return repository.LoginEventFilter{}, 0, false
+	}
+
+	return filter, page, true
+}
+
+func (service *LoginService) sendLoginEvents(w http.ResponseWriter, r *http.Request, filter repository.LoginEventFilter, page int) {
+	response := []LoginEventResponse{}
+	total := 0
+	if service.loginEventRepo != nil {
+		events, err := service.loginEventRepo.GetLoginEvents(filter)
+		if err != nil {
+			service.logger.Errorf("(%s) list login events failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not list login events.")
+			return
+		}
+
+		total, err = service.loginEventRepo.CountLoginEvents(filter)
+		if err != nil {
+			service.logger.Errorf("(%s) count login events failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not list login events.")
+			return
+		}
+
+		for _, event := range events {
+			response = append(response, newLoginEventResponse(event))
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "Login events found.", map[string]interface{}{
+		"events":   response,
+		"page":     page,
+		"pageSize": filter.Limit,
+		"total":    total,
+	})
+}
+
+func (service *LoginService) LoginHistoryHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	filter, page, ok := loginEventFilterFromRequest(r)
+	if !ok {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid query parameters.")
+		return
+	}
+
+	filter.AccountId = claims.UserId
+	filter.Search = ""
+	service.sendLoginEvents(w, r, filter, page)
+}
+
+func (service *LoginService) AdminLoginEventsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	filter, page, ok := loginEventFilterFromRequest(r)
+	if !ok {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid query parameters.")
+		return
+	}
+
+	service.sendLoginEvents(w, r, filter, page)
+}
@@ -0,0 +1,261 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginHandlerShouldRecordSuccessfulLogin(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("CreateLoginEvent", mock.Anything).
+		Return(1, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	request := loginRequest(t, "testuser", "testpass")
+	request.RemoteAddr = "10.0.0.1:1234"
+	request.Header.Set("User-Agent", "fitter-app/1.0")
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedLoginEventRepo.AssertCalled(t, "CreateLoginEvent", mock.MatchedBy(func(event repository.LoginEvent) bool {
+		return event.AccountId == 1 && event.Success && event.Method == security.AuthMethodPassword &&
+			event.IpAddress == "10.0.0.1" && event.UserAgent == "fitter-app/1.0"
+	}))
+}
+
+func TestLoginHandlerShouldRecordUnknownUser(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "unknown").
+		Return(repository.Account{}, errors.New("not found"))
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("CreateLoginEvent", mock.Anything).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "unknown", "testpass"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLoginEventRepo.AssertCalled(t, "CreateLoginEvent", mock.MatchedBy(func(event repository.LoginEvent) bool {
+		return event.AccountId == 0 && event.Username == "unknown" && !event.Success && event.FailureReason == loginFailureUnknownUser
+	}))
+}
+
+func TestLoginHandlerShouldTruncateRecordedUsername(t *testing.T) {
+	// given
+	username := strings.Repeat("ü", maxLoginUsernameLength+10)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("not found"))
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("CreateLoginEvent", mock.Anything).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, username, "testpass"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLoginEventRepo.AssertCalled(t, "CreateLoginEvent", mock.MatchedBy(func(event repository.LoginEvent) bool {
+		return event.Username == strings.Repeat("ü", maxLoginUsernameLength) && utf8.ValidString(event.Username)
+	}))
+}
+
+func TestLoginHandlerShouldRecordWrongPassword(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil).
+		On("IncrementFailedLoginAttempts", 1).
+		Return(1, nil)
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("CreateLoginEvent", mock.Anything).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "wrongpass"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLoginEventRepo.AssertCalled(t, "CreateLoginEvent", mock.MatchedBy(func(event repository.LoginEvent) bool {
+		return event.AccountId == 1 && !event.Success && event.FailureReason == loginFailureWrongPassword
+	}))
+}
+
+func TestLoginOtpHandlerShouldRecordSecondFactorLogin(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeLogin).
+		Return(nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("CreateLoginEvent", mock.Anything).
+		Return(1, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedLoginEventRepo.AssertCalled(t, "CreateLoginEvent", mock.MatchedBy(func(event repository.LoginEvent) bool {
+		return event.AccountId == 1 && event.Success && event.Method == "pwd+otp"
+	}))
+}
+
+func TestLoginHistoryHandlerShouldOnlyReturnOwnEvents(t *testing.T) {
+	// given
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("GetLoginEvents", repository.LoginEventFilter{AccountId: 1, Result: repository.LoginEventResultFailure, Limit: 10, Offset: 10}).
+		Return([]repository.LoginEvent{{Id: 3, AccountId: 1, Username: "testuser", FailureReason: loginFailureWrongPassword}}, nil).
+		On("CountLoginEvents", mock.Anything).
+		Return(11, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me/logins?page=2&pageSize=10&result=failure&accountId=2&search=other", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"failureReason":"wrong_password"`)
+	assert.Contains(t, responseWriter.Body.String(), `"total":11`)
+}
+
+func TestAdminLoginEventsHandlerShouldFilterByAccountAndDate(t *testing.T) {
+	// given
+	from := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	mockedLoginEventRepo := new(mocks.LoginEventRepository)
+	mockedLoginEventRepo.
+		On("GetLoginEvents", mock.MatchedBy(func(filter repository.LoginEventFilter) bool {
+			return filter.AccountId == 2 && filter.From != nil && filter.From.Equal(from) && filter.To == nil
+		})).
+		Return([]repository.LoginEvent{}, nil).
+		On("CountLoginEvents", mock.Anything).
+		Return(0, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithLoginEventRepository(mockedLoginEventRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/logins?accountId=2&from=2022-07-01T00:00:00Z"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedLoginEventRepo.AssertExpectations(t)
+}
+
+func TestAdminLoginEventsHandlerShouldRejectInvalidResult(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithLoginEventRepository(new(mocks.LoginEventRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/logins?result=maybe"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestParseTrustedProxiesShouldAcceptAddressesAndNetworks(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+
+	assert.NoError(t, err)
+	assert.Len(t, proxies, 3)
+	assert.Equal(t, "192.0.2.1/32", proxies[1].String())
+	assert.Equal(t, "2001:db8::1/128", proxies[2].String())
+}
+
+func TestParseTrustedProxiesShouldRejectInvalidValue(t *testing.T) {
+	_, err := ParseTrustedProxies([]string{"proxy.internal"})
+
+	assert.EqualError(t, err, "invalid trusted proxy 'proxy.internal'")
+}
@@ -0,0 +1,123 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultHttpAuthenticatorTimeout = 10 * time.Second
+
+var (
+	errExternalUserNotFound       = errors.New("external user not found")
+	errExternalInvalidCredentials = errors.New("invalid external credentials")
+	errExternalInvalidResponse    = errors.New("external authentication response has no id")
+)
+
+type httpAuthenticationRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type httpAuthenticationResponse struct {
+	Id       string   `json:"id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Groups   []string `json:"groups"`
+}
+
+type httpAuthenticator struct {
+	service *LoginService
+	client  *http.Client
+}
+
+func newHttpAuthenticator(service *LoginService, config HttpAuthenticatorConfig) httpAuthenticator {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultHttpAuthenticatorTimeout
+	}
+
+	return httpAuthenticator{
+		service: service,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (authenticator httpAuthenticator) Name() string {
+	return httpAuthenticatorName
+}
+
+func (authenticator httpAuthenticator) Authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult {
+	service := authenticator.service
+	config := service.config.Authentication.Http
+	if config.Url == "" || request.TenantId != 0 || service.linkedIdentityRepo == nil || (request.Account != nil && request.Account.Password != "") {
+		return PassAuthentication()
+	}
+
+	user, err := authenticator.verify(config, request.Username, request.Password)
+	if err == errExternalUserNotFound {
+		return PassAuthentication()
+	}
+	if err != nil {
+		return RejectAuthentication(externalLoginFailureReason(err), err)
+	}
+
+	account, err := service.externalAccount(r, httpAuthenticatorName, request, user, config.AdminGroups)
+	if err != nil {
+		return RejectAuthentication(externalLoginFailureReason(err), err)
+	}
+
+	return AcceptAuthentication(account)
+}
+
+func (authenticator httpAuthenticator) verify(config HttpAuthenticatorConfig, username string, password string) (externalUser, error) {
+	body, err := json.Marshal(httpAuthenticationRequest{Username: username, Password: password})
+	if err != nil {
+		return externalUser{}, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, config.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return externalUser{}, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	if config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	response, err := authenticator.client.Do(request)
+	if err != nil {
+		return externalUser{}, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return externalUser{}, errExternalInvalidCredentials
+	case http.StatusNotFound:
+		return externalUser{}, errExternalUserNotFound
+	default:
+		return externalUser{}, fmt.Errorf("authentication service responded with status %d", response.StatusCode)
+	}
+
+	var result httpAuthenticationResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return externalUser{}, err
+	}
+
+	if result.Id == "" {
+		return externalUser{}, errExternalInvalidResponse
+	}
+
+	return externalUser{
+		Id:       result.Id,
+		Username: result.Username,
+		Email:    result.Email,
+		Groups:   result.Groups,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package loginservice
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAuthenticationServer(t *testing.T, status int, response httpAuthenticationResponse) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request httpAuthenticationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.Header.Get("Authorization") != "Bearer service-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if request.Username != "alice" || request.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func httpAuthenticatorConfig(url string) HttpAuthenticatorConfig {
+	return HttpAuthenticatorConfig{Url: url, Token: "service-token", AdminGroups: []string{"fitter-admins"}}
+}
+
+func TestHttpAuthenticatorShouldVerifyCredentials(t *testing.T) {
+	server := newAuthenticationServer(t, http.StatusOK, httpAuthenticationResponse{Id: "u-1", Username: "alice", Email: "alice@test.com", Groups: []string{"staff"}})
+	authenticator := newHttpAuthenticator(nil, HttpAuthenticatorConfig{})
+
+	user, err := authenticator.verify(httpAuthenticatorConfig(server.URL), "alice", "secret")
+
+	assert.NoError(t, err)
+	assert.Equal(t, externalUser{Id: "u-1", Username: "alice", Email: "alice@test.com", Groups: []string{"staff"}}, user)
+}
+
+func TestHttpAuthenticatorShouldMapResponseStatus(t *testing.T) {
+	cases := map[string]struct {
+		status   int
+		password string
+		err      error
+	}{
+		"unauthorized": {status: http.StatusOK, password: "wrong", err: errExternalInvalidCredentials},
+		"not found":    {status: http.StatusNotFound, password: "secret", err: errExternalUserNotFound},
+		"missing id":   {status: http.StatusOK, password: "secret", err: errExternalInvalidResponse},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := newAuthenticationServer(t, testCase.status, httpAuthenticationResponse{})
+			authenticator := newHttpAuthenticator(nil, HttpAuthenticatorConfig{})
+
+			_, err := authenticator.verify(httpAuthenticatorConfig(server.URL), "alice", testCase.password)
+
+			assert.Equal(t, testCase.err, err)
+		})
+	}
+}
+
+func TestHttpAuthenticatorShouldFailOnServerError(t *testing.T) {
+	server := newAuthenticationServer(t, http.StatusInternalServerError, httpAuthenticationResponse{})
+	authenticator := newHttpAuthenticator(nil, HttpAuthenticatorConfig{})
+
+	_, err := authenticator.verify(httpAuthenticatorConfig(server.URL), "alice", "secret")
+
+	assert.Error(t, err)
+	assert.Equal(t, loginFailureAuthenticator, externalLoginFailureReason(err))
+}
+
+func TestLoginHandlerShouldProvisionAccountFromHttpAuthenticator(t *testing.T) {
+	// given
+	server := newAuthenticationServer(t, http.StatusOK, httpAuthenticationResponse{Id: "u-1", Username: "alice", Email: "alice@test.com", Groups: []string{"fitter-admins"}})
+	mockedAccountRepo := unknownAccountRepository("alice")
+	mockedAccountRepo.
+		On("GetAccountByUsernameSkeleton", 0, UsernameSkeleton("alice")).
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "alice" && account.Email == "alice@test.com"
+		})).
+		Return(6, nil).
+		On("UpdateAccountEmailVerified", 6, mock.Anything).
+		Return(nil).
+		On("UpdateAccountRole", 6, security.RoleAdmin).
+		Return(nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", httpAuthenticatorName, "u-1").
+		Return(repository.LinkedIdentity{}, sql.ErrNoRows).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(linked repository.LinkedIdentity) bool {
+			return linked.AccountId == 6 && linked.Provider == httpAuthenticatorName && linked.Subject == "u-1"
+		})).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := chainServiceConfig(localAuthenticatorName, httpAuthenticatorName)
+	config.Authentication.Http = httpAuthenticatorConfig(server.URL)
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 6, claims.UserId)
+	assert.Equal(t, security.RoleAdmin, claims.Role)
+	assert.Equal(t, httpAuthenticatorName, claims.Authenticator)
+}
+
+func TestLoginHandlerShouldReportHttpAuthenticatorFailure(t *testing.T) {
+	// given
+	server := newAuthenticationServer(t, http.StatusBadGateway, httpAuthenticationResponse{})
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := chainServiceConfig(httpAuthenticatorName)
+	config.Authentication.Http = httpAuthenticatorConfig(server.URL)
+	service := NewService(config, unknownAccountRepository("alice"), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	assert.Nil(t, service.unknownLogins.entries[loginAttemptKey{username: "alice"}])
+}
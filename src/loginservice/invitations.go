@@ -0,0 +1,340 @@
+package loginservice
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultInvitationLifetime = 7 * 24 * time.Hour
+	defaultInvitationUrl      = "/api/auth/invitation"
+)
+
+var (
+	errInvitationInvalid = errors.New("invitation is invalid or expired")
+)
+
+type InvitationRequest struct {
+	Email            string `json:"email"`
+	Role             string `json:"role"`
+	Organization     string `json:"organization,omitempty"`
+	OrganizationRole string `json:"organizationRole,omitempty"`
+}
+
+type InvitationResponse struct {
+	Id               int       `json:"id"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	Organization     string    `json:"organization,omitempty"`
+	OrganizationRole string    `json:"organizationRole,omitempty"`
+	InvitedBy        int       `json:"invitedBy,omitempty"`
+	CreationDate     time.Time `json:"creationDate"`
+	ExpirationDate   time.Time `json:"expirationDate"`
+}
+
+func newInvitationResponse(invitation repository.Invitation) InvitationResponse {
+	return InvitationResponse{
+		Id:               invitation.Id,
+		Email:            invitation.Email,
+		Role:             invitation.Role,
+		Organization:     invitation.OrganizationSlug,
+		OrganizationRole: invitation.OrganizationRole,
+		InvitedBy:        invitation.InvitedBy,
+		CreationDate:     invitation.CreationDate,
+		ExpirationDate:   invitation.ExpirationDate,
+	}
+}
+
+func (cfg EmailConfig) invitationLifetime() time.Duration {
+	if cfg.InvitationLifetime <= 0 {
+		return defaultInvitationLifetime
+	}
+
+	return cfg.InvitationLifetime
+}
+
+func (cfg EmailConfig) invitationLink(token string) string {
+	invitationUrl := cfg.InvitationUrl
+	if invitationUrl == "" {
+		invitationUrl = defaultInvitationUrl
+	}
+
+	return fmt.Sprintf("%s?token=%s", invitationUrl, url.QueryEscape(token))
+}
+
+func isInvitationPending(invitation repository.Invitation, now time.Time) bool {
+	return invitation.RevokedDate == nil && invitation.AcceptedDate == nil && now.Before(invitation.ExpirationDate)
+}
+
+func (service *LoginService) sendInvitationMail(invitation repository.Invitation) error {
+	if service.mailer == nil {
+		return errMailerUnavailable
+	}
+
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		Email:   invitation.Email,
+		Purpose: security.TokenPurposeInvitation,
+		StandardClaims: jwt.StandardClaims{
+			Id:        strconv.Itoa(invitation.Id),
+			IssuedAt:  invitation.CreationDate.Unix(),
+			ExpiresAt: invitation.ExpirationDate.Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil {
+		return err
+	}
+
+	invitedTo := "the fitter platform"
+	if invitation.OrganizationSlug != "" {
+		invitedTo = fmt.Sprintf("the organization '%s'", invitation.OrganizationSlug)
+	}
+
+	return service.mailer.SendMail(messaging.Mail{
+		To:      invitation.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hello,\n\nyou have been invited to join %s. Please open the following link to create your account:\n\n%s\n",
+			invitedTo, service.config.Email.invitationLink(token)),
+	})
+}
+
+func (service *LoginService) invitationFromToken(token string) (repository.Invitation, error) {
+	if service.invitationRepo == nil {
+		return repository.Invitation{}, errInvitationInvalid
+	}
+
+	claims, err := security.ParseToken(token, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil || claims.Purpose != security.TokenPurposeInvitation {
+		return repository.Invitation{}, errInvitationInvalid
+	}
+
+	id, err := strconv.Atoi(claims.Id)
+	if err != nil {
+		return repository.Invitation{}, errInvitationInvalid
+	}
+
+	invitation, err := service.invitationRepo.GetInvitationById(id)
+	if err != nil || invitation.Email != claims.Email || !isInvitationPending(invitation, time.Now()) {
+		return repository.Invitation{}, errInvitationInvalid
+	}
+
+	return invitation, nil
+}
+
+// applyInvitation grants the new account what its invitation promises.
+func (service *LoginService) applyInvitation(invitation repository.Invitation, accountId int) error {
+	if err := service.accountRepo.UpdateAccountEmailVerified(accountId, time.Now()); err != nil {
+		return err
+	}
+
+	if invitation.Role != "" && invitation.Role != security.RoleUser {
+		return service.accountRepo.UpdateAccountRole(accountId, invitation.Role)
+	}
+
+	return nil
+}
+
+// acceptInvitation marks the invitation as used. Registration does this last,
+// so a registration that fails earlier leaves the invitation pending.
+func (service *LoginService) acceptInvitation(invitation repository.Invitation, accountId int) error {
+	err := service.invitationRepo.AcceptInvitation(invitation.Id, accountId, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvitationInvalid
+	}
+
+	return err
+}
+
+func (service *LoginService) createInvitation(w http.ResponseWriter, r *http.Request, invitation repository.Invitation) {
+	claims := claimsFromRequest(r)
+
+	if service.invitationRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Invitations are not available.")
+		return
+	}
+
+	email, err := NormalizeEmail(invitation.Email)
+	if err != nil || !validateEmail(email) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid email address.")
+		return
+	}
+
+	now := time.Now()
+	invitation.Email = email
+	invitation.InvitedBy = claims.UserId
+	invitation.CreationDate = now
+	invitation.ExpirationDate = now.Add(service.config.Email.invitationLifetime())
+
+	id, err := service.invitationRepo.CreateInvitation(invitation)
+	if err != nil {
+		service.logger.Errorf("(%s) create invitation for '%s' failed: %s", r.RemoteAddr, invitation.Email, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not create invitation.")
+		return
+	}
+	invitation.Id = id
+
+	mailSent := true
+	if err := service.sendInvitationMail(invitation); err != nil {
+		service.logger.Errorf("(%s) send invitation %d failed: %s", r.RemoteAddr, id, err.Error())
+		mailSent = false
+	}
+
+	service.logger.Infof("(%s) invitation %d created by '%s'", r.RemoteAddr, id, claims.Username)
+	sendResponse(w, http.StatusOK, "Invitation created.", map[string]interface{}{
+		"invitationId":   id,
+		"expirationDate": invitation.ExpirationDate,
+		"mailSent":       mailSent,
+	})
+}
+
+func (service *LoginService) InvitationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	invitation, err := service.invitationFromToken(r.URL.Query().Get("token"))
+	if err != nil {
+		service.logger.Warnf("(%s) invalid invitation token", r.RemoteAddr)
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired invitation.")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Invitation found.", map[string]interface{}{
+		"invitation": newInvitationResponse(invitation),
+	})
+}
+
+func (service *LoginService) AdminCreateInvitationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if request.Role == "" {
+		request.Role = security.RoleUser
+	}
+
+	if request.Role != security.RoleUser && request.Role != security.RoleAdmin {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid role.")
+		return
+	}
+
+	invitation := repository.Invitation{
+		Email: request.Email,
+		Role:  request.Role,
+	}
+
+	if request.Organization != "" {
+		organization, err := service.organizationBySlug(request.Organization)
+		if err != nil {
+			sendSimpleResponse(w, http.StatusBadRequest, "Organization not found.")
+			return
+		}
+
+		if request.OrganizationRole == "" {
+			request.OrganizationRole = repository.MembershipRoleMember
+		}
+
+		if !isMembershipRole(request.OrganizationRole) {
+			sendSimpleResponse(w, http.StatusBadRequest, "Invalid membership role.")
+			return
+		}
+
+		invitation.OrganizationId = organization.Id
+		invitation.OrganizationSlug = organization.Slug
+		invitation.OrganizationRole = request.OrganizationRole
+	} else if request.OrganizationRole != "" {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid membership role.")
+		return
+	}
+
+	service.createInvitation(w, r, invitation)
+}
+
+func (service *LoginService) OrganizationInvitationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	organization, ok := service.organizationFromParams(w, r, p, repository.MembershipRoleOwner, repository.MembershipRoleAdmin)
+	if !ok {
+		return
+	}
+
+	var request InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if request.Role == "" {
+		request.Role = repository.MembershipRoleMember
+	}
+
+	if !isMembershipRole(request.Role) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid membership role.")
+		return
+	}
+
+	if !service.canGrantMembershipRole(r, organization.Id, request.Role) {
+		sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+		return
+	}
+
+	service.createInvitation(w, r, repository.Invitation{
+		Email:            request.Email,
+		Role:             security.RoleUser,
+		OrganizationId:   organization.Id,
+		OrganizationSlug: organization.Slug,
+		OrganizationRole: request.Role,
+	})
+}
+
+func (service *LoginService) AdminInvitationsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	response := []InvitationResponse{}
+	if service.invitationRepo != nil {
+		invitations, err := service.invitationRepo.GetPendingInvitations()
+		if err != nil {
+			service.logger.Errorf("(%s) list invitations failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not list invitations.")
+			return
+		}
+
+		for _, invitation := range invitations {
+			response = append(response, newInvitationResponse(invitation))
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "Invitations found.", map[string]interface{}{
+		"invitations": response,
+	})
+}
+
+func (service *LoginService) AdminRevokeInvitationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid invitation id.")
+		return
+	}
+
+	if service.invitationRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Invitations are not available.")
+		return
+	}
+
+	if err := service.invitationRepo.RevokeInvitation(id, time.Now()); err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Invitation not found.")
+		return
+	}
+
+	service.logger.Infof("(%s) invitation %d revoked by '%s'", r.RemoteAddr, id, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "Invitation revoked.")
+}
@@ -0,0 +1,357 @@
+package loginservice
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func invitationToken(t *testing.T, id string, email string, key string) string {
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		Email:   email,
+		Purpose: security.TokenPurposeInvitation,
+		StandardClaims: jwt.StandardClaims{
+			Id:        id,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func invitationRegisterRequest(t *testing.T, email string) *http.Request {
+	body := `{ "username": "staff", "password": "testpass", "email": "` + email + `", "invitationToken": "` + invitationToken(t, "3", "staff@test.com", "secret") + `" }`
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBufferString(body))
+	return request
+}
+
+func TestAdminCreateInvitationHandlerShouldSendInvitationMail(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("CreateInvitation", mock.MatchedBy(func(invitation repository.Invitation) bool {
+			return invitation.Email == "staff@test.com" && invitation.Role == security.RoleAdmin && invitation.InvitedBy == 1
+		})).
+		Return(3, nil)
+	mockedMailer := new(mocks.Mailer)
+	mockedMailer.
+		On("SendMail", mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{InvitationUrl: "https://fitter.test/invite"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger,
+		WithInvitationRepository(mockedInvitationRepo),
+		WithMailer(mockedMailer))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/invitations", bytes.NewBufferString(`{ "email": "staff@test.com", "role": "admin" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"mailSent":true`)
+	mockedMailer.AssertCalled(t, "SendMail", mock.MatchedBy(func(mail messaging.Mail) bool {
+		return mail.To == "staff@test.com" && strings.Contains(mail.Body, "https://fitter.test/invite?token=")
+	}))
+}
+
+func TestAdminCreateInvitationHandlerShouldRejectInvalidEmail(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithInvitationRepository(mockedInvitationRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/invitations", bytes.NewBufferString(`{ "email": "invalid" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedInvitationRepo.AssertNotCalled(t, "CreateInvitation", mock.Anything)
+}
+
+func TestOrganizationInvitationHandlerShouldRejectPlainMember(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("GetMembership", 7, 2).
+		Return(repository.Membership{OrganizationId: 7, AccountId: 2, Role: repository.MembershipRoleMember}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(2), new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo),
+		WithInvitationRepository(new(mocks.InvitationRepository)))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/organizations/acme/invitations", bytes.NewBufferString(`{ "email": "staff@test.com" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 2, "testuser", security.RoleUser, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestRegisterHandlerShouldAcceptInvitation(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("GetInvitationById", 3).
+		Return(repository.Invitation{Id: 3, Email: "staff@test.com", Role: security.RoleUser, OrganizationId: 7, OrganizationSlug: "acme", OrganizationRole: repository.MembershipRoleAdmin, ExpirationDate: time.Now().Add(time.Hour)}, nil).
+		On("AcceptInvitation", 3, 5, mock.Anything).
+		Return(nil)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("CreateMembership", mock.MatchedBy(func(membership repository.Membership) bool {
+			return membership.OrganizationId == 7 && membership.AccountId == 5 && membership.Role == repository.MembershipRoleAdmin
+		})).
+		Return(1, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "staff").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Email == "staff@test.com" && account.Status == repository.AccountStatusActive
+		})).
+		Return(5, nil).
+		On("UpdateAccountEmailVerified", 5, mock.Anything).
+		Return(nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedMailer := new(mocks.Mailer)
+	service := NewService(LoginServiceConfig{
+		Jwt:   security.JwtConfig{SignKey: "secret"},
+		Email: EmailConfig{RequireVerification: true},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo),
+		WithInvitationRepository(mockedInvitationRepo),
+		WithMailer(mockedMailer))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, ""))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedInvitationRepo.AssertExpectations(t)
+	mockedOrgRepo.AssertExpectations(t)
+	mockedAccountRepo.AssertExpectations(t)
+	mockedMailer.AssertNotCalled(t, "SendMail", mock.Anything)
+}
+
+func invitationRegistrationService(mockedInvitationRepo *mocks.InvitationRepository, mockedOrgRepo *mocks.OrganizationRepository) (*LoginService, *mocks.AccountRepository) {
+	mockedInvitationRepo.
+		On("GetInvitationById", 3).
+		Return(repository.Invitation{Id: 3, Email: "staff@test.com", OrganizationId: 7, OrganizationSlug: "acme", OrganizationRole: repository.MembershipRoleMember, ExpirationDate: time.Now().Add(time.Hour)}, nil)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "staff").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(5, nil).
+		On("UpdateAccountEmailVerified", 5, mock.Anything).
+		Return(nil).
+		On("DeleteAccountById", 5).
+		Return(nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo),
+		WithInvitationRepository(mockedInvitationRepo))
+
+	return service, mockedAccountRepo
+}
+
+func TestRegisterHandlerShouldKeepInvitationPendingIfMembershipFails(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("CreateMembership", mock.Anything).
+		Return(0, errors.New("database error"))
+	service, mockedAccountRepo := invitationRegistrationService(mockedInvitationRepo, mockedOrgRepo)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, ""))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	mockedInvitationRepo.AssertNotCalled(t, "AcceptInvitation", mock.Anything, mock.Anything, mock.Anything)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccountById", 5)
+}
+
+func TestRegisterHandlerShouldRejectInvitationAcceptedConcurrently(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("AcceptInvitation", 3, 5, mock.Anything).
+		Return(sql.ErrNoRows)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("CreateMembership", mock.Anything).
+		Return(1, nil)
+	service, mockedAccountRepo := invitationRegistrationService(mockedInvitationRepo, mockedOrgRepo)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, ""))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccountById", 5)
+}
+
+func TestRegisterHandlerShouldFailIfAcceptingInvitationFails(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("AcceptInvitation", 3, 5, mock.Anything).
+		Return(errors.New("database error"))
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("CreateMembership", mock.Anything).
+		Return(1, nil)
+	service, mockedAccountRepo := invitationRegistrationService(mockedInvitationRepo, mockedOrgRepo)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, ""))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccountById", 5)
+}
+
+func TestRegisterHandlerShouldRejectInvitationWithDifferentEmail(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("GetInvitationById", 3).
+		Return(repository.Invitation{Id: 3, Email: "staff@test.com", ExpirationDate: time.Now().Add(time.Hour)}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithInvitationRepository(mockedInvitationRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, "other@test.com"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestRegisterHandlerShouldRejectRevokedInvitation(t *testing.T) {
+	// given
+	revokedDate := time.Now()
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("GetInvitationById", 3).
+		Return(repository.Invitation{Id: 3, Email: "staff@test.com", ExpirationDate: time.Now().Add(time.Hour), RevokedDate: &revokedDate}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithInvitationRepository(mockedInvitationRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, invitationRegisterRequest(t, "staff@test.com"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestAdminInvitationsHandlerShouldListPendingInvitations(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("GetPendingInvitations").
+		Return([]repository.Invitation{{Id: 3, Email: "staff@test.com", Role: security.RoleUser}}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithInvitationRepository(mockedInvitationRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodGet, "/api/admin/invitations"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), "staff@test.com")
+}
+
+func TestAdminRevokeInvitationHandlerShouldReturnNotFound(t *testing.T) {
+	// given
+	mockedInvitationRepo := new(mocks.InvitationRepository)
+	mockedInvitationRepo.
+		On("RevokeInvitation", 3, mock.Anything).
+		Return(errors.New("not found"))
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithInvitationRepository(mockedInvitationRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, adminRequest(t, http.MethodDelete, "/api/admin/invitations/3"))
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+}
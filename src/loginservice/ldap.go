@@ -0,0 +1,65 @@
+package loginservice
+
+import (
+	"errors"
+	"flhansen/fitter-login-service/src/directory"
+	"net/http"
+)
+
+type ldapAuthenticator struct {
+	service *LoginService
+}
+
+func WithDirectory(dir directory.Directory) ServiceOption {
+	return func(service *LoginService) {
+		service.directory = dir
+	}
+}
+
+func newDirectory(config directory.Config) directory.Directory {
+	if config.Url == "" {
+		return nil
+	}
+
+	return directory.NewLdapDirectory(config)
+}
+
+func (service *LoginService) directoryEnabled() bool {
+	return service.directory != nil && service.linkedIdentityRepo != nil
+}
+
+func (authenticator ldapAuthenticator) Name() string {
+	return ldapAuthenticatorName
+}
+
+func (authenticator ldapAuthenticator) Authenticate(r *http.Request, request AuthenticationRequest) AuthenticationResult {
+	service := authenticator.service
+	if request.TenantId != 0 || !service.directoryEnabled() || (request.Account != nil && request.Account.Password != "") {
+		return PassAuthentication()
+	}
+
+	entry, err := service.directory.Authenticate(request.Username, request.Password)
+	if errors.Is(err, directory.ErrUserNotFound) {
+		return PassAuthentication()
+	}
+	if err != nil {
+		result := RejectAuthentication(externalLoginFailureReason(err), err)
+		if request.Account == nil && entry.Id != "" {
+			result.Account, _ = service.linkedAccount(ldapAuthenticatorName, entry.Id)
+		}
+
+		return result
+	}
+
+	account, err := service.externalAccount(r, ldapAuthenticatorName, request, externalUser{
+		Id:       entry.Id,
+		Username: entry.Username,
+		Email:    entry.Email,
+		Groups:   entry.Groups,
+	}, service.config.Ldap.AdminGroups)
+	if err != nil {
+		return RejectAuthentication(externalLoginFailureReason(err), err)
+	}
+
+	return AcceptAuthentication(account)
+}
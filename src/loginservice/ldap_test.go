@@ -0,0 +1,311 @@
+package loginservice
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/directory"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var aliceDirectoryEntry = directory.Entry{
+	Id:       "uid=alice,ou=people,dc=fitter,dc=test",
+	Dn:       "uid=alice,ou=people,dc=fitter,dc=test",
+	Username: "alice",
+	Email:    "Alice@Fitter.test",
+	Groups:   []string{"cn=admins,ou=groups,dc=fitter,dc=test"},
+}
+
+func directoryServiceConfig() LoginServiceConfig {
+	return LoginServiceConfig{
+		Jwt:  security.JwtConfig{SignKey: "secret"},
+		Ldap: LdapConfig{AdminGroups: []string{"admins"}},
+	}
+}
+
+func mockedDirectory(entry directory.Entry, err error) *mocks.Directory {
+	mockedDirectory := new(mocks.Directory)
+	mockedDirectory.
+		On("Authenticate", "alice", "secret").
+		Return(entry, err)
+
+	return mockedDirectory
+}
+
+func TestLoginHandlerShouldProvisionDirectoryAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByUsernameSkeleton", 0, UsernameSkeleton("alice")).
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByEmail", 0, "alice@fitter.test").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "alice" && account.Email == "alice@fitter.test" && account.Password == "" && account.Status == repository.AccountStatusActive
+		})).
+		Return(5, nil).
+		On("UpdateAccountEmailVerified", 5, mock.Anything).
+		Return(nil).
+		On("UpdateAccountRole", 5, security.RoleAdmin).
+		Return(nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, aliceDirectoryEntry.Id).
+		Return(repository.LinkedIdentity{}, sql.ErrNoRows).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(linked repository.LinkedIdentity) bool {
+			return linked.AccountId == 5 && linked.Provider == ldapAuthenticatorName && linked.Subject == aliceDirectoryEntry.Id
+		})).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(aliceDirectoryEntry, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 5, claims.UserId)
+	assert.Equal(t, security.RoleAdmin, claims.Role)
+	assert.Equal(t, []string{security.AuthMethodPassword}, claims.AuthMethods)
+	mockedLinkedIdentityRepo.AssertExpectations(t)
+}
+
+func TestLoginHandlerShouldAuthenticateProvisionedAccountAgainstDirectory(t *testing.T) {
+	// given
+	entry := aliceDirectoryEntry
+	entry.Groups = []string{"cn=users,ou=groups,dc=fitter,dc=test"}
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{Id: 5, Username: "alice", Role: security.RoleAdmin, Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountRole", 5, security.RoleUser).
+		Return(nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, entry.Id).
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 5}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(entry, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 5, claims.UserId)
+	assert.Equal(t, security.RoleUser, claims.Role)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestLoginHandlerShouldRejectDirectoryEntryLinkedToOtherAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{Id: 5, Username: "alice", Status: repository.AccountStatusActive}, nil).
+		On("IncrementFailedLoginAttempts", 5).
+		Return(1, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, aliceDirectoryEntry.Id).
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 9}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(aliceDirectoryEntry, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Errorf", "(%s) wrong password: %s", mock.Anything, errExternalAccountMismatch.Error())
+}
+
+func TestLoginHandlerShouldRejectWrongDirectoryPassword(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithDirectory(mockedDirectory(directory.Entry{}, directory.ErrInvalidCredentials)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	assert.Equal(t, 1, service.unknownLogins.entries[loginAttemptKey{username: "alice"}].attempts)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestLoginHandlerShouldRecordWrongDirectoryPasswordOnLinkedAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountById", 5).
+		Return(repository.Account{Id: 5, Username: "alice.smith", Status: repository.AccountStatusActive}, nil).
+		On("IncrementFailedLoginAttempts", 5).
+		Return(1, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, aliceDirectoryEntry.Id).
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 5}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(aliceDirectoryEntry, directory.ErrInvalidCredentials)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "IncrementFailedLoginAttempts", 5)
+	assert.Nil(t, service.unknownLogins.entries[loginAttemptKey{username: "alice"}])
+}
+
+func TestLoginHandlerShouldRejectLockedLinkedAccount(t *testing.T) {
+	// given
+	lockedUntil := time.Now().Add(time.Hour)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountById", 5).
+		Return(repository.Account{Id: 5, Username: "alice.smith", LockedUntil: &lockedUntil, Status: repository.AccountStatusActive}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, aliceDirectoryEntry.Id).
+		Return(repository.LinkedIdentity{Id: 1, AccountId: 5}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(aliceDirectoryEntry, directory.ErrInvalidCredentials)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "IncrementFailedLoginAttempts", mock.Anything)
+}
+
+func TestLoginHandlerShouldFallBackToUnknownUserWhenNotInDirectory(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithDirectory(mockedDirectory(directory.Entry{}, directory.ErrUserNotFound)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) login of user '%s' failed", mock.Anything, "alice")
+}
+
+func TestLoginHandlerShouldRejectDirectoryAccountWithTakenEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByUsernameSkeleton", 0, UsernameSkeleton("alice")).
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByEmail", 0, "alice@fitter.test").
+		Return(repository.Account{Id: 2, Username: "alice.local"}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", ldapAuthenticatorName, aliceDirectoryEntry.Id).
+		Return(repository.LinkedIdentity{}, sql.ErrNoRows)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithDirectory(mockedDirectory(aliceDirectoryEntry, nil)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "alice", "secret"))
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestLoginHandlerShouldNotUseDirectoryForScopedOrganizationLogins(t *testing.T) {
+	// given
+	mockedDirectory := mockedDirectory(aliceDirectoryEntry, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "alice").
+		Return(repository.Account{}, sql.ErrNoRows)
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(directoryServiceConfig(), mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)),
+		WithDirectory(mockedDirectory))
+
+	// when
+	request := organizationLoginRequest(t, "alice", "acme")
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedDirectory.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,172 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultLockoutThreshold   = 5
+	defaultLockoutDuration    = time.Minute
+	defaultMaxLockoutDuration = 24 * time.Hour
+	maxTrackedUnknownAccounts = 10000
+)
+
+func (cfg LockoutConfig) threshold() int {
+	if cfg.Threshold <= 0 {
+		return defaultLockoutThreshold
+	}
+
+	return cfg.Threshold
+}
+
+func (cfg LockoutConfig) duration() time.Duration {
+	if cfg.Duration <= 0 {
+		return defaultLockoutDuration
+	}
+
+	return cfg.Duration
+}
+
+func (cfg LockoutConfig) maxDuration() time.Duration {
+	if cfg.MaxDuration <= 0 {
+		return defaultMaxLockoutDuration
+	}
+
+	return cfg.MaxDuration
+}
+
+func (cfg LockoutConfig) lockoutDuration(lockoutCount int) time.Duration {
+	duration := cfg.duration()
+	for i := 0; i < lockoutCount && duration < cfg.maxDuration(); i++ {
+		duration *= 2
+	}
+
+	if duration > cfg.maxDuration() {
+		return cfg.maxDuration()
+	}
+
+	return duration
+}
+
+func isLocked(account repository.Account, now time.Time) bool {
+	return account.LockedUntil != nil && now.Before(*account.LockedUntil)
+}
+
+func (service *LoginService) recordFailedLogin(account repository.Account) error {
+	attempts, err := service.accountRepo.IncrementFailedLoginAttempts(account.Id)
+	if err != nil {
+		return err
+	}
+
+	if attempts < service.config.Lockout.threshold() {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(service.config.Lockout.lockoutDuration(account.LockoutCount))
+	return service.accountRepo.LockAccount(account.Id, lockedUntil)
+}
+
+func (service *LoginService) resetFailedLogins(account repository.Account) error {
+	if account.FailedLoginAttempts == 0 && account.LockoutCount == 0 && account.LockedUntil == nil {
+		return nil
+	}
+
+	return service.accountRepo.ResetLoginAttempts(account.Id)
+}
+
+func sendLockedResponse(w http.ResponseWriter, lockedUntil time.Time) {
+	setRetryAfter(w, time.Until(lockedUntil))
+	sendSimpleResponse(w, http.StatusTooManyRequests, "Too many failed login attempts. Try again later.")
+}
+
+type trackedLoginAttempts struct {
+	attempts     int
+	lockoutCount int
+	lockedUntil  time.Time
+}
+
+type loginAttemptKey struct {
+	tenantId int
+	username string
+}
+
+type loginAttemptTracker struct {
+	mutex   sync.Mutex
+	entries map[loginAttemptKey]*trackedLoginAttempts
+}
+
+func newLoginAttemptTracker() *loginAttemptTracker {
+	return &loginAttemptTracker{
+		entries: map[loginAttemptKey]*trackedLoginAttempts{},
+	}
+}
+
+func (tracker *loginAttemptTracker) lockedUntil(tenantId int, username string, now time.Time) (time.Time, bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	entry, ok := tracker.entries[loginAttemptKey{tenantId: tenantId, username: username}]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return time.Time{}, false
+	}
+
+	return entry.lockedUntil, true
+}
+
+func (tracker *loginAttemptTracker) recordFailure(tenantId int, username string, cfg LockoutConfig, now time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	key := loginAttemptKey{tenantId: tenantId, username: username}
+	entry, ok := tracker.entries[key]
+	if !ok {
+		if len(tracker.entries) >= maxTrackedUnknownAccounts {
+			tracker.prune(now)
+		}
+
+		if len(tracker.entries) >= maxTrackedUnknownAccounts {
+			return
+		}
+
+		entry = &trackedLoginAttempts{}
+		tracker.entries[key] = entry
+	}
+
+	entry.attempts++
+	if entry.attempts >= cfg.threshold() {
+		entry.lockedUntil = now.Add(cfg.lockoutDuration(entry.lockoutCount))
+		entry.lockoutCount++
+		entry.attempts = 0
+	}
+}
+
+func (tracker *loginAttemptTracker) prune(now time.Time) {
+	for key, entry := range tracker.entries {
+		if !now.Before(entry.lockedUntil) {
+			delete(tracker.entries, key)
+		}
+	}
+}
+
+func (service *LoginService) UnlockAccountHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	if err := service.accountRepo.ResetLoginAttempts(account.Id); err != nil {
+		service.logger.Errorf("(%s) unlock of account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not unlock account.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d unlocked by '%s'", r.RemoteAddr, account.Id, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "Account unlocked.")
+}
@@ -0,0 +1,220 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func loginRequest(t *testing.T, username string, password string) *http.Request {
+	body, err := json.Marshal(UserLoginRequest{Username: username, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	return request
+}
+
+func TestLockoutDurationShouldIncreaseUpToMaximum(t *testing.T) {
+	cfg := LockoutConfig{Duration: time.Minute, MaxDuration: 10 * time.Minute}
+
+	assert.Equal(t, time.Minute, cfg.lockoutDuration(0))
+	assert.Equal(t, 2*time.Minute, cfg.lockoutDuration(1))
+	assert.Equal(t, 8*time.Minute, cfg.lockoutDuration(3))
+	assert.Equal(t, 10*time.Minute, cfg.lockoutDuration(4))
+	assert.Equal(t, 10*time.Minute, cfg.lockoutDuration(100))
+}
+
+func TestLoginHandlerShouldLockAccountAfterThreshold(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), LockoutCount: 1}, nil).
+		On("IncrementFailedLoginAttempts", 1).
+		Return(3, nil).
+		On("LockAccount", 1, mock.MatchedBy(func(lockedUntil time.Time) bool {
+			return lockedUntil.After(time.Now().Add(time.Minute)) && lockedUntil.Before(time.Now().Add(3*time.Minute))
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Lockout: LockoutConfig{Threshold: 3, Duration: time.Minute},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "wrongpass"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "LockAccount", 1, mock.Anything)
+}
+
+func TestLoginHandlerShouldRejectLockedAccount(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	lockedUntil := time.Now().Add(time.Minute)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), LockedUntil: &lockedUntil}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	assert.NotEmpty(t, responseWriter.Header().Get("Retry-After"))
+	assert.Nil(t, response["token"])
+}
+
+func TestLoginHandlerShouldResetFailedAttemptsOnSuccess(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	lockedUntil := time.Now().Add(-time.Minute)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), FailedLoginAttempts: 2, LockedUntil: &lockedUntil, Status: repository.AccountStatusActive}, nil).
+		On("ResetLoginAttempts", 1).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "ResetLoginAttempts", 1)
+}
+
+func TestLoginHandlerShouldLockUnknownAccountLikeExistingOne(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "unknown").
+		Return(repository.Account{}, errors.New("not found"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Lockout: LockoutConfig{Threshold: 2},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		responseWriter := httptest.NewRecorder()
+		service.handler.ServeHTTP(responseWriter, loginRequest(t, "unknown", "wrongpass"))
+		codes = append(codes, responseWriter.Code)
+	}
+
+	// then
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+}
+
+func TestLoginHandlerShouldTrackUnknownAccountsPerTenant(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "unknown").
+		Return(repository.Account{}, errors.New("not found")).
+		On("GetAccountByUsername", 7, "unknown").
+		Return(repository.Account{}, errors.New("not found"))
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Lockout: LockoutConfig{Threshold: 1},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	service.handler.ServeHTTP(httptest.NewRecorder(), organizationLoginRequest(t, "unknown", ""))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, organizationLoginRequest(t, "unknown", "acme"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	assert.NotNil(t, service.unknownLogins.entries[loginAttemptKey{username: "unknown"}])
+	assert.NotNil(t, service.unknownLogins.entries[loginAttemptKey{tenantId: 7, username: "unknown"}])
+}
+
+func TestUnlockAccountHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser"}, nil).
+		On("ResetLoginAttempts", 2).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/accounts/2/unlock", nil)
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "ResetLoginAttempts", 2)
+}
+
+func TestUnlockAccountHandlerShouldRequireAdminRole(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/accounts/2/unlock", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "ResetLoginAttempts", mock.Anything)
+}
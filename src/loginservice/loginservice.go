@@ -1,12 +1,20 @@
 package loginservice
 
 import (
+	"flhansen/fitter-login-service/src/directory"
+	"flhansen/fitter-login-service/src/federation"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/ratelimit"
 	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/saml"
 	"flhansen/fitter-login-service/src/security"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 type Logger interface {
@@ -15,31 +23,296 @@ type Logger interface {
 	Errorf(format string, v ...any)
 }
 
+type MfaConfig struct {
+	CodeLength            int
+	CodeLifetime          time.Duration
+	MaxAttempts           int
+	MaxSends              int
+	AttemptWindow         time.Duration
+	TrustedDeviceLifetime time.Duration
+}
+
+type EmailConfig struct {
+	VerificationUrl      string
+	VerificationLifetime time.Duration
+	ResendInterval       time.Duration
+	RequireVerification  bool
+	PasswordResetUrl     string
+	InvitationUrl        string
+	InvitationLifetime   time.Duration
+}
+
+type DeletionConfig struct {
+	GracePeriod   time.Duration
+	PurgeInterval time.Duration
+	Anonymize     bool
+}
+
+type LockoutConfig struct {
+	Threshold   int
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+type RegistrationConfig struct {
+	Mode              string
+	AllowedDomains    []string
+	BlockDisposable   bool
+	DisposableDomains []string
+}
+
+type AttributesConfig struct {
+	Schema string
+	Claims []string
+}
+
+type ScimToken struct {
+	Token        string
+	Organization string
+}
+
+type ScimConfig struct {
+	Token           string
+	Tokens          []ScimToken
+	AllowAdminGroup bool
+}
+
+type FederationConfig struct {
+	Providers         []federation.ProviderConfig
+	LinkVerifiedEmail bool
+	StateLifetime     time.Duration
+}
+
+type LdapConfig struct {
+	Directory   directory.Config
+	AdminGroups []string
+}
+
+type SamlConfig struct {
+	ServiceProvider saml.Config
+	AdminGroups     []string
+}
+
+type HttpAuthenticatorConfig struct {
+	Url         string
+	Token       string
+	Timeout     time.Duration
+	AdminGroups []string
+}
+
+type AuthenticationConfig struct {
+	Chain []string
+	Http  HttpAuthenticatorConfig
+}
+
+type ApiKeyConfig struct {
+	MaxLifetime time.Duration
+}
+
+type RateLimitConfig struct {
+	Disabled      bool
+	Routes        map[string]RouteRateLimit
+	PurgeInterval time.Duration
+	// FailClosed rejects requests while the store cannot decide. By default
+	// they are let through, so an outage of the store does not block logins.
+	FailClosed bool
+}
+
 type LoginServiceConfig struct {
-	Host string
-	Port int
-	Jwt  security.JwtConfig
+	Host           string
+	Port           int
+	Jwt            security.JwtConfig
+	Mfa            MfaConfig
+	Email          EmailConfig
+	Deletion       DeletionConfig
+	Lockout        LockoutConfig
+	Registration   RegistrationConfig
+	Attributes     AttributesConfig
+	Scim           ScimConfig
+	Federation     FederationConfig
+	Ldap           LdapConfig
+	Saml           SamlConfig
+	Authentication AuthenticationConfig
+	ApiKeys        ApiKeyConfig
+	RateLimit      RateLimitConfig
+	TrustedProxies []*net.IPNet
 }
 
 type LoginService struct {
-	handler     *httprouter.Router
-	config      LoginServiceConfig
-	accountRepo repository.AccountRepository
-	hashEngine  security.HashEngine
-	logger      Logger
+	handler             *httprouter.Router
+	config              LoginServiceConfig
+	accountRepo         repository.AccountRepository
+	otpRepo             repository.OneTimePasswordRepository
+	deviceRepo          repository.TrustedDeviceRepository
+	orgRepo             repository.OrganizationRepository
+	invitationRepo      repository.InvitationRepository
+	loginEventRepo      repository.LoginEventRepository
+	consentRepo         repository.ConsentRepository
+	linkedIdentityRepo  repository.LinkedIdentityRepository
+	apiKeyRepo          repository.ApiKeyRepository
+	hashEngine          security.HashEngine
+	messageSender       messaging.MessageSender
+	mailer              messaging.Mailer
+	logger              Logger
+	unknownLogins       *loginAttemptTracker
+	disposableDomains   map[string]bool
+	attributeSchema     *jsonschema.Schema
+	federationProviders map[string]federation.Provider
+	directory           directory.Directory
+	samlProvider        saml.ServiceProvider
+	samlAssertions      *samlAssertionCache
+	authenticators      map[string]Authenticator
+	authenticatorChain  []Authenticator
+	rateLimitStore      ratelimit.Store
+}
+
+type ServiceOption func(service *LoginService)
+
+func WithOneTimePasswordRepository(otpRepo repository.OneTimePasswordRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.otpRepo = otpRepo
+	}
+}
+
+func WithTrustedDeviceRepository(deviceRepo repository.TrustedDeviceRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.deviceRepo = deviceRepo
+	}
+}
+
+func WithOrganizationRepository(orgRepo repository.OrganizationRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.orgRepo = orgRepo
+	}
 }
 
-func NewService(cfg LoginServiceConfig, accountRepo repository.AccountRepository, hashEngine security.HashEngine, logger Logger) *LoginService {
+func WithInvitationRepository(invitationRepo repository.InvitationRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.invitationRepo = invitationRepo
+	}
+}
+
+func WithLoginEventRepository(loginEventRepo repository.LoginEventRepository) ServiceOption {
+	return func(service *LoginService) {
+		service.loginEventRepo = loginEventRepo
+	}
+}
+
+func WithMessageSender(messageSender messaging.MessageSender) ServiceOption {
+	return func(service *LoginService) {
+		service.messageSender = messageSender
+	}
+}
+
+func WithMailer(mailer messaging.Mailer) ServiceOption {
+	return func(service *LoginService) {
+		service.mailer = mailer
+	}
+}
+
+func NewService(cfg LoginServiceConfig, accountRepo repository.AccountRepository, hashEngine security.HashEngine, logger Logger, options ...ServiceOption) *LoginService {
 	service := &LoginService{
-		handler:     httprouter.New(),
-		config:      cfg,
-		hashEngine:  hashEngine,
-		accountRepo: accountRepo,
-		logger:      logger,
+		handler:             httprouter.New(),
+		config:              cfg,
+		hashEngine:          hashEngine,
+		accountRepo:         accountRepo,
+		messageSender:       messaging.NewLogSender(logger),
+		logger:              logger,
+		unknownLogins:       newLoginAttemptTracker(),
+		disposableDomains:   loadDisposableDomains(cfg.Registration.DisposableDomains),
+		federationProviders: newFederationProviders(cfg.Federation.Providers),
+		directory:           newDirectory(cfg.Ldap.Directory),
+		samlProvider:        newSamlServiceProvider(cfg.Saml.ServiceProvider, logger),
+		samlAssertions:      newSamlAssertionCache(),
+		rateLimitStore:      ratelimit.NewMemoryStore(),
+	}
+
+	service.authenticators = map[string]Authenticator{
+		localAuthenticatorName: localAuthenticator{},
+		ldapAuthenticatorName:  ldapAuthenticator{service: service},
+		httpAuthenticatorName:  newHttpAuthenticator(service, cfg.Authentication.Http),
+	}
+
+	if schema, err := CompileAttributeSchema(cfg.Attributes.Schema); err == nil {
+		service.attributeSchema = schema
+	} else {
+		logger.Errorf("compile attribute schema failed: %s", err.Error())
+	}
+
+	for _, option := range options {
+		option(service)
 	}
 
-	service.handler.POST("/api/auth/login", service.LoginHandler)
-	service.handler.POST("/api/auth/register", service.RegisterHandler)
+	service.authenticatorChain = service.newAuthenticatorChain()
+
+	service.handler.POST("/api/auth/login", service.rateLimited("/api/auth/login", service.LoginHandler))
+	service.handler.POST("/api/auth/login/otp", service.rateLimited("/api/auth/login/otp", service.LoginOtpHandler))
+	service.handler.POST("/api/auth/register", service.rateLimited("/api/auth/register", service.RegisterHandler))
+	service.handler.GET("/api/auth/email/confirm", service.EmailConfirmHandler)
+	service.handler.POST("/api/auth/email/resend", service.rateLimited("/api/auth/email/resend", service.EmailResendHandler))
+	service.handler.GET("/api/auth/me", service.authenticated(service.ProfileHandler))
+	service.handler.PATCH("/api/auth/me", service.sessionAuthenticated(service.ProfileUpdateHandler))
+	service.handler.GET("/api/auth/me/export", service.authenticated(service.AccountExportHandler))
+	service.handler.GET("/api/auth/me/logins", service.authenticated(service.LoginHistoryHandler))
+	service.handler.GET("/api/auth/me/identities", service.authenticated(service.LinkedIdentitiesHandler))
+	service.handler.POST("/api/auth/me/identities/:provider", service.sessionAuthenticated(service.LinkIdentityHandler))
+	service.handler.DELETE("/api/auth/me/identities/:provider", service.sessionAuthenticated(service.UnlinkIdentityHandler))
+	service.handler.GET("/api/auth/me/consents", service.authenticated(service.ConsentsHandler))
+	service.handler.POST("/api/auth/me/consents", service.authenticated(service.AcceptConsentHandler))
+	service.handler.POST("/api/auth/me/deletion", service.sessionAuthenticated(service.AccountDeletionHandler))
+	service.handler.DELETE("/api/auth/me/deletion", service.sessionAuthenticated(service.CancelAccountDeletionHandler))
+	service.handler.GET("/api/auth/me/api-keys", service.sessionAuthenticated(service.ApiKeysHandler))
+	service.handler.POST("/api/auth/me/api-keys", service.sessionAuthenticated(service.CreateApiKeyHandler))
+	service.handler.DELETE("/api/auth/me/api-keys/:id", service.sessionAuthenticated(service.RevokeApiKeyHandler))
+	service.handler.POST("/api/auth/phone", service.sessionAuthenticated(service.PhoneNumberHandler))
+	service.handler.POST("/api/auth/phone/verify", service.sessionAuthenticated(service.PhoneNumberVerifyHandler))
+	service.handler.GET("/api/auth/devices", service.authenticated(service.TrustedDevicesHandler))
+	service.handler.DELETE("/api/auth/devices/:id", service.sessionAuthenticated(service.RevokeTrustedDeviceHandler))
+	service.handler.POST("/api/auth/step-up", service.sessionAuthenticated(service.StepUpHandler))
+	service.handler.POST("/api/auth/step-up/otp", service.sessionAuthenticated(service.StepUpOtpHandler))
+	service.handler.POST("/api/auth/password/reset", service.rateLimited("/api/auth/password/reset", service.PasswordResetHandler))
+	service.handler.GET("/api/auth/invitation", service.InvitationHandler)
+	service.handler.GET("/api/auth/legal", service.LegalDocumentsHandler)
+	service.handler.GET("/api/auth/federation", service.FederationProvidersHandler)
+	service.handler.GET("/api/auth/federation/:provider/login", service.FederationLoginHandler)
+	service.handler.GET("/api/auth/federation/:provider/callback", service.FederationCallbackHandler)
+	service.handler.GET("/api/auth/saml/metadata", service.SamlMetadataHandler)
+	service.handler.GET("/api/auth/saml/login", service.SamlLoginHandler)
+	service.handler.POST("/api/auth/saml/acs", service.SamlAcsHandler)
+	service.handler.GET("/api/auth/organizations", service.authenticated(service.OrganizationsHandler))
+	service.handler.POST("/api/auth/organization", service.sessionAuthenticated(service.SwitchOrganizationHandler))
+	service.handler.GET("/api/organizations/:slug/members", service.authenticated(service.OrganizationMembersHandler))
+	service.handler.POST("/api/organizations/:slug/members", service.authenticated(service.AddOrganizationMemberHandler))
+	service.handler.PATCH("/api/organizations/:slug/members/:accountId", service.authenticated(service.UpdateOrganizationMemberHandler))
+	service.handler.DELETE("/api/organizations/:slug/members/:accountId", service.authenticated(service.RemoveOrganizationMemberHandler))
+	service.handler.POST("/api/organizations/:slug/invitations", service.authenticated(service.OrganizationInvitationHandler))
+	service.handler.GET("/api/admin/organizations", service.requireRole(security.RoleAdmin, service.AdminOrganizationsHandler))
+	service.handler.POST("/api/admin/organizations", service.requireRole(security.RoleAdmin, service.AdminCreateOrganizationHandler))
+	service.handler.GET("/api/admin/accounts", service.requireRole(security.RoleAdmin, service.AdminAccountsHandler))
+	service.handler.GET("/api/admin/accounts/:id", service.requireRole(security.RoleAdmin, service.AdminAccountHandler))
+	service.handler.DELETE("/api/admin/accounts/:id", service.requireRole(security.RoleAdmin, service.AdminDeleteAccountHandler))
+	service.handler.POST("/api/admin/accounts/:id/disable", service.requireRole(security.RoleAdmin, service.AdminDisableAccountHandler))
+	service.handler.POST("/api/admin/accounts/:id/enable", service.requireRole(security.RoleAdmin, service.AdminEnableAccountHandler))
+	service.handler.POST("/api/admin/accounts/:id/status", service.requireRole(security.RoleAdmin, service.AdminAccountStatusHandler))
+	service.handler.GET("/api/admin/accounts/:id/status", service.requireRole(security.RoleAdmin, service.AdminAccountStatusHistoryHandler))
+	service.handler.POST("/api/admin/accounts/:id/password-reset", service.requireRole(security.RoleAdmin, service.AdminPasswordResetHandler))
+	service.handler.POST("/api/admin/accounts/:id/unlock", service.requireRole(security.RoleAdmin, service.UnlockAccountHandler))
+	service.handler.GET("/api/admin/logins", service.requireRole(security.RoleAdmin, service.AdminLoginEventsHandler))
+	service.handler.GET("/api/admin/invitations", service.requireRole(security.RoleAdmin, service.AdminInvitationsHandler))
+	service.handler.POST("/api/admin/invitations", service.requireRole(security.RoleAdmin, service.AdminCreateInvitationHandler))
+	service.handler.DELETE("/api/admin/invitations/:id", service.requireRole(security.RoleAdmin, service.AdminRevokeInvitationHandler))
+	service.handler.GET("/api/admin/legal-documents", service.requireRole(security.RoleAdmin, service.AdminLegalDocumentsHandler))
+	service.handler.POST("/api/admin/legal-documents", service.requireRole(security.RoleAdmin, service.AdminPublishLegalDocumentHandler))
+	service.handler.GET("/scim/v2/ServiceProviderConfig", service.scimAuthenticated(service.ScimServiceProviderConfigHandler))
+	service.handler.GET("/scim/v2/Users", service.scimAuthenticated(service.ScimUsersHandler))
+	service.handler.POST("/scim/v2/Users", service.scimAuthenticated(service.ScimCreateUserHandler))
+	service.handler.GET("/scim/v2/Users/:id", service.scimAuthenticated(service.ScimUserHandler))
+	service.handler.PUT("/scim/v2/Users/:id", service.scimAuthenticated(service.ScimReplaceUserHandler))
+	service.handler.PATCH("/scim/v2/Users/:id", service.scimAuthenticated(service.ScimPatchUserHandler))
+	service.handler.DELETE("/scim/v2/Users/:id", service.scimAuthenticated(service.ScimDeleteUserHandler))
+	service.handler.GET("/scim/v2/Groups", service.scimAuthenticated(service.ScimGroupsHandler))
+	service.handler.GET("/scim/v2/Groups/:id", service.scimAuthenticated(service.ScimGroupHandler))
+	service.handler.PATCH("/scim/v2/Groups/:id", service.scimAuthenticated(service.ScimPatchGroupHandler))
 	return service
 }
 
@@ -50,3 +323,43 @@ func (service *LoginService) GetAddr() string {
 func (service *LoginService) Start() error {
 	return http.ListenAndServe(service.GetAddr(), service.handler)
 }
+
+func (cfg MfaConfig) codeLength() int {
+	if cfg.CodeLength <= 0 {
+		return 6
+	}
+
+	return cfg.CodeLength
+}
+
+func (cfg MfaConfig) codeLifetime() time.Duration {
+	if cfg.CodeLifetime <= 0 {
+		return 5 * time.Minute
+	}
+
+	return cfg.CodeLifetime
+}
+
+func (cfg MfaConfig) maxAttempts() int {
+	if cfg.MaxAttempts <= 0 {
+		return 5
+	}
+
+	return cfg.MaxAttempts
+}
+
+func (cfg MfaConfig) maxSends() int {
+	if cfg.MaxSends <= 0 {
+		return 5
+	}
+
+	return cfg.MaxSends
+}
+
+func (cfg MfaConfig) attemptWindow() time.Duration {
+	if cfg.AttemptWindow <= 0 {
+		return time.Hour
+	}
+
+	return cfg.AttemptWindow
+}
@@ -30,7 +30,19 @@ func (s *LoginServiceTestSuite) setupDatabase() {
 	preset := postgres.Preset(
 		postgres.WithUser("test", "test"),
 		postgres.WithDatabase("test"),
-		postgres.WithQueries(repository.QUERY_CREATE_ACCOUNT_TABLE))
+		postgres.WithQueries(
+			repository.QUERY_CREATE_ACCOUNT_TABLE,
+			repository.QUERY_CREATE_ONE_TIME_PASSWORD_TABLE,
+			repository.QUERY_CREATE_TRUSTED_DEVICE_TABLE,
+			repository.QUERY_CREATE_ACCOUNT_STATUS_TRANSITION_TABLE,
+			repository.QUERY_CREATE_ORGANIZATION_TABLE,
+			repository.QUERY_CREATE_MEMBERSHIP_TABLE,
+			repository.QUERY_CREATE_INVITATION_TABLE,
+			repository.QUERY_CREATE_LOGIN_EVENT_TABLE,
+			repository.QUERY_CREATE_LEGAL_DOCUMENT_TABLE,
+			repository.QUERY_CREATE_CONSENT_TABLE,
+			repository.QUERY_CREATE_LINKED_IDENTITY_TABLE,
+			repository.QUERY_CREATE_API_KEY_TABLE))
 	database, err := gnomock.Start(preset)
 	if err != nil {
 		s.T().Fatal(err)
@@ -0,0 +1,87 @@
+package loginservice
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	errInvalidUsername    = errors.New("invalid_username")
+	errUsernameConfusable = errors.New("username_confusable")
+	confusableRunes       = map[rune]rune{
+		'0': 'o',
+		'1': 'l',
+		'ı': 'i',
+		'ɑ': 'a',
+		'ɡ': 'g',
+		'ɩ': 'i',
+		'α': 'a',
+		'ι': 'i',
+		'κ': 'k',
+		'ν': 'v',
+		'ο': 'o',
+		'ρ': 'p',
+		'υ': 'u',
+		'ϲ': 'c',
+		'ϳ': 'j',
+		'а': 'a',
+		'е': 'e',
+		'к': 'k',
+		'о': 'o',
+		'р': 'p',
+		'с': 'c',
+		'у': 'y',
+		'х': 'x',
+		'һ': 'h',
+		'і': 'i',
+		'ј': 'j',
+		'ѕ': 's',
+		'ӏ': 'l',
+		'ԁ': 'd',
+		'ԛ': 'q',
+		'ԝ': 'w',
+	}
+)
+
+func NormalizeUsername(username string) (string, error) {
+	normalized, err := precis.UsernameCaseMapped.String(norm.NFKC.String(strings.TrimSpace(username)))
+	if err != nil || normalized == "" {
+		return "", errInvalidUsername
+	}
+
+	return normalized, nil
+}
+
+func NormalizeEmail(email string) (string, error) {
+	email = norm.NFKC.String(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", errInvalidEmail
+	}
+
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return "", errInvalidEmail
+	}
+
+	return cases.Fold().String(email[:at]) + "@" + strings.ToLower(domain), nil
+}
+
+func UsernameSkeleton(username string) string {
+	var builder strings.Builder
+	for _, r := range username {
+		if mapped, ok := confusableRunes[r]; ok {
+			r = mapped
+		}
+
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
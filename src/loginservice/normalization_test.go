@@ -0,0 +1,112 @@
+package loginservice
+
+import (
+	"bytes"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNormalizeUsernameShouldFoldCaseAndWidth(t *testing.T) {
+	for _, username := range []string{"alice", "Alice", "ＡＬＩＣＥ", " alice "} {
+		normalized, err := NormalizeUsername(username)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", normalized)
+	}
+}
+
+func TestNormalizeUsernameShouldRejectInvalidUsernames(t *testing.T) {
+	for _, username := range []string{"", "   ", "john doe", "a​b"} {
+		_, err := NormalizeUsername(username)
+
+		assert.Equal(t, errInvalidUsername, err)
+	}
+}
+
+func TestNormalizeEmailShouldFoldLocalPartAndEncodeDomain(t *testing.T) {
+	email, err := NormalizeEmail(" John.Doe@Bücher.Example ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "john.doe@xn--bcher-kva.example", email)
+}
+
+func TestNormalizeEmailShouldRejectMissingParts(t *testing.T) {
+	for _, email := range []string{"", "invalid", "@test.com", "test@"} {
+		_, err := NormalizeEmail(email)
+
+		assert.Equal(t, errInvalidEmail, err)
+	}
+}
+
+func TestUsernameSkeletonShouldMapConfusables(t *testing.T) {
+	assert.Equal(t, "alice", UsernameSkeleton("аlice"))
+	assert.Equal(t, "rnike", UsernameSkeleton("rnіke"))
+	assert.Equal(t, "pool", UsernameSkeleton("p00l"))
+}
+
+func TestUsernameSkeletonShouldKeepDistinctLatinNames(t *testing.T) {
+	assert.NotEqual(t, UsernameSkeleton("clara"), UsernameSkeleton("dara"))
+	assert.NotEqual(t, UsernameSkeleton("vvendy"), UsernameSkeleton("wendy"))
+	assert.NotEqual(t, UsernameSkeleton("rnike"), UsernameSkeleton("mike"))
+}
+
+func TestRegisterHandlerShouldRejectConfusableUsername(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "аlice").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "alice").
+		Return(repository.Account{Id: 1, Username: "alice"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := []byte(`{ "username": "аlice", "password": "testpass", "email": "alice@test.com" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	assert.Equal(t, "username_confusable", registrationErrorCode(t, responseWriter))
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestRegisterHandlerShouldStoreNormalizedIdentity(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "alice").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "alice").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "alice" && account.UsernameSkeleton == "alice" && account.Email == "alice@test.com"
+		})).
+		Return(1, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	body := []byte(`{ "username": "ALICE", "password": "testpass", "email": "Alice@TEST.com" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertExpectations(t)
+}
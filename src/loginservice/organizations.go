@@ -0,0 +1,529 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const maxOrganizationNameLength = 255
+
+var (
+	errOrganizationNotFound  = errors.New("organization not found")
+	errNotOrganizationMember = errors.New("not a member of the organization")
+	organizationSlugPattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,63}$`)
+	membershipRoles          = []string{repository.MembershipRoleOwner, repository.MembershipRoleAdmin, repository.MembershipRoleMember}
+)
+
+type OrganizationRequest struct {
+	Name             string `json:"name"`
+	Slug             string `json:"slug"`
+	ScopedIdentities bool   `json:"scopedIdentities"`
+	OpenRegistration bool   `json:"openRegistration"`
+}
+
+type SwitchOrganizationRequest struct {
+	Organization string `json:"organization"`
+}
+
+type MembershipRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type OrganizationResponse struct {
+	Id               int       `json:"id"`
+	Name             string    `json:"name"`
+	Slug             string    `json:"slug"`
+	ScopedIdentities bool      `json:"scopedIdentities"`
+	OpenRegistration bool      `json:"openRegistration"`
+	CreationDate     time.Time `json:"creationDate"`
+}
+
+type MembershipResponse struct {
+	OrganizationId   int       `json:"organizationId"`
+	OrganizationSlug string    `json:"organizationSlug"`
+	OrganizationName string    `json:"organizationName"`
+	AccountId        int       `json:"accountId"`
+	Username         string    `json:"username"`
+	Role             string    `json:"role"`
+	CreationDate     time.Time `json:"creationDate"`
+}
+
+func newOrganizationResponse(organization repository.Organization) OrganizationResponse {
+	return OrganizationResponse{
+		Id:               organization.Id,
+		Name:             organization.Name,
+		Slug:             organization.Slug,
+		ScopedIdentities: organization.ScopedIdentities,
+		OpenRegistration: organization.OpenRegistration,
+		CreationDate:     organization.CreationDate,
+	}
+}
+
+func newMembershipResponse(membership repository.Membership) MembershipResponse {
+	return MembershipResponse{
+		OrganizationId:   membership.OrganizationId,
+		OrganizationSlug: membership.OrganizationSlug,
+		OrganizationName: membership.OrganizationName,
+		AccountId:        membership.AccountId,
+		Username:         membership.Username,
+		Role:             membership.Role,
+		CreationDate:     membership.CreationDate,
+	}
+}
+
+func isMembershipRole(role string) bool {
+	for _, membershipRole := range membershipRoles {
+		if membershipRole == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tenantIdOf(organization *repository.Organization) int {
+	if organization == nil || !organization.ScopedIdentities {
+		return 0
+	}
+
+	return organization.Id
+}
+
+func (service *LoginService) organizationBySlug(slug string) (*repository.Organization, error) {
+	if slug == "" {
+		return nil, nil
+	}
+
+	if service.orgRepo == nil {
+		return nil, errOrganizationNotFound
+	}
+
+	organization, err := service.orgRepo.GetOrganizationBySlug(slug)
+	if err != nil {
+		return nil, errOrganizationNotFound
+	}
+
+	return &organization, nil
+}
+
+func (service *LoginService) membership(organizationId int, accountId int) (repository.Membership, error) {
+	if service.orgRepo == nil {
+		return repository.Membership{}, errNotOrganizationMember
+	}
+
+	membership, err := service.orgRepo.GetMembership(organizationId, accountId)
+	if err != nil {
+		return repository.Membership{}, errNotOrganizationMember
+	}
+
+	return membership, nil
+}
+
+func (service *LoginService) loginOrganizationId(account repository.Account, organization *repository.Organization) (int, error) {
+	if organization != nil {
+		if _, err := service.membership(organization.Id, account.Id); err != nil {
+			return 0, err
+		}
+
+		return organization.Id, nil
+	}
+
+	if account.TenantId != 0 {
+		return account.TenantId, nil
+	}
+
+	if service.orgRepo == nil {
+		return 0, nil
+	}
+
+	memberships, err := service.orgRepo.GetMembershipsByAccount(account.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(memberships) == 1 {
+		return memberships[0].OrganizationId, nil
+	}
+
+	return 0, nil
+}
+
+// isServiceAdmin reports whether the caller may manage every organization. API
+// keys of admins need the admin scope, as on the admin routes.
+func isServiceAdmin(claims *security.JwtClaims) bool {
+	return claims.Role == security.RoleAdmin && claims.HasScope(security.ScopeAdmin)
+}
+
+func (service *LoginService) organizationFromParams(w http.ResponseWriter, r *http.Request, p httprouter.Params, roles ...string) (repository.Organization, bool) {
+	claims := claimsFromRequest(r)
+
+	organization, err := service.organizationBySlug(p.ByName("slug"))
+	if err != nil || organization == nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Organization not found.")
+		return repository.Organization{}, false
+	}
+
+	if isServiceAdmin(claims) {
+		return *organization, true
+	}
+
+	membership, err := service.membership(organization.Id, claims.UserId)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Organization not found.")
+		return repository.Organization{}, false
+	}
+
+	if len(roles) == 0 {
+		return *organization, true
+	}
+
+	for _, role := range roles {
+		if membership.Role == role {
+			return *organization, true
+		}
+	}
+
+	service.logger.Warnf("(%s) user '%s' without organization role rejected", r.RemoteAddr, claims.Username)
+	sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+	return repository.Organization{}, false
+}
+
+func (service *LoginService) memberAccountIdFromParams(w http.ResponseWriter, r *http.Request, p httprouter.Params) (int, bool) {
+	accountId, err := strconv.Atoi(p.ByName("accountId"))
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid account id.")
+		return 0, false
+	}
+
+	if accountId == claimsFromRequest(r).UserId {
+		sendSimpleResponse(w, http.StatusBadRequest, "Members cannot change their own membership.")
+		return 0, false
+	}
+
+	return accountId, true
+}
+
+func (service *LoginService) canGrantMembershipRole(r *http.Request, organizationId int, role string) bool {
+	claims := claimsFromRequest(r)
+	if role != repository.MembershipRoleOwner || isServiceAdmin(claims) {
+		return true
+	}
+
+	membership, err := service.membership(organizationId, claims.UserId)
+	return err == nil && membership.Role == repository.MembershipRoleOwner
+}
+
+func (service *LoginService) OrganizationsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	response := []MembershipResponse{}
+	if service.orgRepo != nil {
+		memberships, err := service.orgRepo.GetMembershipsByAccount(claims.UserId)
+		if err != nil {
+			service.logger.Errorf("(%s) get memberships of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not get organizations.")
+			return
+		}
+
+		for _, membership := range memberships {
+			response = append(response, newMembershipResponse(membership))
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "Organizations found.", map[string]interface{}{
+		"organizations":        response,
+		"activeOrganizationId": claims.OrgId,
+	})
+}
+
+func (service *LoginService) SwitchOrganizationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request SwitchOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	organization, err := service.organizationBySlug(request.Organization)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Organization not found.")
+		return
+	}
+
+	organizationId := 0
+	if organization != nil {
+		organizationId = organization.Id
+	} else if account.TenantId != 0 {
+		sendSimpleResponse(w, http.StatusBadRequest, "Tenant accounts must select their organization.")
+		return
+	}
+
+	lifetime := security.DefaultTokenLifetime
+	if claims.ExpiresAt != 0 {
+		if remaining := time.Until(time.Unix(claims.ExpiresAt, 0)); remaining < lifetime {
+			lifetime = remaining
+		}
+	}
+
+	token, err := service.issueToken(account, organizationId, claims.AuthMethods, claims.Authenticator, time.Unix(claims.AuthTime, 0), lifetime)
+	if err != nil {
+		service.logger.Warnf("(%s) switch organization of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Organization switched.", map[string]interface{}{
+		"token": token,
+	})
+}
+
+func (service *LoginService) OrganizationMembersHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	organization, ok := service.organizationFromParams(w, r, p)
+	if !ok {
+		return
+	}
+
+	memberships, err := service.orgRepo.GetMembershipsByOrganization(organization.Id)
+	if err != nil {
+		service.logger.Errorf("(%s) get members of organization '%s' failed: %s", r.RemoteAddr, organization.Slug, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get organization members.")
+		return
+	}
+
+	response := []MembershipResponse{}
+	for _, membership := range memberships {
+		response = append(response, newMembershipResponse(membership))
+	}
+
+	sendResponse(w, http.StatusOK, "Organization members found.", map[string]interface{}{
+		"members": response,
+	})
+}
+
+func (service *LoginService) AddOrganizationMemberHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	organization, ok := service.organizationFromParams(w, r, p, repository.MembershipRoleOwner, repository.MembershipRoleAdmin)
+	if !ok {
+		return
+	}
+
+	var request MembershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if request.Role == "" {
+		request.Role = repository.MembershipRoleMember
+	}
+
+	if !isMembershipRole(request.Role) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid membership role.")
+		return
+	}
+
+	if !service.canGrantMembershipRole(r, organization.Id, request.Role) {
+		sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+		return
+	}
+
+	username, err := NormalizeUsername(request.Username)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountByUsername(tenantIdOf(&organization), username)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	if _, err := service.orgRepo.GetMembership(organization.Id, account.Id); err == nil {
+		sendSimpleResponse(w, http.StatusConflict, "Account is already a member.")
+		return
+	}
+
+	_, err = service.orgRepo.CreateMembership(repository.Membership{
+		OrganizationId: organization.Id,
+		AccountId:      account.Id,
+		Role:           request.Role,
+		CreationDate:   time.Now(),
+	})
+	if err != nil {
+		service.logger.Errorf("(%s) add account %d to organization '%s' failed: %s", r.RemoteAddr, account.Id, organization.Slug, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not add organization member.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d added to organization '%s' as '%s' by '%s'", r.RemoteAddr, account.Id, organization.Slug, request.Role, claims.Username)
+	sendResponse(w, http.StatusOK, "Organization member added.", map[string]interface{}{
+		"accountId": account.Id,
+	})
+}
+
+func (service *LoginService) UpdateOrganizationMemberHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	organization, ok := service.organizationFromParams(w, r, p, repository.MembershipRoleOwner, repository.MembershipRoleAdmin)
+	if !ok {
+		return
+	}
+
+	accountId, ok := service.memberAccountIdFromParams(w, r, p)
+	if !ok {
+		return
+	}
+
+	var request MembershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if !isMembershipRole(request.Role) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid membership role.")
+		return
+	}
+
+	membership, err := service.orgRepo.GetMembership(organization.Id, accountId)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Organization member not found.")
+		return
+	}
+
+	if !service.canGrantMembershipRole(r, organization.Id, request.Role) || !service.canGrantMembershipRole(r, organization.Id, membership.Role) {
+		sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+		return
+	}
+
+	if err := service.orgRepo.UpdateMembershipRole(organization.Id, accountId, request.Role); err != nil {
+		service.logger.Errorf("(%s) update role of account %d in organization '%s' failed: %s", r.RemoteAddr, accountId, organization.Slug, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not update organization member.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d in organization '%s' changed to '%s' by '%s'", r.RemoteAddr, accountId, organization.Slug, request.Role, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "Organization member updated.")
+}
+
+func (service *LoginService) RemoveOrganizationMemberHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	organization, ok := service.organizationFromParams(w, r, p, repository.MembershipRoleOwner, repository.MembershipRoleAdmin)
+	if !ok {
+		return
+	}
+
+	accountId, ok := service.memberAccountIdFromParams(w, r, p)
+	if !ok {
+		return
+	}
+
+	membership, err := service.orgRepo.GetMembership(organization.Id, accountId)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusNotFound, "Organization member not found.")
+		return
+	}
+
+	if !service.canGrantMembershipRole(r, organization.Id, membership.Role) {
+		sendSimpleResponse(w, http.StatusForbidden, "Insufficient permissions.")
+		return
+	}
+
+	if err := service.orgRepo.DeleteMembership(organization.Id, accountId); err != nil {
+		service.logger.Errorf("(%s) remove account %d from organization '%s' failed: %s", r.RemoteAddr, accountId, organization.Slug, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not remove organization member.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d removed from organization '%s' by '%s'", r.RemoteAddr, accountId, organization.Slug, claims.Username)
+	sendSimpleResponse(w, http.StatusOK, "Organization member removed.")
+}
+
+func (service *LoginService) AdminOrganizationsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	response := []OrganizationResponse{}
+	if service.orgRepo != nil {
+		organizations, err := service.orgRepo.GetOrganizations()
+		if err != nil {
+			service.logger.Errorf("(%s) list organizations failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not list organizations.")
+			return
+		}
+
+		for _, organization := range organizations {
+			response = append(response, newOrganizationResponse(organization))
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "Organizations found.", map[string]interface{}{
+		"organizations": response,
+	})
+}
+
+func (service *LoginService) AdminCreateOrganizationHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request OrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > maxOrganizationNameLength || !organizationSlugPattern.MatchString(request.Slug) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid organization.")
+		return
+	}
+
+	if service.orgRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "Organizations are not available.")
+		return
+	}
+
+	if _, err := service.orgRepo.GetOrganizationBySlug(request.Slug); err == nil {
+		sendSimpleResponse(w, http.StatusConflict, "Organization already exists.")
+		return
+	}
+
+	id, err := service.orgRepo.CreateOrganization(repository.Organization{
+		Name:             request.Name,
+		Slug:             request.Slug,
+		ScopedIdentities: request.ScopedIdentities,
+		OpenRegistration: request.OpenRegistration,
+		CreationDate:     time.Now(),
+	})
+	if err != nil {
+		service.logger.Errorf("(%s) create organization '%s' failed: %s", r.RemoteAddr, request.Slug, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not create organization.")
+		return
+	}
+
+	service.logger.Infof("(%s) organization '%s' created by '%s'", r.RemoteAddr, request.Slug, claims.Username)
+	sendResponse(w, http.StatusOK, "Organization created.", map[string]interface{}{
+		"organizationId": id,
+	})
+}
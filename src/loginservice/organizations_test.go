@@ -0,0 +1,431 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func organizationLoginRequest(t *testing.T, username string, organization string) *http.Request {
+	body, err := json.Marshal(UserLoginRequest{Username: username, Password: "testpass", Organization: organization})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	return request
+}
+
+func tokenClaimsFromResponse(t *testing.T, responseWriter *httptest.ResponseRecorder) *security.JwtClaims {
+	var response map[string]interface{}
+	if err := json.NewDecoder(responseWriter.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := response["token"].(string)
+	claims, err := security.ParseToken(token, jwt.SigningMethodHS256, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return claims
+}
+
+func TestLoginHandlerShouldLookUpAccountInScopedOrganization(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil).
+		On("GetMembership", 7, 2).
+		Return(repository.Membership{OrganizationId: 7, AccountId: 2, Role: repository.MembershipRoleAdmin}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "testuser").
+		Return(repository.Account{Id: 2, TenantId: 7, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, organizationLoginRequest(t, "testuser", "acme"))
+	claims := tokenClaimsFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, 7, claims.OrgId)
+	assert.Equal(t, repository.MembershipRoleAdmin, claims.OrgRole)
+}
+
+func TestLoginHandlerShouldSelectSingleMembership(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetMembershipsByAccount", 2).
+		Return([]repository.Membership{{OrganizationId: 3, AccountId: 2, Role: repository.MembershipRoleMember}}, nil).
+		On("GetMembership", 3, 2).
+		Return(repository.Membership{OrganizationId: 3, AccountId: 2, Role: repository.MembershipRoleMember}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 2, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, organizationLoginRequest(t, "testuser", ""))
+	claims := tokenClaimsFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, 3, claims.OrgId)
+	assert.Equal(t, repository.MembershipRoleMember, claims.OrgRole)
+}
+
+func TestLoginHandlerShouldRejectNonMember(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("GetMembership", 7, 2).
+		Return(repository.Membership{}, errors.New("not found"))
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 2, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, organizationLoginRequest(t, "testuser", "acme"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestSwitchOrganizationHandlerShouldIssueTokenForOrganization(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "globex").
+		Return(repository.Organization{Id: 4, Slug: "globex"}, nil).
+		On("GetMembership", 4, 2).
+		Return(repository.Membership{OrganizationId: 4, AccountId: 2, Role: repository.MembershipRoleOwner}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/organization", bytes.NewBufferString(`{ "organization": "globex" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 2, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, 4, claims.OrgId)
+	assert.Equal(t, repository.MembershipRoleOwner, claims.OrgRole)
+}
+
+func TestSwitchOrganizationHandlerShouldKeepExpiryOfPresentedToken(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "globex").
+		Return(repository.Organization{Id: 4, Slug: "globex"}, nil).
+		On("GetMembership", 4, 2).
+		Return(repository.Membership{OrganizationId: 4, AccountId: 2, Role: repository.MembershipRoleMember}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	expiresAt := time.Now().Add(10 * time.Minute).Unix()
+	token, _ := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:         2,
+		Username:       "testuser",
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expiresAt},
+	}, jwt.SigningMethodHS256, []byte("secret"))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/organization", bytes.NewBufferString(`{ "organization": "globex" }`))
+	request.Header.Set("Authorization", "Bearer "+token)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.LessOrEqual(t, claims.ExpiresAt, expiresAt)
+}
+
+func TestRegisterHandlerShouldCreateTenantAccount(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true, OpenRegistration: true}, nil).
+		On("CreateMembership", mock.MatchedBy(func(membership repository.Membership) bool {
+			return membership.OrganizationId == 7 && membership.AccountId == 5 && membership.Role == repository.MembershipRoleMember
+		})).
+		Return(1, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 7, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool { return account.TenantId == 7 })).
+		Return(5, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "test@test.com", "organization": "acme" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedOrgRepo.AssertExpectations(t)
+}
+
+func TestRegisterHandlerShouldRejectUnscopedOrganization(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil)
+	service := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "test@test.com", "organization": "acme" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestRegisterHandlerShouldRejectScopedOrganizationWithoutOpenRegistration(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "test@test.com", "organization": "acme" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestRegisterHandlerShouldDeleteAccountIfMembershipFails(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true, OpenRegistration: true}, nil).
+		On("CreateMembership", mock.Anything).
+		Return(0, errors.New("insert failed"))
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 7, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(5, nil).
+		On("DeleteAccountById", 5).
+		Return(nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "test@test.com", "organization": "acme" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccountById", 5)
+}
+
+func TestAddOrganizationMemberHandlerShouldRejectPlainMember(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("GetMembership", 7, 2).
+		Return(repository.Membership{OrganizationId: 7, AccountId: 2, Role: repository.MembershipRoleMember}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(2), new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/organizations/acme/members", bytes.NewBufferString(`{ "username": "other" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 2, "testuser", security.RoleUser, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	mockedOrgRepo.AssertNotCalled(t, "CreateMembership", mock.Anything)
+}
+
+func TestAddOrganizationMemberHandlerShouldRejectOwnerGrantByAdmin(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("GetMembership", 7, 2).
+		Return(repository.Membership{OrganizationId: 7, AccountId: 2, Role: repository.MembershipRoleAdmin}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(2), new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/organizations/acme/members", bytes.NewBufferString(`{ "username": "other", "role": "owner" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 2, "testuser", security.RoleUser, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestAddOrganizationMemberHandlerShouldLookUpTenantAccount(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil).
+		On("GetMembership", 7, 3).
+		Return(repository.Membership{}, errors.New("not found")).
+		On("CreateMembership", mock.Anything).
+		Return(1, nil)
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "other").
+		Return(repository.Account{Id: 3, TenantId: 7, Username: "other"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/organizations/acme/members", bytes.NewBufferString(`{ "username": "other" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedOrgRepo.AssertCalled(t, "CreateMembership", mock.Anything)
+}
+
+func TestOrganizationMembersHandlerShouldRequireAdminScopeForApiKey(t *testing.T) {
+	// given
+	account := repository.Account{Id: 1, Username: "admin", Role: security.RoleAdmin, Status: repository.AccountStatusActive}
+	service, _, _ := apiKeyService(account, []string{security.ScopeRead, security.ScopeWrite})
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil).
+		On("GetMembership", 7, 1).
+		Return(repository.Membership{}, errors.New("not found"))
+	WithOrganizationRepository(mockedOrgRepo)(service)
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/organizations/acme/members", nil)
+	request.Header.Set("Authorization", "Bearer "+testApiKey)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+	mockedOrgRepo.AssertNotCalled(t, "GetMembershipsByOrganization", mock.Anything)
+}
+
+func TestAdminCreateOrganizationHandlerShouldRejectInvalidSlug(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(new(mocks.OrganizationRepository)))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/organizations", bytes.NewBufferString(`{ "name": "Acme", "slug": "Acme Inc" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
@@ -0,0 +1,204 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	otpPurposeLogin             = "login"
+	otpPurposePhoneVerification = "phone_verification"
+
+	mfaTokenLifetime = 10 * time.Minute
+)
+
+var (
+	errOtpUnavailable      = errors.New("one-time passwords are not configured")
+	errOtpInvalid          = errors.New("one-time password is invalid")
+	errOtpExpired          = errors.New("one-time password is expired")
+	errOtpAttemptsExceeded = errors.New("too many attempts for one-time password")
+	errOtpSendsExceeded    = errors.New("too many one-time passwords requested")
+
+	errTrustedDevicesUnavailable = errors.New("trusted devices are not configured")
+)
+
+type UserLoginOtpRequest struct {
+	MfaToken       string `json:"mfaToken"`
+	Code           string `json:"code"`
+	RememberDevice bool   `json:"rememberDevice,omitempty"`
+	DeviceName     string `json:"deviceName,omitempty"`
+}
+
+func (service *LoginService) requiresSecondFactor(account repository.Account) bool {
+	return account.PhoneVerified && account.PhoneNumber != ""
+}
+
+func (service *LoginService) sendOneTimePassword(accountId int, phoneNumber string, channel string, purpose string) error {
+	if service.otpRepo == nil {
+		return errOtpUnavailable
+	}
+
+	if channel != messaging.ChannelVoice {
+		channel = messaging.ChannelSms
+	}
+
+	now := time.Now()
+	windowStart := now.Add(-service.config.Mfa.attemptWindow())
+	if err := service.otpRepo.DeleteOneTimePasswordsBefore(accountId, purpose, windowStart); err != nil {
+		return err
+	}
+
+	usage, err := service.otpRepo.GetOneTimePasswordUsage(accountId, purpose, windowStart)
+	if err != nil {
+		return err
+	}
+
+	if usage.Codes >= service.config.Mfa.maxSends() {
+		return errOtpSendsExceeded
+	}
+
+	code, err := security.GenerateNumericCode(service.config.Mfa.codeLength())
+	if err != nil {
+		return err
+	}
+
+	codeHash, err := service.hashEngine.HashPassword([]byte(code))
+	if err != nil {
+		return err
+	}
+
+	_, err = service.otpRepo.CreateOneTimePassword(repository.OneTimePassword{
+		AccountId:      accountId,
+		Purpose:        purpose,
+		CodeHash:       string(codeHash),
+		ExpirationDate: now.Add(service.config.Mfa.codeLifetime()),
+		CreationDate:   now,
+	})
+	if err != nil {
+		return err
+	}
+
+	return service.messageSender.SendMessage(messaging.Message{
+		Channel: channel,
+		To:      phoneNumber,
+		Body:    fmt.Sprintf("Your Fitter verification code is %s.", code),
+	})
+}
+
+func sendOneTimePasswordErrorResponse(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errOtpSendsExceeded) {
+		sendSimpleResponse(w, http.StatusTooManyRequests, "Too many one-time passwords requested. Try again later.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusInternalServerError, message)
+}
+
+func (service *LoginService) verifyOneTimePassword(accountId int, purpose string, code string) error {
+	if service.otpRepo == nil {
+		return errOtpUnavailable
+	}
+
+	otp, err := service.otpRepo.GetLatestOneTimePassword(accountId, purpose)
+	if err != nil {
+		return errOtpInvalid
+	}
+
+	if time.Now().After(otp.ExpirationDate) {
+		return errOtpExpired
+	}
+
+	usage, err := service.otpRepo.GetOneTimePasswordUsage(accountId, purpose, time.Now().Add(-service.config.Mfa.attemptWindow()))
+	if err != nil {
+		return err
+	}
+
+	if otp.Attempts >= service.config.Mfa.maxAttempts() || usage.Attempts >= service.config.Mfa.maxAttempts() {
+		return errOtpAttemptsExceeded
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(otp.CodeHash), []byte(code)); err != nil {
+		if err := service.otpRepo.IncrementOneTimePasswordAttempts(otp.Id); err != nil {
+			return err
+		}
+
+		return errOtpInvalid
+	}
+
+	return service.otpRepo.DeleteOneTimePasswords(accountId, purpose)
+}
+
+func (service *LoginService) LoginOtpHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request UserLoginOtpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	claims, err := security.ParseToken(request.MfaToken, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil || claims.Purpose != security.TokenPurposeMfa {
+		service.logger.Warnf("(%s) invalid mfa token", r.RemoteAddr)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	methods := append([]string{}, claims.AuthMethods...)
+	if len(methods) == 0 {
+		methods = []string{security.AuthMethodPassword}
+	}
+	methods = append(methods, security.AuthMethodOtp)
+	if err := service.verifyOneTimePassword(claims.UserId, otpPurposeLogin, request.Code); err != nil {
+		service.logger.Warnf("(%s) second factor of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		service.recordLogin(r, claims.UserId, claims.Username, methods, loginFailureInvalidOtp)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	token, err := service.issueToken(account, claims.OrgId, methods, claims.Authenticator, time.Now(), security.DefaultTokenLifetime)
+	if err != nil {
+		service.logger.Errorf("(%s) generate token for user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		service.recordLogin(r, account.Id, account.Username, methods, loginFailureReason(err))
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	service.recordLogin(r, account.Id, account.Username, methods, "")
+	props := map[string]interface{}{
+		"token": token,
+	}
+	service.addConsentProps(r, account.Id, props)
+
+	if request.RememberDevice {
+		deviceName := request.DeviceName
+		if deviceName == "" {
+			deviceName = r.UserAgent()
+		}
+
+		deviceToken, err := service.trustDevice(w, claims.UserId, claims.Username, deviceName)
+		if err != nil {
+			service.logger.Errorf("(%s) trust device of user '%s' failed: %s", r.RemoteAddr, claims.Username, err.Error())
+		} else {
+			props["deviceToken"] = deviceToken
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "User login successful.", props)
+}
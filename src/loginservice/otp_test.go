@@ -0,0 +1,322 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginHandlerShouldRequireSecondFactorIfPhoneVerified(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("codehash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 1, otpPurposeLogin, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("CreateOneTimePassword", mock.Anything).
+		Return(1, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedSender.
+		On("SendMessage", mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithMessageSender(mockedSender))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "otpChannel": "voice" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, true, response["mfaRequired"])
+	assert.NotNil(t, response["mfaToken"])
+	assert.Nil(t, response["token"])
+	mockedSender.AssertCalled(t, "SendMessage", mock.MatchedBy(func(message messaging.Message) bool {
+		return message.Channel == messaging.ChannelVoice && message.To == "+4915112345678"
+	}))
+}
+
+func TestLoginHandlerShouldReturnErrorIfOneTimePasswordCouldNotBeSent(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Errorf", "(%s) send one-time password to user '%s' failed: %s", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLoginOtpHandlerSucceeded(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeLogin).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotNil(t, response["token"])
+	mockedOtpRepo.AssertCalled(t, "DeleteOneTimePasswords", 1, otpPurposeLogin)
+}
+
+func TestLoginOtpHandlerShouldKeepMethodsOfMfaToken(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeLogin).
+		Return(nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	mfaToken, _ := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:      1,
+		Username:    "testuser",
+		Purpose:     security.TokenPurposeMfa,
+		AuthMethods: []string{security.AuthMethodFederated},
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, []string{security.AuthMethodFederated, security.AuthMethodOtp}, claims.AuthMethods)
+}
+
+func TestLoginOtpHandlerShouldRejectWrongCode(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 7, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("IncrementOneTimePasswordAttempts", 7).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "654321"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedOtpRepo.AssertCalled(t, "IncrementOneTimePasswordAttempts", 7)
+}
+
+func TestLoginOtpHandlerShouldRejectExpiredCode(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(-time.Minute)}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) second factor of user '%s' failed: %s", mock.Anything, "testuser", errOtpExpired.Error())
+}
+
+func TestLoginOtpHandlerShouldRejectCodeAfterAttemptsInWindow(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeLogin).
+		Return(repository.OneTimePassword{Id: 9, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{Codes: 3, Attempts: 5}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	mfaToken, _ := security.GeneratePurposeToken(1, "testuser", security.TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: mfaToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) second factor of user '%s' failed: %s", mock.Anything, "testuser", errOtpAttemptsExceeded.Error())
+	mockedOtpRepo.AssertNotCalled(t, "DeleteOneTimePasswords", mock.Anything, mock.Anything)
+}
+
+func TestLoginHandlerShouldThrottleOneTimePasswordSends(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 1, otpPurposeLogin, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{Codes: 3}, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+		Mfa: MfaConfig{MaxSends: 3},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithMessageSender(mockedSender))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	mockedOtpRepo.AssertNotCalled(t, "CreateOneTimePassword", mock.Anything)
+	mockedSender.AssertNotCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestLoginOtpHandlerShouldRejectAccessToken(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	accessToken, _ := security.GenerateToken(1, "testuser", jwt.SigningMethodHS256, []byte("secret"))
+	body, _ := json.Marshal(UserLoginOtpRequest{MfaToken: accessToken, Code: "123456"})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login/otp", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) invalid mfa token", mock.Anything)
+}
@@ -0,0 +1,105 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultPasswordResetLifetime = 24 * time.Hour
+	defaultPasswordResetUrl      = "/api/auth/password/reset"
+)
+
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (cfg EmailConfig) passwordResetLink(token string) string {
+	passwordResetUrl := cfg.PasswordResetUrl
+	if passwordResetUrl == "" {
+		passwordResetUrl = defaultPasswordResetUrl
+	}
+
+	return fmt.Sprintf("%s?token=%s", passwordResetUrl, url.QueryEscape(token))
+}
+
+func (service *LoginService) sendPasswordResetMail(account repository.Account) error {
+	if service.mailer == nil {
+		return errMailerUnavailable
+	}
+
+	now := time.Now()
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:   account.Id,
+		Username: account.Username,
+		Email:    account.Email,
+		Purpose:  security.TokenPurposePasswordReset,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(defaultPasswordResetLifetime).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil {
+		return err
+	}
+
+	return service.mailer.SendMail(messaging.Mail{
+		To:      account.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nyou are required to choose a new password. Please open the following link:\n\n%s\n",
+			account.Username, service.config.Email.passwordResetLink(token)),
+	})
+}
+
+func (service *LoginService) PasswordResetHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	claims, err := security.ParseToken(request.Token, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	if err != nil || claims.Purpose != security.TokenPurposePasswordReset {
+		service.logger.Warnf("(%s) invalid password reset token", r.RemoteAddr)
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired password reset link.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil || account.Email != claims.Email || !account.PasswordResetRequired {
+		service.logger.Warnf("(%s) password reset of user %d does not match account", r.RemoteAddr, claims.UserId)
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired password reset link.")
+		return
+	}
+
+	if request.Password == "" {
+		sendSimpleResponse(w, http.StatusBadRequest, "Password must not be empty.")
+		return
+	}
+
+	passwordHash, err := service.hashEngine.HashPassword([]byte(request.Password))
+	if err != nil {
+		service.logger.Errorf("(%s) hashing password failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not reset password.")
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountPassword(account.Id, string(passwordHash)); err != nil {
+		service.logger.Errorf("(%s) reset password of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not reset password.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Password reset successful.")
+}
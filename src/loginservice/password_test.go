@@ -0,0 +1,117 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashedTestPassword(t *testing.T) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(hash)
+}
+
+func passwordResetRequest(t *testing.T, id int, email string, password string) *http.Request {
+	token, err := security.GenerateTokenWithClaims(security.JwtClaims{
+		UserId:  id,
+		Email:   email,
+		Purpose: security.TokenPurposePasswordReset,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	}, jwt.SigningMethodHS256, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(PasswordResetRequest{Token: token, Password: password})
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/password/reset", bytes.NewBuffer(body))
+	return request
+}
+
+func TestPasswordResetHandlerSucceeded(t *testing.T) {
+	// given
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", []byte("newpass")).
+		Return([]byte("newhash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", PasswordResetRequired: true}, nil).
+		On("UpdateAccountPassword", 1, "newhash").
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, passwordResetRequest(t, 1, "test@test.com", "newpass"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountPassword", 1, "newhash")
+}
+
+func TestPasswordResetHandlerShouldRejectUsedToken(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, passwordResetRequest(t, 1, "test@test.com", "newpass"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountPassword", mock.Anything, mock.Anything)
+}
+
+func TestLoginHandlerShouldRequirePasswordReset(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusActive, PasswordResetRequired: true}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, true, response["passwordResetRequired"])
+	assert.Nil(t, response["token"])
+}
@@ -0,0 +1,95 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+type PhoneNumberRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+	Password    string `json:"password"`
+	Channel     string `json:"channel,omitempty"`
+}
+
+type PhoneNumberVerifyRequest struct {
+	Code string `json:"code"`
+}
+
+func (service *LoginService) PhoneNumberHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request PhoneNumberRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if !phoneNumberPattern.MatchString(request.PhoneNumber) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid phone number.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	if err := service.verifyPassword(r, &account, request.Password); err != nil {
+		service.logger.Warnf("(%s) wrong password while changing phone number of user '%s'", r.RemoteAddr, account.Username)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountPhoneNumber(account.Id, request.PhoneNumber, false); err != nil {
+		service.logger.Errorf("(%s) update phone number of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not update phone number.")
+		return
+	}
+
+	if err := service.sendOneTimePassword(account.Id, request.PhoneNumber, request.Channel, otpPurposePhoneVerification); err != nil {
+		service.logger.Errorf("(%s) send verification code to user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendOneTimePasswordErrorResponse(w, err, "Could not send verification code.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Verification code sent.")
+}
+
+func (service *LoginService) PhoneNumberVerifyHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request PhoneNumberVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil || account.PhoneNumber == "" {
+		sendSimpleResponse(w, http.StatusBadRequest, "No phone number to verify.")
+		return
+	}
+
+	if err := service.verifyOneTimePassword(account.Id, otpPurposePhoneVerification, request.Code); err != nil {
+		service.logger.Warnf("(%s) phone verification of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid verification code.")
+		return
+	}
+
+	if err := service.accountRepo.UpdateAccountPhoneNumber(account.Id, account.PhoneNumber, true); err != nil {
+		service.logger.Errorf("(%s) update phone number of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not verify phone number.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "Phone number verified.")
+}
@@ -0,0 +1,140 @@
+package loginservice
+
+import (
+	"bytes"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPhoneNumberHandlerShouldRejectInvalidPhoneNumber(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := []byte(`{ "phoneNumber": "0151 123", "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/phone", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestPhoneNumberHandlerShouldRejectWrongPassword(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword)}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := []byte(`{ "phoneNumber": "+4915112345678", "password": "wrongpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/phone", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountPhoneNumber", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPhoneNumberHandlerSucceeded(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("codehash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountPhoneNumber", 1, "+4915112345678", false).
+		Return(nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 1, otpPurposePhoneVerification, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposePhoneVerification, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("CreateOneTimePassword", mock.Anything).
+		Return(1, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedSender.
+		On("SendMessage", mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithMessageSender(mockedSender))
+
+	// when
+	body := []byte(`{ "phoneNumber": "+4915112345678", "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/phone", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountPhoneNumber", 1, "+4915112345678", false)
+	mockedSender.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestPhoneNumberVerifyHandlerSucceeded(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", PhoneNumber: "+4915112345678", Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountPhoneNumber", 1, "+4915112345678", true).
+		Return(nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposePhoneVerification).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposePhoneVerification, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposePhoneVerification).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	// when
+	body := []byte(`{ "code": "123456" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/phone/verify", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountPhoneNumber", 1, "+4915112345678", true)
+}
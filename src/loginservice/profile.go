@@ -0,0 +1,174 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const maxDisplayNameLength = 64
+
+type ProfileResponse struct {
+	Id                    int                    `json:"id"`
+	Username              string                 `json:"username"`
+	Email                 string                 `json:"email"`
+	EmailVerified         bool                   `json:"emailVerified"`
+	DisplayName           string                 `json:"displayName"`
+	PhoneNumber           string                 `json:"phoneNumber,omitempty"`
+	PhoneVerified         bool                   `json:"phoneVerified"`
+	Status                string                 `json:"status"`
+	CreationDate          time.Time              `json:"creationDate"`
+	DeletionScheduledDate *time.Time             `json:"deletionScheduledDate,omitempty"`
+	Attributes            map[string]interface{} `json:"attributes"`
+	LastLoginAt           *time.Time             `json:"lastLoginAt,omitempty"`
+}
+
+type ProfileUpdateRequest struct {
+	Password    string                 `json:"password,omitempty"`
+	Email       *string                `json:"email,omitempty"`
+	DisplayName *string                `json:"displayName,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func newProfileResponse(account repository.Account) ProfileResponse {
+	return ProfileResponse{
+		Id:                    account.Id,
+		Username:              account.Username,
+		Email:                 account.Email,
+		EmailVerified:         account.EmailVerified,
+		DisplayName:           account.DisplayName,
+		PhoneNumber:           account.PhoneNumber,
+		PhoneVerified:         account.PhoneVerified,
+		Status:                account.Status,
+		CreationDate:          account.CreationDate,
+		DeletionScheduledDate: account.DeletionScheduledDate,
+		Attributes:            account.Attributes,
+		LastLoginAt:           account.LastLoginAt,
+	}
+}
+
+func validateEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+func validateDisplayName(displayName string) bool {
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		return false
+	}
+
+	for _, r := range displayName {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (service *LoginService) ProfileHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Profile of current user.", map[string]interface{}{
+		"account": newProfileResponse(account),
+	})
+}
+
+func (service *LoginService) ProfileUpdateHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request ProfileUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusNotFound, "Account not found.")
+		return
+	}
+
+	emailChanged := false
+	if request.Email != nil {
+		email, err := NormalizeEmail(*request.Email)
+		if err != nil || !validateEmail(email) {
+			sendSimpleResponse(w, http.StatusBadRequest, "Invalid email address.")
+			return
+		}
+
+		if email != account.Email {
+			if !service.recentlyAuthenticated(claims) {
+				if err := service.verifyPassword(r, &account, request.Password); err != nil {
+					service.logger.Warnf("(%s) wrong password while changing email address of user '%s'", r.RemoteAddr, account.Username)
+					sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+					return
+				}
+			}
+
+			if other, err := service.accountRepo.GetAccountByEmail(account.TenantId, email); err == nil && other.Id != account.Id {
+				sendSimpleResponse(w, http.StatusConflict, "Email address already in use.")
+				return
+			}
+
+			account.Email = email
+			account.EmailVerified = false
+			account.EmailVerifiedDate = nil
+			emailChanged = true
+		}
+	}
+
+	if request.DisplayName != nil {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if !validateDisplayName(displayName) {
+			sendSimpleResponse(w, http.StatusBadRequest, "Invalid display name.")
+			return
+		}
+
+		account.DisplayName = displayName
+	}
+
+	var attributes map[string]interface{}
+	if request.Attributes != nil {
+		attributes = mergeAttributes(account.Attributes, request.Attributes)
+		if err := service.validateAttributes(attributes); err != nil {
+			service.logger.Warnf("(%s) invalid attributes of user '%s': %s", r.RemoteAddr, account.Username, err.Error())
+			sendSimpleResponse(w, http.StatusBadRequest, "Invalid attributes.")
+			return
+		}
+
+		account.Attributes = attributes
+	}
+
+	if err := service.accountRepo.UpdateAccountProfile(account.Id, account.Email, account.DisplayName, account.EmailVerified, attributes); err != nil {
+		service.logger.Errorf("(%s) update profile of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not update profile.")
+		return
+	}
+
+	if emailChanged && service.mailer != nil {
+		if err := service.sendVerificationMail(account); err != nil {
+			service.logger.Errorf("(%s) send verification email to user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		}
+	}
+
+	sendResponse(w, http.StatusOK, "Profile updated.", map[string]interface{}{
+		"account": newProfileResponse(account),
+	})
+}
@@ -0,0 +1,229 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProfileHandlerShouldNotExposePassword(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: "hash", Email: "test@test.com", DisplayName: "Test", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	body := responseWriter.Body.String()
+	var response struct {
+		Account map[string]interface{} `json:"account"`
+	}
+	err := json.Unmarshal([]byte(body), &response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotContains(t, body, "hash")
+	assert.NotContains(t, body, "password")
+	assert.Equal(t, "testuser", response.Account["username"])
+	assert.Equal(t, "Test", response.Account["displayName"])
+}
+
+func TestProfileHandlerShouldRequireAuthentication(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+}
+
+func TestProfileUpdateHandlerShouldUpdateDisplayName(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", EmailVerified: true, Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountProfile", 1, "test@test.com", "New Name", true, map[string]interface{}(nil)).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "displayName": " New Name " }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountProfile", 1, "test@test.com", "New Name", true, map[string]interface{}(nil))
+}
+
+func TestProfileUpdateHandlerShouldResetVerificationWhenEmailChanges(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Email: "test@test.com", EmailVerified: true, Status: repository.AccountStatusActive}, nil).
+		On("GetAccountByEmail", 0, "new@test.com").
+		Return(repository.Account{}, assert.AnError).
+		On("UpdateAccountProfile", 1, "new@test.com", "", false, map[string]interface{}(nil)).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "email": "new@test.com", "password": "testpass" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountProfile", 1, "new@test.com", "", false, map[string]interface{}(nil))
+}
+
+func TestProfileUpdateHandlerShouldAllowEmailChangeAfterRecentAuthentication(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", EmailVerified: true, Status: repository.AccountStatusActive}, nil).
+		On("GetAccountByEmail", 0, "new@test.com").
+		Return(repository.Account{}, assert.AnError).
+		On("UpdateAccountProfile", 1, "new@test.com", "", false, map[string]interface{}(nil)).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+	accessToken, _ := service.issueToken(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, 0, []string{security.AuthMethodPassword}, localAuthenticatorName, time.Now(), time.Hour)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "email": "new@test.com" }`))
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountProfile", 1, "new@test.com", "", false, map[string]interface{}(nil))
+}
+
+func TestProfileUpdateHandlerShouldRequirePasswordToChangeEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Email: "test@test.com", EmailVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+	accessToken, _ := service.issueToken(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, 0, []string{security.AuthMethodPassword}, localAuthenticatorName, time.Now().Add(-time.Hour), time.Hour)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "email": "new@test.com", "password": "wrongpass" }`))
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProfileUpdateHandlerShouldRejectInvalidEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "email": "Test <test@test.com>" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProfileUpdateHandlerShouldRejectTakenEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Email: "test@test.com", Status: repository.AccountStatusActive}, nil).
+		On("GetAccountByEmail", 0, "other@test.com").
+		Return(repository.Account{Id: 2}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBufferString(`{ "email": "other@test.com", "password": "testpass" }`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+}
+
+func TestProfileUpdateHandlerShouldRejectInvalidDisplayName(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	body, _ := json.Marshal(ProfileUpdateRequest{DisplayName: func(s string) *string { return &s }("bad\u0007name")})
+
+	// when
+	request, _ := http.NewRequest(http.MethodPatch, "/api/auth/me", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
@@ -0,0 +1,200 @@
+package loginservice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/ratelimit"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	rateLimitKeyIp                = "ip"
+	rateLimitKeyUsername          = "username"
+	maxRateLimitBodySize          = 1 << 16
+	maxRateLimitValueLength       = 128
+	defaultRateLimitPurgeInterval = 10 * time.Minute
+)
+
+type RouteRateLimit struct {
+	Ip       ratelimit.Limit
+	Username ratelimit.Limit
+}
+
+func WithRateLimitStore(store ratelimit.Store) ServiceOption {
+	return func(service *LoginService) {
+		service.rateLimitStore = store
+	}
+}
+
+func defaultRateLimitRoutes() map[string]RouteRateLimit {
+	return map[string]RouteRateLimit{
+		"/api/auth/login": {
+			Ip:       ratelimit.Limit{Requests: 20, Interval: time.Minute},
+			Username: ratelimit.Limit{Requests: 10, Interval: time.Minute},
+		},
+		"/api/auth/login/otp": {
+			Ip: ratelimit.Limit{Requests: 20, Interval: time.Minute},
+		},
+		"/api/auth/register": {
+			Ip:       ratelimit.Limit{Requests: 5, Interval: time.Minute, Burst: 10},
+			Username: ratelimit.Limit{Requests: 5, Interval: time.Minute},
+		},
+		"/api/auth/email/resend": {
+			Ip: ratelimit.Limit{Requests: 5, Interval: time.Minute},
+		},
+		"/api/auth/password/reset": {
+			Ip: ratelimit.Limit{Requests: 5, Interval: time.Minute},
+		},
+	}
+}
+
+func (cfg RateLimitConfig) route(route string) (RouteRateLimit, bool) {
+	if cfg.Disabled {
+		return RouteRateLimit{}, false
+	}
+
+	routes := cfg.Routes
+	if routes == nil {
+		routes = defaultRateLimitRoutes()
+	}
+
+	limits, ok := routes[route]
+	return limits, ok && (limits.Ip.Enabled() || limits.Username.Enabled())
+}
+
+func (cfg RateLimitConfig) purgeInterval() time.Duration {
+	if cfg.PurgeInterval <= 0 {
+		return defaultRateLimitPurgeInterval
+	}
+
+	return cfg.PurgeInterval
+}
+
+// rateLimitKey builds the store key of a limit. Long values are hashed, so
+// client input cannot grow the key beyond what the stores accept.
+func rateLimitKey(route string, kind string, value string) string {
+	if len(value) > maxRateLimitValueLength {
+		sum := sha256.Sum256([]byte(value))
+		value = hex.EncodeToString(sum[:])
+	}
+
+	return route + "|" + kind + "|" + value
+}
+
+func rateLimitUsername(w http.ResponseWriter, r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateLimitBodySize)
+
+	var consumed bytes.Buffer
+	var request struct {
+		Username string `json:"username"`
+	}
+	err := json.NewDecoder(io.TeeReader(r.Body, &consumed)).Decode(&request)
+	r.Body = io.NopCloser(io.MultiReader(&consumed, r.Body))
+	if err != nil {
+		return ""
+	}
+
+	username, err := NormalizeUsername(request.Username)
+	if err != nil {
+		username = strings.ToLower(strings.TrimSpace(request.Username))
+	}
+
+	if username == "" {
+		return ""
+	}
+
+	return UsernameSkeleton(username)
+}
+
+func sendRateLimitedResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	sendSimpleResponse(w, http.StatusTooManyRequests, "Too many requests. Try again later.")
+}
+
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+func (service *LoginService) takeRateLimit(r *http.Request, route string, kind string, value string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, bool) {
+	decision, err := service.rateLimitStore.Take(rateLimitKey(route, kind, value), limit, now)
+	if err != nil {
+		service.logger.Errorf("(%s) rate limit check of route '%s' failed: %s", r.RemoteAddr, route, err.Error())
+		return decision, !service.config.RateLimit.FailClosed
+	}
+
+	if !decision.Allowed {
+		service.logger.Warnf("(%s) %s rate limit of route '%s' exceeded", r.RemoteAddr, kind, route)
+	}
+
+	return decision, decision.Allowed
+}
+
+func (service *LoginService) rateLimited(route string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		limits, ok := service.config.RateLimit.route(route)
+		if !ok || service.rateLimitStore == nil {
+			handle(w, r, p)
+			return
+		}
+
+		now := time.Now()
+		if limits.Ip.Enabled() {
+			if decision, allowed := service.takeRateLimit(r, route, rateLimitKeyIp, service.clientIp(r), limits.Ip, now); !allowed {
+				sendRateLimitedResponse(w, decision.RetryAfter)
+				return
+			}
+		}
+
+		if limits.Username.Enabled() {
+			if username := rateLimitUsername(w, r); username != "" {
+				if decision, allowed := service.takeRateLimit(r, route, rateLimitKeyUsername, username, limits.Username, now); !allowed {
+					sendRateLimitedResponse(w, decision.RetryAfter)
+					return
+				}
+			}
+		}
+
+		handle(w, r, p)
+	}
+}
+
+func (service *LoginService) StartRateLimitPurge() func() {
+	ticker := time.NewTicker(service.config.RateLimit.purgeInterval())
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := service.rateLimitStore.Prune(now); err != nil {
+					service.logger.Errorf("purge of rate limits failed: %s", err.Error())
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
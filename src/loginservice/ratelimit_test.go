@@ -0,0 +1,320 @@
+package loginservice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/ratelimit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func rateLimitTestConfig(route string, limits RouteRateLimit) LoginServiceConfig {
+	return LoginServiceConfig{
+		RateLimit: RateLimitConfig{
+			Routes: map[string]RouteRateLimit{route: limits},
+		},
+	}
+}
+
+func TestRateLimitedShouldRejectExceededIpLimit(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	calls := 0
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		calls++
+	})
+
+	// when
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test", nil), nil)
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, httptest.NewRequest(http.MethodPost, "/test", nil), nil)
+
+	// then
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	assert.Equal(t, "60", responseWriter.Header().Get("Retry-After"))
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) %s rate limit of route '%s' exceeded", mock.Anything, rateLimitKeyIp, "/test")
+}
+
+func TestRateLimitedShouldLimitUsernameAcrossAddresses(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Username: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	var bodies []string
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	})
+
+	// when
+	first := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{ "username": "TestUser" }`))
+	first.RemoteAddr = "10.0.0.1:1234"
+	handle(httptest.NewRecorder(), first, nil)
+	second := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{ "username": "testuser" }`))
+	second.RemoteAddr = "10.0.0.2:1234"
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, second, nil)
+	other := httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(`{ "username": "otheruser" }`))
+	handle(httptest.NewRecorder(), other, nil)
+
+	// then
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	assert.Equal(t, []string{`{ "username": "TestUser" }`, `{ "username": "otheruser" }`}, bodies)
+}
+
+func TestRateLimitedShouldUseForwardedAddressOfTrustedProxy(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	})
+	config.TrustedProxies, _ = ParseTrustedProxies([]string{"10.0.0.0/8"})
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	calls := 0
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		calls++
+	})
+	forwardedRequest := func(remoteAddr string, forwardedFor string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/test", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		return request
+	}
+
+	// when
+	handle(httptest.NewRecorder(), forwardedRequest("10.0.0.1:1234", "203.0.113.5"), nil)
+	handle(httptest.NewRecorder(), forwardedRequest("10.0.0.1:1234", "203.0.113.6, 10.0.0.2"), nil)
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, forwardedRequest("10.0.0.3:1234", "198.51.100.1, 203.0.113.5"), nil)
+
+	// then
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+}
+
+func TestRateLimitedShouldIgnoreForwardedAddressOfUntrustedClient(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	calls := 0
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		calls++
+	})
+
+	// when
+	for _, forwardedFor := range []string{"203.0.113.5", "203.0.113.6"} {
+		request := httptest.NewRequest(http.MethodPost, "/test", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		handle(httptest.NewRecorder(), request, nil)
+	}
+
+	// then
+	assert.Equal(t, 1, calls)
+}
+
+func TestRateLimitedShouldLimitBodySizeOfUsernameLookup(t *testing.T) {
+	// given
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Username: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger))
+
+	var readErr error
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		_, readErr = io.ReadAll(r.Body)
+	})
+
+	// when
+	body := `{ "username": "testuser", "padding": "` + strings.Repeat("x", maxRateLimitBodySize) + `" }`
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(body)), nil)
+
+	// then
+	assert.Error(t, readErr)
+}
+
+func TestRateLimitedShouldAllowRequestIfStoreFails(t *testing.T) {
+	// given
+	mockedStore := new(mocks.Store)
+	mockedStore.
+		On("Take", mock.Anything, mock.Anything, mock.Anything).
+		Return(ratelimit.Decision{}, errors.New("connection refused"))
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithRateLimitStore(mockedStore))
+
+	called := false
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test", nil), nil)
+
+	// then
+	assert.True(t, called)
+	mockedLogger.AssertCalled(t, "Errorf", "(%s) rate limit check of route '%s' failed: %s", mock.Anything, "/test", "connection refused")
+}
+
+func TestRateLimitedShouldRejectRequestIfStoreFailsClosed(t *testing.T) {
+	// given
+	mockedStore := new(mocks.Store)
+	mockedStore.
+		On("Take", mock.Anything, mock.Anything, mock.Anything).
+		Return(ratelimit.Decision{}, ratelimit.ErrStoreFull)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	config := rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	})
+	config.RateLimit.FailClosed = true
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithRateLimitStore(mockedStore))
+
+	called := false
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	handle(responseWriter, httptest.NewRequest(http.MethodPost, "/test", nil), nil)
+
+	// then
+	assert.False(t, called)
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+}
+
+func TestRateLimitedShouldHashLongUsernames(t *testing.T) {
+	// given
+	username := strings.Repeat("a", 1000)
+	mockedStore := new(mocks.Store)
+	mockedStore.
+		On("Take", mock.MatchedBy(func(key string) bool {
+			return len(key) <= len("/test|username|")+2*sha256.Size
+		}), mock.Anything, mock.Anything).
+		Return(ratelimit.Decision{Allowed: true}, nil)
+	service := NewService(rateLimitTestConfig("/test", RouteRateLimit{
+		Username: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	}), new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithRateLimitStore(mockedStore))
+
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {})
+
+	// when
+	body := `{ "username": "` + username + `" }`
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test", bytes.NewBufferString(body)), nil)
+
+	// then
+	mockedStore.AssertNumberOfCalls(t, "Take", 1)
+}
+
+func TestRateLimitedShouldSkipDisabledLimits(t *testing.T) {
+	// given
+	mockedStore := new(mocks.Store)
+	config := rateLimitTestConfig("/test", RouteRateLimit{
+		Ip: ratelimit.Limit{Requests: 1, Interval: time.Minute},
+	})
+	config.RateLimit.Disabled = true
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithRateLimitStore(mockedStore))
+
+	called := false
+	handle := service.rateLimited("/test", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		called = true
+	})
+
+	// when
+	handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/test", nil), nil)
+
+	// then
+	assert.True(t, called)
+	mockedStore.AssertNotCalled(t, "Take", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRegisterHandlerShouldBeRateLimitedByDefault(t *testing.T) {
+	// given
+	mockedStore := new(mocks.Store)
+	mockedStore.
+		On("Take", "/api/auth/register|ip|192.0.2.1", mock.Anything, mock.Anything).
+		Return(ratelimit.Decision{RetryAfter: 1500 * time.Millisecond}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithRateLimitStore(mockedStore))
+
+	// when
+	request := httptest.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBufferString(`{ "username": "testuser", "password": "testpass", "email": "test@test.com" }`))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusTooManyRequests, responseWriter.Code)
+	assert.Equal(t, "2", responseWriter.Header().Get("Retry-After"))
+	assert.Len(t, mockedAccountRepo.Calls, 0)
+}
+
+func TestStartRateLimitPurgeShouldRunPeriodically(t *testing.T) {
+	// given
+	pruned := make(chan struct{}, 1)
+	mockedStore := new(mocks.Store)
+	mockedStore.
+		On("Prune", mock.Anything).
+		Run(func(args mock.Arguments) {
+			select {
+			case pruned <- struct{}{}:
+			default:
+			}
+		}).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		RateLimit: RateLimitConfig{PurgeInterval: 10 * time.Millisecond},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithRateLimitStore(mockedStore))
+
+	// when
+	stop := service.StartRateLimitPurge()
+	defer stop()
+
+	// then
+	select {
+	case <-pruned:
+	case <-time.After(time.Second):
+		t.Fatal("Purge did not run")
+	}
+}
@@ -0,0 +1,152 @@
+package loginservice
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	RegistrationModeOpen            = "open"
+	RegistrationModeClosed          = "closed"
+	RegistrationModeInviteOnly      = "invite_only"
+	RegistrationModeDomainAllowlist = "domain_allowlist"
+)
+
+var (
+	errRegistrationClosed    = errors.New("registration_closed")
+	errInvitationRequired    = errors.New("invitation_required")
+	errInvalidEmail          = errors.New("invalid_email")
+	errEmailDomainNotAllowed = errors.New("email_domain_not_allowed")
+	errDisposableEmail       = errors.New("disposable_email")
+
+	//go:embed disposable_domains.txt
+	bundledDisposableDomains string
+)
+
+func IsRegistrationMode(mode string) bool {
+	switch mode {
+	case "", RegistrationModeOpen, RegistrationModeClosed, RegistrationModeInviteOnly, RegistrationModeDomainAllowlist:
+		return true
+	default:
+		return false
+	}
+}
+
+func ReadDomainList(r io.Reader) ([]string, error) {
+	domains := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains = append(domains, strings.ToLower(line))
+	}
+
+	return domains, scanner.Err()
+}
+
+func newDomainSet(domains []string) map[string]bool {
+	set := map[string]bool{}
+	for _, domain := range domains {
+		set[strings.ToLower(strings.TrimSpace(domain))] = true
+	}
+
+	return set
+}
+
+func loadDisposableDomains(extra []string) map[string]bool {
+	domains, _ := ReadDomainList(strings.NewReader(bundledDisposableDomains))
+	return newDomainSet(append(domains, extra...))
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+
+	return strings.ToLower(email[at+1:])
+}
+
+func matchesDomain(domain string, set map[string]bool) bool {
+	for domain != "" {
+		if set[domain] {
+			return true
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+
+		domain = domain[dot+1:]
+	}
+
+	return false
+}
+
+func (service *LoginService) checkRegistrationPolicy(email string, invited bool) error {
+	policy := service.config.Registration
+
+	switch policy.Mode {
+	case "", RegistrationModeOpen, RegistrationModeDomainAllowlist:
+	case RegistrationModeInviteOnly:
+		if !invited {
+			return errInvitationRequired
+		}
+	default:
+		return errRegistrationClosed
+	}
+
+	if invited {
+		return nil
+	}
+
+	if policy.Mode != RegistrationModeDomainAllowlist && !policy.BlockDisposable {
+		return nil
+	}
+
+	if !validateEmail(email) {
+		return errInvalidEmail
+	}
+
+	domain := emailDomain(email)
+	if policy.Mode == RegistrationModeDomainAllowlist && !matchesDomain(domain, newDomainSet(policy.AllowedDomains)) {
+		return errEmailDomainNotAllowed
+	}
+
+	if policy.BlockDisposable && matchesDomain(domain, service.disposableDomains) {
+		return errDisposableEmail
+	}
+
+	return nil
+}
+
+func sendRegistrationPolicyResponse(w http.ResponseWriter, err error) {
+	props := map[string]interface{}{
+		"code": err.Error(),
+	}
+
+	switch err {
+	case errRegistrationClosed:
+		sendResponse(w, http.StatusForbidden, "Registration is closed.", props)
+	case errInvitationRequired:
+		sendResponse(w, http.StatusForbidden, "Registration requires an invitation.", props)
+	case errInvalidEmail:
+		sendResponse(w, http.StatusBadRequest, "Invalid email address.", props)
+	case errInvalidUsername:
+		sendResponse(w, http.StatusBadRequest, "Invalid username.", props)
+	case errUsernameConfusable:
+		sendResponse(w, http.StatusConflict, "Username is too similar to an existing user.", props)
+	case errEmailDomainNotAllowed:
+		sendResponse(w, http.StatusForbidden, "Email domain is not allowed.", props)
+	default:
+		sendResponse(w, http.StatusForbidden, "Disposable email addresses are not allowed.", props)
+	}
+}
@@ -0,0 +1,150 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func registerRequest(email string) *http.Request {
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "` + email + `" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	return request
+}
+
+func registrationErrorCode(t *testing.T, responseWriter *httptest.ResponseRecorder) string {
+	var response map[string]interface{}
+	if err := json.NewDecoder(responseWriter.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := response["code"].(string)
+	return code
+}
+
+func TestReadDomainListShouldSkipCommentsAndBlankLines(t *testing.T) {
+	domains, err := ReadDomainList(strings.NewReader("# comment\n\nExample.com\n  test.org  \n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "test.org"}, domains)
+}
+
+func TestMatchesDomainShouldMatchSubdomains(t *testing.T) {
+	set := newDomainSet([]string{"mailinator.com"})
+
+	assert.True(t, matchesDomain("mailinator.com", set))
+	assert.True(t, matchesDomain("eu.mailinator.com", set))
+	assert.False(t, matchesDomain("notmailinator.com", set))
+}
+
+func TestRegisterHandlerShouldRejectWhenClosed(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Registration: RegistrationConfig{Mode: RegistrationModeClosed},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, registerRequest("test@test.com"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, "registration_closed", registrationErrorCode(t, responseWriter))
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestRegisterHandlerShouldRequireInvitationWhenInviteOnly(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Registration: RegistrationConfig{Mode: RegistrationModeInviteOnly},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, registerRequest("test@test.com"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, "invitation_required", registrationErrorCode(t, responseWriter))
+}
+
+func TestRegisterHandlerShouldRejectDomainOutsideAllowlist(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Registration: RegistrationConfig{Mode: RegistrationModeDomainAllowlist, AllowedDomains: []string{"fitter.test"}},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, registerRequest("test@other.test"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, "email_domain_not_allowed", registrationErrorCode(t, responseWriter))
+}
+
+func TestRegisterHandlerShouldAcceptDomainInAllowlist(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.Anything).
+		Return(1, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	service := NewService(LoginServiceConfig{
+		Registration: RegistrationConfig{Mode: RegistrationModeDomainAllowlist, AllowedDomains: []string{"Fitter.test"}},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, registerRequest("test@fitter.test"))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+}
+
+func TestRegisterHandlerShouldRejectDisposableEmail(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Registration: RegistrationConfig{BlockDisposable: true, DisposableDomains: []string{"throwaway.test"}},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	bundled := httptest.NewRecorder()
+	service.handler.ServeHTTP(bundled, registerRequest("test@mailinator.com"))
+	configured := httptest.NewRecorder()
+	service.handler.ServeHTTP(configured, registerRequest("test@throwaway.test"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, bundled.Code)
+	assert.Equal(t, "disposable_email", registrationErrorCode(t, bundled))
+	assert.Equal(t, http.StatusForbidden, configured.Code)
+}
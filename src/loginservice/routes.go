@@ -9,18 +9,23 @@ import (
 
 	"github.com/golang-jwt/jwt"
 	"github.com/julienschmidt/httprouter"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserLoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	OtpChannel   string `json:"otpChannel,omitempty"`
+	DeviceToken  string `json:"deviceToken,omitempty"`
+	Organization string `json:"organization,omitempty"`
 }
 
 type UserRegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Username          string                    `json:"username"`
+	Password          string                    `json:"password"`
+	Email             string                    `json:"email"`
+	Organization      string                    `json:"organization,omitempty"`
+	InvitationToken   string                    `json:"invitationToken,omitempty"`
+	AcceptedDocuments []LegalDocumentAcceptance `json:"acceptedDocuments,omitempty"`
 }
 
 func sendSimpleResponse(w http.ResponseWriter, status int, message string) {
@@ -52,26 +57,194 @@ func (service *LoginService) LoginHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := service.accountRepo.GetAccountByUsername(request.Username)
+	if username, err := NormalizeUsername(request.Username); err == nil {
+		request.Username = username
+	}
+
+	methods := []string{security.AuthMethodPassword}
+	organization, err := service.organizationBySlug(request.Organization)
 	if err != nil {
-		service.logger.Warnf("(%s) login of user '%s' failed", r.RemoteAddr, request.Username)
+		service.logger.Warnf("(%s) login of user '%s' failed: %s", r.RemoteAddr, request.Username, err.Error())
+		service.recordLogin(r, 0, request.Username, methods, loginFailureReason(err))
 		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
-		service.logger.Errorf("(%s) wrong password: %s", r.RemoteAddr, err.Error())
+	now := time.Now()
+	authRequest := AuthenticationRequest{
+		Username: request.Username,
+		Password: request.Password,
+		TenantId: tenantIdOf(organization),
+	}
+
+	user, err := service.accountRepo.GetAccountByUsername(tenantIdOf(organization), request.Username)
+	if err != nil {
+		if lockedUntil, locked := service.unknownLogins.lockedUntil(authRequest.TenantId, request.Username, now); locked {
+			service.logger.Warnf("(%s) login of locked user '%s' rejected", r.RemoteAddr, request.Username)
+			service.recordLogin(r, 0, request.Username, methods, loginFailureLocked)
+			sendLockedResponse(w, lockedUntil)
+			return
+		}
+	} else {
+		if isLocked(user, now) {
+			service.logger.Warnf("(%s) login of locked user '%s' rejected", r.RemoteAddr, user.Username)
+			service.recordLogin(r, user.Id, user.Username, methods, loginFailureLocked)
+			sendLockedResponse(w, *user.LockedUntil)
+			return
+		}
+
+		authRequest.Account = &user
+	}
+
+	result := service.authenticate(r, authRequest)
+	switch result.Decision {
+	case AuthenticationPassed:
+		service.unknownLogins.recordFailure(authRequest.TenantId, request.Username, service.config.Lockout, now)
+		service.logger.Warnf("(%s) login of user '%s' failed", r.RemoteAddr, request.Username)
+		service.recordLogin(r, 0, request.Username, methods, loginFailureUnknownUser)
 		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
 		return
+	case AuthenticationRejected:
+		account := result.Account
+		known := authRequest.Account != nil || account.Id != 0
+		if known && isLocked(account, now) {
+			service.logger.Warnf("(%s) login of locked user '%s' rejected", r.RemoteAddr, account.Username)
+			service.recordLogin(r, account.Id, account.Username, methods, loginFailureLocked)
+			sendLockedResponse(w, *account.LockedUntil)
+			return
+		}
+
+		if known && result.FailureReason == loginFailureWrongPassword {
+			service.logger.Errorf("(%s) wrong password: %s", r.RemoteAddr, result.Err.Error())
+			if err := service.recordFailedLogin(account); err != nil {
+				service.logger.Errorf("(%s) record failed login of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+			}
+
+			service.recordLogin(r, account.Id, account.Username, methods, loginFailureWrongPassword)
+			sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+			return
+		}
+
+		if !known && result.FailureReason == loginFailureWrongPassword {
+			service.unknownLogins.recordFailure(authRequest.TenantId, request.Username, service.config.Lockout, now)
+		}
+
+		service.logger.Warnf("(%s) login of user '%s' rejected by authenticator '%s': %s", r.RemoteAddr, request.Username, result.Authenticator, result.Err.Error())
+		service.recordLogin(r, account.Id, request.Username, methods, result.FailureReason)
+		sendAuthenticationResponse(w, result)
+		return
 	}
 
-	token, _ := security.GenerateToken(user.Id, user.Username, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+	user = result.Account
+	if result.Authenticator != localAuthenticatorName {
+		service.logger.Infof("(%s) user '%s' authenticated by '%s'", r.RemoteAddr, user.Username, result.Authenticator)
+	}
 
-	sendResponse(w, 200, "User login successful.", map[string]interface{}{
-		"token": token,
+	service.completeLogin(w, r, user, loginCompletion{
+		Organization:  organization,
+		Methods:       methods,
+		Authenticator: result.Authenticator,
+		DeviceToken:   request.DeviceToken,
+		OtpChannel:    request.OtpChannel,
 	})
 }
 
+type loginCompletion struct {
+	Organization  *repository.Organization
+	Methods       []string
+	Authenticator string
+	DeviceToken   string
+	OtpChannel    string
+}
+
+func (service *LoginService) completeLogin(w http.ResponseWriter, r *http.Request, user repository.Account, login loginCompletion) {
+	methods := login.Methods
+	if isLocked(user, time.Now()) {
+		service.logger.Warnf("(%s) login of locked user '%s' rejected", r.RemoteAddr, user.Username)
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailureLocked)
+		sendLockedResponse(w, *user.LockedUntil)
+		return
+	}
+
+	if err := service.resetFailedLogins(user); err != nil {
+		service.logger.Errorf("(%s) reset failed logins of user '%s' failed: %s", r.RemoteAddr, user.Username, err.Error())
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		service.logger.Warnf("(%s) login of user '%s' rejected: %s", r.RemoteAddr, user.Username, err.Error())
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailureReason(err))
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	if user.PasswordResetRequired {
+		service.logger.Warnf("(%s) login of user '%s' requires password reset", r.RemoteAddr, user.Username)
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailurePasswordResetRequired)
+		sendResponse(w, http.StatusForbidden, "Password reset required.", map[string]interface{}{
+			"passwordResetRequired": true,
+		})
+		return
+	}
+
+	if service.config.Email.RequireVerification && !user.EmailVerified {
+		service.logger.Warnf("(%s) login of unverified user '%s' rejected", r.RemoteAddr, user.Username)
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailureEmailNotVerified)
+		sendSimpleResponse(w, http.StatusForbidden, "Email address not verified.")
+		return
+	}
+
+	organizationId, err := service.loginOrganizationId(user, login.Organization)
+	if err != nil {
+		service.logger.Warnf("(%s) login of user '%s' rejected: %s", r.RemoteAddr, user.Username, err.Error())
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailureReason(err))
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	if service.requiresSecondFactor(user) && !service.isTrustedDevice(r, login.DeviceToken, user.Id) {
+		if err := service.sendOneTimePassword(user.Id, user.PhoneNumber, login.OtpChannel, otpPurposeLogin); err != nil {
+			service.logger.Errorf("(%s) send one-time password to user '%s' failed: %s", r.RemoteAddr, user.Username, err.Error())
+			sendOneTimePasswordErrorResponse(w, err, "Could not send one-time password.")
+			return
+		}
+
+		mfaToken, _ := security.GenerateTokenWithClaims(security.JwtClaims{
+			UserId:        user.Id,
+			Username:      user.Username,
+			Purpose:       security.TokenPurposeMfa,
+			AuthMethods:   methods,
+			OrgId:         organizationId,
+			Authenticator: login.Authenticator,
+			StandardClaims: jwt.StandardClaims{
+				ExpiresAt: time.Now().Add(mfaTokenLifetime).Unix(),
+			},
+		}, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+
+		sendResponse(w, http.StatusOK, "Second factor required.", map[string]interface{}{
+			"mfaRequired": true,
+			"mfaToken":    mfaToken,
+			"mfaMethods":  []string{"sms"},
+		})
+		return
+	}
+
+	token, err := service.issueToken(user, organizationId, methods, login.Authenticator, time.Now(), security.DefaultTokenLifetime)
+	if err != nil {
+		service.logger.Errorf("(%s) generate token for user '%s' failed: %s", r.RemoteAddr, user.Username, err.Error())
+		service.recordLogin(r, user.Id, user.Username, methods, loginFailureReason(err))
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	service.recordLogin(r, user.Id, user.Username, methods, "")
+	props := map[string]interface{}{
+		"token": token,
+	}
+	service.addConsentProps(r, user.Id, props)
+
+	sendResponse(w, 200, "User login successful.", props)
+}
+
 func (service *LoginService) RegisterHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	var request UserRegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -80,13 +253,92 @@ func (service *LoginService) RegisterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	_, err := service.accountRepo.GetAccountByUsername(request.Username)
+	username, err := NormalizeUsername(request.Username)
+	if err != nil {
+		sendRegistrationPolicyResponse(w, err)
+		return
+	}
+	request.Username = username
+
+	if request.Email != "" {
+		email, err := NormalizeEmail(request.Email)
+		if err != nil {
+			sendRegistrationPolicyResponse(w, err)
+			return
+		}
+		request.Email = email
+	}
+
+	var invitation *repository.Invitation
+	if request.InvitationToken != "" {
+		pending, err := service.invitationFromToken(request.InvitationToken)
+		if err != nil {
+			service.logger.Warnf("(%s) register user '%s' with invalid invitation", r.RemoteAddr, request.Username)
+			sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired invitation.")
+			return
+		}
+
+		if invitationEmail, _ := NormalizeEmail(pending.Email); request.Email != "" && request.Email != invitationEmail {
+			sendSimpleResponse(w, http.StatusBadRequest, "Email address does not match the invitation.")
+			return
+		}
+
+		request.Email = pending.Email
+		request.Organization = pending.OrganizationSlug
+		invitation = &pending
+	}
+
+	if err := service.checkRegistrationPolicy(request.Email, invitation != nil); err != nil {
+		service.logger.Warnf("(%s) register user '%s' rejected: %s", r.RemoteAddr, request.Username, err.Error())
+		sendRegistrationPolicyResponse(w, err)
+		return
+	}
+
+	var consents []repository.LegalDocument
+	if service.consentRepo != nil {
+		current, err := service.consentRepo.GetCurrentLegalDocuments(time.Now())
+		if err != nil {
+			service.logger.Errorf("(%s) get current legal documents failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not register user.")
+			return
+		}
+
+		accepted, missing := acceptedLegalDocuments(current, request.AcceptedDocuments)
+		if len(missing) > 0 {
+			service.logger.Warnf("(%s) register user '%s' rejected: %s", r.RemoteAddr, request.Username, errConsentRequired.Error())
+			sendConsentRequiredResponse(w, missing)
+			return
+		}
+
+		consents = accepted
+	}
+
+	organization, err := service.organizationBySlug(request.Organization)
+	if err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Organization not found.")
+		return
+	}
+
+	if organization != nil && invitation == nil && (!organization.ScopedIdentities || !organization.OpenRegistration) {
+		sendSimpleResponse(w, http.StatusBadRequest, "Organization does not allow registration.")
+		return
+	}
+
+	tenantId := tenantIdOf(organization)
+	_, err = service.accountRepo.GetAccountByUsername(tenantId, request.Username)
 	if err == nil {
 		service.logger.Warnf("(%s) register user '%s' failed", r.RemoteAddr, request.Username)
 		sendSimpleResponse(w, http.StatusBadRequest, "User already exists.")
 		return
 	}
 
+	skeleton := UsernameSkeleton(request.Username)
+	if _, err := service.accountRepo.GetAccountByUsernameSkeleton(tenantId, skeleton); err == nil {
+		service.logger.Warnf("(%s) register user '%s' rejected: %s", r.RemoteAddr, request.Username, errUsernameConfusable.Error())
+		sendRegistrationPolicyResponse(w, errUsernameConfusable)
+		return
+	}
+
 	passwordHash, err := service.hashEngine.HashPassword([]byte(request.Password))
 	if err != nil {
 		service.logger.Errorf("(%s) hashing password failed: %s", r.RemoteAddr, err.Error())
@@ -94,11 +346,19 @@ func (service *LoginService) RegisterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	status := repository.AccountStatusActive
+	if service.config.Email.RequireVerification && invitation == nil {
+		status = repository.AccountStatusPending
+	}
+
 	id, err := service.accountRepo.CreateAccount(repository.Account{
-		Username:     request.Username,
-		Password:     string(passwordHash),
-		Email:        request.Email,
-		CreationDate: time.Now(),
+		TenantId:         tenantId,
+		Username:         request.Username,
+		UsernameSkeleton: skeleton,
+		Password:         string(passwordHash),
+		Email:            request.Email,
+		CreationDate:     time.Now(),
+		Status:           status,
 	})
 	if err != nil {
 		service.logger.Errorf("(%s) insert user '%s' into database failed: %s", r.RemoteAddr, request.Username, err.Error())
@@ -106,7 +366,70 @@ func (service *LoginService) RegisterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := service.recordConsents(r, id, consents); err != nil {
+		service.logger.Errorf("(%s) record consents of user '%s' failed: %s", r.RemoteAddr, request.Username, err.Error())
+		service.discardRegistration(r, id, request.Username)
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not register user.")
+		return
+	}
+
+	membershipRole := repository.MembershipRoleMember
+	if invitation != nil {
+		if err := service.applyInvitation(*invitation, id); err != nil {
+			service.logger.Errorf("(%s) apply invitation %d for user '%s' failed: %s", r.RemoteAddr, invitation.Id, request.Username, err.Error())
+			service.discardRegistration(r, id, request.Username)
+
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not register user.")
+			return
+		}
+
+		membershipRole = invitation.OrganizationRole
+	}
+
+	if organization != nil {
+		_, err := service.orgRepo.CreateMembership(repository.Membership{
+			OrganizationId: organization.Id,
+			AccountId:      id,
+			Role:           membershipRole,
+			CreationDate:   time.Now(),
+		})
+		if err != nil {
+			service.logger.Errorf("(%s) add user '%s' to organization '%s' failed: %s", r.RemoteAddr, request.Username, organization.Slug, err.Error())
+			service.discardRegistration(r, id, request.Username)
+
+			sendSimpleResponse(w, http.StatusInternalServerError, "Could not register user.")
+			return
+		}
+	}
+
+	if invitation != nil {
+		if err := service.acceptInvitation(*invitation, id); err != nil {
+			service.logger.Errorf("(%s) accept invitation %d for user '%s' failed: %s", r.RemoteAddr, invitation.Id, request.Username, err.Error())
+			service.discardRegistration(r, id, request.Username)
+
+			if err == errInvitationInvalid {
+				sendSimpleResponse(w, http.StatusBadRequest, "Invalid or expired invitation.")
+			} else {
+				sendSimpleResponse(w, http.StatusInternalServerError, "Could not register user.")
+			}
+			return
+		}
+	}
+
+	if service.mailer != nil && invitation == nil {
+		account := repository.Account{Id: id, Username: request.Username, Email: request.Email}
+		if err := service.sendVerificationMail(account); err != nil {
+			service.logger.Errorf("(%s) send verification email to user '%s' failed: %s", r.RemoteAddr, request.Username, err.Error())
+		}
+	}
+
 	sendResponse(w, http.StatusOK, "User registered successfully", map[string]interface{}{
 		"userId": id,
 	})
 }
+
+func (service *LoginService) discardRegistration(r *http.Request, id int, username string) {
+	if err := service.accountRepo.DeleteAccountById(id); err != nil {
+		service.logger.Errorf("(%s) delete user '%s' failed: %s", r.RemoteAddr, username, err.Error())
+	}
+}
@@ -24,8 +24,8 @@ func TestLoginHandlerSuccess(t *testing.T) {
 		Return(hashedPassword, nil)
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
-		Return(repository.Account{Password: string(hashedPassword)}, nil)
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
 	mockedLogger := new(mocks.Logger)
 	service := NewService(LoginServiceConfig{
 		Jwt: security.JwtConfig{
@@ -64,8 +64,10 @@ func TestLoginHandlerWrongCredentials(t *testing.T) {
 	mockedHashEngine := new(mocks.HashEngine)
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
-		Return(repository.Account{}, nil)
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, nil).
+		On("IncrementFailedLoginAttempts", 0).
+		Return(1, nil)
 	mockedLogger := new(mocks.Logger)
 	mockedLogger.
 		On("Errorf", mock.Anything, mock.Anything, mock.Anything)
@@ -102,7 +104,7 @@ func TestLoginHandlerWrongCredentials(t *testing.T) {
 func TestLoginHandlerUserNotExist(t *testing.T) {
 	mockedHashEngine := new(mocks.HashEngine)
 	mockedAccountRepo := new(mocks.AccountRepository)
-	mockedAccountRepo.On("GetAccountByUsername", mock.Anything).Return(repository.Account{}, errors.New("err"))
+	mockedAccountRepo.On("GetAccountByUsername", 0, mock.Anything).Return(repository.Account{}, errors.New("err"))
 	mockedLogger := new(mocks.Logger)
 	mockedLogger.
 		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
@@ -197,7 +199,7 @@ func TestRegisterHandlerShouldReturnErrorIfUserAlreadyExists(t *testing.T) {
 	mockedHashEngine := new(mocks.HashEngine)
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
+		On("GetAccountByUsername", 0, mock.Anything).
 		Return(repository.Account{}, nil)
 	mockedLogger := new(mocks.Logger)
 	mockedLogger.
@@ -230,7 +232,9 @@ func TestRegisterHandlerShouldReturnErrorWhenCreatingUserFailed(t *testing.T) {
 		Once()
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
 		Return(repository.Account{}, errors.New("user not found")).
 		On("CreateAccount", mock.Anything).
 		Return(-1, errors.New("could not create user"))
@@ -265,7 +269,9 @@ func TestRegisterHandlerShouldReturnErrorIfPasswordCouldNotBeHashed(t *testing.T
 		Once()
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
 		Return(repository.Account{}, errors.New("user not found"))
 	mockedLogger := new(mocks.Logger)
 	mockedLogger.
@@ -297,7 +303,9 @@ func TestRegisterHandlerSucceeded(t *testing.T) {
 		Return([]byte{}, nil)
 	mockedAccountRepo := new(mocks.AccountRepository)
 	mockedAccountRepo.
-		On("GetAccountByUsername", mock.Anything).
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
 		Return(repository.Account{}, errors.New("user not found")).
 		On("CreateAccount", mock.Anything).
 		Return(1, nil)
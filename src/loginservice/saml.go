@@ -0,0 +1,233 @@
+package loginservice
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flhansen/fitter-login-service/src/saml"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	samlProviderName         = "saml"
+	samlCookieName           = "saml_request"
+	maxTrackedSamlAssertions = 10000
+)
+
+var errSamlAssertionReplayed = errors.New("saml assertion was already used")
+
+type samlAssertionCache struct {
+	mutex   sync.Mutex
+	entries map[string]time.Time
+}
+
+func WithSamlServiceProvider(provider saml.ServiceProvider) ServiceOption {
+	return func(service *LoginService) {
+		service.samlProvider = provider
+	}
+}
+
+func newSamlServiceProvider(config saml.Config, logger Logger) saml.ServiceProvider {
+	if config.EntityId == "" || config.IdpSsoUrl == "" {
+		return nil
+	}
+
+	provider, err := saml.NewServiceProvider(config)
+	if err != nil {
+		logger.Errorf("create saml service provider failed: %s", err.Error())
+		return nil
+	}
+
+	return provider
+}
+
+func newSamlAssertionCache() *samlAssertionCache {
+	return &samlAssertionCache{
+		entries: map[string]time.Time{},
+	}
+}
+
+func (cache *samlAssertionCache) use(id string, expiresAt time.Time, now time.Time) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if usedUntil, ok := cache.entries[id]; ok && now.Before(usedUntil) {
+		return false
+	}
+
+	if len(cache.entries) >= maxTrackedSamlAssertions {
+		cache.prune(now)
+	}
+
+	if len(cache.entries) >= maxTrackedSamlAssertions {
+		return false
+	}
+
+	cache.entries[id] = expiresAt
+	return true
+}
+
+func (cache *samlAssertionCache) prune(now time.Time) {
+	for id, expiresAt := range cache.entries {
+		if !now.Before(expiresAt) {
+			delete(cache.entries, id)
+		}
+	}
+}
+
+func (service *LoginService) samlServiceProvider(w http.ResponseWriter) (saml.ServiceProvider, bool) {
+	if service.samlProvider == nil || service.linkedIdentityRepo == nil {
+		sendSimpleResponse(w, http.StatusNotImplemented, "SAML login is not available.")
+		return nil, false
+	}
+
+	return service.samlProvider, true
+}
+
+func samlUsername(identity saml.Identity) string {
+	if identity.Username != "" {
+		return identity.Username
+	}
+
+	if at := strings.LastIndex(identity.Email, "@"); at > 0 {
+		return identity.Email[:at]
+	}
+
+	return identity.Subject
+}
+
+func (service *LoginService) SamlMetadataHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	provider, ok := service.samlServiceProvider(w)
+	if !ok {
+		return
+	}
+
+	metadata, err := provider.Metadata()
+	if err != nil {
+		service.logger.Errorf("(%s) create saml metadata failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not create SAML metadata.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+func (service *LoginService) SamlLoginHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	provider, ok := service.samlServiceProvider(w)
+	if !ok {
+		return
+	}
+
+	requestId, err := saml.NewRequestId()
+	if err != nil {
+		service.logger.Errorf("(%s) start saml login failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not start SAML login.")
+		return
+	}
+
+	relayState, err := service.signFederationState(samlProviderName, requestId, 0)
+	if err != nil {
+		service.logger.Errorf("(%s) start saml login failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not start SAML login.")
+		return
+	}
+
+	requestUrl, err := provider.AuthnRequestUrl(requestId, relayState)
+	if err != nil {
+		service.logger.Errorf("(%s) start saml login failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not start SAML login.")
+		return
+	}
+
+	// The identity provider posts the response cross-site, so the cookie that
+	// binds it to this browser has to be sent with SameSite=None.
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlCookieName,
+		Value:    requestId,
+		Path:     federationCookiePath,
+		MaxAge:   int(service.config.Federation.stateLifetime().Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	http.Redirect(w, r, requestUrl, http.StatusFound)
+}
+
+func (service *LoginService) parseSamlState(r *http.Request) (federationState, error) {
+	state, err := service.parseFederationStateToken(r.PostForm.Get("RelayState"), samlProviderName)
+	if err != nil {
+		return federationState{}, err
+	}
+
+	cookie, err := r.Cookie(samlCookieName)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		return federationState{}, errInvalidFederationState
+	}
+
+	return state, nil
+}
+
+func clearSamlCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlCookieName,
+		Path:     federationCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+func (service *LoginService) SamlAcsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	provider, ok := service.samlServiceProvider(w)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid SAML response.")
+		return
+	}
+
+	state, err := service.parseSamlState(r)
+	clearSamlCookie(w)
+	if err != nil {
+		service.logger.Warnf("(%s) saml response rejected: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid federation state.")
+		return
+	}
+
+	methods := []string{security.AuthMethodFederated}
+	identity, err := provider.ParseResponse(r.PostForm.Get("SAMLResponse"), state.Nonce)
+	if err == nil && !service.samlAssertions.use(identity.AssertionId, identity.ExpiresAt, time.Now()) {
+		err = errSamlAssertionReplayed
+	}
+	if err != nil {
+		service.logger.Warnf("(%s) saml response rejected: %s", r.RemoteAddr, err.Error())
+		service.recordLogin(r, 0, "", methods, loginFailureFederation)
+		sendSimpleResponse(w, http.StatusUnauthorized, "Federated login failed.")
+		return
+	}
+
+	account, err := service.externalAccount(r, samlProviderName, AuthenticationRequest{Username: samlUsername(identity)}, externalUser{
+		Id:       identity.Subject,
+		Username: identity.Username,
+		Email:    identity.Email,
+		Groups:   identity.Groups,
+	}, service.config.Saml.AdminGroups)
+	if err != nil {
+		service.logger.Warnf("(%s) saml login of subject '%s' failed: %s", r.RemoteAddr, identity.Subject, err.Error())
+		sendAuthenticationResponse(w, RejectAuthentication(externalLoginFailureReason(err), err))
+		return
+	}
+
+	service.completeLogin(w, r, account, loginCompletion{Methods: methods})
+}
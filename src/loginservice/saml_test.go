@@ -0,0 +1,337 @@
+package loginservice
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/saml"
+	"flhansen/fitter-login-service/src/security"
+	"flhansen/fitter-login-service/src/testhelper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	samlTestEntityId = "https://fitter.test/saml"
+	samlTestAcsUrl   = "https://fitter.test/api/auth/saml/acs"
+)
+
+func newSamlTestConfig(t *testing.T) (*testhelper.SamlIdentityProvider, LoginServiceConfig) {
+	idp, err := testhelper.NewSamlIdentityProvider("https://idp.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return idp, LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+		Saml: SamlConfig{
+			ServiceProvider: saml.Config{
+				EntityId:       samlTestEntityId,
+				AcsUrl:         samlTestAcsUrl,
+				IdpEntityId:    "https://idp.test",
+				IdpSsoUrl:      "https://idp.test/sso",
+				IdpCertificate: idp.CertificatePem(),
+			},
+			AdminGroups: []string{"fitter-admins"},
+		},
+	}
+}
+
+func samlAcsRequest(form url.Values) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/api/auth/saml/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(&http.Cookie{Name: samlCookieName, Value: "_request-1"})
+	return request
+}
+
+func samlAcsForm(t *testing.T, service *LoginService, idp *testhelper.SamlIdentityProvider, subject string) url.Values {
+	relayState, err := service.signFederationState(samlProviderName, "_request-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := idp.Response(testhelper.SamlAssertion{
+		RequestId: "_request-1",
+		Audience:  samlTestEntityId,
+		Recipient: samlTestAcsUrl,
+		Subject:   subject,
+		Attributes: map[string][]string{
+			"uid":      {"alice"},
+			"mail":     {"alice@test.com"},
+			"memberOf": {"cn=fitter-admins,ou=groups"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{}
+	form.Set("SAMLResponse", response)
+	form.Set("RelayState", relayState)
+
+	return form
+}
+
+func TestSamlMetadataHandlerShouldDescribeServiceProvider(t *testing.T) {
+	// given
+	_, config := newSamlTestConfig(t)
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, httptest.NewRequest(http.MethodGet, "/api/auth/saml/metadata", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "application/samlmetadata+xml", responseWriter.Header().Get("Content-Type"))
+	assert.Contains(t, responseWriter.Body.String(), `entityID="`+samlTestEntityId+`"`)
+}
+
+func TestSamlMetadataHandlerShouldRequireConfiguration(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, httptest.NewRequest(http.MethodGet, "/api/auth/saml/metadata", nil))
+
+	// then
+	assert.Equal(t, http.StatusNotImplemented, responseWriter.Code)
+}
+
+func TestNewServiceShouldSkipSamlProviderWithInvalidCertificate(t *testing.T) {
+	// given
+	_, config := newSamlTestConfig(t)
+	config.Saml.ServiceProvider.IdpCertificate = "invalid"
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything)
+
+	// when
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// then
+	assert.Nil(t, service.samlProvider)
+	mockedLogger.AssertCalled(t, "Errorf", "create saml service provider failed: %s", mock.Anything)
+}
+
+func TestSamlLoginHandlerShouldRedirectToIdentityProvider(t *testing.T) {
+	// given
+	_, config := newSamlTestConfig(t)
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger),
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, httptest.NewRequest(http.MethodGet, "/api/auth/saml/login", nil))
+
+	// then
+	assert.Equal(t, http.StatusFound, responseWriter.Code)
+	location, err := url.Parse(responseWriter.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "idp.test", location.Host)
+	assert.NotEmpty(t, location.Query().Get("SAMLRequest"))
+
+	state, err := service.parseFederationStateToken(location.Query().Get("RelayState"), samlProviderName)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(state.Nonce, "_"))
+
+	cookies := responseWriter.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, samlCookieName, cookies[0].Name)
+	assert.Equal(t, state.Nonce, cookies[0].Value)
+	assert.Equal(t, http.SameSiteNoneMode, cookies[0].SameSite)
+	assert.True(t, cookies[0].Secure)
+}
+
+func TestSamlAcsHandlerShouldProvisionAccount(t *testing.T) {
+	// given
+	idp, config := newSamlTestConfig(t)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsernameSkeleton", 0, UsernameSkeleton("alice")).
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("GetAccountByEmail", 0, "alice@test.com").
+		Return(repository.Account{}, sql.ErrNoRows).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "alice" && account.Email == "alice@test.com"
+		})).
+		Return(6, nil).
+		On("UpdateAccountEmailVerified", 6, mock.Anything).
+		Return(nil).
+		On("UpdateAccountRole", 6, security.RoleAdmin).
+		Return(nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", samlProviderName, "alice-id").
+		Return(repository.LinkedIdentity{}, sql.ErrNoRows).
+		On("CreateLinkedIdentity", mock.MatchedBy(func(linked repository.LinkedIdentity) bool {
+			return linked.AccountId == 6 && linked.Provider == samlProviderName && linked.Subject == "alice-id"
+		})).
+		Return(1, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, samlAcsRequest(samlAcsForm(t, service, idp, "alice-id")))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	claims := tokenClaimsFromResponse(t, responseWriter)
+	assert.Equal(t, 6, claims.UserId)
+	assert.Equal(t, security.RoleAdmin, claims.Role)
+	assert.Equal(t, []string{security.AuthMethodFederated}, claims.AuthMethods)
+}
+
+func TestSamlAcsHandlerShouldRequireSecondFactorIfPhoneVerified(t *testing.T) {
+	// given
+	idp, config := newSamlTestConfig(t)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte("codehash"), nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 6).
+		Return(repository.Account{Id: 6, Username: "alice", PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive, Role: security.RoleAdmin}, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", samlProviderName, "alice-id").
+		Return(repository.LinkedIdentity{AccountId: 6, Provider: samlProviderName, Subject: "alice-id"}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("DeleteOneTimePasswordsBefore", 6, otpPurposeLogin, mock.Anything).
+		Return(nil).
+		On("GetOneTimePasswordUsage", 6, otpPurposeLogin, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("CreateOneTimePassword", mock.Anything).
+		Return(1, nil)
+	mockedSender := new(mocks.MessageSender)
+	mockedSender.
+		On("SendMessage", mock.Anything).
+		Return(nil)
+	service := NewService(config, mockedAccountRepo, mockedHashEngine, new(mocks.Logger),
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo),
+		WithOneTimePasswordRepository(mockedOtpRepo),
+		WithMessageSender(mockedSender))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, samlAcsRequest(samlAcsForm(t, service, idp, "alice-id")))
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, true, response["mfaRequired"])
+	assert.Nil(t, response["token"])
+	mockedSender.AssertCalled(t, "SendMessage", mock.Anything)
+}
+
+func TestSamlAcsHandlerShouldRejectReplayedAssertion(t *testing.T) {
+	// given
+	idp, config := newSamlTestConfig(t)
+	account := repository.Account{Id: 6, Username: "alice", Status: repository.AccountStatusActive, Role: security.RoleAdmin}
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 6).
+		Return(account, nil)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	mockedLinkedIdentityRepo.
+		On("GetLinkedIdentity", samlProviderName, "alice-id").
+		Return(repository.LinkedIdentity{AccountId: 6, Provider: samlProviderName, Subject: "alice-id"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(config, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+	form := samlAcsForm(t, service, idp, "alice-id")
+	service.handler.ServeHTTP(httptest.NewRecorder(), samlAcsRequest(form))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, samlAcsRequest(form))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) saml response rejected: %s", mock.Anything, errSamlAssertionReplayed.Error())
+}
+
+func TestSamlAcsHandlerShouldRejectInvalidRelayState(t *testing.T) {
+	// given
+	_, config := newSamlTestConfig(t)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+	form := url.Values{}
+	form.Set("SAMLResponse", "response")
+	form.Set("RelayState", "invalid")
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, samlAcsRequest(form))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestSamlAcsHandlerShouldRejectResponseForOtherClient(t *testing.T) {
+	// given
+	idp, config := newSamlTestConfig(t)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	mockedLinkedIdentityRepo := new(mocks.LinkedIdentityRepository)
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(mockedLinkedIdentityRepo))
+	request := httptest.NewRequest(http.MethodPost, "/api/auth/saml/acs", strings.NewReader(samlAcsForm(t, service, idp, "alice-id").Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.AddCookie(&http.Cookie{Name: samlCookieName, Value: "_request-2"})
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) saml response rejected: %s", mock.Anything, errInvalidFederationState.Error())
+	mockedLinkedIdentityRepo.AssertNotCalled(t, "GetLinkedIdentity", mock.Anything, mock.Anything)
+}
+
+func TestSamlAcsHandlerShouldRejectForgedResponse(t *testing.T) {
+	// given
+	_, config := newSamlTestConfig(t)
+	forger, err := testhelper.NewSamlIdentityProvider("https://idp.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(config, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger,
+		WithLinkedIdentityRepository(new(mocks.LinkedIdentityRepository)))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, samlAcsRequest(samlAcsForm(t, service, forger, "alice-id")))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+}
@@ -0,0 +1,1060 @@
+package loginservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	scimUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimContentType                 = "application/scim+json"
+	scimActor                       = "scim"
+	scimStatusReason                = "scim provisioning"
+	maxScimPageSize                 = 100
+
+	scimTenantContextKey contextKey = "scimTenant"
+)
+
+var (
+	scimFilterPattern     = regexp.MustCompile(`^\s*(\S+)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+	scimValuePathPattern  = regexp.MustCompile(`^(?i)(emails|phoneNumbers)\[[^\]]*\]\.value$`)
+	scimMemberPathPattern = regexp.MustCompile(`^(?i)members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+	errScimInvalidSyntax = errors.New("invalid scim request")
+	errScimInvalidFilter = errors.New("unsupported scim filter")
+	errScimInvalidPath   = errors.New("unsupported scim attribute path")
+	errScimInvalidValue  = errors.New("invalid scim attribute value")
+	errScimNoTarget      = errors.New("scim operation has no target")
+	errScimMutability    = errors.New("scim attribute cannot be modified")
+	errScimUserNotFound  = errors.New("scim user not found")
+)
+
+type ScimName struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+type ScimMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type ScimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	Location     string     `json:"location"`
+	Version      string     `json:"version,omitempty"`
+}
+
+type ScimUser struct {
+	Schemas      []string         `json:"schemas"`
+	Id           string           `json:"id,omitempty"`
+	UserName     string           `json:"userName"`
+	Name         *ScimName        `json:"name,omitempty"`
+	DisplayName  string           `json:"displayName,omitempty"`
+	Active       *bool            `json:"active,omitempty"`
+	Password     string           `json:"password,omitempty"`
+	Emails       []ScimMultiValue `json:"emails,omitempty"`
+	PhoneNumbers []ScimMultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []ScimMultiValue `json:"groups,omitempty"`
+	Meta         *ScimMeta        `json:"meta,omitempty"`
+}
+
+type ScimGroup struct {
+	Schemas     []string         `json:"schemas"`
+	Id          string           `json:"id"`
+	DisplayName string           `json:"displayName"`
+	Members     []ScimMultiValue `json:"members,omitempty"`
+	Meta        *ScimMeta        `json:"meta,omitempty"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type ScimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
+
+type ScimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func (service *LoginService) scimTokens() []ScimToken {
+	tokens := service.config.Scim.Tokens
+	if service.config.Scim.Token != "" {
+		tokens = append([]ScimToken{{Token: service.config.Scim.Token}}, tokens...)
+	}
+
+	return tokens
+}
+
+func (service *LoginService) scimTenant(r *http.Request) (int, bool) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, scimToken := range service.scimTokens() {
+		if scimToken.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(scimToken.Token)) != 1 {
+			continue
+		}
+
+		if scimToken.Organization == "" {
+			return 0, true
+		}
+
+		organization, err := service.organizationBySlug(scimToken.Organization)
+		if err != nil || !organization.ScopedIdentities {
+			service.logger.Errorf("(%s) scim token of organization '%s' has no scoped tenant", r.RemoteAddr, scimToken.Organization)
+			return 0, false
+		}
+
+		return organization.Id, true
+	}
+
+	return 0, false
+}
+
+func (service *LoginService) scimAuthenticated(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		tenantId, ok := service.scimTenant(r)
+		if !ok {
+			service.logger.Warnf("(%s) scim authentication failed", r.RemoteAddr)
+			sendScimError(w, http.StatusUnauthorized, "", "Authentication required.")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), scimTenantContextKey, tenantId)
+		handle(w, r.WithContext(ctx), p)
+	}
+}
+
+func scimTenantId(r *http.Request) int {
+	tenantId, _ := r.Context().Value(scimTenantContextKey).(int)
+	return tenantId
+}
+
+func sendScimResponse(w http.ResponseWriter, status int, resource interface{}) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resource)
+}
+
+func sendScimError(w http.ResponseWriter, status int, scimType string, detail string) {
+	sendScimResponse(w, status, ScimError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func sendScimErrorResponse(w http.ResponseWriter, err error) {
+	switch err {
+	case errScimInvalidSyntax:
+		sendScimError(w, http.StatusBadRequest, "invalidSyntax", "Wrong request body format.")
+	case errScimInvalidFilter:
+		sendScimError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter.")
+	case errScimInvalidPath:
+		sendScimError(w, http.StatusBadRequest, "invalidPath", "Unsupported attribute path.")
+	case errScimNoTarget:
+		sendScimError(w, http.StatusBadRequest, "noTarget", "Operation has no target.")
+	case errScimMutability:
+		sendScimError(w, http.StatusBadRequest, "mutability", "Attribute cannot be modified.")
+	case errScimInvalidValue, errInvalidUsername, errInvalidEmail, errInvalidDisplayName, errInvalidPhoneNumber, errInvalidStatusTransition:
+		sendScimError(w, http.StatusBadRequest, "invalidValue", "Invalid attribute value.")
+	case errUsernameTaken, errUsernameConfusable, errEmailTaken:
+		sendScimError(w, http.StatusConflict, "uniqueness", "User already exists.")
+	case errScimUserNotFound:
+		sendScimError(w, http.StatusNotFound, "", "User not found.")
+	default:
+		sendScimError(w, http.StatusInternalServerError, "", "Could not process request.")
+	}
+}
+
+func scimLocation(r *http.Request, resourceType string, id string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s/scim/v2/%s/%s", scheme, r.Host, resourceType, id)
+}
+
+func scimVersion(resource interface{}) string {
+	encoded, _ := json.Marshal(resource)
+	hash := sha256.Sum256(encoded)
+	return `W/"` + hex.EncodeToString(hash[:8]) + `"`
+}
+
+func etagMatches(header string, version string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseScimFilter(filter string, attribute string) (string, error) {
+	match := scimFilterPattern.FindStringSubmatch(filter)
+	if match == nil || !strings.EqualFold(match[1], attribute) {
+		return "", errScimInvalidFilter
+	}
+
+	value, err := strconv.Unquote(`"` + match[2] + `"`)
+	if err != nil {
+		return "", errScimInvalidFilter
+	}
+
+	return value, nil
+}
+
+func scimPage(r *http.Request) (int, int, error) {
+	startIndex, err := queryInt(r, "startIndex", 1)
+	if err != nil {
+		return 0, 0, errScimInvalidValue
+	}
+
+	count, err := queryInt(r, "count", maxScimPageSize)
+	if err != nil {
+		return 0, 0, errScimInvalidValue
+	}
+
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	if count < 0 {
+		count = 0
+	}
+
+	if count > maxScimPageSize {
+		count = maxScimPageSize
+	}
+
+	return startIndex, count, nil
+}
+
+func newScimList(resources []interface{}, total int, startIndex int) ScimListResponse {
+	return ScimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+func newScimUser(r *http.Request, account repository.Account) ScimUser {
+	id := strconv.Itoa(account.Id)
+	active := account.Status == repository.AccountStatusActive
+	user := ScimUser{
+		Schemas:     []string{scimUserSchema},
+		Id:          id,
+		UserName:    account.Username,
+		DisplayName: account.DisplayName,
+		Active:      &active,
+	}
+
+	if account.DisplayName != "" {
+		user.Name = &ScimName{Formatted: account.DisplayName}
+	}
+
+	if account.Email != "" {
+		user.Emails = []ScimMultiValue{{Value: account.Email, Type: "work", Primary: true}}
+	}
+
+	if account.PhoneNumber != "" {
+		user.PhoneNumbers = []ScimMultiValue{{Value: account.PhoneNumber, Type: "mobile", Primary: true}}
+	}
+
+	if account.Role != "" {
+		user.Groups = []ScimMultiValue{{Value: account.Role, Display: account.Role, Ref: scimLocation(r, "Groups", account.Role)}}
+	}
+
+	user.Meta = &ScimMeta{
+		ResourceType: "User",
+		Created:      &account.CreationDate,
+		Location:     scimLocation(r, "Users", id),
+		Version:      scimVersion(user),
+	}
+
+	return user
+}
+
+func sendScimUser(w http.ResponseWriter, status int, user ScimUser) {
+	w.Header().Set("ETag", user.Meta.Version)
+	w.Header().Set("Location", user.Meta.Location)
+	sendScimResponse(w, status, user)
+}
+
+func primaryScimValue(values []ScimMultiValue) string {
+	for _, value := range values {
+		if value.Primary {
+			return strings.TrimSpace(value.Value)
+		}
+	}
+
+	if len(values) > 0 {
+		return strings.TrimSpace(values[0].Value)
+	}
+
+	return ""
+}
+
+func scimBool(value json.RawMessage) (bool, error) {
+	var active bool
+	if err := json.Unmarshal(value, &active); err == nil {
+		return active, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return false, errScimInvalidValue
+	}
+
+	active, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, errScimInvalidValue
+	}
+
+	return active, nil
+}
+
+func scimString(value json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return "", errScimInvalidValue
+	}
+
+	return text, nil
+}
+
+func applyScimAttribute(user *ScimUser, path string, value json.RawMessage, remove bool) error {
+	path = strings.TrimPrefix(path, scimUserSchema+":")
+	attribute := strings.ToLower(path)
+	if match := scimValuePathPattern.FindStringSubmatch(path); match != nil {
+		attribute = strings.ToLower(match[1]) + ".value"
+	}
+
+	var err error
+	switch attribute {
+	case "username":
+		if remove {
+			return errScimMutability
+		}
+
+		user.UserName, err = scimString(value)
+	case "displayname", "name.formatted":
+		displayName := ""
+		if !remove {
+			displayName, err = scimString(value)
+		}
+
+		user.DisplayName = displayName
+		user.Name = &ScimName{Formatted: displayName}
+	case "name":
+		name := ScimName{}
+		if !remove && json.Unmarshal(value, &name) != nil {
+			return errScimInvalidValue
+		}
+
+		user.DisplayName = name.Formatted
+		user.Name = &name
+	case "active":
+		if remove {
+			return errScimMutability
+		}
+
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+
+		user.Active = &active
+	case "password":
+		if remove {
+			return errScimMutability
+		}
+
+		user.Password, err = scimString(value)
+	case "emails", "phonenumbers":
+		values := []ScimMultiValue{}
+		if !remove && json.Unmarshal(value, &values) != nil {
+			return errScimInvalidValue
+		}
+
+		if attribute == "emails" {
+			user.Emails = values
+		} else {
+			user.PhoneNumbers = values
+		}
+	case "emails.value", "phonenumbers.value":
+		values := []ScimMultiValue{}
+		if !remove {
+			text, err := scimString(value)
+			if err != nil {
+				return err
+			}
+
+			values = append(values, ScimMultiValue{Value: text, Primary: true})
+		}
+
+		if attribute == "emails.value" {
+			user.Emails = values
+		} else {
+			user.PhoneNumbers = values
+		}
+	default:
+		return errScimInvalidPath
+	}
+
+	return err
+}
+
+func applyScimPatch(user *ScimUser, operation ScimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return errScimInvalidSyntax
+	}
+
+	if operation.Path != "" {
+		return applyScimAttribute(user, operation.Path, operation.Value, op == "remove")
+	}
+
+	if op == "remove" {
+		return errScimNoTarget
+	}
+
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(operation.Value, &values); err != nil {
+		return errScimInvalidValue
+	}
+
+	for path, value := range values {
+		if err := applyScimAttribute(user, path, value, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (service *LoginService) accountFromScimUser(user ScimUser, account repository.Account) (repository.Account, error) {
+	username, err := NormalizeUsername(user.UserName)
+	if err != nil {
+		return account, err
+	}
+
+	email := primaryScimValue(user.Emails)
+	if email != "" {
+		if email, err = NormalizeEmail(email); err != nil || !validateEmail(email) {
+			return account, errInvalidEmail
+		}
+	}
+
+	displayName := strings.TrimSpace(user.DisplayName)
+	if displayName == "" && user.Name != nil {
+		displayName = strings.TrimSpace(user.Name.Formatted)
+	}
+
+	if !validateDisplayName(displayName) {
+		return account, errInvalidDisplayName
+	}
+
+	phoneNumber := primaryScimValue(user.PhoneNumbers)
+	if phoneNumber != "" && !phoneNumberPattern.MatchString(phoneNumber) {
+		return account, errInvalidPhoneNumber
+	}
+
+	if user.Active != nil {
+		if *user.Active {
+			account.Status = repository.AccountStatusActive
+		} else if account.Status == repository.AccountStatusActive {
+			account.Status = repository.AccountStatusSuspended
+		}
+	}
+
+	account.Username = username
+	account.UsernameSkeleton = UsernameSkeleton(username)
+	account.Email = email
+	account.DisplayName = displayName
+	account.PhoneNumber = phoneNumber
+	return account, nil
+}
+
+func (service *LoginService) checkScimUsername(account repository.Account) error {
+	if existing, err := service.accountRepo.GetAccountByUsername(account.TenantId, account.Username); err == nil && existing.Id != account.Id {
+		return errUsernameTaken
+	}
+
+	if existing, err := service.accountRepo.GetAccountByUsernameSkeleton(account.TenantId, account.UsernameSkeleton); err == nil && existing.Id != account.Id {
+		return errUsernameConfusable
+	}
+
+	return nil
+}
+
+func (service *LoginService) checkScimEmail(account repository.Account) error {
+	if account.Email == "" {
+		return nil
+	}
+
+	if existing, err := service.accountRepo.GetAccountByEmail(account.TenantId, account.Email); err == nil && existing.Id != account.Id {
+		return errEmailTaken
+	}
+
+	return nil
+}
+
+func (service *LoginService) saveScimUser(current repository.Account, user ScimUser) (repository.Account, error) {
+	account, err := service.accountFromScimUser(user, current)
+	if err != nil {
+		return current, err
+	}
+
+	if account.Username != current.Username {
+		if err := service.checkScimUsername(account); err != nil {
+			return current, err
+		}
+	}
+
+	if account.Email != current.Email {
+		if err := service.checkScimEmail(account); err != nil {
+			return current, err
+		}
+	}
+
+	account.EmailVerified = current.EmailVerified && account.Email == current.Email
+	account.PhoneVerified = current.PhoneVerified && account.PhoneNumber == current.PhoneNumber
+
+	var transition *repository.AccountStatusTransition
+	if account.Status != current.Status {
+		if !canTransitionAccountStatus(current.Status, account.Status) {
+			return current, errInvalidStatusTransition
+		}
+
+		transition = &repository.AccountStatusTransition{
+			AccountId:    current.Id,
+			FromStatus:   current.Status,
+			ToStatus:     account.Status,
+			Reason:       scimStatusReason,
+			Actor:        scimActor,
+			CreationDate: time.Now(),
+		}
+	}
+
+	if user.Password != "" {
+		passwordHash, err := service.hashEngine.HashPassword([]byte(user.Password))
+		if err != nil {
+			return current, err
+		}
+
+		account.Password = string(passwordHash)
+		account.PasswordResetRequired = false
+	}
+
+	if err := service.accountRepo.UpdateAccount(account, transition); err != nil {
+		return current, err
+	}
+
+	return account, nil
+}
+
+func (service *LoginService) scimAccount(r *http.Request, value string) (repository.Account, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return repository.Account{}, errScimUserNotFound
+	}
+
+	account, err := service.accountRepo.GetAccountById(id)
+	if err != nil || account.Status == repository.AccountStatusDeleted || account.TenantId != scimTenantId(r) {
+		return repository.Account{}, errScimUserNotFound
+	}
+
+	return account, nil
+}
+
+func (service *LoginService) scimAccountForUpdate(w http.ResponseWriter, r *http.Request, p httprouter.Params) (repository.Account, bool) {
+	account, err := service.scimAccount(r, p.ByName("id"))
+	if err != nil {
+		sendScimErrorResponse(w, err)
+		return account, false
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, newScimUser(r, account).Meta.Version) {
+		sendScimError(w, http.StatusPreconditionFailed, "", "Resource version mismatch.")
+		return account, false
+	}
+
+	return account, true
+}
+
+func (service *LoginService) ScimUsersHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	resources := []interface{}{}
+	total := 0
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		userName, err := parseScimFilter(filter, "userName")
+		if err != nil {
+			sendScimErrorResponse(w, err)
+			return
+		}
+
+		if userName, err = NormalizeUsername(userName); err == nil {
+			account, err := service.accountRepo.GetAccountByUsername(scimTenantId(r), userName)
+			if err == nil && account.Status != repository.AccountStatusDeleted {
+				total = 1
+				if startIndex == 1 && count > 0 {
+					resources = append(resources, newScimUser(r, account))
+				}
+			}
+		}
+
+		sendScimResponse(w, http.StatusOK, newScimList(resources, total, startIndex))
+		return
+	}
+
+	tenantId := scimTenantId(r)
+	accountFilter := repository.AccountFilter{TenantId: &tenantId, Limit: count, Offset: startIndex - 1}
+	accounts, err := service.accountRepo.GetAccounts(accountFilter)
+	if err != nil {
+		service.logger.Errorf("(%s) list scim users failed: %s", r.RemoteAddr, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	total, err = service.accountRepo.CountAccounts(accountFilter)
+	if err != nil {
+		service.logger.Errorf("(%s) count scim users failed: %s", r.RemoteAddr, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	for _, account := range accounts {
+		resources = append(resources, newScimUser(r, account))
+	}
+
+	sendScimResponse(w, http.StatusOK, newScimList(resources, total, startIndex))
+}
+
+func (service *LoginService) ScimUserHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	account, err := service.scimAccount(r, p.ByName("id"))
+	if err != nil {
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	user := newScimUser(r, account)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, user.Meta.Version) {
+		w.Header().Set("ETag", user.Meta.Version)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	sendScimUser(w, http.StatusOK, user)
+}
+
+func (service *LoginService) ScimCreateUserHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request ScimUser
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendScimErrorResponse(w, errScimInvalidSyntax)
+		return
+	}
+
+	account, err := service.accountFromScimUser(request, repository.Account{
+		TenantId:     scimTenantId(r),
+		Role:         security.RoleUser,
+		Status:       repository.AccountStatusActive,
+		CreationDate: time.Now(),
+	})
+	if err == nil {
+		err = service.checkScimUsername(account)
+	}
+
+	if err == nil {
+		err = service.checkScimEmail(account)
+	}
+
+	if err != nil {
+		service.logger.Warnf("(%s) provision scim user '%s' rejected: %s", r.RemoteAddr, request.UserName, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	if request.Password != "" {
+		passwordHash, err := service.hashEngine.HashPassword([]byte(request.Password))
+		if err != nil {
+			service.logger.Errorf("(%s) hashing password failed: %s", r.RemoteAddr, err.Error())
+			sendScimErrorResponse(w, err)
+			return
+		}
+
+		account.Password = string(passwordHash)
+	}
+
+	account.Id, err = service.accountRepo.CreateAccount(account)
+	if err != nil {
+		service.logger.Errorf("(%s) insert scim user '%s' into database failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	if account.TenantId != 0 {
+		_, err := service.orgRepo.CreateMembership(repository.Membership{
+			OrganizationId: account.TenantId,
+			AccountId:      account.Id,
+			Role:           repository.MembershipRoleMember,
+			CreationDate:   time.Now(),
+		})
+		if err != nil {
+			service.logger.Errorf("(%s) add scim user '%s' to organization %d failed: %s", r.RemoteAddr, account.Username, account.TenantId, err.Error())
+			service.discardRegistration(r, account.Id, account.Username)
+			sendScimErrorResponse(w, err)
+			return
+		}
+	}
+
+	service.logger.Infof("(%s) account %d provisioned via scim", r.RemoteAddr, account.Id)
+	sendScimUser(w, http.StatusCreated, newScimUser(r, account))
+}
+
+func (service *LoginService) ScimReplaceUserHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request ScimUser
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendScimErrorResponse(w, errScimInvalidSyntax)
+		return
+	}
+
+	current, ok := service.scimAccountForUpdate(w, r, p)
+	if !ok {
+		return
+	}
+
+	account, err := service.saveScimUser(current, request)
+	if err != nil {
+		service.logger.Warnf("(%s) replace scim user %d failed: %s", r.RemoteAddr, current.Id, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	sendScimUser(w, http.StatusOK, newScimUser(r, account))
+}
+
+func (service *LoginService) ScimPatchUserHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var request ScimPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Operations) == 0 {
+		sendScimErrorResponse(w, errScimInvalidSyntax)
+		return
+	}
+
+	current, ok := service.scimAccountForUpdate(w, r, p)
+	if !ok {
+		return
+	}
+
+	user := newScimUser(r, current)
+	for _, operation := range request.Operations {
+		if err := applyScimPatch(&user, operation); err != nil {
+			sendScimErrorResponse(w, err)
+			return
+		}
+	}
+
+	account, err := service.saveScimUser(current, user)
+	if err != nil {
+		service.logger.Warnf("(%s) patch scim user %d failed: %s", r.RemoteAddr, current.Id, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	sendScimUser(w, http.StatusOK, newScimUser(r, account))
+}
+
+func (service *LoginService) ScimDeleteUserHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	account, ok := service.scimAccountForUpdate(w, r, p)
+	if !ok {
+		return
+	}
+
+	if account.TenantId != 0 {
+		if err := service.orgRepo.DeleteMembership(account.TenantId, account.Id); err != nil {
+			service.logger.Errorf("(%s) remove scim user %d from organization %d failed: %s", r.RemoteAddr, account.Id, account.TenantId, err.Error())
+			sendScimErrorResponse(w, err)
+			return
+		}
+	}
+
+	if err := service.deleteAccount(account, scimStatusReason, scimActor); err != nil {
+		service.logger.Errorf("(%s) delete scim user %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	service.logger.Infof("(%s) account %d deprovisioned via scim", r.RemoteAddr, account.Id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimGroups lists the groups a token may see. The admin group grants the
+// service-wide admin role, so it is never offered to organization tokens.
+func (service *LoginService) scimGroups(r *http.Request) []string {
+	if service.config.Scim.AllowAdminGroup && scimTenantId(r) == 0 {
+		return []string{security.RoleUser, security.RoleAdmin}
+	}
+
+	return []string{security.RoleUser}
+}
+
+func (service *LoginService) isScimGroup(r *http.Request, id string) bool {
+	for _, group := range service.scimGroups(r) {
+		if group == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (service *LoginService) newScimGroup(r *http.Request, role string, withMembers bool) (ScimGroup, error) {
+	group := ScimGroup{
+		Schemas:     []string{scimGroupSchema},
+		Id:          role,
+		DisplayName: role,
+		Meta: &ScimMeta{
+			ResourceType: "Group",
+			Location:     scimLocation(r, "Groups", role),
+		},
+	}
+
+	if !withMembers {
+		return group, nil
+	}
+
+	tenantId := scimTenantId(r)
+	filter := repository.AccountFilter{Role: role, TenantId: &tenantId, Limit: maxScimPageSize}
+	for {
+		accounts, err := service.accountRepo.GetAccounts(filter)
+		if err != nil {
+			return group, err
+		}
+
+		for _, account := range accounts {
+			if account.Status == repository.AccountStatusDeleted {
+				continue
+			}
+
+			id := strconv.Itoa(account.Id)
+			group.Members = append(group.Members, ScimMultiValue{Value: id, Display: account.Username, Ref: scimLocation(r, "Users", id)})
+		}
+
+		if len(accounts) < filter.Limit {
+			return group, nil
+		}
+
+		filter.Offset += filter.Limit
+	}
+}
+
+func scimMembersExcluded(r *http.Request) bool {
+	for _, attribute := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (service *LoginService) ScimGroupsHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	groups := service.scimGroups(r)
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		displayName, err := parseScimFilter(filter, "displayName")
+		if err != nil {
+			sendScimErrorResponse(w, err)
+			return
+		}
+
+		groups = []string{}
+		if service.isScimGroup(r, displayName) {
+			groups = append(groups, displayName)
+		}
+	}
+
+	resources := []interface{}{}
+	for _, role := range groups {
+		group, err := service.newScimGroup(r, role, !scimMembersExcluded(r))
+		if err != nil {
+			service.logger.Errorf("(%s) list members of scim group '%s' failed: %s", r.RemoteAddr, role, err.Error())
+			sendScimErrorResponse(w, err)
+			return
+		}
+
+		resources = append(resources, group)
+	}
+
+	sendScimResponse(w, http.StatusOK, newScimList(resources, len(resources), 1))
+}
+
+func (service *LoginService) ScimGroupHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	role := p.ByName("id")
+	if !service.isScimGroup(r, role) {
+		sendScimError(w, http.StatusNotFound, "", "Group not found.")
+		return
+	}
+
+	group, err := service.newScimGroup(r, role, !scimMembersExcluded(r))
+	if err != nil {
+		service.logger.Errorf("(%s) list members of scim group '%s' failed: %s", r.RemoteAddr, role, err.Error())
+		sendScimErrorResponse(w, err)
+		return
+	}
+
+	sendScimResponse(w, http.StatusOK, group)
+}
+
+func scimPatchMembers(operation ScimPatchOperation) ([]string, error) {
+	if match := scimMemberPathPattern.FindStringSubmatch(operation.Path); match != nil {
+		return []string{match[1]}, nil
+	}
+
+	if !strings.EqualFold(operation.Path, "members") {
+		return nil, errScimInvalidPath
+	}
+
+	members := []ScimMultiValue{}
+	if err := json.Unmarshal(operation.Value, &members); err != nil {
+		return nil, errScimInvalidValue
+	}
+
+	ids := []string{}
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+
+	return ids, nil
+}
+
+func (service *LoginService) ScimPatchGroupHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	role := p.ByName("id")
+	if !service.isScimGroup(r, role) {
+		sendScimError(w, http.StatusNotFound, "", "Group not found.")
+		return
+	}
+
+	var request ScimPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Operations) == 0 {
+		sendScimErrorResponse(w, errScimInvalidSyntax)
+		return
+	}
+
+	for _, operation := range request.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "remove" {
+			sendScimErrorResponse(w, errScimMutability)
+			return
+		}
+
+		if op == "remove" && role == security.RoleUser {
+			sendScimErrorResponse(w, errScimMutability)
+			return
+		}
+
+		members, err := scimPatchMembers(operation)
+		if err != nil {
+			sendScimErrorResponse(w, err)
+			return
+		}
+
+		for _, member := range members {
+			if _, err := strconv.Atoi(member); err != nil {
+				sendScimErrorResponse(w, errScimInvalidValue)
+				return
+			}
+
+			account, err := service.scimAccount(r, member)
+			if err != nil {
+				sendScimErrorResponse(w, err)
+				return
+			}
+
+			memberRole := role
+			if op == "remove" {
+				if account.Role != role {
+					continue
+				}
+
+				memberRole = security.RoleUser
+			}
+
+			if err := service.accountRepo.UpdateAccountRole(account.Id, memberRole); err != nil {
+				service.logger.Errorf("(%s) change role of account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+				sendScimErrorResponse(w, err)
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (service *LoginService) ScimServiceProviderConfigHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	sendScimResponse(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scimServiceProviderConfigSchema},
+		"patch":          map[string]interface{}{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxScimPageSize},
+		"changePassword": map[string]interface{}{"supported": true},
+		"sort":           map[string]interface{}{"supported": false},
+		"etag":           map[string]interface{}{"supported": true},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication with the configured provisioning token",
+		}},
+	})
+}
@@ -0,0 +1,612 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func scimRequest(t *testing.T, method string, path string, body string) *http.Request {
+	request, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Host = "login.fitter.test"
+	request.Header.Set("Authorization", "Bearer scim-token")
+	return request
+}
+
+func scimService(accountRepo repository.AccountRepository, hashEngine security.HashEngine, logger Logger) *LoginService {
+	return NewService(LoginServiceConfig{
+		Jwt:  security.JwtConfig{SignKey: "secret"},
+		Scim: ScimConfig{Token: "scim-token"},
+	}, accountRepo, hashEngine, logger)
+}
+
+func scimUserFromResponse(t *testing.T, responseWriter *httptest.ResponseRecorder) ScimUser {
+	var user ScimUser
+	if err := json.Unmarshal(responseWriter.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func TestParseScimFilterShouldOnlyAcceptEqualityOnAttribute(t *testing.T) {
+	value, err := parseScimFilter(`userName eq "john\"doe"`, "userName")
+	assert.NoError(t, err)
+	assert.Equal(t, `john"doe`, value)
+
+	value, err = parseScimFilter(`USERNAME EQ "alice"`, "userName")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", value)
+
+	for _, filter := range []string{`userName sw "a"`, `email eq "a"`, `userName eq "a" and active eq true`} {
+		_, err := parseScimFilter(filter, "userName")
+		assert.Equal(t, errScimInvalidFilter, err)
+	}
+}
+
+func TestScimHandlersShouldRejectInvalidToken(t *testing.T) {
+	// given
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything)
+	service := scimService(new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+	unconfigured := NewService(LoginServiceConfig{}, new(mocks.AccountRepository), new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request := scimRequest(t, http.MethodGet, "/scim/v2/Users", "")
+	request.Header.Set("Authorization", "Bearer wrong")
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	unconfiguredRequest := scimRequest(t, http.MethodGet, "/scim/v2/Users", "")
+	unconfiguredRequest.Header.Set("Authorization", "Bearer ")
+	unconfiguredResponseWriter := httptest.NewRecorder()
+	unconfigured.handler.ServeHTTP(unconfiguredResponseWriter, unconfiguredRequest)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	assert.Equal(t, scimContentType, responseWriter.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusUnauthorized, unconfiguredResponseWriter.Code)
+}
+
+func TestScimCreateUserHandlerShouldProvisionAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByEmail", 0, "john@test.com").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Username == "jdoe" && account.Email == "john@test.com" && account.DisplayName == "John Doe" &&
+				account.Password == "hashed" && account.Status == repository.AccountStatusSuspended && account.Role == security.RoleUser
+		})).
+		Return(7, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", []byte("secret")).
+		Return([]byte("hashed"), nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything)
+	service := scimService(mockedAccountRepo, mockedHashEngine, mockedLogger)
+
+	body := `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "00u1",
+		"userName": "JDoe",
+		"name": { "formatted": "John Doe" },
+		"active": false,
+		"password": "secret",
+		"emails": [{ "value": "other@test.com" }, { "value": "John@Test.com", "primary": true }]
+	}`
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPost, "/scim/v2/Users", body))
+	user := scimUserFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusCreated, responseWriter.Code)
+	assert.Equal(t, "http://login.fitter.test/scim/v2/Users/7", responseWriter.Header().Get("Location"))
+	assert.Equal(t, user.Meta.Version, responseWriter.Header().Get("ETag"))
+	assert.Equal(t, "7", user.Id)
+	assert.False(t, *user.Active)
+	assert.Empty(t, user.Password)
+}
+
+func TestScimCreateUserHandlerShouldRejectExistingUserName(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "jdoe").
+		Return(repository.Account{Id: 1, Username: "jdoe"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPost, "/scim/v2/Users", `{ "userName": "jdoe" }`))
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"scimType":"uniqueness"`)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestScimCreateUserHandlerShouldRejectExistingEmail(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByEmail", 0, "john@test.com").
+		Return(repository.Account{Id: 1, Username: "john"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := `{ "userName": "jdoe", "emails": [{ "value": "john@test.com" }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPost, "/scim/v2/Users", body))
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"scimType":"uniqueness"`)
+	mockedAccountRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestScimUsersHandlerShouldFilterByUserName(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "jdoe").
+		Return(repository.Account{Id: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodGet, `/scim/v2/Users?filter=userName+eq+%22JDoe%22`, ""))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"totalResults":1`)
+	assert.Contains(t, responseWriter.Body.String(), `"userName":"jdoe"`)
+}
+
+func TestScimUsersHandlerShouldPaginate(t *testing.T) {
+	// given
+	tenantId := 0
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccounts", repository.AccountFilter{TenantId: &tenantId, Limit: 2, Offset: 2}).
+		Return([]repository.Account{{Id: 3, Username: "carol"}}, nil).
+		On("CountAccounts", mock.Anything).
+		Return(3, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodGet, "/scim/v2/Users?startIndex=3&count=2", ""))
+
+	var response ScimListResponse
+	json.Unmarshal(responseWriter.Body.Bytes(), &response)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, 3, response.TotalResults)
+	assert.Equal(t, 3, response.StartIndex)
+	assert.Equal(t, 1, response.ItemsPerPage)
+}
+
+func TestScimUserHandlerShouldHonorIfNoneMatch(t *testing.T) {
+	// given
+	account := repository.Account{Id: 7, Username: "jdoe", Status: repository.AccountStatusActive, CreationDate: time.Now()}
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(account, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodGet, "/scim/v2/Users/7", ""))
+	etag := responseWriter.Header().Get("ETag")
+
+	// when
+	request := scimRequest(t, http.MethodGet, "/scim/v2/Users/7", "")
+	request.Header.Set("If-None-Match", etag)
+	cachedResponseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(cachedResponseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, cachedResponseWriter.Code)
+}
+
+func TestScimReplaceUserHandlerShouldRejectStaleVersion(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(repository.Account{Id: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request := scimRequest(t, http.MethodPut, "/scim/v2/Users/7", `{ "userName": "jdoe", "displayName": "John" }`)
+	request.Header.Set("If-Match", `W/"stale"`)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusPreconditionFailed, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccount", mock.Anything, mock.Anything)
+}
+
+func TestScimReplaceUserHandlerShouldRenameAndClearAttributes(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(repository.Account{Id: 7, Username: "jdoe", Email: "john@test.com", EmailVerified: true, DisplayName: "John", PhoneNumber: "+4915112345678", Status: repository.AccountStatusActive}, nil).
+		On("GetAccountByUsername", 0, "john.doe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("GetAccountByUsernameSkeleton", 0, "john.doe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("UpdateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Id == 7 && account.Username == "john.doe" && account.UsernameSkeleton == "john.doe" &&
+				account.Email == "john@test.com" && account.EmailVerified && account.DisplayName == "" && account.PhoneNumber == ""
+		}), (*repository.AccountStatusTransition)(nil)).
+		Return(nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := `{ "userName": "john.doe", "emails": [{ "value": "john@test.com", "primary": true }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPut, "/scim/v2/Users/7", body))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "john.doe", scimUserFromResponse(t, responseWriter).UserName)
+	mockedAccountRepo.AssertExpectations(t)
+}
+
+func TestScimPatchUserHandlerShouldApplyOperations(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(repository.Account{Id: 7, Username: "jdoe", Email: "john@test.com", EmailVerified: true, Status: repository.AccountStatusActive}, nil).
+		On("GetAccountByEmail", 0, "jdoe@test.com").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("UpdateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Email == "jdoe@test.com" && account.DisplayName == "John Doe" && !account.EmailVerified
+		}), mock.MatchedBy(func(transition *repository.AccountStatusTransition) bool {
+			return transition.AccountId == 7 && transition.ToStatus == repository.AccountStatusSuspended && transition.Actor == scimActor
+		})).
+		Return(nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{ "op": "Replace", "path": "emails[type eq \"work\"].value", "value": "jdoe@test.com" },
+			{ "op": "replace", "value": { "displayName": "John Doe", "active": "False" } }
+		]
+	}`
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Users/7", body))
+	user := scimUserFromResponse(t, responseWriter)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.False(t, *user.Active)
+	assert.Equal(t, "jdoe@test.com", user.Emails[0].Value)
+	mockedAccountRepo.AssertExpectations(t)
+}
+
+func TestScimPatchUserHandlerShouldRejectUnsupportedPath(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(repository.Account{Id: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := `{ "Operations": [{ "op": "replace", "path": "nickName", "value": "jd" }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Users/7", body))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"scimType":"invalidPath"`)
+}
+
+func TestScimDeleteUserHandlerShouldDeleteAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 7).
+		Return(repository.Account{Id: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil).
+		On("DeleteAccount", 7, mock.MatchedBy(func(transition *repository.AccountStatusTransition) bool {
+			return transition.AccountId == 7 && transition.ToStatus == repository.AccountStatusDeleted && transition.Actor == scimActor
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodDelete, "/scim/v2/Users/7", ""))
+
+	// then
+	assert.Equal(t, http.StatusNoContent, responseWriter.Code)
+	mockedAccountRepo.AssertExpectations(t)
+}
+
+func TestScimGroupsHandlerShouldListRolesWithMembers(t *testing.T) {
+	// given
+	tenantId := 0
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccounts", repository.AccountFilter{Role: security.RoleAdmin, TenantId: &tenantId, Limit: maxScimPageSize}).
+		Return([]repository.Account{{Id: 1, Username: "root"}}, nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+	service.config.Scim.AllowAdminGroup = true
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodGet, `/scim/v2/Groups?filter=displayName+eq+%22admin%22`, ""))
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"totalResults":1`)
+	assert.Contains(t, responseWriter.Body.String(), `"members":[{"value":"1","display":"root"`)
+}
+
+func TestScimPatchGroupHandlerShouldChangeRoles(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 5).
+		Return(repository.Account{Id: 5, Role: security.RoleUser}, nil).
+		On("GetAccountById", 6).
+		Return(repository.Account{Id: 6, Role: security.RoleAdmin}, nil).
+		On("UpdateAccountRole", 5, security.RoleAdmin).
+		Return(nil).
+		On("UpdateAccountRole", 6, security.RoleUser).
+		Return(nil)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+	service.config.Scim.AllowAdminGroup = true
+
+	body := `{
+		"Operations": [
+			{ "op": "add", "path": "members", "value": [{ "value": "5" }] },
+			{ "op": "remove", "path": "members[value eq \"6\"]" }
+		]
+	}`
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Groups/admin", body))
+
+	// then
+	assert.Equal(t, http.StatusNoContent, responseWriter.Code)
+	mockedAccountRepo.AssertExpectations(t)
+}
+
+func TestScimPatchGroupHandlerShouldNotRemoveFromDefaultGroup(t *testing.T) {
+	// given
+	service := scimService(new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := `{ "Operations": [{ "op": "remove", "path": "members[value eq \"6\"]" }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Groups/user", body))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+	assert.Contains(t, responseWriter.Body.String(), `"scimType":"mutability"`)
+}
+
+func TestScimPatchGroupHandlerShouldNotExposeAdminGroupByDefault(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := scimService(mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := `{ "Operations": [{ "op": "add", "path": "members", "value": [{ "value": "5" }] }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Groups/admin", body))
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountRole", mock.Anything, mock.Anything)
+}
+
+func TestScimPatchGroupHandlerShouldNotExposeAdminGroupToOrganizationToken(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{
+		Scim: ScimConfig{Tokens: []ScimToken{{Token: "scim-token", Organization: "acme"}}, AllowAdminGroup: true},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	body := `{ "Operations": [{ "op": "add", "path": "members", "value": [{ "value": "5" }] }] }`
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodPatch, "/scim/v2/Groups/admin", body))
+
+	// then
+	assert.Equal(t, http.StatusNotFound, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountRole", mock.Anything, mock.Anything)
+}
+
+func TestScimHandlersShouldScopeOrganizationTokenToTenant(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "jdoe").
+		Return(repository.Account{Id: 3, TenantId: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil).
+		On("GetAccountById", 4).
+		Return(repository.Account{Id: 4, Username: "other", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Scim: ScimConfig{Tokens: []ScimToken{{Token: "scim-token", Organization: "acme"}}},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	listResponseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(listResponseWriter, scimRequest(t, http.MethodGet, `/scim/v2/Users?filter=userName+eq+%22jdoe%22`, ""))
+	getResponseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(getResponseWriter, scimRequest(t, http.MethodGet, "/scim/v2/Users/4", ""))
+
+	// then
+	assert.Contains(t, listResponseWriter.Body.String(), `"totalResults":1`)
+	assert.Equal(t, http.StatusNotFound, getResponseWriter.Code)
+}
+
+func TestScimCreateUserHandlerShouldProvisionTenantUserThatCanLogIn(t *testing.T) {
+	// given
+	passwordHash := hashedTestPassword(t)
+	account := repository.Account{Id: 9, TenantId: 7, Username: "jdoe", Password: passwordHash, Role: security.RoleUser, Status: repository.AccountStatusActive}
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil).
+		On("CreateMembership", mock.MatchedBy(func(membership repository.Membership) bool {
+			return membership.OrganizationId == 7 && membership.AccountId == 9 && membership.Role == repository.MembershipRoleMember
+		})).
+		Return(1, nil).
+		On("GetMembership", 7, 9).
+		Return(repository.Membership{OrganizationId: 7, AccountId: 9, Role: repository.MembershipRoleMember}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 7, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		Once().
+		On("GetAccountByUsernameSkeleton", 7, "jdoe").
+		Return(repository.Account{}, errors.New("user not found")).
+		On("CreateAccount", mock.MatchedBy(func(created repository.Account) bool {
+			return created.TenantId == 7 && created.Username == "jdoe"
+		})).
+		Return(9, nil).
+		On("GetAccountByUsername", 7, "jdoe").
+		Return(account, nil)
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", []byte("testpass")).
+		Return([]byte(passwordHash), nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt:  security.JwtConfig{SignKey: "secret"},
+		Scim: ScimConfig{Tokens: []ScimToken{{Token: "scim-token", Organization: "acme"}}},
+	}, mockedAccountRepo, mockedHashEngine, mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	createResponseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(createResponseWriter, scimRequest(t, http.MethodPost, "/scim/v2/Users", `{ "userName": "jdoe", "password": "testpass" }`))
+	loginResponseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(loginResponseWriter, organizationLoginRequest(t, "jdoe", "acme"))
+
+	// then
+	assert.Equal(t, http.StatusCreated, createResponseWriter.Code)
+	assert.Equal(t, http.StatusOK, loginResponseWriter.Code)
+	assert.Equal(t, 7, tokenClaimsFromResponse(t, loginResponseWriter).OrgId)
+	mockedOrgRepo.AssertCalled(t, "CreateMembership", mock.Anything)
+}
+
+func TestScimDeleteUserHandlerShouldRemoveTenantMembership(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme", ScopedIdentities: true}, nil).
+		On("DeleteMembership", 7, 9).
+		Return(nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 9).
+		Return(repository.Account{Id: 9, TenantId: 7, Username: "jdoe", Status: repository.AccountStatusActive}, nil).
+		On("DeleteAccount", 9, mock.Anything).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Scim: ScimConfig{Tokens: []ScimToken{{Token: "scim-token", Organization: "acme"}}},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodDelete, "/scim/v2/Users/9", ""))
+
+	// then
+	assert.Equal(t, http.StatusNoContent, responseWriter.Code)
+	mockedOrgRepo.AssertCalled(t, "DeleteMembership", 7, 9)
+	mockedAccountRepo.AssertCalled(t, "DeleteAccount", 9, mock.Anything)
+}
+
+func TestScimHandlersShouldRejectTokenOfUnscopedOrganization(t *testing.T) {
+	// given
+	mockedOrgRepo := new(mocks.OrganizationRepository)
+	mockedOrgRepo.
+		On("GetOrganizationBySlug", "acme").
+		Return(repository.Organization{Id: 7, Slug: "acme"}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Errorf", mock.Anything, mock.Anything, mock.Anything).
+		On("Warnf", mock.Anything, mock.Anything)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	service := NewService(LoginServiceConfig{
+		Scim: ScimConfig{Tokens: []ScimToken{{Token: "scim-token", Organization: "acme"}}},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger,
+		WithOrganizationRepository(mockedOrgRepo))
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, scimRequest(t, http.MethodGet, "/scim/v2/Users", ""))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	assert.Len(t, mockedAccountRepo.Calls, 0)
+}
@@ -0,0 +1,188 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"errors"
+	"flhansen/fitter-login-service/src/repository"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	statusActorSystem = "system"
+	maxStatusReason   = 255
+)
+
+var (
+	errAccountPending          = errors.New("account is pending")
+	errAccountSuspended        = errors.New("account is suspended")
+	errAccountDeleted          = errors.New("account is deleted")
+	errInvalidStatusTransition = errors.New("invalid account status transition")
+	accountStatusTransitions   = map[string][]string{
+		repository.AccountStatusPending:   {repository.AccountStatusActive, repository.AccountStatusSuspended, repository.AccountStatusDeleted},
+		repository.AccountStatusActive:    {repository.AccountStatusSuspended, repository.AccountStatusDeleted},
+		repository.AccountStatusSuspended: {repository.AccountStatusActive, repository.AccountStatusDeleted},
+	}
+)
+
+type AccountStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type AccountStatusTransitionResponse struct {
+	FromStatus   string    `json:"fromStatus"`
+	ToStatus     string    `json:"toStatus"`
+	Reason       string    `json:"reason"`
+	Actor        string    `json:"actor"`
+	CreationDate time.Time `json:"creationDate"`
+}
+
+func canTransitionAccountStatus(from string, to string) bool {
+	for _, status := range accountStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAccountStatus(status string) bool {
+	_, ok := accountStatusTransitions[status]
+	return ok || status == repository.AccountStatusDeleted
+}
+
+func checkAccountStatus(account repository.Account) error {
+	switch account.Status {
+	case repository.AccountStatusActive:
+		return nil
+	case repository.AccountStatusPending:
+		return errAccountPending
+	case repository.AccountStatusSuspended:
+		return errAccountSuspended
+	default:
+		return errAccountDeleted
+	}
+}
+
+func sendAccountStatusResponse(w http.ResponseWriter, err error) {
+	switch err {
+	case errAccountPending:
+		sendSimpleResponse(w, http.StatusForbidden, "Account not activated.")
+	case errAccountSuspended:
+		sendSimpleResponse(w, http.StatusForbidden, "Account suspended.")
+	case errAccountDeleted:
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+	case errNotOrganizationMember:
+		sendSimpleResponse(w, http.StatusForbidden, "Not a member of the organization.")
+	default:
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not generate token.")
+	}
+}
+
+func accountStatusTransition(account repository.Account, status string, reason string, actor string) (repository.AccountStatusTransition, error) {
+	if !canTransitionAccountStatus(account.Status, status) {
+		return repository.AccountStatusTransition{}, errInvalidStatusTransition
+	}
+
+	return repository.AccountStatusTransition{
+		AccountId:    account.Id,
+		FromStatus:   account.Status,
+		ToStatus:     status,
+		Reason:       reason,
+		Actor:        actor,
+		CreationDate: time.Now(),
+	}, nil
+}
+
+func (service *LoginService) changeAccountStatus(account repository.Account, status string, reason string, actor string) error {
+	transition, err := accountStatusTransition(account, status, reason, actor)
+	if err != nil {
+		return err
+	}
+
+	return service.accountRepo.UpdateAccountStatus(transition)
+}
+
+func (service *LoginService) adminChangeAccountStatus(w http.ResponseWriter, r *http.Request, p httprouter.Params, status string) {
+	claims := claimsFromRequest(r)
+
+	var request AccountStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if status == "" {
+		status = request.Status
+	}
+
+	if !isAccountStatus(status) || len(request.Reason) > maxStatusReason {
+		sendSimpleResponse(w, http.StatusBadRequest, "Invalid account status.")
+		return
+	}
+
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	if account.Id == claims.UserId {
+		sendSimpleResponse(w, http.StatusBadRequest, "Administrators cannot change the status of their own account.")
+		return
+	}
+
+	if err := service.changeAccountStatus(account, status, request.Reason, claims.Username); err != nil {
+		if err == errInvalidStatusTransition {
+			sendSimpleResponse(w, http.StatusConflict, "Account status transition not allowed.")
+			return
+		}
+
+		service.logger.Errorf("(%s) change status of account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not change account status.")
+		return
+	}
+
+	service.logger.Infof("(%s) account %d changed from '%s' to '%s' by '%s'", r.RemoteAddr, account.Id, account.Status, status, claims.Username)
+	sendResponse(w, http.StatusOK, "Account status changed.", map[string]interface{}{
+		"status": status,
+	})
+}
+
+func (service *LoginService) AdminAccountStatusHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	service.adminChangeAccountStatus(w, r, p, "")
+}
+
+func (service *LoginService) AdminAccountStatusHistoryHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	account, ok := service.accountFromParams(w, p)
+	if !ok {
+		return
+	}
+
+	transitions, err := service.accountRepo.GetAccountStatusTransitions(account.Id)
+	if err != nil {
+		service.logger.Errorf("(%s) get status history of account %d failed: %s", r.RemoteAddr, account.Id, err.Error())
+		sendSimpleResponse(w, http.StatusInternalServerError, "Could not get account status history.")
+		return
+	}
+
+	response := []AccountStatusTransitionResponse{}
+	for _, transition := range transitions {
+		response = append(response, AccountStatusTransitionResponse{
+			FromStatus:   transition.FromStatus,
+			ToStatus:     transition.ToStatus,
+			Reason:       transition.Reason,
+			Actor:        transition.Actor,
+			CreationDate: transition.CreationDate,
+		})
+	}
+
+	sendResponse(w, http.StatusOK, "Account status history found.", map[string]interface{}{
+		"transitions": response,
+	})
+}
@@ -0,0 +1,219 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCanTransitionAccountStatus(t *testing.T) {
+	assert.True(t, canTransitionAccountStatus(repository.AccountStatusPending, repository.AccountStatusActive))
+	assert.True(t, canTransitionAccountStatus(repository.AccountStatusActive, repository.AccountStatusSuspended))
+	assert.True(t, canTransitionAccountStatus(repository.AccountStatusSuspended, repository.AccountStatusActive))
+	assert.False(t, canTransitionAccountStatus(repository.AccountStatusActive, repository.AccountStatusPending))
+	assert.False(t, canTransitionAccountStatus(repository.AccountStatusDeleted, repository.AccountStatusActive))
+	assert.False(t, canTransitionAccountStatus(repository.AccountStatusActive, repository.AccountStatusActive))
+}
+
+func TestLoginHandlerShouldRejectSuspendedAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusSuspended}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	var response map[string]interface{}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+	assert.Equal(t, "Account suspended.", response["message"])
+	assert.Nil(t, response["token"])
+}
+
+func TestLoginHandlerShouldRejectDeletedAccountAsWrongCredentials(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, "testuser").
+		Return(repository.Account{Id: 1, Username: "testuser", Password: hashedTestPassword(t), Status: repository.AccountStatusDeleted}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, loginRequest(t, "testuser", "testpass"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+}
+
+func TestIssueTokenShouldRefuseInactiveAccount(t *testing.T) {
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, new(mocks.AccountRepository), new(mocks.HashEngine), new(mocks.Logger))
+
+	_, err := service.issueToken(repository.Account{Id: 1, Status: repository.AccountStatusPending}, 0, []string{security.AuthMethodPassword}, localAuthenticatorName, time.Now(), time.Hour)
+
+	assert.ErrorIs(t, err, errAccountPending)
+}
+
+func TestRegisterHandlerShouldCreatePendingAccountIfVerificationRequired(t *testing.T) {
+	// given
+	mockedHashEngine := new(mocks.HashEngine)
+	mockedHashEngine.
+		On("HashPassword", mock.Anything).
+		Return([]byte{}, nil)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{}, assert.AnError).
+		On("GetAccountByUsernameSkeleton", 0, mock.Anything).
+		Return(repository.Account{}, assert.AnError).
+		On("CreateAccount", mock.MatchedBy(func(account repository.Account) bool {
+			return account.Status == repository.AccountStatusPending
+		})).
+		Return(1, nil)
+	service := NewService(LoginServiceConfig{
+		Email: EmailConfig{RequireVerification: true},
+	}, mockedAccountRepo, mockedHashEngine, new(mocks.Logger))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass", "email": "test@test.com" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/register", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "CreateAccount", mock.Anything)
+}
+
+func TestEmailConfirmHandlerShouldActivatePendingAccount(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Email: "test@test.com", Status: repository.AccountStatusPending}, nil).
+		On("UpdateAccountEmailVerified", 1, mock.Anything).
+		Return(nil).
+		On("UpdateAccountStatus", mock.MatchedBy(func(transition repository.AccountStatusTransition) bool {
+			return transition.FromStatus == repository.AccountStatusPending && transition.ToStatus == repository.AccountStatusActive && transition.Actor == statusActorSystem
+		})).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	token := verificationToken(t, 1, "test@test.com", "secret")
+	request, _ := http.NewRequest(http.MethodGet, "/api/auth/email/confirm?token="+url.QueryEscape(token), nil)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountStatus", mock.Anything)
+}
+
+func TestAdminAccountStatusHandlerShouldRejectInvalidTransition(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusDeleted}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/accounts/2/status", bytes.NewBufferString(`{ "status": "active", "reason": "restore" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusConflict, responseWriter.Code)
+	mockedAccountRepo.AssertNotCalled(t, "UpdateAccountStatus", mock.Anything)
+}
+
+func TestAdminAccountStatusHandlerShouldRecordReason(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusActive}, nil).
+		On("UpdateAccountStatus", mock.MatchedBy(func(transition repository.AccountStatusTransition) bool {
+			return transition.ToStatus == repository.AccountStatusSuspended && transition.Reason == "chargeback"
+		})).
+		Return(nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Infof", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/admin/accounts/2/status", bytes.NewBufferString(`{ "status": "suspended", "reason": "chargeback" }`))
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	mockedAccountRepo.AssertCalled(t, "UpdateAccountStatus", mock.Anything)
+}
+
+func TestAdminAccountStatusHistoryHandlerSucceeded(t *testing.T) {
+	// given
+	mockedAccountRepo := sessionAccountRepository(1)
+	mockedAccountRepo.
+		On("GetAccountById", 2).
+		Return(repository.Account{Id: 2, Username: "testuser", Status: repository.AccountStatusSuspended}, nil).
+		On("GetAccountStatusTransitions", 2).
+		Return([]repository.AccountStatusTransition{
+			{FromStatus: repository.AccountStatusActive, ToStatus: repository.AccountStatusSuspended, Reason: "chargeback", Actor: "admin"},
+		}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodGet, "/api/admin/accounts/2/status", nil)
+	request.Header.Set("Authorization", roleBearerHeader(t, 1, "admin", security.RoleAdmin, "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response struct {
+		Transitions []AccountStatusTransitionResponse `json:"transitions"`
+	}
+	err := json.NewDecoder(responseWriter.Body).Decode(&response)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Len(t, response.Transitions, 1)
+	assert.Equal(t, "chargeback", response.Transitions[0].Reason)
+}
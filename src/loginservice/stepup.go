@@ -0,0 +1,103 @@
+package loginservice
+
+import (
+	"encoding/json"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const otpPurposeStepUp = "step_up"
+
+type StepUpOtpRequest struct {
+	Channel string `json:"channel,omitempty"`
+}
+
+type StepUpRequest struct {
+	Password string `json:"password,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+func (service *LoginService) StepUpOtpHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request StepUpOtpRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+			sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+			return
+		}
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil || !service.requiresSecondFactor(account) {
+		sendSimpleResponse(w, http.StatusBadRequest, "No second factor available.")
+		return
+	}
+
+	if err := service.sendOneTimePassword(account.Id, account.PhoneNumber, request.Channel, otpPurposeStepUp); err != nil {
+		service.logger.Errorf("(%s) send one-time password to user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendOneTimePasswordErrorResponse(w, err, "Could not send one-time password.")
+		return
+	}
+
+	sendSimpleResponse(w, http.StatusOK, "One-time password sent.")
+}
+
+func (service *LoginService) StepUpHandler(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	claims := claimsFromRequest(r)
+
+	var request StepUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		service.logger.Errorf("(%s) decode request body failed: %s", r.RemoteAddr, err.Error())
+		sendSimpleResponse(w, http.StatusBadRequest, "Wrong request body format.")
+		return
+	}
+
+	if request.Password == "" && request.Code == "" {
+		sendSimpleResponse(w, http.StatusBadRequest, "No authentication factor given.")
+		return
+	}
+
+	account, err := service.accountRepo.GetAccountById(claims.UserId)
+	if err != nil {
+		service.logger.Errorf("(%s) get account %d failed: %s", r.RemoteAddr, claims.UserId, err.Error())
+		sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+		return
+	}
+
+	var methods []string
+	if request.Password != "" {
+		if err := service.verifyPassword(r, &account, request.Password); err != nil {
+			service.logger.Warnf("(%s) step-up of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+			sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+			return
+		}
+
+		methods = append(methods, security.AuthMethodPassword)
+	}
+
+	if request.Code != "" {
+		if err := service.verifyOneTimePassword(account.Id, otpPurposeStepUp, request.Code); err != nil {
+			service.logger.Warnf("(%s) step-up of user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+			sendSimpleResponse(w, http.StatusUnauthorized, "Wrong user credentials.")
+			return
+		}
+
+		methods = append(methods, security.AuthMethodOtp)
+	}
+
+	token, err := service.issueToken(account, claims.OrgId, methods, claims.Authenticator, time.Now(), service.stepUpLifetime())
+	if err != nil {
+		service.logger.Errorf("(%s) generate token for user '%s' failed: %s", r.RemoteAddr, account.Username, err.Error())
+		sendAccountStatusResponse(w, err)
+		return
+	}
+
+	sendResponse(w, http.StatusOK, "Step-up authentication successful.", map[string]interface{}{
+		"token": token,
+	})
+}
@@ -0,0 +1,220 @@
+package loginservice
+
+import (
+	"bytes"
+	"encoding/json"
+	"flhansen/fitter-login-service/src/mocks"
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginHandlerShouldIssuePasswordAuthenticationClaims(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountByUsername", 0, mock.Anything).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := []byte(`{ "username": "testuser", "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBuffer(body))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	json.NewDecoder(responseWriter.Body).Decode(&response)
+	claims, err := security.ParseToken(response["token"].(string), jwt.SigningMethodHS256, []byte("secret"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{security.AuthMethodPassword}, claims.AuthMethods)
+	assert.Equal(t, security.AuthContextSingleFactor, claims.AuthContext)
+	assert.NotZero(t, claims.AuthTime)
+}
+
+func TestStepUpHandlerShouldIssueElevatedTokenForPassword(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret", StepUpLifetime: time.Minute},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	body := []byte(`{ "password": "testpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	json.NewDecoder(responseWriter.Body).Decode(&response)
+	claims, err := security.ParseToken(response["token"].(string), jwt.SigningMethodHS256, []byte("secret"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, []string{security.AuthMethodPassword}, claims.AuthMethods)
+	assert.Equal(t, security.AuthContextSingleFactor, claims.AuthContext)
+	assert.LessOrEqual(t, claims.ExpiresAt, time.Now().Add(time.Minute).Unix())
+}
+
+func TestStepUpHandlerShouldIssueMultiFactorTokenForPasswordAndOtp(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeStepUp).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeStepUp, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeStepUp).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	accessToken, _ := service.issueToken(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, 0, []string{security.AuthMethodPassword}, localAuthenticatorName, time.Now().Add(-time.Hour), time.Hour)
+
+	// when
+	body := []byte(`{ "password": "testpass", "code": "123456" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	json.NewDecoder(responseWriter.Body).Decode(&response)
+	claims, err := security.ParseToken(response["token"].(string), jwt.SigningMethodHS256, []byte("secret"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, []string{security.AuthMethodPassword, security.AuthMethodOtp}, claims.AuthMethods)
+	assert.Equal(t, security.AuthContextMultiFactor, claims.AuthContext)
+	assert.GreaterOrEqual(t, claims.AuthTime, time.Now().Add(-time.Minute).Unix())
+}
+
+func TestStepUpHandlerShouldOnlyClaimFactorsVerifiedInRequest(t *testing.T) {
+	// given
+	codeHash, _ := bcrypt.GenerateFromPassword([]byte("123456"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", PhoneNumber: "+4915112345678", PhoneVerified: true, Status: repository.AccountStatusActive}, nil)
+	mockedOtpRepo := new(mocks.OneTimePasswordRepository)
+	mockedOtpRepo.
+		On("GetLatestOneTimePassword", 1, otpPurposeStepUp).
+		Return(repository.OneTimePassword{Id: 1, CodeHash: string(codeHash), ExpirationDate: time.Now().Add(time.Minute)}, nil).
+		On("GetOneTimePasswordUsage", 1, otpPurposeStepUp, mock.Anything).
+		Return(repository.OneTimePasswordUsage{}, nil).
+		On("DeleteOneTimePasswords", 1, otpPurposeStepUp).
+		Return(nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger),
+		WithOneTimePasswordRepository(mockedOtpRepo))
+
+	accessToken, _ := service.issueToken(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, 0, []string{security.AuthMethodPassword}, localAuthenticatorName, time.Now().Add(-time.Hour), time.Hour)
+
+	// when
+	body := []byte(`{ "code": "123456" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	var response map[string]interface{}
+	json.NewDecoder(responseWriter.Body).Decode(&response)
+	claims, err := security.ParseToken(response["token"].(string), jwt.SigningMethodHS256, []byte("secret"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, []string{security.AuthMethodOtp}, claims.AuthMethods)
+	assert.GreaterOrEqual(t, claims.AuthTime, time.Now().Add(-time.Minute).Unix())
+}
+
+func TestStepUpHandlerShouldRejectWrongPassword(t *testing.T) {
+	// given
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("testpass"), 8)
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Password: string(hashedPassword), Status: repository.AccountStatusActive}, nil)
+	mockedLogger := new(mocks.Logger)
+	mockedLogger.
+		On("Warnf", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), mockedLogger)
+
+	// when
+	body := []byte(`{ "password": "wrongpass" }`)
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up", bytes.NewBuffer(body))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, responseWriter.Code)
+	mockedLogger.AssertCalled(t, "Warnf", "(%s) step-up of user '%s' failed: %s", mock.Anything, "testuser", mock.Anything)
+}
+
+func TestStepUpHandlerShouldRejectMissingFactor(t *testing.T) {
+	// given
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, sessionAccountRepository(1), new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up", bytes.NewBufferString(`{}`))
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
+
+func TestStepUpOtpHandlerShouldRejectAccountWithoutSecondFactor(t *testing.T) {
+	// given
+	mockedAccountRepo := new(mocks.AccountRepository)
+	mockedAccountRepo.
+		On("GetAccountById", 1).
+		Return(repository.Account{Id: 1, Username: "testuser", Status: repository.AccountStatusActive}, nil)
+	service := NewService(LoginServiceConfig{
+		Jwt: security.JwtConfig{SignKey: "secret"},
+	}, mockedAccountRepo, new(mocks.HashEngine), new(mocks.Logger))
+
+	// when
+	request, _ := http.NewRequest(http.MethodPost, "/api/auth/step-up/otp", nil)
+	request.Header.Set("Authorization", bearerHeader(t, 1, "testuser", "secret"))
+	responseWriter := httptest.NewRecorder()
+	service.handler.ServeHTTP(responseWriter, request)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, responseWriter.Code)
+}
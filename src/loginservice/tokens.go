@@ -0,0 +1,57 @@
+package loginservice
+
+import (
+	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/security"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const defaultStepUpLifetime = 10 * time.Minute
+
+func (service *LoginService) issueToken(account repository.Account, organizationId int, methods []string, authenticator string, authTime time.Time, lifetime time.Duration) (string, error) {
+	if err := checkAccountStatus(account); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := security.JwtClaims{
+		UserId:        account.Id,
+		Username:      account.Username,
+		AuthMethods:   methods,
+		AuthContext:   security.AuthContextForMethods(methods),
+		AuthTime:      authTime.Unix(),
+		Authenticator: authenticator,
+		Role:          account.Role,
+		OrgId:         organizationId,
+		Attributes:    service.claimAttributes(account),
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+		},
+	}
+
+	if organizationId != 0 {
+		membership, err := service.membership(organizationId, account.Id)
+		if err != nil {
+			return "", err
+		}
+
+		claims.OrgRole = membership.Role
+	}
+
+	return security.GenerateTokenWithClaims(claims, jwt.SigningMethodHS256, []byte(service.config.Jwt.SignKey))
+}
+
+func (service *LoginService) stepUpLifetime() time.Duration {
+	if service.config.Jwt.StepUpLifetime <= 0 {
+		return defaultStepUpLifetime
+	}
+
+	return service.config.Jwt.StepUpLifetime
+}
+
+func (service *LoginService) recentlyAuthenticated(claims *security.JwtClaims) bool {
+	return claims.AuthTime != 0 && time.Since(time.Unix(claims.AuthTime, 0)) <= service.stepUpLifetime()
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"flhansen/fitter-login-service/src/directory"
+	"flhansen/fitter-login-service/src/federation"
 	"flhansen/fitter-login-service/src/loginservice"
+	"flhansen/fitter-login-service/src/messaging"
+	"flhansen/fitter-login-service/src/ratelimit"
 	"flhansen/fitter-login-service/src/repository"
+	"flhansen/fitter-login-service/src/saml"
 	"flhansen/fitter-login-service/src/security"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	os.Exit(runApplication())
+	os.Exit(runCommand(os.Args[1:]))
 }
 
 func createConfigFromEnvironment() (loginservice.LoginServiceConfig, repository.DatabaseConfig, error) {
@@ -24,6 +31,10 @@ func createConfigFromEnvironment() (loginservice.LoginServiceConfig, repository.
 	databaseUser := os.Getenv("LOGIN_SERVICE_DATABASE_USER")
 	databasePass := os.Getenv("LOGIN_SERVICE_DATABASE_PASSWORD")
 	databaseName := os.Getenv("LOGIN_SERVICE_DATABASE_NAME")
+	emailVerificationUrl := os.Getenv("LOGIN_SERVICE_EMAIL_VERIFICATION_URL")
+	passwordResetUrl := os.Getenv("LOGIN_SERVICE_PASSWORD_RESET_URL")
+	invitationUrl := os.Getenv("LOGIN_SERVICE_INVITATION_URL")
+	registrationMode := os.Getenv("LOGIN_SERVICE_REGISTRATION_MODE")
 
 	var serviceConfig loginservice.LoginServiceConfig
 	var databaseConfig repository.DatabaseConfig
@@ -38,12 +49,172 @@ func createConfigFromEnvironment() (loginservice.LoginServiceConfig, repository.
 		return serviceConfig, databaseConfig, err
 	}
 
+	emailVerificationRequired, err := getenvBool("LOGIN_SERVICE_EMAIL_VERIFICATION_REQUIRED")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	deletionGracePeriod, err := getenvDuration("LOGIN_SERVICE_DELETION_GRACE_PERIOD")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	deletionAnonymize, err := getenvBool("LOGIN_SERVICE_DELETION_ANONYMIZE")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	invitationLifetime, err := getenvDuration("LOGIN_SERVICE_INVITATION_LIFETIME")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	emailVerificationLifetime, err := getenvDuration("LOGIN_SERVICE_EMAIL_VERIFICATION_LIFETIME")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	emailResendInterval, err := getenvDuration("LOGIN_SERVICE_EMAIL_RESEND_INTERVAL")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	deletionPurgeInterval, err := getenvDuration("LOGIN_SERVICE_DELETION_PURGE_INTERVAL")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	stepUpLifetime, err := getenvDuration("LOGIN_SERVICE_JWT_STEP_UP_LIFETIME")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	if !loginservice.IsRegistrationMode(registrationMode) {
+		return serviceConfig, databaseConfig, fmt.Errorf("unknown registration mode '%s'", registrationMode)
+	}
+
+	registrationBlockDisposable, err := getenvBool("LOGIN_SERVICE_REGISTRATION_BLOCK_DISPOSABLE")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	disposableDomains, err := readDomainListFile(os.Getenv("LOGIN_SERVICE_REGISTRATION_DISPOSABLE_DOMAINS_FILE"))
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	attributeSchema, err := readAttributeSchemaFile(os.Getenv("LOGIN_SERVICE_ATTRIBUTES_SCHEMA_FILE"))
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	lockoutThreshold, err := getenvInt("LOGIN_SERVICE_LOCKOUT_THRESHOLD")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	lockoutDuration, err := getenvDuration("LOGIN_SERVICE_LOCKOUT_DURATION")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	lockoutMaxDuration, err := getenvDuration("LOGIN_SERVICE_LOCKOUT_MAX_DURATION")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	apiKeyMaxLifetime, err := getenvDuration("LOGIN_SERVICE_API_KEY_MAX_LIFETIME")
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	mfaConfig, err := createMfaConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	federationConfig, err := createFederationConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	ldapConfig, err := createLdapConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	samlConfig, err := createSamlConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	authenticationConfig, err := createAuthenticationConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	rateLimitConfig, err := createRateLimitConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	scimConfig, err := createScimConfigFromEnvironment()
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
+	trustedProxies, err := loginservice.ParseTrustedProxies(getenvList("LOGIN_SERVICE_TRUSTED_PROXIES"))
+	if err != nil {
+		return serviceConfig, databaseConfig, err
+	}
+
 	serviceConfig = loginservice.LoginServiceConfig{
 		Host: host,
 		Port: portValue,
 		Jwt: security.JwtConfig{
-			SignKey: jwtSignKey,
+			SignKey:        jwtSignKey,
+			StepUpLifetime: stepUpLifetime,
+		},
+		Mfa: mfaConfig,
+		Email: loginservice.EmailConfig{
+			VerificationUrl:      emailVerificationUrl,
+			VerificationLifetime: emailVerificationLifetime,
+			ResendInterval:       emailResendInterval,
+			RequireVerification:  emailVerificationRequired,
+			PasswordResetUrl:     passwordResetUrl,
+			InvitationUrl:        invitationUrl,
+			InvitationLifetime:   invitationLifetime,
 		},
+		Deletion: loginservice.DeletionConfig{
+			GracePeriod:   deletionGracePeriod,
+			PurgeInterval: deletionPurgeInterval,
+			Anonymize:     deletionAnonymize,
+		},
+		Lockout: loginservice.LockoutConfig{
+			Threshold:   lockoutThreshold,
+			Duration:    lockoutDuration,
+			MaxDuration: lockoutMaxDuration,
+		},
+		Registration: loginservice.RegistrationConfig{
+			Mode:              registrationMode,
+			AllowedDomains:    getenvList("LOGIN_SERVICE_REGISTRATION_ALLOWED_DOMAINS"),
+			BlockDisposable:   registrationBlockDisposable,
+			DisposableDomains: disposableDomains,
+		},
+		Attributes: loginservice.AttributesConfig{
+			Schema: attributeSchema,
+			Claims: getenvList("LOGIN_SERVICE_ATTRIBUTES_CLAIMS"),
+		},
+		Scim:           scimConfig,
+		Federation:     federationConfig,
+		Ldap:           ldapConfig,
+		Saml:           samlConfig,
+		Authentication: authenticationConfig,
+		ApiKeys: loginservice.ApiKeyConfig{
+			MaxLifetime: apiKeyMaxLifetime,
+		},
+		RateLimit:      rateLimitConfig,
+		TrustedProxies: trustedProxies,
 	}
 
 	databaseConfig = repository.DatabaseConfig{
@@ -57,6 +228,508 @@ func createConfigFromEnvironment() (loginservice.LoginServiceConfig, repository.
 	return serviceConfig, databaseConfig, nil
 }
 
+func createMfaConfigFromEnvironment() (loginservice.MfaConfig, error) {
+	var config loginservice.MfaConfig
+
+	codeLength, err := getenvInt("LOGIN_SERVICE_MFA_CODE_LENGTH")
+	if err != nil {
+		return config, err
+	}
+
+	codeLifetime, err := getenvDuration("LOGIN_SERVICE_MFA_CODE_LIFETIME")
+	if err != nil {
+		return config, err
+	}
+
+	maxAttempts, err := getenvInt("LOGIN_SERVICE_MFA_MAX_ATTEMPTS")
+	if err != nil {
+		return config, err
+	}
+
+	maxSends, err := getenvInt("LOGIN_SERVICE_MFA_MAX_SENDS")
+	if err != nil {
+		return config, err
+	}
+
+	attemptWindow, err := getenvDuration("LOGIN_SERVICE_MFA_ATTEMPT_WINDOW")
+	if err != nil {
+		return config, err
+	}
+
+	trustedDeviceLifetime, err := getenvDuration("LOGIN_SERVICE_MFA_TRUSTED_DEVICE_LIFETIME")
+	if err != nil {
+		return config, err
+	}
+
+	config.CodeLength = codeLength
+	config.CodeLifetime = codeLifetime
+	config.MaxAttempts = maxAttempts
+	config.MaxSends = maxSends
+	config.AttemptWindow = attemptWindow
+	config.TrustedDeviceLifetime = trustedDeviceLifetime
+
+	return config, nil
+}
+
+func createFederationConfigFromEnvironment() (loginservice.FederationConfig, error) {
+	var config loginservice.FederationConfig
+
+	linkVerifiedEmail, err := getenvBool("LOGIN_SERVICE_FEDERATION_LINK_VERIFIED_EMAIL")
+	if err != nil {
+		return config, err
+	}
+
+	stateLifetime, err := getenvDuration("LOGIN_SERVICE_FEDERATION_STATE_LIFETIME")
+	if err != nil {
+		return config, err
+	}
+
+	config.LinkVerifiedEmail = linkVerifiedEmail
+	config.StateLifetime = stateLifetime
+
+	for _, name := range getenvList("LOGIN_SERVICE_FEDERATION_PROVIDERS") {
+		prefix := "LOGIN_SERVICE_FEDERATION_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := federation.ProviderConfig{
+			Name:             name,
+			IssuerUrl:        os.Getenv(prefix + "ISSUER_URL"),
+			ClientId:         os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:     os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectUrl:      os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:           getenvList(prefix + "SCOPES"),
+			AuthorizationUrl: os.Getenv(prefix + "AUTHORIZATION_URL"),
+			TokenUrl:         os.Getenv(prefix + "TOKEN_URL"),
+			UserInfoUrl:      os.Getenv(prefix + "USERINFO_URL"),
+		}
+
+		if provider.ClientId == "" || provider.RedirectUrl == "" {
+			return config, fmt.Errorf("identity provider '%s' requires a client id and redirect url", name)
+		}
+
+		if provider.IssuerUrl == "" && (provider.AuthorizationUrl == "" || provider.TokenUrl == "" || provider.UserInfoUrl == "") {
+			return config, fmt.Errorf("identity provider '%s' requires an issuer url or authorization, token and userinfo urls", name)
+		}
+
+		config.Providers = append(config.Providers, provider)
+	}
+
+	return config, nil
+}
+
+func createLdapConfigFromEnvironment() (loginservice.LdapConfig, error) {
+	var config loginservice.LdapConfig
+
+	startTls, err := getenvBool("LOGIN_SERVICE_LDAP_START_TLS")
+	if err != nil {
+		return config, err
+	}
+
+	timeout, err := getenvDuration("LOGIN_SERVICE_LDAP_TIMEOUT")
+	if err != nil {
+		return config, err
+	}
+
+	config.Directory = directory.Config{
+		Url:               os.Getenv("LOGIN_SERVICE_LDAP_URL"),
+		StartTls:          startTls,
+		BindDn:            os.Getenv("LOGIN_SERVICE_LDAP_BIND_DN"),
+		BindPassword:      os.Getenv("LOGIN_SERVICE_LDAP_BIND_PASSWORD"),
+		BaseDn:            os.Getenv("LOGIN_SERVICE_LDAP_BASE_DN"),
+		UserFilter:        os.Getenv("LOGIN_SERVICE_LDAP_USER_FILTER"),
+		UsernameAttribute: os.Getenv("LOGIN_SERVICE_LDAP_USERNAME_ATTRIBUTE"),
+		EmailAttribute:    os.Getenv("LOGIN_SERVICE_LDAP_EMAIL_ATTRIBUTE"),
+		GroupAttribute:    os.Getenv("LOGIN_SERVICE_LDAP_GROUP_ATTRIBUTE"),
+		IdAttribute:       os.Getenv("LOGIN_SERVICE_LDAP_ID_ATTRIBUTE"),
+		Timeout:           timeout,
+	}
+
+	for _, group := range strings.Split(os.Getenv("LOGIN_SERVICE_LDAP_ADMIN_GROUPS"), ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			config.AdminGroups = append(config.AdminGroups, group)
+		}
+	}
+
+	if config.Directory.Url != "" && config.Directory.BaseDn == "" {
+		return config, fmt.Errorf("ldap directory '%s' requires a base dn", config.Directory.Url)
+	}
+
+	return config, nil
+}
+
+func createSamlConfigFromEnvironment() (loginservice.SamlConfig, error) {
+	var config loginservice.SamlConfig
+
+	clockSkew, err := getenvDuration("LOGIN_SERVICE_SAML_CLOCK_SKEW")
+	if err != nil {
+		return config, err
+	}
+
+	certificate, err := readSamlCertificateFile(os.Getenv("LOGIN_SERVICE_SAML_IDP_CERTIFICATE_FILE"))
+	if err != nil {
+		return config, err
+	}
+
+	config.ServiceProvider = saml.Config{
+		EntityId:          os.Getenv("LOGIN_SERVICE_SAML_ENTITY_ID"),
+		AcsUrl:            os.Getenv("LOGIN_SERVICE_SAML_ACS_URL"),
+		IdpEntityId:       os.Getenv("LOGIN_SERVICE_SAML_IDP_ENTITY_ID"),
+		IdpSsoUrl:         os.Getenv("LOGIN_SERVICE_SAML_IDP_SSO_URL"),
+		IdpCertificate:    certificate,
+		NameIdFormat:      os.Getenv("LOGIN_SERVICE_SAML_NAME_ID_FORMAT"),
+		UsernameAttribute: os.Getenv("LOGIN_SERVICE_SAML_USERNAME_ATTRIBUTE"),
+		EmailAttribute:    os.Getenv("LOGIN_SERVICE_SAML_EMAIL_ATTRIBUTE"),
+		GroupAttribute:    os.Getenv("LOGIN_SERVICE_SAML_GROUP_ATTRIBUTE"),
+		ClockSkew:         clockSkew,
+	}
+
+	for _, group := range strings.Split(os.Getenv("LOGIN_SERVICE_SAML_ADMIN_GROUPS"), ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			config.AdminGroups = append(config.AdminGroups, group)
+		}
+	}
+
+	if config.ServiceProvider.EntityId == "" {
+		return config, nil
+	}
+
+	if config.ServiceProvider.AcsUrl == "" || config.ServiceProvider.IdpSsoUrl == "" || certificate == "" {
+		return config, fmt.Errorf("saml service provider '%s' requires an acs url, idp sso url and idp certificate", config.ServiceProvider.EntityId)
+	}
+
+	return config, nil
+}
+
+func readSamlCertificateFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	certificate, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := saml.NewServiceProvider(saml.Config{IdpCertificate: string(certificate)}); err != nil {
+		return "", err
+	}
+
+	return string(certificate), nil
+}
+
+func createAuthenticationConfigFromEnvironment() (loginservice.AuthenticationConfig, error) {
+	var config loginservice.AuthenticationConfig
+
+	timeout, err := getenvDuration("LOGIN_SERVICE_HTTP_AUTHENTICATOR_TIMEOUT")
+	if err != nil {
+		return config, err
+	}
+
+	config.Chain = getenvList("LOGIN_SERVICE_AUTHENTICATORS")
+	config.Http = loginservice.HttpAuthenticatorConfig{
+		Url:     os.Getenv("LOGIN_SERVICE_HTTP_AUTHENTICATOR_URL"),
+		Token:   os.Getenv("LOGIN_SERVICE_HTTP_AUTHENTICATOR_TOKEN"),
+		Timeout: timeout,
+	}
+
+	for _, group := range strings.Split(os.Getenv("LOGIN_SERVICE_HTTP_AUTHENTICATOR_ADMIN_GROUPS"), ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			config.Http.AdminGroups = append(config.Http.AdminGroups, group)
+		}
+	}
+
+	for _, name := range config.Chain {
+		if name != "local" && name != "ldap" && name != "http" {
+			return config, fmt.Errorf("unknown authenticator '%s'", name)
+		}
+	}
+
+	return config, nil
+}
+
+func createScimConfigFromEnvironment() (loginservice.ScimConfig, error) {
+	var config loginservice.ScimConfig
+
+	allowAdminGroup, err := getenvBool("LOGIN_SERVICE_SCIM_ALLOW_ADMIN_GROUP")
+	if err != nil {
+		return config, err
+	}
+
+	config.Token = os.Getenv("LOGIN_SERVICE_SCIM_TOKEN")
+	config.AllowAdminGroup = allowAdminGroup
+
+	for _, entry := range strings.Split(os.Getenv("LOGIN_SERVICE_SCIM_TOKENS"), ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		organization, token, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(organization) == "" || strings.TrimSpace(token) == "" {
+			return config, fmt.Errorf("invalid scim token, expected organization=token")
+		}
+
+		config.Tokens = append(config.Tokens, loginservice.ScimToken{
+			Token:        strings.TrimSpace(token),
+			Organization: strings.TrimSpace(organization),
+		})
+	}
+
+	return config, nil
+}
+
+func createRateLimitConfigFromEnvironment() (loginservice.RateLimitConfig, error) {
+	var config loginservice.RateLimitConfig
+
+	disabled, err := getenvBool("LOGIN_SERVICE_RATE_LIMIT_DISABLED")
+	if err != nil {
+		return config, err
+	}
+
+	purgeInterval, err := getenvDuration("LOGIN_SERVICE_RATE_LIMIT_PURGE_INTERVAL")
+	if err != nil {
+		return config, err
+	}
+
+	failClosed, err := getenvBool("LOGIN_SERVICE_RATE_LIMIT_FAIL_CLOSED")
+	if err != nil {
+		return config, err
+	}
+
+	config.Disabled = disabled
+	config.PurgeInterval = purgeInterval
+	config.FailClosed = failClosed
+
+	value := os.Getenv("LOGIN_SERVICE_RATE_LIMITS")
+	if value == "" {
+		return config, nil
+	}
+
+	config.Routes = map[string]loginservice.RouteRateLimit{}
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		route, limits, ok := strings.Cut(entry, " ")
+		if !ok {
+			return config, fmt.Errorf("invalid rate limit '%s'", entry)
+		}
+
+		var routeLimit loginservice.RouteRateLimit
+		for _, field := range strings.Split(limits, ",") {
+			kind, spec, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return config, fmt.Errorf("invalid rate limit '%s'", entry)
+			}
+
+			limit, err := parseRateLimit(spec)
+			if err != nil {
+				return config, fmt.Errorf("invalid rate limit '%s': %w", entry, err)
+			}
+
+			switch kind {
+			case "ip":
+				routeLimit.Ip = limit
+			case "username":
+				routeLimit.Username = limit
+			default:
+				return config, fmt.Errorf("unknown rate limit key '%s'", kind)
+			}
+		}
+
+		config.Routes[route] = routeLimit
+	}
+
+	return config, nil
+}
+
+func parseRateLimit(spec string) (ratelimit.Limit, error) {
+	var limit ratelimit.Limit
+
+	parts := strings.Split(spec, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return limit, fmt.Errorf("expected requests/interval[/burst], got '%s'", spec)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return limit, err
+	}
+
+	interval, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return limit, err
+	}
+
+	limit.Requests = requests
+	limit.Interval = interval
+	if len(parts) == 3 {
+		if limit.Burst, err = strconv.Atoi(parts[2]); err != nil {
+			return limit, err
+		}
+	}
+
+	if !limit.Enabled() || limit.Burst < 0 {
+		return limit, fmt.Errorf("rate limit '%s' must be positive", spec)
+	}
+
+	return limit, nil
+}
+
+func createRateLimitStoreFromEnvironment(databaseConfig repository.DatabaseConfig) (ratelimit.Store, error) {
+	switch store := os.Getenv("LOGIN_SERVICE_RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "postgres":
+		return repository.NewRateLimitRepository(databaseConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store '%s'", store)
+	}
+}
+
+func getenvBool(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+func getenvInt(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func getenvList(name string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func readDomainListFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return loginservice.ReadDomainList(file)
+}
+
+func readAttributeSchemaFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	schema, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := loginservice.CompileAttributeSchema(string(schema)); err != nil {
+		return "", fmt.Errorf("invalid attribute schema: %w", err)
+	}
+
+	return string(schema), nil
+}
+
+func getenvDuration(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func createMessageSenderFromEnvironment(logger *logrus.Logger) messaging.MessageSender {
+	webhookUrl := os.Getenv("LOGIN_SERVICE_MESSAGE_WEBHOOK_URL")
+	webhookToken := os.Getenv("LOGIN_SERVICE_MESSAGE_WEBHOOK_TOKEN")
+
+	if webhookUrl == "" {
+		return messaging.NewLogSender(logger)
+	}
+
+	return messaging.NewWebhookSender(messaging.WebhookConfig{
+		Url:   webhookUrl,
+		Token: webhookToken,
+	})
+}
+
+func createMailerFromEnvironment(logger *logrus.Logger) (messaging.Mailer, error) {
+	smtpHost := os.Getenv("LOGIN_SERVICE_SMTP_HOST")
+	smtpPort := os.Getenv("LOGIN_SERVICE_SMTP_PORT")
+	smtpUser := os.Getenv("LOGIN_SERVICE_SMTP_USER")
+	smtpPass := os.Getenv("LOGIN_SERVICE_SMTP_PASSWORD")
+	smtpFrom := os.Getenv("LOGIN_SERVICE_SMTP_FROM")
+
+	if smtpHost == "" {
+		return messaging.NewLogMailer(logger), nil
+	}
+
+	smtpPortValue, err := strconv.Atoi(smtpPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return messaging.NewSmtpMailer(messaging.SmtpConfig{
+		Host:     smtpHost,
+		Port:     smtpPortValue,
+		Username: smtpUser,
+		Password: smtpPass,
+		From:     smtpFrom,
+	}), nil
+}
+
+func createService(serviceConfig loginservice.LoginServiceConfig, databaseConfig repository.DatabaseConfig, logger *logrus.Logger) (*loginservice.LoginService, error) {
+	mailer, err := createMailerFromEnvironment(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitStore, err := createRateLimitStoreFromEnvironment(databaseConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	hashEngine := security.NewBcryptEngine()
+	accountRepo := repository.NewAccountRepository(databaseConfig)
+	otpRepo := repository.NewOneTimePasswordRepository(databaseConfig)
+	deviceRepo := repository.NewTrustedDeviceRepository(databaseConfig)
+	orgRepo := repository.NewOrganizationRepository(databaseConfig)
+	invitationRepo := repository.NewInvitationRepository(databaseConfig)
+	loginEventRepo := repository.NewLoginEventRepository(databaseConfig)
+	consentRepo := repository.NewConsentRepository(databaseConfig)
+	linkedIdentityRepo := repository.NewLinkedIdentityRepository(databaseConfig)
+	apiKeyRepo := repository.NewApiKeyRepository(databaseConfig)
+	service := loginservice.NewService(serviceConfig, accountRepo, hashEngine, logger,
+		loginservice.WithOneTimePasswordRepository(otpRepo),
+		loginservice.WithTrustedDeviceRepository(deviceRepo),
+		loginservice.WithOrganizationRepository(orgRepo),
+		loginservice.WithInvitationRepository(invitationRepo),
+		loginservice.WithLoginEventRepository(loginEventRepo),
+		loginservice.WithConsentRepository(consentRepo),
+		loginservice.WithLinkedIdentityRepository(linkedIdentityRepo),
+		loginservice.WithApiKeyRepository(apiKeyRepo),
+		loginservice.WithRateLimitStore(rateLimitStore),
+		loginservice.WithMessageSender(createMessageSenderFromEnvironment(logger)),
+		loginservice.WithMailer(mailer))
+
+	return service, nil
+}
+
 func runApplication() int {
 	serviceConfig, databaseConfig, err := createConfigFromEnvironment()
 	if err != nil {
@@ -67,9 +740,17 @@ func runApplication() int {
 	logger := logrus.New()
 	logger.SetOutput(os.Stdout)
 
-	hashEngine := security.NewBcryptEngine()
-	accountRepo := repository.NewAccountRepository(databaseConfig)
-	service := loginservice.NewService(serviceConfig, accountRepo, hashEngine, logger)
+	service, err := createService(serviceConfig, databaseConfig, logger)
+	if err != nil {
+		fmt.Printf("An error occured while creating the service: %v", err)
+		return 1
+	}
+
+	stopPurge := service.StartAccountPurge()
+	defer stopPurge()
+
+	stopRateLimitPurge := service.StartRateLimitPurge()
+	defer stopRateLimitPurge()
 
 	fmt.Printf("Starting service at %s", service.GetAddr())
 	if err := service.Start(); err != nil {
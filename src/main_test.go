@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"flhansen/fitter-login-service/src/loginservice"
+	"flhansen/fitter-login-service/src/ratelimit"
+	"flhansen/fitter-login-service/src/repository"
 	"flhansen/fitter-login-service/src/testhelper"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -84,3 +90,470 @@ func TestRunApplicationShouldSucceed(t *testing.T) {
 		return
 	}
 }
+
+func TestRunApplicationShouldReturnErrorIfParsingSmtpPortFailed(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":          "0",
+		"LOGIN_SERVICE_DATABASE_PORT": "0",
+		"LOGIN_SERVICE_SMTP_HOST":     "localhost",
+		"LOGIN_SERVICE_SMTP_PORT":     "a",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfParsingEmailVerificationRequiredFailed(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":                        "0",
+		"LOGIN_SERVICE_DATABASE_PORT":               "0",
+		"LOGIN_SERVICE_EMAIL_VERIFICATION_REQUIRED": "maybe",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfParsingDeletionGracePeriodFailed(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":                  "0",
+		"LOGIN_SERVICE_DATABASE_PORT":         "0",
+		"LOGIN_SERVICE_DELETION_GRACE_PERIOD": "30 days",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfParsingLockoutThresholdFailed(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":              "0",
+		"LOGIN_SERVICE_DATABASE_PORT":     "0",
+		"LOGIN_SERVICE_LOCKOUT_THRESHOLD": "five",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfRegistrationModeUnknown(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":              "0",
+		"LOGIN_SERVICE_DATABASE_PORT":     "0",
+		"LOGIN_SERVICE_REGISTRATION_MODE": "sometimes",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfTrustedProxyInvalid(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":            "0",
+		"LOGIN_SERVICE_DATABASE_PORT":   "0",
+		"LOGIN_SERVICE_TRUSTED_PROXIES": "10.0.0.0/8, proxy.internal",
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunApplicationShouldReturnErrorIfAttributeSchemaInvalid(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(schemaFile, []byte(`{ "type": 42 }`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":                   "0",
+		"LOGIN_SERVICE_DATABASE_PORT":          "0",
+		"LOGIN_SERVICE_ATTRIBUTES_SCHEMA_FILE": schemaFile,
+	}))
+
+	done := make(chan int)
+	go func() {
+		done <- runApplication()
+	}()
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 1, exitCode)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Application did not terminate")
+	}
+}
+
+func TestRunCommandShouldReturnErrorOnUnknownCommand(t *testing.T) {
+	assert.Equal(t, 1, runCommand([]string{"unknown"}))
+}
+
+func TestRunExportAccountShouldReturnErrorWithoutAccount(t *testing.T) {
+	assert.Equal(t, 1, runCommand([]string{"export-account", "-format", "zip"}))
+}
+
+func TestRunSetRoleShouldReturnErrorOnInvalidRole(t *testing.T) {
+	assert.Equal(t, 1, runCommand([]string{"set-role", "-username", "test", "-role", "root"}))
+}
+
+func TestRunImportAccountsShouldReturnErrorOnUnknownFormat(t *testing.T) {
+	assert.Equal(t, 1, runCommand([]string{"import-accounts", "-format", "xml"}))
+}
+
+func TestRunExportAccountsShouldReturnErrorOnUnknownFormat(t *testing.T) {
+	assert.Equal(t, 1, runCommand([]string{"export-accounts", "-format", "xml"}))
+}
+
+func TestWriteImportSummaryShouldListRowErrors(t *testing.T) {
+	var output bytes.Buffer
+	writeImportSummary(&output, loginservice.ImportSummary{
+		Total:    2,
+		Imported: 1,
+		Failed:   1,
+		Errors:   []loginservice.ImportRowError{{Row: 3, Username: "alice", Error: "username already exists"}},
+	})
+
+	assert.Equal(t, "Row 3 (alice): username already exists\n2 accounts processed, 1 imported, 1 failed\n", output.String())
+}
+
+func TestCreateConfigFromEnvironmentShouldReadLifetimes(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_PORT":                        "0",
+		"LOGIN_SERVICE_DATABASE_PORT":               "0",
+		"LOGIN_SERVICE_JWT_STEP_UP_LIFETIME":        "10m",
+		"LOGIN_SERVICE_EMAIL_VERIFICATION_LIFETIME": "48h",
+		"LOGIN_SERVICE_EMAIL_RESEND_INTERVAL":       "2m",
+		"LOGIN_SERVICE_DELETION_PURGE_INTERVAL":     "30m",
+		"LOGIN_SERVICE_MFA_MAX_SENDS":               "3",
+	}))
+
+	config, _, err := createConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, config.Jwt.StepUpLifetime)
+	assert.Equal(t, 48*time.Hour, config.Email.VerificationLifetime)
+	assert.Equal(t, 2*time.Minute, config.Email.ResendInterval)
+	assert.Equal(t, 30*time.Minute, config.Deletion.PurgeInterval)
+	assert.Equal(t, 3, config.Mfa.MaxSends)
+}
+
+func TestCreateConfigFromEnvironmentShouldRejectInvalidLifetimes(t *testing.T) {
+	for _, name := range []string{
+		"LOGIN_SERVICE_JWT_STEP_UP_LIFETIME",
+		"LOGIN_SERVICE_EMAIL_VERIFICATION_LIFETIME",
+		"LOGIN_SERVICE_EMAIL_RESEND_INTERVAL",
+		"LOGIN_SERVICE_DELETION_PURGE_INTERVAL",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+				"LOGIN_SERVICE_PORT":          "0",
+				"LOGIN_SERVICE_DATABASE_PORT": "0",
+				name:                          "soon",
+			}))
+
+			_, _, err := createConfigFromEnvironment()
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateMfaConfigFromEnvironmentShouldReadLimits(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_MFA_CODE_LENGTH":             "8",
+		"LOGIN_SERVICE_MFA_CODE_LIFETIME":           "5m",
+		"LOGIN_SERVICE_MFA_MAX_ATTEMPTS":            "3",
+		"LOGIN_SERVICE_MFA_MAX_SENDS":               "4",
+		"LOGIN_SERVICE_MFA_ATTEMPT_WINDOW":          "30m",
+		"LOGIN_SERVICE_MFA_TRUSTED_DEVICE_LIFETIME": "720h",
+	}))
+
+	config, err := createMfaConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, loginservice.MfaConfig{
+		CodeLength:            8,
+		CodeLifetime:          5 * time.Minute,
+		MaxAttempts:           3,
+		MaxSends:              4,
+		AttemptWindow:         30 * time.Minute,
+		TrustedDeviceLifetime: 720 * time.Hour,
+	}, config)
+}
+
+func TestCreateMfaConfigFromEnvironmentShouldRejectInvalidValues(t *testing.T) {
+	for _, name := range []string{"LOGIN_SERVICE_MFA_CODE_LENGTH", "LOGIN_SERVICE_MFA_MAX_SENDS", "LOGIN_SERVICE_MFA_ATTEMPT_WINDOW"} {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+				name: "many",
+			}))
+
+			_, err := createMfaConfigFromEnvironment()
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateFederationConfigFromEnvironmentShouldReadProviders(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_FEDERATION_PROVIDERS":                "google, github",
+		"LOGIN_SERVICE_FEDERATION_GOOGLE_ISSUER_URL":        "https://accounts.google.com",
+		"LOGIN_SERVICE_FEDERATION_GOOGLE_CLIENT_ID":         "google-client",
+		"LOGIN_SERVICE_FEDERATION_GOOGLE_REDIRECT_URL":      "https://fitter.test/api/auth/federation/google/callback",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_CLIENT_ID":         "github-client",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_REDIRECT_URL":      "https://fitter.test/api/auth/federation/github/callback",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_AUTHORIZATION_URL": "https://github.com/login/oauth/authorize",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_TOKEN_URL":         "https://github.com/login/oauth/access_token",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_USERINFO_URL":      "https://api.github.com/user",
+		"LOGIN_SERVICE_FEDERATION_GITHUB_SCOPES":            "read:user,user:email",
+		"LOGIN_SERVICE_FEDERATION_LINK_VERIFIED_EMAIL":      "true",
+	}))
+
+	config, err := createFederationConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.True(t, config.LinkVerifiedEmail)
+	assert.Len(t, config.Providers, 2)
+	assert.Equal(t, "google-client", config.Providers[0].ClientId)
+	assert.Equal(t, []string{"read:user", "user:email"}, config.Providers[1].Scopes)
+}
+
+func TestCreateFederationConfigFromEnvironmentShouldRequireEndpoints(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_FEDERATION_PROVIDERS":         "corp",
+		"LOGIN_SERVICE_FEDERATION_CORP_CLIENT_ID":    "corp-client",
+		"LOGIN_SERVICE_FEDERATION_CORP_REDIRECT_URL": "https://fitter.test/api/auth/federation/corp/callback",
+	}))
+
+	_, err := createFederationConfigFromEnvironment()
+
+	assert.Error(t, err)
+}
+
+func TestCreateLdapConfigFromEnvironmentShouldReadDirectory(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_LDAP_URL":                "ldaps://ldap.fitter.test",
+		"LOGIN_SERVICE_LDAP_BIND_DN":            "cn=service,dc=fitter,dc=test",
+		"LOGIN_SERVICE_LDAP_BASE_DN":            "ou=people,dc=fitter,dc=test",
+		"LOGIN_SERVICE_LDAP_USERNAME_ATTRIBUTE": "sAMAccountName",
+		"LOGIN_SERVICE_LDAP_TIMEOUT":            "5s",
+		"LOGIN_SERVICE_LDAP_ADMIN_GROUPS":       "cn=admins,ou=groups,dc=fitter,dc=test; fitter-admins",
+	}))
+
+	config, err := createLdapConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ldaps://ldap.fitter.test", config.Directory.Url)
+	assert.Equal(t, "sAMAccountName", config.Directory.UsernameAttribute)
+	assert.Equal(t, 5*time.Second, config.Directory.Timeout)
+	assert.Equal(t, []string{"cn=admins,ou=groups,dc=fitter,dc=test", "fitter-admins"}, config.AdminGroups)
+}
+
+func TestCreateLdapConfigFromEnvironmentShouldRequireBaseDn(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_LDAP_URL": "ldaps://ldap.fitter.test",
+	}))
+
+	_, err := createLdapConfigFromEnvironment()
+
+	assert.Error(t, err)
+}
+
+func TestCreateSamlConfigFromEnvironmentShouldReadServiceProvider(t *testing.T) {
+	idp, err := testhelper.NewSamlIdentityProvider("https://idp.fitter.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificateFile := filepath.Join(t.TempDir(), "idp.pem")
+	if err := os.WriteFile(certificateFile, []byte(idp.CertificatePem()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_SAML_ENTITY_ID":            "https://fitter.test/saml",
+		"LOGIN_SERVICE_SAML_ACS_URL":              "https://fitter.test/api/auth/saml/acs",
+		"LOGIN_SERVICE_SAML_IDP_SSO_URL":          "https://idp.fitter.test/sso",
+		"LOGIN_SERVICE_SAML_IDP_CERTIFICATE_FILE": certificateFile,
+		"LOGIN_SERVICE_SAML_CLOCK_SKEW":           "30s",
+		"LOGIN_SERVICE_SAML_ADMIN_GROUPS":         "fitter-admins",
+	}))
+
+	config, err := createSamlConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://fitter.test/saml", config.ServiceProvider.EntityId)
+	assert.Equal(t, idp.CertificatePem(), config.ServiceProvider.IdpCertificate)
+	assert.Equal(t, 30*time.Second, config.ServiceProvider.ClockSkew)
+	assert.Equal(t, []string{"fitter-admins"}, config.AdminGroups)
+}
+
+func TestCreateSamlConfigFromEnvironmentShouldRequireIdentityProvider(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_SAML_ENTITY_ID": "https://fitter.test/saml",
+		"LOGIN_SERVICE_SAML_ACS_URL":   "https://fitter.test/api/auth/saml/acs",
+	}))
+
+	_, err := createSamlConfigFromEnvironment()
+
+	assert.Error(t, err)
+}
+
+func TestCreateAuthenticationConfigFromEnvironmentShouldReadChain(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_AUTHENTICATORS":                  "local, ldap, http",
+		"LOGIN_SERVICE_HTTP_AUTHENTICATOR_URL":          "https://auth.fitter.test/verify",
+		"LOGIN_SERVICE_HTTP_AUTHENTICATOR_TOKEN":        "service-token",
+		"LOGIN_SERVICE_HTTP_AUTHENTICATOR_TIMEOUT":      "3s",
+		"LOGIN_SERVICE_HTTP_AUTHENTICATOR_ADMIN_GROUPS": "fitter-admins",
+	}))
+
+	config, err := createAuthenticationConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"local", "ldap", "http"}, config.Chain)
+	assert.Equal(t, "https://auth.fitter.test/verify", config.Http.Url)
+	assert.Equal(t, "service-token", config.Http.Token)
+	assert.Equal(t, 3*time.Second, config.Http.Timeout)
+	assert.Equal(t, []string{"fitter-admins"}, config.Http.AdminGroups)
+}
+
+func TestCreateAuthenticationConfigFromEnvironmentShouldRejectUnknownAuthenticator(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_AUTHENTICATORS": "local, radius",
+	}))
+
+	_, err := createAuthenticationConfigFromEnvironment()
+
+	assert.Error(t, err)
+}
+
+func TestCreateScimConfigFromEnvironmentShouldReadOrganizationTokens(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_SCIM_TOKEN":             "default-token",
+		"LOGIN_SERVICE_SCIM_TOKENS":            "acme=acme-token; globex=globex-token",
+		"LOGIN_SERVICE_SCIM_ALLOW_ADMIN_GROUP": "true",
+	}))
+
+	config, err := createScimConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "default-token", config.Token)
+	assert.True(t, config.AllowAdminGroup)
+	assert.Equal(t, []loginservice.ScimToken{
+		{Token: "acme-token", Organization: "acme"},
+		{Token: "globex-token", Organization: "globex"},
+	}, config.Tokens)
+}
+
+func TestCreateScimConfigFromEnvironmentShouldRejectTokenWithoutOrganization(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_SCIM_TOKENS": "acme-token",
+	}))
+
+	_, err := createScimConfigFromEnvironment()
+
+	assert.Error(t, err)
+}
+
+func TestCreateRateLimitConfigFromEnvironmentShouldReadRoutes(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_RATE_LIMITS":               "/api/auth/login ip=30/1m/50, username=5/1m; /api/auth/register ip=3/1h",
+		"LOGIN_SERVICE_RATE_LIMIT_PURGE_INTERVAL": "5m",
+		"LOGIN_SERVICE_RATE_LIMIT_FAIL_CLOSED":    "true",
+	}))
+
+	config, err := createRateLimitConfigFromEnvironment()
+
+	assert.NoError(t, err)
+	assert.False(t, config.Disabled)
+	assert.True(t, config.FailClosed)
+	assert.Equal(t, 5*time.Minute, config.PurgeInterval)
+	assert.Equal(t, map[string]loginservice.RouteRateLimit{
+		"/api/auth/login": {
+			Ip:       ratelimit.Limit{Requests: 30, Interval: time.Minute, Burst: 50},
+			Username: ratelimit.Limit{Requests: 5, Interval: time.Minute},
+		},
+		"/api/auth/register": {
+			Ip: ratelimit.Limit{Requests: 3, Interval: time.Hour},
+		},
+	}, config.Routes)
+}
+
+func TestCreateRateLimitConfigFromEnvironmentShouldRejectInvalidLimit(t *testing.T) {
+	for _, value := range []string{"/api/auth/login", "/api/auth/login ip=0/1m", "/api/auth/login ip=5", "/api/auth/login email=5/1m"} {
+		t.Run(value, func(t *testing.T) {
+			t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+				"LOGIN_SERVICE_RATE_LIMITS": value,
+			}))
+
+			_, err := createRateLimitConfigFromEnvironment()
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateRateLimitStoreFromEnvironmentShouldRejectUnknownStore(t *testing.T) {
+	t.Cleanup(testhelper.CreateTestEnvironment(map[string]string{
+		"LOGIN_SERVICE_RATE_LIMIT_STORE": "redis",
+	}))
+
+	_, err := createRateLimitStoreFromEnvironment(repository.DatabaseConfig{})
+
+	assert.Error(t, err)
+}
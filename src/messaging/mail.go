@@ -0,0 +1,73 @@
+package messaging
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	SendMail(mail Mail) error
+}
+
+type SmtpConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type logMailer struct {
+	logger MessageLogger
+}
+
+type smtpMailer struct {
+	config SmtpConfig
+	send   func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewLogMailer(logger MessageLogger) Mailer {
+	return &logMailer{
+		logger: logger,
+	}
+}
+
+func NewSmtpMailer(config SmtpConfig) Mailer {
+	return &smtpMailer{
+		config: config,
+		send:   smtp.SendMail,
+	}
+}
+
+func (mailer *logMailer) SendMail(mail Mail) error {
+	mailer.logger.Infof("(mail) message to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+func (mailer *smtpMailer) SendMail(mail Mail) error {
+	var auth smtp.Auth
+	if mailer.config.Username != "" {
+		auth = smtp.PlainAuth("", mailer.config.Username, mailer.config.Password, mailer.config.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", mailer.config.Host, mailer.config.Port)
+	return mailer.send(addr, auth, mailer.config.From, []string{mail.To}, buildMailMessage(mailer.config.From, mail))
+}
+
+func buildMailMessage(from string, mail Mail) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mail.Subject)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	fmt.Fprintf(&message, "\r\n%s\r\n", mail.Body)
+	return message.Bytes()
+}
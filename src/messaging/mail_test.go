@@ -0,0 +1,54 @@
+package messaging
+
+import (
+	"errors"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogMailerSendMail(t *testing.T) {
+	logger := &testLogger{}
+	mailer := NewLogMailer(logger)
+
+	err := mailer.SendMail(Mail{To: "test@test.com", Subject: "Subject", Body: "Body"})
+
+	assert.NoError(t, err)
+	assert.Len(t, logger.lines, 1)
+}
+
+func TestSmtpMailerSendMail(t *testing.T) {
+	var addr, from string
+	var to []string
+	var message []byte
+	mailer := &smtpMailer{
+		config: SmtpConfig{Host: "localhost", Port: 25, From: "noreply@fitter.app"},
+		send: func(a string, auth smtp.Auth, f string, t []string, msg []byte) error {
+			addr, from, to, message = a, f, t, msg
+			return nil
+		},
+	}
+
+	err := mailer.SendMail(Mail{To: "test@test.com", Subject: "Subject", Body: "Body"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:25", addr)
+	assert.Equal(t, "noreply@fitter.app", from)
+	assert.Equal(t, []string{"test@test.com"}, to)
+	assert.Contains(t, string(message), "Subject: Subject\r\n")
+	assert.Contains(t, string(message), "\r\n\r\nBody\r\n")
+}
+
+func TestSmtpMailerShouldReturnErrorIfSendFails(t *testing.T) {
+	mailer := &smtpMailer{
+		config: SmtpConfig{Host: "localhost", Port: 25, Username: "user", Password: "pass"},
+		send: func(a string, auth smtp.Auth, f string, t []string, msg []byte) error {
+			return errors.New("connection refused")
+		},
+	}
+
+	err := mailer.SendMail(Mail{To: "test@test.com", Subject: "Subject", Body: "Body"})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ChannelSms   = "sms"
+	ChannelVoice = "voice"
+)
+
+type Message struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Body    string `json:"body"`
+}
+
+type MessageSender interface {
+	SendMessage(message Message) error
+}
+
+type MessageLogger interface {
+	Infof(format string, v ...any)
+}
+
+type WebhookConfig struct {
+	Url     string
+	Token   string
+	Timeout time.Duration
+}
+
+type logSender struct {
+	logger MessageLogger
+}
+
+type webhookSender struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+func NewLogSender(logger MessageLogger) MessageSender {
+	return &logSender{
+		logger: logger,
+	}
+}
+
+func NewWebhookSender(config WebhookConfig) MessageSender {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &webhookSender{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (sender *logSender) SendMessage(message Message) error {
+	sender.logger.Infof("(%s) message to %s: %s", message.Channel, message.To, message.Body)
+	return nil
+}
+
+func (sender *webhookSender) SendMessage(message Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sender.config.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if sender.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+sender.config.Token)
+	}
+
+	res, err := sender.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package messaging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, v ...any) {
+	l.lines = append(l.lines, format)
+}
+
+func TestLogSenderSendMessage(t *testing.T) {
+	logger := &testLogger{}
+	sender := NewLogSender(logger)
+
+	err := sender.SendMessage(Message{Channel: ChannelSms, To: "+4915112345678", Body: "123456"})
+
+	assert.NoError(t, err)
+	assert.Len(t, logger.lines, 1)
+}
+
+func TestWebhookSenderSendMessage(t *testing.T) {
+	var received Message
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(WebhookConfig{Url: server.URL, Token: "secret"})
+	err := sender.SendMessage(Message{Channel: ChannelVoice, To: "+4915112345678", Body: "123456"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authorization)
+	assert.Equal(t, ChannelVoice, received.Channel)
+	assert.Equal(t, "+4915112345678", received.To)
+	assert.Equal(t, "123456", received.Body)
+}
+
+func TestWebhookSenderShouldReturnErrorIfProviderFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(WebhookConfig{Url: server.URL})
+	err := sender.SendMessage(Message{Channel: ChannelSms, To: "+4915112345678", Body: "123456"})
+
+	assert.Error(t, err)
+}
+
+func TestWebhookSenderShouldReturnErrorIfUrlInvalid(t *testing.T) {
+	sender := NewWebhookSender(WebhookConfig{Url: "://invalid"})
+	err := sender.SendMessage(Message{Channel: ChannelSms, To: "+4915112345678", Body: "123456"})
+
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,6 +14,55 @@ type AccountRepository struct {
 	mock.Mock
 }
 
+// AnonymizeAccountById provides a mock function with given fields: id
+func (_m *AccountRepository) AnonymizeAccountById(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CancelAccountDeletion provides a mock function with given fields: id
+func (_m *AccountRepository) CancelAccountDeletion(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountAccounts provides a mock function with given fields: filter
+func (_m *AccountRepository) CountAccounts(filter repository.AccountFilter) (int, error) {
+	ret := _m.Called(filter)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.AccountFilter) int); ok {
+		r0 = rf(filter)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.AccountFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateAccount provides a mock function with given fields: account
 func (_m *AccountRepository) CreateAccount(account repository.Account) (int, error) {
 	ret := _m.Called(account)
@@ -34,6 +84,43 @@ func (_m *AccountRepository) CreateAccount(account repository.Account) (int, err
 	return r0, r1
 }
 
+// CreateAccounts provides a mock function with given fields: accounts, organizationId
+func (_m *AccountRepository) CreateAccounts(accounts []repository.Account, organizationId int) ([]repository.AccountBatchResult, error) {
+	ret := _m.Called(accounts, organizationId)
+
+	var r0 []repository.AccountBatchResult
+	if rf, ok := ret.Get(0).(func([]repository.Account, int) []repository.AccountBatchResult); ok {
+		r0 = rf(accounts, organizationId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.AccountBatchResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]repository.Account, int) error); ok {
+		r1 = rf(accounts, organizationId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteAccount provides a mock function with given fields: id, transition
+func (_m *AccountRepository) DeleteAccount(id int, transition *repository.AccountStatusTransition) error {
+	ret := _m.Called(id, transition)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, *repository.AccountStatusTransition) error); ok {
+		r0 = rf(id, transition)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteAccountById provides a mock function with given fields: id
 func (_m *AccountRepository) DeleteAccountById(id int) error {
 	ret := _m.Called(id)
@@ -62,6 +149,27 @@ func (_m *AccountRepository) DeleteAccounts() error {
 	return r0
 }
 
+// GetAccountByEmail provides a mock function with given fields: tenantId, email
+func (_m *AccountRepository) GetAccountByEmail(tenantId int, email string) (repository.Account, error) {
+	ret := _m.Called(tenantId, email)
+
+	var r0 repository.Account
+	if rf, ok := ret.Get(0).(func(int, string) repository.Account); ok {
+		r0 = rf(tenantId, email)
+	} else {
+		r0 = ret.Get(0).(repository.Account)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string) error); ok {
+		r1 = rf(tenantId, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetAccountById provides a mock function with given fields: id
 func (_m *AccountRepository) GetAccountById(id int) (repository.Account, error) {
 	ret := _m.Called(id)
@@ -83,20 +191,110 @@ func (_m *AccountRepository) GetAccountById(id int) (repository.Account, error)
 	return r0, r1
 }
 
-// GetAccountByUsername provides a mock function with given fields: username
-func (_m *AccountRepository) GetAccountByUsername(username string) (repository.Account, error) {
-	ret := _m.Called(username)
+// GetAccountByUsername provides a mock function with given fields: tenantId, username
+func (_m *AccountRepository) GetAccountByUsername(tenantId int, username string) (repository.Account, error) {
+	ret := _m.Called(tenantId, username)
+
+	var r0 repository.Account
+	if rf, ok := ret.Get(0).(func(int, string) repository.Account); ok {
+		r0 = rf(tenantId, username)
+	} else {
+		r0 = ret.Get(0).(repository.Account)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string) error); ok {
+		r1 = rf(tenantId, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccountByUsernameSkeleton provides a mock function with given fields: tenantId, skeleton
+func (_m *AccountRepository) GetAccountByUsernameSkeleton(tenantId int, skeleton string) (repository.Account, error) {
+	ret := _m.Called(tenantId, skeleton)
 
 	var r0 repository.Account
-	if rf, ok := ret.Get(0).(func(string) repository.Account); ok {
-		r0 = rf(username)
+	if rf, ok := ret.Get(0).(func(int, string) repository.Account); ok {
+		r0 = rf(tenantId, skeleton)
 	} else {
 		r0 = ret.Get(0).(repository.Account)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(username)
+	if rf, ok := ret.Get(1).(func(int, string) error); ok {
+		r1 = rf(tenantId, skeleton)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccountStatusTransitions provides a mock function with given fields: accountId
+func (_m *AccountRepository) GetAccountStatusTransitions(accountId int) ([]repository.AccountStatusTransition, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.AccountStatusTransition
+	if rf, ok := ret.Get(0).(func(int) []repository.AccountStatusTransition); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.AccountStatusTransition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccounts provides a mock function with given fields: filter
+func (_m *AccountRepository) GetAccounts(filter repository.AccountFilter) ([]repository.Account, error) {
+	ret := _m.Called(filter)
+
+	var r0 []repository.Account
+	if rf, ok := ret.Get(0).(func(repository.AccountFilter) []repository.Account); ok {
+		r0 = rf(filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Account)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.AccountFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccountsScheduledForDeletion provides a mock function with given fields: before
+func (_m *AccountRepository) GetAccountsScheduledForDeletion(before time.Time) ([]repository.Account, error) {
+	ret := _m.Called(before)
+
+	var r0 []repository.Account
+	if rf, ok := ret.Get(0).(func(time.Time) []repository.Account); ok {
+		r0 = rf(before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Account)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -104,6 +302,223 @@ func (_m *AccountRepository) GetAccountByUsername(username string) (repository.A
 	return r0, r1
 }
 
+// IncrementFailedLoginAttempts provides a mock function with given fields: id
+func (_m *AccountRepository) IncrementFailedLoginAttempts(id int) (int, error) {
+	ret := _m.Called(id)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(int) int); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LockAccount provides a mock function with given fields: id, lockedUntil
+func (_m *AccountRepository) LockAccount(id int, lockedUntil time.Time) error {
+	ret := _m.Called(id, lockedUntil)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, lockedUntil)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetLoginAttempts provides a mock function with given fields: id
+func (_m *AccountRepository) ResetLoginAttempts(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScheduleAccountDeletion provides a mock function with given fields: id, requestedDate, scheduledDate
+func (_m *AccountRepository) ScheduleAccountDeletion(id int, requestedDate time.Time, scheduledDate time.Time) error {
+	ret := _m.Called(id, requestedDate, scheduledDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time, time.Time) error); ok {
+		r0 = rf(id, requestedDate, scheduledDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccount provides a mock function with given fields: account, transition
+func (_m *AccountRepository) UpdateAccount(account repository.Account, transition *repository.AccountStatusTransition) error {
+	ret := _m.Called(account, transition)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(repository.Account, *repository.AccountStatusTransition) error); ok {
+		r0 = rf(account, transition)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountAttributes provides a mock function with given fields: id, attributes
+func (_m *AccountRepository) UpdateAccountAttributes(id int, attributes map[string]interface{}) error {
+	ret := _m.Called(id, attributes)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, map[string]interface{}) error); ok {
+		r0 = rf(id, attributes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountEmailVerified provides a mock function with given fields: id, verifiedDate
+func (_m *AccountRepository) UpdateAccountEmailVerified(id int, verifiedDate time.Time) error {
+	ret := _m.Called(id, verifiedDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, verifiedDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountPassword provides a mock function with given fields: id, password
+func (_m *AccountRepository) UpdateAccountPassword(id int, password string) error {
+	ret := _m.Called(id, password)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string) error); ok {
+		r0 = rf(id, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountPasswordResetRequired provides a mock function with given fields: id, required
+func (_m *AccountRepository) UpdateAccountPasswordResetRequired(id int, required bool) error {
+	ret := _m.Called(id, required)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, bool) error); ok {
+		r0 = rf(id, required)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountPhoneNumber provides a mock function with given fields: id, phoneNumber, verified
+func (_m *AccountRepository) UpdateAccountPhoneNumber(id int, phoneNumber string, verified bool) error {
+	ret := _m.Called(id, phoneNumber, verified)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, bool) error); ok {
+		r0 = rf(id, phoneNumber, verified)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountProfile provides a mock function with given fields: id, email, displayName, emailVerified, attributes
+func (_m *AccountRepository) UpdateAccountProfile(id int, email string, displayName string, emailVerified bool, attributes map[string]interface{}) error {
+	ret := _m.Called(id, email, displayName, emailVerified, attributes)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, string, bool, map[string]interface{}) error); ok {
+		r0 = rf(id, email, displayName, emailVerified, attributes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountRole provides a mock function with given fields: id, role
+func (_m *AccountRepository) UpdateAccountRole(id int, role string) error {
+	ret := _m.Called(id, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string) error); ok {
+		r0 = rf(id, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountStatus provides a mock function with given fields: transition
+func (_m *AccountRepository) UpdateAccountStatus(transition repository.AccountStatusTransition) error {
+	ret := _m.Called(transition)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(repository.AccountStatusTransition) error); ok {
+		r0 = rf(transition)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountUsername provides a mock function with given fields: id, username, skeleton
+func (_m *AccountRepository) UpdateAccountUsername(id int, username string, skeleton string) error {
+	ret := _m.Called(id, username, skeleton)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, string) error); ok {
+		r0 = rf(id, username, skeleton)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountVerificationSentDate provides a mock function with given fields: id, sentDate
+func (_m *AccountRepository) UpdateAccountVerificationSentDate(id int, sentDate time.Time) error {
+	ret := _m.Called(id, sentDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, sentDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewAccountRepository interface {
 	mock.TestingT
 	Cleanup(func())
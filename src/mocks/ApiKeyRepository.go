@@ -0,0 +1,123 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ApiKeyRepository is an autogenerated mock type for the ApiKeyRepository type
+type ApiKeyRepository struct {
+	mock.Mock
+}
+
+// CreateApiKey provides a mock function with given fields: key
+func (_m *ApiKeyRepository) CreateApiKey(key repository.ApiKey) (int, error) {
+	ret := _m.Called(key)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.ApiKey) int); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.ApiKey) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteApiKey provides a mock function with given fields: accountId, id
+func (_m *ApiKeyRepository) DeleteApiKey(accountId int, id int) error {
+	ret := _m.Called(accountId, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int) error); ok {
+		r0 = rf(accountId, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetApiKeyByHash provides a mock function with given fields: keyHash
+func (_m *ApiKeyRepository) GetApiKeyByHash(keyHash string) (repository.ApiKey, error) {
+	ret := _m.Called(keyHash)
+
+	var r0 repository.ApiKey
+	if rf, ok := ret.Get(0).(func(string) repository.ApiKey); ok {
+		r0 = rf(keyHash)
+	} else {
+		r0 = ret.Get(0).(repository.ApiKey)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(keyHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetApiKeys provides a mock function with given fields: accountId
+func (_m *ApiKeyRepository) GetApiKeys(accountId int) ([]repository.ApiKey, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.ApiKey
+	if rf, ok := ret.Get(0).(func(int) []repository.ApiKey); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.ApiKey)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateApiKeyLastUsed provides a mock function with given fields: id, lastUsed
+func (_m *ApiKeyRepository) UpdateApiKeyLastUsed(id int, lastUsed time.Time) error {
+	ret := _m.Called(id, lastUsed)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, lastUsed)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewApiKeyRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewApiKeyRepository creates a new instance of ApiKeyRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewApiKeyRepository(t mockConstructorTestingTNewApiKeyRepository) *ApiKeyRepository {
+	mock := &ApiKeyRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,157 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ConsentRepository is an autogenerated mock type for the ConsentRepository type
+type ConsentRepository struct {
+	mock.Mock
+}
+
+// CreateConsents provides a mock function with given fields: consents
+func (_m *ConsentRepository) CreateConsents(consents []repository.Consent) error {
+	ret := _m.Called(consents)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]repository.Consent) error); ok {
+		r0 = rf(consents)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateLegalDocument provides a mock function with given fields: document
+func (_m *ConsentRepository) CreateLegalDocument(document repository.LegalDocument) (int, error) {
+	ret := _m.Called(document)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.LegalDocument) int); ok {
+		r0 = rf(document)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.LegalDocument) error); ok {
+		r1 = rf(document)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetConsentsByAccount provides a mock function with given fields: accountId
+func (_m *ConsentRepository) GetConsentsByAccount(accountId int) ([]repository.Consent, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.Consent
+	if rf, ok := ret.Get(0).(func(int) []repository.Consent); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Consent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCurrentLegalDocuments provides a mock function with given fields: now
+func (_m *ConsentRepository) GetCurrentLegalDocuments(now time.Time) ([]repository.LegalDocument, error) {
+	ret := _m.Called(now)
+
+	var r0 []repository.LegalDocument
+	if rf, ok := ret.Get(0).(func(time.Time) []repository.LegalDocument); ok {
+		r0 = rf(now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.LegalDocument)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLegalDocuments provides a mock function with given fields:
+func (_m *ConsentRepository) GetLegalDocuments() ([]repository.LegalDocument, error) {
+	ret := _m.Called()
+
+	var r0 []repository.LegalDocument
+	if rf, ok := ret.Get(0).(func() []repository.LegalDocument); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.LegalDocument)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPendingLegalDocuments provides a mock function with given fields: accountId, now
+func (_m *ConsentRepository) GetPendingLegalDocuments(accountId int, now time.Time) ([]repository.LegalDocument, error) {
+	ret := _m.Called(accountId, now)
+
+	var r0 []repository.LegalDocument
+	if rf, ok := ret.Get(0).(func(int, time.Time) []repository.LegalDocument); ok {
+		r0 = rf(accountId, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.LegalDocument)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, time.Time) error); ok {
+		r1 = rf(accountId, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewConsentRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewConsentRepository creates a new instance of ConsentRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewConsentRepository(t mockConstructorTestingTNewConsentRepository) *ConsentRepository {
+	mock := &ConsentRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
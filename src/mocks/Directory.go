@@ -0,0 +1,50 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	directory "flhansen/fitter-login-service/src/directory"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Directory is an autogenerated mock type for the Directory type
+type Directory struct {
+	mock.Mock
+}
+
+// Authenticate provides a mock function with given fields: username, password
+func (_m *Directory) Authenticate(username string, password string) (directory.Entry, error) {
+	ret := _m.Called(username, password)
+
+	var r0 directory.Entry
+	if rf, ok := ret.Get(0).(func(string, string) directory.Entry); ok {
+		r0 = rf(username, password)
+	} else {
+		r0 = ret.Get(0).(directory.Entry)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(username, password)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewDirectory interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewDirectory creates a new instance of Directory. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewDirectory(t mockConstructorTestingTNewDirectory) *Directory {
+	mock := &Directory{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,123 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// InvitationRepository is an autogenerated mock type for the InvitationRepository type
+type InvitationRepository struct {
+	mock.Mock
+}
+
+// AcceptInvitation provides a mock function with given fields: id, accountId, acceptedDate
+func (_m *InvitationRepository) AcceptInvitation(id int, accountId int, acceptedDate time.Time) error {
+	ret := _m.Called(id, accountId, acceptedDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int, time.Time) error); ok {
+		r0 = rf(id, accountId, acceptedDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateInvitation provides a mock function with given fields: invitation
+func (_m *InvitationRepository) CreateInvitation(invitation repository.Invitation) (int, error) {
+	ret := _m.Called(invitation)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.Invitation) int); ok {
+		r0 = rf(invitation)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.Invitation) error); ok {
+		r1 = rf(invitation)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInvitationById provides a mock function with given fields: id
+func (_m *InvitationRepository) GetInvitationById(id int) (repository.Invitation, error) {
+	ret := _m.Called(id)
+
+	var r0 repository.Invitation
+	if rf, ok := ret.Get(0).(func(int) repository.Invitation); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(repository.Invitation)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPendingInvitations provides a mock function with given fields:
+func (_m *InvitationRepository) GetPendingInvitations() ([]repository.Invitation, error) {
+	ret := _m.Called()
+
+	var r0 []repository.Invitation
+	if rf, ok := ret.Get(0).(func() []repository.Invitation); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Invitation)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RevokeInvitation provides a mock function with given fields: id, revokedDate
+func (_m *InvitationRepository) RevokeInvitation(id int, revokedDate time.Time) error {
+	ret := _m.Called(id, revokedDate)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, revokedDate)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewInvitationRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewInvitationRepository creates a new instance of InvitationRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewInvitationRepository(t mockConstructorTestingTNewInvitationRepository) *InvitationRepository {
+	mock := &InvitationRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,108 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// LinkedIdentityRepository is an autogenerated mock type for the LinkedIdentityRepository type
+type LinkedIdentityRepository struct {
+	mock.Mock
+}
+
+// CreateLinkedIdentity provides a mock function with given fields: identity
+func (_m *LinkedIdentityRepository) CreateLinkedIdentity(identity repository.LinkedIdentity) (int, error) {
+	ret := _m.Called(identity)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.LinkedIdentity) int); ok {
+		r0 = rf(identity)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.LinkedIdentity) error); ok {
+		r1 = rf(identity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteLinkedIdentity provides a mock function with given fields: accountId, provider
+func (_m *LinkedIdentityRepository) DeleteLinkedIdentity(accountId int, provider string) error {
+	ret := _m.Called(accountId, provider)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string) error); ok {
+		r0 = rf(accountId, provider)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLinkedIdentitiesByAccount provides a mock function with given fields: accountId
+func (_m *LinkedIdentityRepository) GetLinkedIdentitiesByAccount(accountId int) ([]repository.LinkedIdentity, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.LinkedIdentity
+	if rf, ok := ret.Get(0).(func(int) []repository.LinkedIdentity); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.LinkedIdentity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLinkedIdentity provides a mock function with given fields: provider, subject
+func (_m *LinkedIdentityRepository) GetLinkedIdentity(provider string, subject string) (repository.LinkedIdentity, error) {
+	ret := _m.Called(provider, subject)
+
+	var r0 repository.LinkedIdentity
+	if rf, ok := ret.Get(0).(func(string, string) repository.LinkedIdentity); ok {
+		r0 = rf(provider, subject)
+	} else {
+		r0 = ret.Get(0).(repository.LinkedIdentity)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(provider, subject)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewLinkedIdentityRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewLinkedIdentityRepository creates a new instance of LinkedIdentityRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewLinkedIdentityRepository(t mockConstructorTestingTNewLinkedIdentityRepository) *LinkedIdentityRepository {
+	mock := &LinkedIdentityRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,94 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// LoginEventRepository is an autogenerated mock type for the LoginEventRepository type
+type LoginEventRepository struct {
+	mock.Mock
+}
+
+// CountLoginEvents provides a mock function with given fields: filter
+func (_m *LoginEventRepository) CountLoginEvents(filter repository.LoginEventFilter) (int, error) {
+	ret := _m.Called(filter)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.LoginEventFilter) int); ok {
+		r0 = rf(filter)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.LoginEventFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateLoginEvent provides a mock function with given fields: event
+func (_m *LoginEventRepository) CreateLoginEvent(event repository.LoginEvent) (int, error) {
+	ret := _m.Called(event)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.LoginEvent) int); ok {
+		r0 = rf(event)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.LoginEvent) error); ok {
+		r1 = rf(event)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLoginEvents provides a mock function with given fields: filter
+func (_m *LoginEventRepository) GetLoginEvents(filter repository.LoginEventFilter) ([]repository.LoginEvent, error) {
+	ret := _m.Called(filter)
+
+	var r0 []repository.LoginEvent
+	if rf, ok := ret.Get(0).(func(repository.LoginEventFilter) []repository.LoginEvent); ok {
+		r0 = rf(filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.LoginEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.LoginEventFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewLoginEventRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewLoginEventRepository creates a new instance of LoginEventRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewLoginEventRepository(t mockConstructorTestingTNewLoginEventRepository) *LoginEventRepository {
+	mock := &LoginEventRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,43 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	messaging "flhansen/fitter-login-service/src/messaging"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Mailer is an autogenerated mock type for the Mailer type
+type Mailer struct {
+	mock.Mock
+}
+
+// SendMail provides a mock function with given fields: mail
+func (_m *Mailer) SendMail(mail messaging.Mail) error {
+	ret := _m.Called(mail)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(messaging.Mail) error); ok {
+		r0 = rf(mail)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewMailer interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewMailer creates a new instance of Mailer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewMailer(t mockConstructorTestingTNewMailer) *Mailer {
+	mock := &Mailer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
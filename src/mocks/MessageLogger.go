@@ -0,0 +1,33 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// MessageLogger is an autogenerated mock type for the MessageLogger type
+type MessageLogger struct {
+	mock.Mock
+}
+
+// Infof provides a mock function with given fields: format, v
+func (_m *MessageLogger) Infof(format string, v ...interface{}) {
+	var _ca []interface{}
+	_ca = append(_ca, format)
+	_ca = append(_ca, v...)
+	_m.Called(_ca...)
+}
+
+type mockConstructorTestingTNewMessageLogger interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewMessageLogger creates a new instance of MessageLogger. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewMessageLogger(t mockConstructorTestingTNewMessageLogger) *MessageLogger {
+	mock := &MessageLogger{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
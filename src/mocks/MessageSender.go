@@ -0,0 +1,43 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	messaging "flhansen/fitter-login-service/src/messaging"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MessageSender is an autogenerated mock type for the MessageSender type
+type MessageSender struct {
+	mock.Mock
+}
+
+// SendMessage provides a mock function with given fields: message
+func (_m *MessageSender) SendMessage(message messaging.Message) error {
+	ret := _m.Called(message)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(messaging.Message) error); ok {
+		r0 = rf(message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewMessageSender interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewMessageSender creates a new instance of MessageSender. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewMessageSender(t mockConstructorTestingTNewMessageSender) *MessageSender {
+	mock := &MessageSender{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,135 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OneTimePasswordRepository is an autogenerated mock type for the OneTimePasswordRepository type
+type OneTimePasswordRepository struct {
+	mock.Mock
+}
+
+// CreateOneTimePassword provides a mock function with given fields: otp
+func (_m *OneTimePasswordRepository) CreateOneTimePassword(otp repository.OneTimePassword) (int, error) {
+	ret := _m.Called(otp)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.OneTimePassword) int); ok {
+		r0 = rf(otp)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.OneTimePassword) error); ok {
+		r1 = rf(otp)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteOneTimePasswords provides a mock function with given fields: accountId, purpose
+func (_m *OneTimePasswordRepository) DeleteOneTimePasswords(accountId int, purpose string) error {
+	ret := _m.Called(accountId, purpose)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string) error); ok {
+		r0 = rf(accountId, purpose)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOneTimePasswordsBefore provides a mock function with given fields: accountId, purpose, before
+func (_m *OneTimePasswordRepository) DeleteOneTimePasswordsBefore(accountId int, purpose string, before time.Time) error {
+	ret := _m.Called(accountId, purpose, before)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, string, time.Time) error); ok {
+		r0 = rf(accountId, purpose, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLatestOneTimePassword provides a mock function with given fields: accountId, purpose
+func (_m *OneTimePasswordRepository) GetLatestOneTimePassword(accountId int, purpose string) (repository.OneTimePassword, error) {
+	ret := _m.Called(accountId, purpose)
+
+	var r0 repository.OneTimePassword
+	if rf, ok := ret.Get(0).(func(int, string) repository.OneTimePassword); ok {
+		r0 = rf(accountId, purpose)
+	} else {
+		r0 = ret.Get(0).(repository.OneTimePassword)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string) error); ok {
+		r1 = rf(accountId, purpose)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOneTimePasswordUsage provides a mock function with given fields: accountId, purpose, since
+func (_m *OneTimePasswordRepository) GetOneTimePasswordUsage(accountId int, purpose string, since time.Time) (repository.OneTimePasswordUsage, error) {
+	ret := _m.Called(accountId, purpose, since)
+
+	var r0 repository.OneTimePasswordUsage
+	if rf, ok := ret.Get(0).(func(int, string, time.Time) repository.OneTimePasswordUsage); ok {
+		r0 = rf(accountId, purpose, since)
+	} else {
+		r0 = ret.Get(0).(repository.OneTimePasswordUsage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string, time.Time) error); ok {
+		r1 = rf(accountId, purpose, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementOneTimePasswordAttempts provides a mock function with given fields: id
+func (_m *OneTimePasswordRepository) IncrementOneTimePasswordAttempts(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewOneTimePasswordRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewOneTimePasswordRepository creates a new instance of OneTimePasswordRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewOneTimePasswordRepository(t mockConstructorTestingTNewOneTimePasswordRepository) *OneTimePasswordRepository {
+	mock := &OneTimePasswordRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,245 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OrganizationRepository is an autogenerated mock type for the OrganizationRepository type
+type OrganizationRepository struct {
+	mock.Mock
+}
+
+// CreateMembership provides a mock function with given fields: membership
+func (_m *OrganizationRepository) CreateMembership(membership repository.Membership) (int, error) {
+	ret := _m.Called(membership)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.Membership) int); ok {
+		r0 = rf(membership)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.Membership) error); ok {
+		r1 = rf(membership)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateOrganization provides a mock function with given fields: organization
+func (_m *OrganizationRepository) CreateOrganization(organization repository.Organization) (int, error) {
+	ret := _m.Called(organization)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.Organization) int); ok {
+		r0 = rf(organization)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.Organization) error); ok {
+		r1 = rf(organization)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteMembership provides a mock function with given fields: organizationId, accountId
+func (_m *OrganizationRepository) DeleteMembership(organizationId int, accountId int) error {
+	ret := _m.Called(organizationId, accountId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int) error); ok {
+		r0 = rf(organizationId, accountId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOrganization provides a mock function with given fields: id
+func (_m *OrganizationRepository) DeleteOrganization(id int) error {
+	ret := _m.Called(id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetMembership provides a mock function with given fields: organizationId, accountId
+func (_m *OrganizationRepository) GetMembership(organizationId int, accountId int) (repository.Membership, error) {
+	ret := _m.Called(organizationId, accountId)
+
+	var r0 repository.Membership
+	if rf, ok := ret.Get(0).(func(int, int) repository.Membership); ok {
+		r0 = rf(organizationId, accountId)
+	} else {
+		r0 = ret.Get(0).(repository.Membership)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, int) error); ok {
+		r1 = rf(organizationId, accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMembershipsByAccount provides a mock function with given fields: accountId
+func (_m *OrganizationRepository) GetMembershipsByAccount(accountId int) ([]repository.Membership, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.Membership
+	if rf, ok := ret.Get(0).(func(int) []repository.Membership); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Membership)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMembershipsByOrganization provides a mock function with given fields: organizationId
+func (_m *OrganizationRepository) GetMembershipsByOrganization(organizationId int) ([]repository.Membership, error) {
+	ret := _m.Called(organizationId)
+
+	var r0 []repository.Membership
+	if rf, ok := ret.Get(0).(func(int) []repository.Membership); ok {
+		r0 = rf(organizationId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Membership)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(organizationId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrganizationById provides a mock function with given fields: id
+func (_m *OrganizationRepository) GetOrganizationById(id int) (repository.Organization, error) {
+	ret := _m.Called(id)
+
+	var r0 repository.Organization
+	if rf, ok := ret.Get(0).(func(int) repository.Organization); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(repository.Organization)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrganizationBySlug provides a mock function with given fields: slug
+func (_m *OrganizationRepository) GetOrganizationBySlug(slug string) (repository.Organization, error) {
+	ret := _m.Called(slug)
+
+	var r0 repository.Organization
+	if rf, ok := ret.Get(0).(func(string) repository.Organization); ok {
+		r0 = rf(slug)
+	} else {
+		r0 = ret.Get(0).(repository.Organization)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrganizations provides a mock function with given fields:
+func (_m *OrganizationRepository) GetOrganizations() ([]repository.Organization, error) {
+	ret := _m.Called()
+
+	var r0 []repository.Organization
+	if rf, ok := ret.Get(0).(func() []repository.Organization); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.Organization)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateMembershipRole provides a mock function with given fields: organizationId, accountId, role
+func (_m *OrganizationRepository) UpdateMembershipRole(organizationId int, accountId int, role string) error {
+	ret := _m.Called(organizationId, accountId, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int, string) error); ok {
+		r0 = rf(organizationId, accountId, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewOrganizationRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewOrganizationRepository creates a new instance of OrganizationRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewOrganizationRepository(t mockConstructorTestingTNewOrganizationRepository) *OrganizationRepository {
+	mock := &OrganizationRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,66 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	ratelimit "flhansen/fitter-login-service/src/ratelimit"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// RateLimitRepository is an autogenerated mock type for the RateLimitRepository type
+type RateLimitRepository struct {
+	mock.Mock
+}
+
+// Prune provides a mock function with given fields: now
+func (_m *RateLimitRepository) Prune(now time.Time) error {
+	ret := _m.Called(now)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(time.Time) error); ok {
+		r0 = rf(now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Take provides a mock function with given fields: key, limit, now
+func (_m *RateLimitRepository) Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error) {
+	ret := _m.Called(key, limit, now)
+
+	var r0 ratelimit.Decision
+	if rf, ok := ret.Get(0).(func(string, ratelimit.Limit, time.Time) ratelimit.Decision); ok {
+		r0 = rf(key, limit, now)
+	} else {
+		r0 = ret.Get(0).(ratelimit.Decision)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, ratelimit.Limit, time.Time) error); ok {
+		r1 = rf(key, limit, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewRateLimitRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewRateLimitRepository creates a new instance of RateLimitRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewRateLimitRepository(t mockConstructorTestingTNewRateLimitRepository) *RateLimitRepository {
+	mock := &RateLimitRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
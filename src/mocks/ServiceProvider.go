@@ -0,0 +1,94 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	saml "flhansen/fitter-login-service/src/saml"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// ServiceProvider is an autogenerated mock type for the ServiceProvider type
+type ServiceProvider struct {
+	mock.Mock
+}
+
+// AuthnRequestUrl provides a mock function with given fields: requestId, relayState
+func (_m *ServiceProvider) AuthnRequestUrl(requestId string, relayState string) (string, error) {
+	ret := _m.Called(requestId, relayState)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(requestId, relayState)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(requestId, relayState)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Metadata provides a mock function with given fields:
+func (_m *ServiceProvider) Metadata() ([]byte, error) {
+	ret := _m.Called()
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func() []byte); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ParseResponse provides a mock function with given fields: encodedResponse, requestId
+func (_m *ServiceProvider) ParseResponse(encodedResponse string, requestId string) (saml.Identity, error) {
+	ret := _m.Called(encodedResponse, requestId)
+
+	var r0 saml.Identity
+	if rf, ok := ret.Get(0).(func(string, string) saml.Identity); ok {
+		r0 = rf(encodedResponse, requestId)
+	} else {
+		r0 = ret.Get(0).(saml.Identity)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(encodedResponse, requestId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewServiceProvider interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewServiceProvider creates a new instance of ServiceProvider. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewServiceProvider(t mockConstructorTestingTNewServiceProvider) *ServiceProvider {
+	mock := &ServiceProvider{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,123 @@
+// Code generated by mockery v2.14.0. DO NOT EDIT.
+
+package mocks
+
+import (
+	repository "flhansen/fitter-login-service/src/repository"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// TrustedDeviceRepository is an autogenerated mock type for the TrustedDeviceRepository type
+type TrustedDeviceRepository struct {
+	mock.Mock
+}
+
+// CreateTrustedDevice provides a mock function with given fields: device
+func (_m *TrustedDeviceRepository) CreateTrustedDevice(device repository.TrustedDevice) (int, error) {
+	ret := _m.Called(device)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(repository.TrustedDevice) int); ok {
+		r0 = rf(device)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(repository.TrustedDevice) error); ok {
+		r1 = rf(device)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteTrustedDevice provides a mock function with given fields: accountId, id
+func (_m *TrustedDeviceRepository) DeleteTrustedDevice(accountId int, id int) error {
+	ret := _m.Called(accountId, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, int) error); ok {
+		r0 = rf(accountId, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetTrustedDevice provides a mock function with given fields: accountId, deviceId
+func (_m *TrustedDeviceRepository) GetTrustedDevice(accountId int, deviceId string) (repository.TrustedDevice, error) {
+	ret := _m.Called(accountId, deviceId)
+
+	var r0 repository.TrustedDevice
+	if rf, ok := ret.Get(0).(func(int, string) repository.TrustedDevice); ok {
+		r0 = rf(accountId, deviceId)
+	} else {
+		r0 = ret.Get(0).(repository.TrustedDevice)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, string) error); ok {
+		r1 = rf(accountId, deviceId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTrustedDevices provides a mock function with given fields: accountId
+func (_m *TrustedDeviceRepository) GetTrustedDevices(accountId int) ([]repository.TrustedDevice, error) {
+	ret := _m.Called(accountId)
+
+	var r0 []repository.TrustedDevice
+	if rf, ok := ret.Get(0).(func(int) []repository.TrustedDevice); ok {
+		r0 = rf(accountId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]repository.TrustedDevice)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(accountId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateTrustedDeviceLastUsed provides a mock function with given fields: id, lastUsed
+func (_m *TrustedDeviceRepository) UpdateTrustedDeviceLastUsed(id int, lastUsed time.Time) error {
+	ret := _m.Called(id, lastUsed)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int, time.Time) error); ok {
+		r0 = rf(id, lastUsed)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+type mockConstructorTestingTNewTrustedDeviceRepository interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+// NewTrustedDeviceRepository creates a new instance of TrustedDeviceRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+func NewTrustedDeviceRepository(t mockConstructorTestingTNewTrustedDeviceRepository) *TrustedDeviceRepository {
+	mock := &TrustedDeviceRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
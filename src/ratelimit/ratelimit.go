@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const maxTrackedBuckets = 100000
+
+var ErrStoreFull = errors.New("rate limit store is full")
+
+type Limit struct {
+	Requests int
+	Interval time.Duration
+	Burst    int
+}
+
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+type Decision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Decision, error)
+	Prune(now time.Time) error
+}
+
+type memoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]Bucket
+	expires map[string]time.Time
+}
+
+func (limit Limit) Enabled() bool {
+	return limit.Requests > 0 && limit.Interval > 0
+}
+
+func (limit Limit) capacity() float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+
+	return float64(limit.Requests)
+}
+
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Interval.Seconds()
+}
+
+func (bucket Bucket) Take(limit Limit, now time.Time) (Bucket, Decision) {
+	tokens := limit.capacity()
+	if !bucket.UpdatedAt.IsZero() {
+		tokens = bucket.Tokens
+		if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+			tokens += elapsed.Seconds() * limit.rate()
+		}
+
+		if tokens > limit.capacity() {
+			tokens = limit.capacity()
+		}
+	}
+
+	if tokens >= 1 {
+		tokens--
+		return Bucket{Tokens: tokens, UpdatedAt: now}, Decision{Allowed: true, Remaining: int(tokens)}
+	}
+
+	retryAfter := time.Duration((1 - tokens) / limit.rate() * float64(time.Second))
+	return Bucket{Tokens: tokens, UpdatedAt: now}, Decision{RetryAfter: retryAfter}
+}
+
+func (bucket Bucket) FullAt(limit Limit) time.Time {
+	missing := limit.capacity() - bucket.Tokens
+	if missing <= 0 {
+		return bucket.UpdatedAt
+	}
+
+	return bucket.UpdatedAt.Add(time.Duration(missing / limit.rate() * float64(time.Second)))
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: map[string]Bucket{},
+		expires: map[string]time.Time{},
+	}
+}
+
+func (store *memoryStore) Take(key string, limit Limit, now time.Time) (Decision, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		if len(store.buckets) >= maxTrackedBuckets {
+			store.prune(now)
+		}
+
+		if len(store.buckets) >= maxTrackedBuckets {
+			return Decision{}, ErrStoreFull
+		}
+	}
+
+	bucket, decision := bucket.Take(limit, now)
+	store.buckets[key] = bucket
+	store.expires[key] = bucket.FullAt(limit)
+
+	return decision, nil
+}
+
+func (store *memoryStore) Prune(now time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.prune(now)
+	return nil
+}
+
+func (store *memoryStore) prune(now time.Time) {
+	for key, expiresAt := range store.expires {
+		if !now.Before(expiresAt) {
+			delete(store.buckets, key)
+			delete(store.expires, key)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitEnabled(t *testing.T) {
+	assert.True(t, Limit{Requests: 1, Interval: time.Second}.Enabled())
+	assert.False(t, Limit{Requests: 1}.Enabled())
+	assert.False(t, Limit{Interval: time.Second}.Enabled())
+}
+
+func TestBucketTakeShouldAllowBurst(t *testing.T) {
+	limit := Limit{Requests: 1, Interval: time.Minute, Burst: 3}
+	now := time.Now()
+	bucket := Bucket{}
+
+	for i := 2; i >= 0; i-- {
+		var decision Decision
+		bucket, decision = bucket.Take(limit, now)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, i, decision.Remaining)
+	}
+
+	_, decision := bucket.Take(limit, now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, time.Minute, decision.RetryAfter)
+}
+
+func TestBucketTakeShouldRefillOverTime(t *testing.T) {
+	limit := Limit{Requests: 2, Interval: time.Minute}
+	now := time.Now()
+	bucket := Bucket{Tokens: 0, UpdatedAt: now}
+
+	_, decision := bucket.Take(limit, now.Add(15*time.Second))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 15*time.Second, decision.RetryAfter)
+
+	_, decision = bucket.Take(limit, now.Add(30*time.Second))
+	assert.True(t, decision.Allowed)
+
+	bucket, decision = bucket.Take(limit, now.Add(time.Hour))
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 1.0, bucket.Tokens)
+}
+
+func TestBucketFullAt(t *testing.T) {
+	limit := Limit{Requests: 2, Interval: time.Minute}
+	now := time.Now()
+
+	assert.Equal(t, now.Add(time.Minute), Bucket{Tokens: 0, UpdatedAt: now}.FullAt(limit))
+	assert.Equal(t, now, Bucket{Tokens: 2, UpdatedAt: now}.FullAt(limit))
+}
+
+func TestMemoryStoreShouldFailIfFull(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Interval: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < maxTrackedBuckets; i++ {
+		_, err := store.Take(strconv.Itoa(i), limit, now)
+		assert.NoError(t, err)
+	}
+
+	_, err := store.Take("other", limit, now)
+	assert.Equal(t, ErrStoreFull, err)
+
+	decision, err := store.Take("0", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestMemoryStoreShouldLimitPerKey(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Interval: time.Minute}
+	now := time.Now()
+
+	decision, err := store.Take("login|ip|1.2.3.4", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = store.Take("login|ip|1.2.3.4", limit, now)
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	decision, err = store.Take("login|ip|5.6.7.8", limit, now)
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestMemoryStorePruneShouldForgetFullBuckets(t *testing.T) {
+	store := NewMemoryStore().(*memoryStore)
+	limit := Limit{Requests: 1, Interval: time.Minute}
+	now := time.Now()
+	store.Take("first", limit, now)
+	store.Take("second", limit, now.Add(30*time.Second))
+
+	assert.NoError(t, store.Prune(now.Add(time.Minute)))
+
+	assert.NotContains(t, store.buckets, "first")
+	assert.Contains(t, store.buckets, "second")
+}
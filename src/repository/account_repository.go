@@ -2,7 +2,10 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"flhansen/fitter-login-service/src/database"
+	"fmt"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -17,21 +20,120 @@ type DatabaseConfig struct {
 }
 
 type Account struct {
+	Id                    int
+	TenantId              int
+	UsernameSkeleton      string
+	Username              string
+	Password              string
+	Email                 string
+	CreationDate          time.Time
+	PhoneNumber           string
+	PhoneVerified         bool
+	EmailVerified         bool
+	EmailVerifiedDate     *time.Time
+	VerificationSentDate  *time.Time
+	DisplayName           string
+	DeletionRequestedDate *time.Time
+	DeletionScheduledDate *time.Time
+	Role                  string
+	FailedLoginAttempts   int
+	LockoutCount          int
+	LockedUntil           *time.Time
+	PasswordResetRequired bool
+	Status                string
+	StatusReason          string
+	StatusChangedDate     *time.Time
+	Attributes            map[string]interface{}
+	LastLoginAt           *time.Time
+}
+
+type AccountBatchResult struct {
+	Id  int
+	Err error
+}
+
+type AccountStatusTransition struct {
 	Id           int
-	Username     string
-	Password     string
-	Email        string
+	AccountId    int
+	FromStatus   string
+	ToStatus     string
+	Reason       string
+	Actor        string
 	CreationDate time.Time
 }
 
+type AccountFilter struct {
+	Search     string
+	Role       string
+	Status     string
+	TenantId   *int
+	SortBy     string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+const (
+	AccountStatusPending   = "pending"
+	AccountStatusActive    = "active"
+	AccountStatusSuspended = "suspended"
+	AccountStatusDeleted   = "deleted"
+)
+
+var ErrInvalidSortField = errors.New("invalid sort field")
+
+var accountSortColumns = map[string]string{
+	"":             "id",
+	"id":           "id",
+	"username":     "username",
+	"email":        "email",
+	"creationDate": "creation_date",
+}
+
 type AccountRepository interface {
 	CreateAccount(account Account) (int, error)
+	CreateAccounts(accounts []Account, organizationId int) ([]AccountBatchResult, error)
 	GetAccountById(id int) (Account, error)
-	GetAccountByUsername(username string) (Account, error)
+	GetAccountByUsername(tenantId int, username string) (Account, error)
+	GetAccountByUsernameSkeleton(tenantId int, skeleton string) (Account, error)
+	GetAccountByEmail(tenantId int, email string) (Account, error)
+	UpdateAccountEmailVerified(id int, verifiedDate time.Time) error
+	UpdateAccountVerificationSentDate(id int, sentDate time.Time) error
+	UpdateAccountProfile(id int, email string, displayName string, emailVerified bool, attributes map[string]interface{}) error
+	UpdateAccountAttributes(id int, attributes map[string]interface{}) error
+	UpdateAccountPhoneNumber(id int, phoneNumber string, verified bool) error
+	GetAccounts(filter AccountFilter) ([]Account, error)
+	CountAccounts(filter AccountFilter) (int, error)
+	UpdateAccountStatus(transition AccountStatusTransition) error
+	GetAccountStatusTransitions(accountId int) ([]AccountStatusTransition, error)
+	UpdateAccountPasswordResetRequired(id int, required bool) error
+	UpdateAccountPassword(id int, password string) error
+	UpdateAccountUsername(id int, username string, skeleton string) error
+	UpdateAccount(account Account, transition *AccountStatusTransition) error
+	UpdateAccountRole(id int, role string) error
+	IncrementFailedLoginAttempts(id int) (int, error)
+	LockAccount(id int, lockedUntil time.Time) error
+	ResetLoginAttempts(id int) error
+	GetAccountsScheduledForDeletion(before time.Time) ([]Account, error)
+	ScheduleAccountDeletion(id int, requestedDate time.Time, scheduledDate time.Time) error
+	CancelAccountDeletion(id int) error
+	AnonymizeAccountById(id int) error
 	DeleteAccountById(id int) error
+	DeleteAccount(id int, transition *AccountStatusTransition) error
 	DeleteAccounts() error
 }
 
+var accountRelatedDeleteQueries = []string{
+	QUERY_DELETE_ONE_TIME_PASSWORDS_BY_ACCOUNT,
+	QUERY_DELETE_TRUSTED_DEVICES_BY_ACCOUNT,
+	QUERY_DELETE_MEMBERSHIPS_BY_ACCOUNT,
+	QUERY_DELETE_INVITATIONS_BY_ACCOUNT,
+	QUERY_DELETE_LOGIN_EVENTS_BY_ACCOUNT,
+	QUERY_DELETE_CONSENTS_BY_ACCOUNT,
+	QUERY_DELETE_LINKED_IDENTITIES_BY_ACCOUNT,
+	QUERY_DELETE_API_KEYS_BY_ACCOUNT,
+}
+
 type accountRepository struct {
 	db *sql.DB
 }
@@ -46,27 +148,388 @@ func NewAccountRepository(config DatabaseConfig) AccountRepository {
 }
 
 func (repo *accountRepository) CreateAccount(account Account) (int, error) {
-	row := repo.db.QueryRow(QUERY_CREATE_ACCOUNT, account.Username, account.Password, account.Email, account.CreationDate)
+	status := account.Status
+	if status == "" {
+		status = AccountStatusActive
+	}
+
+	row := repo.db.QueryRow(QUERY_CREATE_ACCOUNT, account.Username, account.Password, account.Email, account.CreationDate, status, account.TenantId, account.UsernameSkeleton)
 
 	id := -1
 	err := row.Scan(&id)
 	return id, err
 }
 
+func (repo *accountRepository) CreateAccounts(accounts []Account, organizationId int) ([]AccountBatchResult, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]AccountBatchResult, len(accounts))
+	for i, account := range accounts {
+		results[i].Id = -1
+
+		attributes := account.Attributes
+		if attributes == nil {
+			attributes = map[string]interface{}{}
+		}
+
+		encoded, err := json.Marshal(attributes)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		status := account.Status
+		if status == "" {
+			status = AccountStatusActive
+		}
+
+		if _, err := tx.Exec(QUERY_SAVEPOINT_ACCOUNT_IMPORT); err != nil {
+			return nil, err
+		}
+
+		row := tx.QueryRow(QUERY_IMPORT_ACCOUNT, account.TenantId, account.Username, account.UsernameSkeleton, account.Password,
+			account.Email, account.EmailVerified, account.EmailVerifiedDate, account.DisplayName, account.PhoneNumber,
+			account.Role, status, string(encoded), account.CreationDate)
+		if err := row.Scan(&results[i].Id); err != nil {
+			results[i].Id = -1
+			results[i].Err = err
+			if _, err := tx.Exec(QUERY_ROLLBACK_ACCOUNT_IMPORT); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if organizationId != 0 {
+			var membershipId int
+			row := tx.QueryRow(QUERY_CREATE_MEMBERSHIP, organizationId, results[i].Id, MembershipRoleMember, account.CreationDate)
+			if err := row.Scan(&membershipId); err != nil {
+				results[i].Id = -1
+				results[i].Err = fmt.Errorf("membership failed: %w", err)
+				if _, err := tx.Exec(QUERY_ROLLBACK_ACCOUNT_IMPORT); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+		}
+
+		if _, err := tx.Exec(QUERY_RELEASE_ACCOUNT_IMPORT); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, tx.Commit()
+}
+
 func (repo *accountRepository) GetAccountById(id int) (Account, error) {
 	row := repo.db.QueryRow(QUERY_SELECT_ACCOUNT_BY_ID, id)
 
-	var account Account
-	err := row.Scan(&account.Id, &account.Username, &account.Password, &account.Email, &account.CreationDate)
-	return account, err
+	return scanAccount(row.Scan)
 }
 
-func (repo *accountRepository) GetAccountByUsername(username string) (Account, error) {
-	row := repo.db.QueryRow(QUERY_SELECT_ACCOUNT_BY_USERNAME, username)
+func (repo *accountRepository) GetAccountByUsername(tenantId int, username string) (Account, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ACCOUNT_BY_USERNAME, tenantId, username)
 
-	var account Account
-	err := row.Scan(&account.Id, &account.Username, &account.Password, &account.Email, &account.CreationDate)
-	return account, err
+	return scanAccount(row.Scan)
+}
+
+func (repo *accountRepository) GetAccountByUsernameSkeleton(tenantId int, skeleton string) (Account, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ACCOUNT_BY_USERNAME_SKELETON, tenantId, skeleton)
+
+	return scanAccount(row.Scan)
+}
+
+func (repo *accountRepository) GetAccountByEmail(tenantId int, email string) (Account, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ACCOUNT_BY_EMAIL, tenantId, email)
+	return scanAccount(row.Scan)
+}
+
+func (repo *accountRepository) UpdateAccountEmailVerified(id int, verifiedDate time.Time) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_EMAIL_VERIFIED, id, verifiedDate)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountVerificationSentDate(id int, sentDate time.Time) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_VERIFICATION_SENT_DATE, id, sentDate)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountProfile(id int, email string, displayName string, emailVerified bool, attributes map[string]interface{}) error {
+	var encoded interface{}
+	if attributes != nil {
+		value, err := json.Marshal(attributes)
+		if err != nil {
+			return err
+		}
+
+		encoded = string(value)
+	}
+
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_PROFILE, id, email, displayName, emailVerified, encoded)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountAttributes(id int, attributes map[string]interface{}) error {
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_ATTRIBUTES, id, string(encoded))
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountPhoneNumber(id int, phoneNumber string, verified bool) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_PHONE_NUMBER, id, phoneNumber, verified)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func IsValidAccountSortField(field string) bool {
+	_, ok := accountSortColumns[field]
+	return ok
+}
+
+func (repo *accountRepository) GetAccounts(filter AccountFilter) ([]Account, error) {
+	column, ok := accountSortColumns[filter.SortBy]
+	if !ok {
+		return nil, ErrInvalidSortField
+	}
+
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+
+	rows, err := repo.db.Query(fmt.Sprintf(QUERY_SELECT_ACCOUNTS, column, direction),
+		filter.Search, filter.Role, filter.Status, filter.TenantId, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (repo *accountRepository) CountAccounts(filter AccountFilter) (int, error) {
+	row := repo.db.QueryRow(QUERY_COUNT_ACCOUNTS, filter.Search, filter.Role, filter.Status, filter.TenantId)
+
+	count := 0
+	err := row.Scan(&count)
+	return count, err
+}
+
+func (repo *accountRepository) UpdateAccountStatus(transition AccountStatusTransition) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateAccountStatus(tx, transition); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateAccountStatus(tx *sql.Tx, transition AccountStatusTransition) error {
+	updatedId := -1
+	row := tx.QueryRow(QUERY_UPDATE_ACCOUNT_STATUS, transition.AccountId, transition.FromStatus,
+		transition.ToStatus, transition.Reason, transition.CreationDate)
+	if err := row.Scan(&updatedId); err != nil {
+		return err
+	}
+
+	transitionId := -1
+	row = tx.QueryRow(QUERY_CREATE_ACCOUNT_STATUS_TRANSITION, transition.AccountId, transition.FromStatus,
+		transition.ToStatus, transition.Reason, transition.Actor, transition.CreationDate)
+	return row.Scan(&transitionId)
+}
+
+func (repo *accountRepository) GetAccountStatusTransitions(accountId int) ([]AccountStatusTransition, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_ACCOUNT_STATUS_TRANSITIONS, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []AccountStatusTransition{}
+	for rows.Next() {
+		var transition AccountStatusTransition
+		err := rows.Scan(
+			&transition.Id,
+			&transition.AccountId,
+			&transition.FromStatus,
+			&transition.ToStatus,
+			&transition.Reason,
+			&transition.Actor,
+			&transition.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, rows.Err()
+}
+
+func (repo *accountRepository) UpdateAccountPasswordResetRequired(id int, required bool) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_PASSWORD_RESET_REQUIRED, id, required)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountPassword(id int, password string) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_PASSWORD, id, password)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccountUsername(id int, username string, skeleton string) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_USERNAME, id, username, skeleton)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) UpdateAccount(account Account, transition *AccountStatusTransition) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	updatedId := -1
+	row := tx.QueryRow(QUERY_UPDATE_ACCOUNT, account.Id, account.Username, account.UsernameSkeleton, account.Email,
+		account.DisplayName, account.EmailVerified, account.PhoneNumber, account.PhoneVerified, account.Password,
+		account.PasswordResetRequired)
+	if err := row.Scan(&updatedId); err != nil {
+		return err
+	}
+
+	if transition != nil {
+		if err := updateAccountStatus(tx, *transition); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *accountRepository) UpdateAccountRole(id int, role string) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_ACCOUNT_ROLE, id, role)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) IncrementFailedLoginAttempts(id int) (int, error) {
+	row := repo.db.QueryRow(QUERY_INCREMENT_ACCOUNT_FAILED_LOGIN_ATTEMPTS, id)
+
+	attempts := 0
+	err := row.Scan(&attempts)
+	return attempts, err
+}
+
+func (repo *accountRepository) LockAccount(id int, lockedUntil time.Time) error {
+	row := repo.db.QueryRow(QUERY_LOCK_ACCOUNT, id, lockedUntil)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) ResetLoginAttempts(id int) error {
+	row := repo.db.QueryRow(QUERY_RESET_ACCOUNT_LOGIN_ATTEMPTS, id)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) GetAccountsScheduledForDeletion(before time.Time) ([]Account, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_ACCOUNTS_SCHEDULED_FOR_DELETION, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (repo *accountRepository) ScheduleAccountDeletion(id int, requestedDate time.Time, scheduledDate time.Time) error {
+	row := repo.db.QueryRow(QUERY_SCHEDULE_ACCOUNT_DELETION, id, requestedDate, scheduledDate)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) CancelAccountDeletion(id int) error {
+	row := repo.db.QueryRow(QUERY_CANCEL_ACCOUNT_DELETION, id)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *accountRepository) AnonymizeAccountById(id int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range accountRelatedDeleteQueries {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
+	updatedId := -1
+	if err := tx.QueryRow(QUERY_ANONYMIZE_ACCOUNT, id).Scan(&updatedId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (repo *accountRepository) DeleteAccountById(id int) error {
@@ -77,7 +540,69 @@ func (repo *accountRepository) DeleteAccountById(id int) error {
 	return err
 }
 
+func (repo *accountRepository) DeleteAccount(id int, transition *AccountStatusTransition) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if transition != nil {
+		if err := updateAccountStatus(tx, *transition); err != nil {
+			return err
+		}
+	}
+
+	deletedId := -1
+	if err := tx.QueryRow(QUERY_DELETE_ACCOUNT_BY_ID, id).Scan(&deletedId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (repo *accountRepository) DeleteAccounts() error {
 	_, err := repo.db.Exec(QUERY_DELETE_ACCOUNTS)
 	return err
 }
+
+func scanAccount(scan func(dest ...any) error) (Account, error) {
+	var account Account
+	var attributes []byte
+	err := scan(
+		&account.Id,
+		&account.Username,
+		&account.Password,
+		&account.Email,
+		&account.CreationDate,
+		&account.PhoneNumber,
+		&account.PhoneVerified,
+		&account.EmailVerified,
+		&account.EmailVerifiedDate,
+		&account.VerificationSentDate,
+		&account.DisplayName,
+		&account.DeletionRequestedDate,
+		&account.DeletionScheduledDate,
+		&account.Role,
+		&account.FailedLoginAttempts,
+		&account.LockoutCount,
+		&account.LockedUntil,
+		&account.PasswordResetRequired,
+		&account.Status,
+		&account.StatusReason,
+		&account.StatusChangedDate,
+		&account.TenantId,
+		&account.UsernameSkeleton,
+		&attributes,
+		&account.LastLoginAt)
+	if err != nil {
+		return account, err
+	}
+
+	account.Attributes = map[string]interface{}{}
+	if len(attributes) > 0 {
+		err = json.Unmarshal(attributes, &account.Attributes)
+	}
+
+	return account, err
+}
@@ -26,7 +26,19 @@ func (suite *AccountRepositoryTestSuite) SetupSuite() {
 	preset := postgres.Preset(
 		postgres.WithUser("test", "test"),
 		postgres.WithDatabase("test"),
-		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE))
+		postgres.WithQueries(
+			QUERY_CREATE_ACCOUNT_TABLE,
+			QUERY_CREATE_ONE_TIME_PASSWORD_TABLE,
+			QUERY_CREATE_TRUSTED_DEVICE_TABLE,
+			QUERY_CREATE_ACCOUNT_STATUS_TRANSITION_TABLE,
+			QUERY_CREATE_ORGANIZATION_TABLE,
+			QUERY_CREATE_MEMBERSHIP_TABLE,
+			QUERY_CREATE_INVITATION_TABLE,
+			QUERY_CREATE_LOGIN_EVENT_TABLE,
+			QUERY_CREATE_LEGAL_DOCUMENT_TABLE,
+			QUERY_CREATE_CONSENT_TABLE,
+			QUERY_CREATE_LINKED_IDENTITY_TABLE,
+			QUERY_CREATE_API_KEY_TABLE))
 	suite.database, _ = gnomock.Start(preset)
 	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
 
@@ -91,6 +103,106 @@ func (suite *AccountRepositoryTestSuite) TestCreateAccountShouldSucceed() {
 	suite.NotEqual(-1, id)
 }
 
+func (suite *AccountRepositoryTestSuite) TestCreateAccountsShouldSkipFailedRowsAndCommitOthers() {
+	suite.insertAccount("taken")
+
+	results, err := suite.repo.CreateAccounts([]Account{
+		{Username: "first", Password: "hash", Email: "first@test.com", Role: "user", CreationDate: time.Now()},
+		{Username: "taken", Password: "hash", Email: "other@test.com", Role: "user", CreationDate: time.Now()},
+		{Username: "second", Password: "hash", Email: "second@test.com", Role: "admin", CreationDate: time.Now(), Attributes: map[string]interface{}{"locale": "de-DE"}},
+	}, 0)
+
+	suite.NoError(err)
+	suite.Len(results, 3)
+	suite.NoError(results[0].Err)
+	suite.Error(results[1].Err)
+	suite.NoError(results[2].Err)
+
+	account, err := suite.repo.GetAccountById(results[2].Id)
+	suite.NoError(err)
+	suite.Equal("admin", account.Role)
+	suite.Equal("de-DE", account.Attributes["locale"])
+}
+
+func (suite *AccountRepositoryTestSuite) TestCreateAccountsShouldCreateMembershipsInTheSameTransaction() {
+	var organizationId int
+	row := suite.db.QueryRow("INSERT INTO organization (name, slug, creation_date) VALUES ($1, $2, $3) RETURNING id",
+		"Acme", "acme", time.Now())
+	suite.NoError(row.Scan(&organizationId))
+	defer suite.db.Exec("DELETE FROM organization")
+
+	results, err := suite.repo.CreateAccounts([]Account{
+		{TenantId: organizationId, Username: "first", Password: "hash", Email: "first@test.com", Role: "user", CreationDate: time.Now()},
+	}, organizationId)
+
+	suite.NoError(err)
+	suite.Len(results, 1)
+	suite.NoError(results[0].Err)
+
+	var role string
+	row = suite.db.QueryRow("SELECT role FROM membership WHERE organization_id = $1 AND account_id = $2", organizationId, results[0].Id)
+	suite.NoError(row.Scan(&role))
+	suite.Equal(MembershipRoleMember, role)
+}
+
+func (suite *AccountRepositoryTestSuite) TestCreateAccountsShouldRollBackRowIfMembershipFails() {
+	results, err := suite.repo.CreateAccounts([]Account{
+		{TenantId: 1, Username: "first", Password: "hash", Email: "first@test.com", Role: "user", CreationDate: time.Now()},
+	}, 999)
+
+	suite.NoError(err)
+	suite.Len(results, 1)
+	suite.Error(results[0].Err)
+	suite.Equal(-1, results[0].Id)
+
+	_, err = suite.repo.GetAccountByUsername(1, "first")
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestCreateAccountShouldAllowSameUsernameInDifferentTenants() {
+	_, err := suite.repo.CreateAccount(Account{Username: "test", Password: "test", Email: "test@test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	id, err := suite.repo.CreateAccount(Account{TenantId: 1, Username: "test", Password: "test", Email: "test@test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountByUsername(1, "test")
+	suite.NoError(err)
+	suite.Equal(id, user.Id)
+	suite.Equal(1, user.TenantId)
+}
+
+func (suite *AccountRepositoryTestSuite) TestCreateAccountShouldRejectUsernameDifferingOnlyInCase() {
+	_, err := suite.repo.CreateAccount(Account{Username: "Alice", Password: "test", Email: "alice@test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	_, err = suite.repo.CreateAccount(Account{Username: "alice", Password: "test", Email: "other@test.com", CreationDate: time.Now()})
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountByUsernameShouldIgnoreCase() {
+	id, err := suite.repo.CreateAccount(Account{Username: "Alice", Password: "test", Email: "Alice@Test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountByUsername(0, "alice")
+	suite.NoError(err)
+	suite.Equal(id, user.Id)
+
+	user, err = suite.repo.GetAccountByEmail(0, "alice@test.com")
+	suite.NoError(err)
+	suite.Equal(id, user.Id)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountByUsernameSkeletonShouldSucceed() {
+	id, err := suite.repo.CreateAccount(Account{Username: "alice", UsernameSkeleton: "alice", Password: "test", Email: "alice@test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountByUsernameSkeleton(0, "alice")
+	suite.NoError(err)
+	suite.Equal(id, user.Id)
+	suite.Equal("alice", user.UsernameSkeleton)
+}
+
 func (suite *AccountRepositoryTestSuite) TestGetAccountByIdShouldReturnErrorIfScanFails() {
 	_, err := suite.repo.GetAccountById(-1)
 	suite.Error(err)
@@ -122,7 +234,7 @@ func (suite *AccountRepositoryTestSuite) TestGetAccountByIdShouldSucceed() {
 }
 
 func (suite *AccountRepositoryTestSuite) TestGetAccountByUsernameShouldReturnErrorIfScanFails() {
-	_, err := suite.repo.GetAccountByUsername("test")
+	_, err := suite.repo.GetAccountByUsername(0, "test")
 	suite.Error(err)
 }
 
@@ -135,7 +247,7 @@ func (suite *AccountRepositoryTestSuite) TestGetAccountByUsernameShouldSucceed()
 	suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
 		username, password, email, creationDate)
 
-	user, err := suite.repo.GetAccountByUsername(username)
+	user, err := suite.repo.GetAccountByUsername(0, username)
 
 	suite.NoError(err)
 	suite.NotEqual(-1, user.Id)
@@ -145,6 +257,504 @@ func (suite *AccountRepositoryTestSuite) TestGetAccountByUsernameShouldSucceed()
 	suite.Equal(creationDate.UnixMilli(), user.CreationDate.UnixMilli())
 }
 
+func (suite *AccountRepositoryTestSuite) TestGetAccountByEmailShouldReturnErrorIfScanFails() {
+	_, err := suite.repo.GetAccountByEmail(0, "test@test.com")
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountByEmailShouldSucceed() {
+	suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+
+	user, err := suite.repo.GetAccountByEmail(0, "test@test.com")
+
+	suite.NoError(err)
+	suite.Equal("test", user.Username)
+	suite.False(user.EmailVerified)
+	suite.Nil(user.EmailVerifiedDate)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountEmailVerifiedShouldSucceed() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+
+	id := -1
+	if err := row.Scan(&id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	verifiedDate := time.Now()
+	err := suite.repo.UpdateAccountEmailVerified(id, verifiedDate)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.True(user.EmailVerified)
+	suite.Equal(verifiedDate.UnixMilli(), user.EmailVerifiedDate.UnixMilli())
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountVerificationSentDateShouldSucceed() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+
+	id := -1
+	if err := row.Scan(&id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	sentDate := time.Now()
+	err := suite.repo.UpdateAccountVerificationSentDate(id, sentDate)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(sentDate.UnixMilli(), user.VerificationSentDate.UnixMilli())
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountProfileShouldSucceed() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, email_verified, email_verified_date, creation_date) VALUES ($1, $2, $3, true, $4, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+
+	id := -1
+	if err := row.Scan(&id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	err := suite.repo.UpdateAccountProfile(id, "new@test.com", "Test User", false, nil)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("new@test.com", user.Email)
+	suite.Equal("Test User", user.DisplayName)
+	suite.False(user.EmailVerified)
+	suite.Nil(user.EmailVerifiedDate)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountProfileShouldReturnErrorIfEmailTaken() {
+	suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"other", "test", "other@test.com", time.Now())
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+
+	id := -1
+	if err := row.Scan(&id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	err := suite.repo.UpdateAccountProfile(id, "other@test.com", "", false, nil)
+
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountProfileShouldUpdateAttributes() {
+	id := suite.insertAccount("test")
+
+	err := suite.repo.UpdateAccountProfile(id, "test@test.com", "Test User", false, map[string]interface{}{"locale": "de-DE"})
+	suite.NoError(err)
+
+	err = suite.repo.UpdateAccountProfile(id, "test@test.com", "Other User", false, nil)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("Other User", user.DisplayName)
+	suite.Equal("de-DE", user.Attributes["locale"])
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountPhoneNumberShouldReturnErrorIfAccountNotExists() {
+	err := suite.repo.UpdateAccountPhoneNumber(-1, "+4915112345678", false)
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountPhoneNumberShouldSucceed() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test", time.Now())
+
+	id := -1
+	err := row.Scan(&id)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	err = suite.repo.UpdateAccountPhoneNumber(id, "+4915112345678", true)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("+4915112345678", user.PhoneNumber)
+	suite.True(user.PhoneVerified)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountAttributesShouldSucceed() {
+	id := suite.insertAccount("test")
+
+	err := suite.repo.UpdateAccountAttributes(id, map[string]interface{}{"locale": "de-DE", "goals": []interface{}{"strength"}})
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("de-DE", user.Attributes["locale"])
+	suite.Equal([]interface{}{"strength"}, user.Attributes["goals"])
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountAttributesShouldReturnErrorIfAccountNotExists() {
+	err := suite.repo.UpdateAccountAttributes(-1, map[string]interface{}{})
+	suite.Error(err)
+}
+
+func (suite *AccountRepositoryTestSuite) insertAccount(username string) int {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		username, "test", username+"@test.com", time.Now())
+
+	id := -1
+	if err := row.Scan(&id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return id
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountsShouldFilterSortAndPaginate() {
+	suite.insertAccount("alice")
+	suite.insertAccount("bob")
+	suite.insertAccount("alina")
+	suite.insertAccount("carl")
+
+	accounts, err := suite.repo.GetAccounts(AccountFilter{Search: "AL", SortBy: "username", Descending: true, Limit: 10})
+	suite.NoError(err)
+	suite.Len(accounts, 2)
+	suite.Equal("alina", accounts[0].Username)
+	suite.Equal("alice", accounts[1].Username)
+
+	accounts, err = suite.repo.GetAccounts(AccountFilter{SortBy: "username", Limit: 2, Offset: 2})
+	suite.NoError(err)
+	suite.Len(accounts, 2)
+	suite.Equal("bob", accounts[0].Username)
+	suite.Equal("carl", accounts[1].Username)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountsShouldFilterByTenant() {
+	suite.insertAccount("alice")
+	id := suite.insertAccount("bob")
+	if _, err := suite.db.Exec("UPDATE account SET tenant_id = 7 WHERE id = $1", id); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	tenantId := 7
+	accounts, err := suite.repo.GetAccounts(AccountFilter{TenantId: &tenantId, SortBy: "username", Limit: 10})
+	suite.NoError(err)
+	suite.Len(accounts, 1)
+	suite.Equal("bob", accounts[0].Username)
+
+	count, err := suite.repo.CountAccounts(AccountFilter{TenantId: &tenantId})
+	suite.NoError(err)
+	suite.Equal(1, count)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountsShouldReturnErrorIfSortFieldInvalid() {
+	_, err := suite.repo.GetAccounts(AccountFilter{SortBy: "password", Limit: 10})
+	suite.ErrorIs(err, ErrInvalidSortField)
+}
+
+func (suite *AccountRepositoryTestSuite) suspendAccount(id int) {
+	err := suite.repo.UpdateAccountStatus(AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusSuspended,
+		Reason:       "test",
+		Actor:        "admin",
+		CreationDate: time.Now(),
+	})
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *AccountRepositoryTestSuite) TestCountAccountsShouldApplyFilter() {
+	id := suite.insertAccount("alice")
+	suite.insertAccount("bob")
+	suite.suspendAccount(id)
+
+	count, err := suite.repo.CountAccounts(AccountFilter{Status: AccountStatusSuspended})
+
+	suite.NoError(err)
+	suite.Equal(1, count)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountStatusShouldRecordTransition() {
+	id := suite.insertAccount("test")
+
+	suite.suspendAccount(id)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(AccountStatusSuspended, user.Status)
+	suite.Equal("test", user.StatusReason)
+	suite.NotNil(user.StatusChangedDate)
+
+	transitions, err := suite.repo.GetAccountStatusTransitions(id)
+	suite.NoError(err)
+	suite.Len(transitions, 1)
+	suite.Equal(AccountStatusActive, transitions[0].FromStatus)
+	suite.Equal(AccountStatusSuspended, transitions[0].ToStatus)
+	suite.Equal("admin", transitions[0].Actor)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountStatusShouldFailIfStatusChanged() {
+	id := suite.insertAccount("test")
+	suite.suspendAccount(id)
+
+	err := suite.repo.UpdateAccountStatus(AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusDeleted,
+		CreationDate: time.Now(),
+	})
+	suite.Error(err)
+
+	transitions, err := suite.repo.GetAccountStatusTransitions(id)
+	suite.NoError(err)
+	suite.Len(transitions, 1)
+}
+
+func (suite *AccountRepositoryTestSuite) TestDeleteAccountByIdShouldKeepStatusTransitions() {
+	id := suite.insertAccount("test")
+	suite.suspendAccount(id)
+
+	suite.NoError(suite.repo.DeleteAccountById(id))
+
+	count := 0
+	row := suite.db.QueryRow("SELECT count(*) FROM account_status_transition WHERE account_id IS NULL")
+	suite.NoError(row.Scan(&count))
+	suite.Equal(1, count)
+}
+
+func (suite *AccountRepositoryTestSuite) TestDeleteAccountShouldRecordTransition() {
+	id := suite.insertAccount("test")
+
+	err := suite.repo.DeleteAccount(id, &AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusDeleted,
+		Actor:        "admin",
+		CreationDate: time.Now(),
+	})
+	suite.NoError(err)
+
+	_, err = suite.repo.GetAccountById(id)
+	suite.Error(err)
+
+	count := 0
+	row := suite.db.QueryRow("SELECT count(*) FROM account_status_transition WHERE account_id IS NULL AND to_status = $1", AccountStatusDeleted)
+	suite.NoError(row.Scan(&count))
+	suite.Equal(1, count)
+}
+
+func (suite *AccountRepositoryTestSuite) TestDeleteAccountShouldKeepAccountIfTransitionFails() {
+	id := suite.insertAccount("test")
+	suite.suspendAccount(id)
+
+	err := suite.repo.DeleteAccount(id, &AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusDeleted,
+		CreationDate: time.Now(),
+	})
+	suite.Error(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(AccountStatusSuspended, user.Status)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountPasswordShouldClearResetRequired() {
+	id := suite.insertAccount("test")
+	suite.NoError(suite.repo.UpdateAccountPasswordResetRequired(id, true))
+
+	err := suite.repo.UpdateAccountPassword(id, "newhash")
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("newhash", user.Password)
+	suite.False(user.PasswordResetRequired)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountUsernameShouldSucceed() {
+	id := suite.insertAccount("test")
+
+	err := suite.repo.UpdateAccountUsername(id, "renamed", "renarned")
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountByUsernameSkeleton(0, "renarned")
+	suite.NoError(err)
+	suite.Equal(id, user.Id)
+	suite.Equal("renamed", user.Username)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountShouldWriteFieldsAndTransition() {
+	id := suite.insertAccount("test")
+	account, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+
+	account.Username = "renamed"
+	account.Email = "renamed@test.com"
+	account.PhoneNumber = "+4915112345678"
+	err = suite.repo.UpdateAccount(account, &AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusSuspended,
+		Actor:        "scim",
+		CreationDate: time.Now(),
+	})
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("renamed", user.Username)
+	suite.Equal("renamed@test.com", user.Email)
+	suite.Equal("+4915112345678", user.PhoneNumber)
+	suite.Equal(AccountStatusSuspended, user.Status)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountShouldRollbackIfTransitionFails() {
+	id := suite.insertAccount("test")
+	suite.suspendAccount(id)
+	account, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+
+	account.Username = "renamed"
+	err = suite.repo.UpdateAccount(account, &AccountStatusTransition{
+		AccountId:    id,
+		FromStatus:   AccountStatusActive,
+		ToStatus:     AccountStatusDeleted,
+		CreationDate: time.Now(),
+	})
+	suite.Error(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("test", user.Username)
+}
+
+func (suite *AccountRepositoryTestSuite) TestCreateAccountShouldAllowMultipleAccountsWithoutEmail() {
+	_, err := suite.repo.CreateAccount(Account{Username: "first", Password: "test", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	_, err = suite.repo.CreateAccount(Account{Username: "second", Password: "test", CreationDate: time.Now()})
+	suite.NoError(err)
+}
+
+func (suite *AccountRepositoryTestSuite) TestUpdateAccountRoleShouldSucceed() {
+	id := suite.insertAccount("test")
+
+	err := suite.repo.UpdateAccountRole(id, "admin")
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal("admin", user.Role)
+}
+
+func (suite *AccountRepositoryTestSuite) TestIncrementFailedLoginAttemptsShouldReturnAttempts() {
+	id := suite.insertAccount("test")
+
+	_, err := suite.repo.IncrementFailedLoginAttempts(id)
+	suite.NoError(err)
+	attempts, err := suite.repo.IncrementFailedLoginAttempts(id)
+
+	suite.NoError(err)
+	suite.Equal(2, attempts)
+}
+
+func (suite *AccountRepositoryTestSuite) TestLockAccountShouldResetAttemptsAndCountLockouts() {
+	id := suite.insertAccount("test")
+	lockedUntil := time.Now().Add(time.Minute)
+	_, err := suite.repo.IncrementFailedLoginAttempts(id)
+	suite.NoError(err)
+
+	err = suite.repo.LockAccount(id, lockedUntil)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(0, user.FailedLoginAttempts)
+	suite.Equal(1, user.LockoutCount)
+	suite.Equal(lockedUntil.UnixMilli(), user.LockedUntil.UnixMilli())
+}
+
+func (suite *AccountRepositoryTestSuite) TestResetLoginAttemptsShouldUnlockAccount() {
+	id := suite.insertAccount("test")
+	suite.NoError(suite.repo.LockAccount(id, time.Now().Add(time.Minute)))
+
+	err := suite.repo.ResetLoginAttempts(id)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(0, user.LockoutCount)
+	suite.Nil(user.LockedUntil)
+}
+
+func (suite *AccountRepositoryTestSuite) TestScheduleAccountDeletionShouldSucceed() {
+	id := suite.insertAccount("test")
+	requestedDate := time.Now()
+	scheduledDate := requestedDate.Add(time.Hour)
+
+	err := suite.repo.ScheduleAccountDeletion(id, requestedDate, scheduledDate)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Equal(requestedDate.UnixMilli(), user.DeletionRequestedDate.UnixMilli())
+	suite.Equal(scheduledDate.UnixMilli(), user.DeletionScheduledDate.UnixMilli())
+}
+
+func (suite *AccountRepositoryTestSuite) TestCancelAccountDeletionShouldSucceed() {
+	id := suite.insertAccount("test")
+	suite.NoError(suite.repo.ScheduleAccountDeletion(id, time.Now(), time.Now().Add(time.Hour)))
+
+	err := suite.repo.CancelAccountDeletion(id)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.Nil(user.DeletionRequestedDate)
+	suite.Nil(user.DeletionScheduledDate)
+}
+
+func (suite *AccountRepositoryTestSuite) TestGetAccountsScheduledForDeletionShouldReturnDueAccounts() {
+	due := suite.insertAccount("due")
+	notDue := suite.insertAccount("notdue")
+	suite.insertAccount("active")
+	suite.NoError(suite.repo.ScheduleAccountDeletion(due, time.Now(), time.Now().Add(-time.Minute)))
+	suite.NoError(suite.repo.ScheduleAccountDeletion(notDue, time.Now(), time.Now().Add(time.Hour)))
+
+	accounts, err := suite.repo.GetAccountsScheduledForDeletion(time.Now())
+
+	suite.NoError(err)
+	suite.Len(accounts, 1)
+	suite.Equal(due, accounts[0].Id)
+}
+
+func (suite *AccountRepositoryTestSuite) TestAnonymizeAccountByIdShouldRemovePersonalData() {
+	id := suite.insertAccount("test")
+	suite.NoError(suite.repo.UpdateAccountPhoneNumber(id, "+4915112345678", true))
+	suite.NoError(suite.repo.ScheduleAccountDeletion(id, time.Now(), time.Now()))
+
+	err := suite.repo.AnonymizeAccountById(id)
+	suite.NoError(err)
+
+	user, err := suite.repo.GetAccountById(id)
+	suite.NoError(err)
+	suite.NotEqual("test", user.Username)
+	suite.NotEqual("test@test.com", user.Email)
+	suite.Empty(user.Password)
+	suite.Empty(user.PhoneNumber)
+	suite.Nil(user.DeletionScheduledDate)
+}
+
 func (suite *AccountRepositoryTestSuite) TestDeleteAccountByIdShouldReturnErrorIfExecFails() {
 	err := suite.repo.DeleteAccountById(-1)
 	suite.Error(err)
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ApiKey struct {
+	Id             int
+	AccountId      int
+	Name           string
+	KeyHash        string
+	Hint           string
+	Scopes         []string
+	CreationDate   time.Time
+	ExpirationDate *time.Time
+	LastUsedDate   *time.Time
+}
+
+type ApiKeyRepository interface {
+	CreateApiKey(key ApiKey) (int, error)
+	GetApiKeyByHash(keyHash string) (ApiKey, error)
+	GetApiKeys(accountId int) ([]ApiKey, error)
+	UpdateApiKeyLastUsed(id int, lastUsed time.Time) error
+	DeleteApiKey(accountId int, id int) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewApiKeyRepository(config DatabaseConfig) ApiKeyRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (repo *apiKeyRepository) CreateApiKey(key ApiKey) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_API_KEY, key.AccountId, key.Name, key.KeyHash, key.Hint, pq.Array(key.Scopes), key.CreationDate, key.ExpirationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *apiKeyRepository) GetApiKeyByHash(keyHash string) (ApiKey, error) {
+	return scanApiKey(repo.db.QueryRow(QUERY_SELECT_API_KEY_BY_HASH, keyHash).Scan)
+}
+
+func (repo *apiKeyRepository) GetApiKeys(accountId int) ([]ApiKey, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_API_KEYS_BY_ACCOUNT, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []ApiKey{}
+	for rows.Next() {
+		key, err := scanApiKey(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (repo *apiKeyRepository) UpdateApiKeyLastUsed(id int, lastUsed time.Time) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_API_KEY_LAST_USED, id, lastUsed)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *apiKeyRepository) DeleteApiKey(accountId int, id int) error {
+	row := repo.db.QueryRow(QUERY_DELETE_API_KEY, accountId, id)
+
+	deletedId := -1
+	return row.Scan(&deletedId)
+}
+
+func scanApiKey(scan func(dest ...any) error) (ApiKey, error) {
+	var key ApiKey
+	err := scan(
+		&key.Id,
+		&key.AccountId,
+		&key.Name,
+		&key.KeyHash,
+		&key.Hint,
+		pq.Array(&key.Scopes),
+		&key.CreationDate,
+		&key.ExpirationDate,
+		&key.LastUsedDate)
+	return key, err
+}
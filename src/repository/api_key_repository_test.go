@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type ApiKeyRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      ApiKeyRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestApiKeyRepository(t *testing.T) {
+	suite.Run(t, new(ApiKeyRepositoryTestSuite))
+}
+
+func (suite *ApiKeyRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_API_KEY_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewApiKeyRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *ApiKeyRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *ApiKeyRepositoryTestSuite) TearDownTest() {
+	for _, query := range []string{"DELETE FROM api_key", "DELETE FROM account"} {
+		if _, err := suite.db.Exec(query); err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+func (suite *ApiKeyRepositoryTestSuite) TestCreateApiKeyShouldBeFoundByHash() {
+	id, err := suite.repo.CreateApiKey(ApiKey{AccountId: suite.accountId, Name: "ci", KeyHash: "hash", Hint: "fitter_abcd", Scopes: []string{"read", "write"}, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	key, err := suite.repo.GetApiKeyByHash("hash")
+	suite.NoError(err)
+	suite.Equal(id, key.Id)
+	suite.Equal(suite.accountId, key.AccountId)
+	suite.Equal([]string{"read", "write"}, key.Scopes)
+	suite.Nil(key.ExpirationDate)
+	suite.Nil(key.LastUsedDate)
+
+	_, err = suite.repo.GetApiKeyByHash("other")
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *ApiKeyRepositoryTestSuite) TestGetApiKeyByHashShouldSkipExpiredKeys() {
+	expirationDate := time.Now().Add(-time.Hour)
+	_, err := suite.repo.CreateApiKey(ApiKey{AccountId: suite.accountId, Name: "old", KeyHash: "hash", CreationDate: time.Now(), ExpirationDate: &expirationDate})
+	suite.NoError(err)
+
+	_, err = suite.repo.GetApiKeyByHash("hash")
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *ApiKeyRepositoryTestSuite) TestUpdateApiKeyLastUsedShouldSetTimestamp() {
+	id, err := suite.repo.CreateApiKey(ApiKey{AccountId: suite.accountId, Name: "ci", KeyHash: "hash", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.UpdateApiKeyLastUsed(id, time.Now()))
+
+	keys, err := suite.repo.GetApiKeys(suite.accountId)
+	suite.NoError(err)
+	suite.Len(keys, 1)
+	suite.NotNil(keys[0].LastUsedDate)
+}
+
+func (suite *ApiKeyRepositoryTestSuite) TestDeleteApiKeyShouldOnlyRemoveKeyOfAccount() {
+	id, err := suite.repo.CreateApiKey(ApiKey{AccountId: suite.accountId, Name: "ci", KeyHash: "hash", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.ErrorIs(suite.repo.DeleteApiKey(suite.accountId+1, id), sql.ErrNoRows)
+	suite.NoError(suite.repo.DeleteApiKey(suite.accountId, id))
+	suite.ErrorIs(suite.repo.DeleteApiKey(suite.accountId, id), sql.ErrNoRows)
+
+	keys, err := suite.repo.GetApiKeys(suite.accountId)
+	suite.NoError(err)
+	suite.Empty(keys)
+}
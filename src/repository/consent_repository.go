@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type LegalDocument struct {
+	Id            int
+	Type          string
+	Version       string
+	Url           string
+	PublishedDate time.Time
+}
+
+type Consent struct {
+	Id              int
+	AccountId       int
+	DocumentId      int
+	DocumentType    string
+	DocumentVersion string
+	IpAddress       string
+	CreationDate    time.Time
+}
+
+const (
+	LegalDocumentTermsOfService = "tos"
+	LegalDocumentPrivacyPolicy  = "privacy"
+)
+
+type ConsentRepository interface {
+	CreateLegalDocument(document LegalDocument) (int, error)
+	GetLegalDocuments() ([]LegalDocument, error)
+	GetCurrentLegalDocuments(now time.Time) ([]LegalDocument, error)
+	GetPendingLegalDocuments(accountId int, now time.Time) ([]LegalDocument, error)
+	CreateConsents(consents []Consent) error
+	GetConsentsByAccount(accountId int) ([]Consent, error)
+}
+
+type consentRepository struct {
+	db *sql.DB
+}
+
+func NewConsentRepository(config DatabaseConfig) ConsentRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &consentRepository{
+		db: db,
+	}
+}
+
+func (repo *consentRepository) CreateLegalDocument(document LegalDocument) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_LEGAL_DOCUMENT, document.Type, document.Version, document.Url, document.PublishedDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *consentRepository) GetLegalDocuments() ([]LegalDocument, error) {
+	return repo.queryLegalDocuments(QUERY_SELECT_LEGAL_DOCUMENTS)
+}
+
+func (repo *consentRepository) GetCurrentLegalDocuments(now time.Time) ([]LegalDocument, error) {
+	return repo.queryLegalDocuments(QUERY_SELECT_CURRENT_LEGAL_DOCUMENTS, now)
+}
+
+func (repo *consentRepository) GetPendingLegalDocuments(accountId int, now time.Time) ([]LegalDocument, error) {
+	return repo.queryLegalDocuments(QUERY_SELECT_PENDING_LEGAL_DOCUMENTS, now, accountId)
+}
+
+func (repo *consentRepository) queryLegalDocuments(query string, args ...any) ([]LegalDocument, error) {
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []LegalDocument{}
+	for rows.Next() {
+		document, err := scanLegalDocument(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, document)
+	}
+
+	return documents, rows.Err()
+}
+
+func (repo *consentRepository) CreateConsents(consents []Consent) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, consent := range consents {
+		if _, err := tx.Exec(QUERY_CREATE_CONSENT, consent.AccountId, consent.DocumentId, consent.IpAddress, consent.CreationDate); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *consentRepository) GetConsentsByAccount(accountId int) ([]Consent, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_CONSENTS_BY_ACCOUNT, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := []Consent{}
+	for rows.Next() {
+		var consent Consent
+		err := rows.Scan(
+			&consent.Id,
+			&consent.AccountId,
+			&consent.DocumentId,
+			&consent.DocumentType,
+			&consent.DocumentVersion,
+			&consent.IpAddress,
+			&consent.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
+
+func scanLegalDocument(scan func(dest ...any) error) (LegalDocument, error) {
+	var document LegalDocument
+	err := scan(
+		&document.Id,
+		&document.Type,
+		&document.Version,
+		&document.Url,
+		&document.PublishedDate)
+	return document, err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConsentRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      ConsentRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestConsentRepository(t *testing.T) {
+	suite.Run(t, new(ConsentRepositoryTestSuite))
+}
+
+func (suite *ConsentRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_LEGAL_DOCUMENT_TABLE, QUERY_CREATE_CONSENT_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewConsentRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *ConsentRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *ConsentRepositoryTestSuite) TearDownTest() {
+	for _, query := range []string{"DELETE FROM consent", "DELETE FROM legal_document", "DELETE FROM account"} {
+		if _, err := suite.db.Exec(query); err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+func (suite *ConsentRepositoryTestSuite) createDocument(documentType string, version string, publishedDate time.Time) LegalDocument {
+	document := LegalDocument{Type: documentType, Version: version, Url: "https://fitter.test/" + version, PublishedDate: publishedDate}
+
+	id, err := suite.repo.CreateLegalDocument(document)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	document.Id = id
+	return document
+}
+
+func (suite *ConsentRepositoryTestSuite) TestCreateLegalDocumentShouldRejectDuplicateVersion() {
+	suite.createDocument(LegalDocumentTermsOfService, "1", time.Now())
+
+	_, err := suite.repo.CreateLegalDocument(LegalDocument{Type: LegalDocumentTermsOfService, Version: "1", PublishedDate: time.Now()})
+	suite.Error(err)
+}
+
+func (suite *ConsentRepositoryTestSuite) TestGetCurrentLegalDocumentsShouldReturnLatestPublishedVersionPerType() {
+	now := time.Now()
+	suite.createDocument(LegalDocumentTermsOfService, "1", now.Add(-2*time.Hour))
+	current := suite.createDocument(LegalDocumentTermsOfService, "2", now.Add(-time.Hour))
+	suite.createDocument(LegalDocumentTermsOfService, "3", now.Add(time.Hour))
+	privacy := suite.createDocument(LegalDocumentPrivacyPolicy, "1", now.Add(-time.Hour))
+
+	documents, err := suite.repo.GetCurrentLegalDocuments(now)
+	suite.NoError(err)
+	suite.Len(documents, 2)
+	suite.Equal(privacy.Id, documents[0].Id)
+	suite.Equal(current.Id, documents[1].Id)
+
+	all, err := suite.repo.GetLegalDocuments()
+	suite.NoError(err)
+	suite.Len(all, 4)
+}
+
+func (suite *ConsentRepositoryTestSuite) TestGetPendingLegalDocumentsShouldExcludeAcceptedVersions() {
+	now := time.Now()
+	tos := suite.createDocument(LegalDocumentTermsOfService, "1", now.Add(-time.Hour))
+	privacy := suite.createDocument(LegalDocumentPrivacyPolicy, "1", now.Add(-time.Hour))
+
+	err := suite.repo.CreateConsents([]Consent{
+		{AccountId: suite.accountId, DocumentId: tos.Id, IpAddress: "10.0.0.1", CreationDate: now},
+		{AccountId: suite.accountId, DocumentId: tos.Id, IpAddress: "10.0.0.2", CreationDate: now},
+	})
+	suite.NoError(err)
+
+	pending, err := suite.repo.GetPendingLegalDocuments(suite.accountId, now)
+	suite.NoError(err)
+	suite.Len(pending, 1)
+	suite.Equal(privacy.Id, pending[0].Id)
+
+	consents, err := suite.repo.GetConsentsByAccount(suite.accountId)
+	suite.NoError(err)
+	suite.Len(consents, 1)
+	suite.Equal(LegalDocumentTermsOfService, consents[0].DocumentType)
+	suite.Equal("1", consents[0].DocumentVersion)
+	suite.Equal("10.0.0.1", consents[0].IpAddress)
+
+	updated := suite.createDocument(LegalDocumentTermsOfService, "2", now.Add(-time.Minute))
+	pending, err = suite.repo.GetPendingLegalDocuments(suite.accountId, now)
+	suite.NoError(err)
+	suite.Len(pending, 2)
+	suite.Equal(updated.Id, pending[1].Id)
+}
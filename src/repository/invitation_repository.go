@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type Invitation struct {
+	Id               int
+	Email            string
+	Role             string
+	OrganizationId   int
+	OrganizationSlug string
+	OrganizationRole string
+	InvitedBy        int
+	AccountId        int
+	CreationDate     time.Time
+	ExpirationDate   time.Time
+	RevokedDate      *time.Time
+	AcceptedDate     *time.Time
+}
+
+type InvitationRepository interface {
+	CreateInvitation(invitation Invitation) (int, error)
+	GetInvitationById(id int) (Invitation, error)
+	GetPendingInvitations() ([]Invitation, error)
+	RevokeInvitation(id int, revokedDate time.Time) error
+	AcceptInvitation(id int, accountId int, acceptedDate time.Time) error
+}
+
+type invitationRepository struct {
+	db *sql.DB
+}
+
+func NewInvitationRepository(config DatabaseConfig) InvitationRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &invitationRepository{
+		db: db,
+	}
+}
+
+func (repo *invitationRepository) CreateInvitation(invitation Invitation) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_INVITATION,
+		invitation.Email,
+		invitation.Role,
+		invitation.OrganizationId,
+		invitation.OrganizationRole,
+		invitation.InvitedBy,
+		invitation.CreationDate,
+		invitation.ExpirationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *invitationRepository) GetInvitationById(id int) (Invitation, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_INVITATION_BY_ID, id)
+	return scanInvitation(row.Scan)
+}
+
+func (repo *invitationRepository) GetPendingInvitations() ([]Invitation, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_PENDING_INVITATIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (repo *invitationRepository) RevokeInvitation(id int, revokedDate time.Time) error {
+	row := repo.db.QueryRow(QUERY_REVOKE_INVITATION, id, revokedDate)
+
+	revokedId := -1
+	return row.Scan(&revokedId)
+}
+
+func (repo *invitationRepository) AcceptInvitation(id int, accountId int, acceptedDate time.Time) error {
+	row := repo.db.QueryRow(QUERY_ACCEPT_INVITATION, id, accountId, acceptedDate)
+
+	acceptedId := -1
+	return row.Scan(&acceptedId)
+}
+
+func scanInvitation(scan func(dest ...any) error) (Invitation, error) {
+	var invitation Invitation
+	err := scan(
+		&invitation.Id,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.OrganizationId,
+		&invitation.OrganizationSlug,
+		&invitation.OrganizationRole,
+		&invitation.InvitedBy,
+		&invitation.AccountId,
+		&invitation.CreationDate,
+		&invitation.ExpirationDate,
+		&invitation.RevokedDate,
+		&invitation.AcceptedDate)
+	return invitation, err
+}
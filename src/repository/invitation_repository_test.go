@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type InvitationRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      InvitationRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestInvitationRepository(t *testing.T) {
+	suite.Run(t, new(InvitationRepositoryTestSuite))
+}
+
+func (suite *InvitationRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_ORGANIZATION_TABLE, QUERY_CREATE_INVITATION_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewInvitationRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *InvitationRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *InvitationRepositoryTestSuite) TearDownTest() {
+	for _, table := range []string{"invitation", "organization", "account"} {
+		if _, err := suite.db.Exec("DELETE FROM " + table); err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+func (suite *InvitationRepositoryTestSuite) createInvitation(expirationDate time.Time) int {
+	id, err := suite.repo.CreateInvitation(Invitation{
+		Email:          "staff@test.com",
+		Role:           "user",
+		InvitedBy:      suite.accountId,
+		CreationDate:   time.Now(),
+		ExpirationDate: expirationDate,
+	})
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return id
+}
+
+func (suite *InvitationRepositoryTestSuite) TestGetInvitationByIdShouldSucceed() {
+	id := suite.createInvitation(time.Now().Add(time.Hour))
+
+	invitation, err := suite.repo.GetInvitationById(id)
+	suite.NoError(err)
+	suite.Equal("staff@test.com", invitation.Email)
+	suite.Equal(suite.accountId, invitation.InvitedBy)
+	suite.Equal(0, invitation.OrganizationId)
+	suite.Nil(invitation.AcceptedDate)
+}
+
+func (suite *InvitationRepositoryTestSuite) TestGetInvitationByIdShouldIncludeOrganization() {
+	var organizationId int
+	row := suite.db.QueryRow("INSERT INTO organization (name, slug) VALUES ($1, $2) RETURNING id", "Acme", "acme")
+	if err := row.Scan(&organizationId); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	id, err := suite.repo.CreateInvitation(Invitation{
+		Email:            "staff@test.com",
+		Role:             "user",
+		OrganizationId:   organizationId,
+		OrganizationRole: MembershipRoleAdmin,
+		CreationDate:     time.Now(),
+		ExpirationDate:   time.Now().Add(time.Hour),
+	})
+	suite.NoError(err)
+
+	invitation, err := suite.repo.GetInvitationById(id)
+	suite.NoError(err)
+	suite.Equal(organizationId, invitation.OrganizationId)
+	suite.Equal("acme", invitation.OrganizationSlug)
+	suite.Equal(MembershipRoleAdmin, invitation.OrganizationRole)
+	suite.Equal(0, invitation.InvitedBy)
+}
+
+func (suite *InvitationRepositoryTestSuite) TestGetPendingInvitationsShouldSkipExpiredAndRevoked() {
+	pendingId := suite.createInvitation(time.Now().Add(time.Hour))
+	suite.createInvitation(time.Now().Add(-time.Hour))
+	revokedId := suite.createInvitation(time.Now().Add(time.Hour))
+	suite.NoError(suite.repo.RevokeInvitation(revokedId, time.Now()))
+
+	invitations, err := suite.repo.GetPendingInvitations()
+	suite.NoError(err)
+	suite.Len(invitations, 1)
+	suite.Equal(pendingId, invitations[0].Id)
+}
+
+func (suite *InvitationRepositoryTestSuite) TestRevokeInvitationShouldReturnErrorIfAlreadyRevoked() {
+	id := suite.createInvitation(time.Now().Add(time.Hour))
+	suite.NoError(suite.repo.RevokeInvitation(id, time.Now()))
+
+	err := suite.repo.RevokeInvitation(id, time.Now())
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *InvitationRepositoryTestSuite) TestAcceptInvitationShouldSucceedOnce() {
+	id := suite.createInvitation(time.Now().Add(time.Hour))
+
+	suite.NoError(suite.repo.AcceptInvitation(id, suite.accountId, time.Now()))
+	suite.ErrorIs(suite.repo.AcceptInvitation(id, suite.accountId, time.Now()), sql.ErrNoRows)
+
+	invitation, err := suite.repo.GetInvitationById(id)
+	suite.NoError(err)
+	suite.Equal(suite.accountId, invitation.AccountId)
+	suite.NotNil(invitation.AcceptedDate)
+}
+
+func (suite *InvitationRepositoryTestSuite) TestAcceptInvitationShouldRejectExpired() {
+	id := suite.createInvitation(time.Now().Add(-time.Hour))
+
+	err := suite.repo.AcceptInvitation(id, suite.accountId, time.Now())
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
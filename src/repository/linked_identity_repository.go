@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type LinkedIdentity struct {
+	Id           int
+	AccountId    int
+	Provider     string
+	Subject      string
+	Email        string
+	CreationDate time.Time
+}
+
+type LinkedIdentityRepository interface {
+	CreateLinkedIdentity(identity LinkedIdentity) (int, error)
+	GetLinkedIdentity(provider string, subject string) (LinkedIdentity, error)
+	GetLinkedIdentitiesByAccount(accountId int) ([]LinkedIdentity, error)
+	DeleteLinkedIdentity(accountId int, provider string) error
+}
+
+type linkedIdentityRepository struct {
+	db *sql.DB
+}
+
+func NewLinkedIdentityRepository(config DatabaseConfig) LinkedIdentityRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &linkedIdentityRepository{
+		db: db,
+	}
+}
+
+func (repo *linkedIdentityRepository) CreateLinkedIdentity(identity LinkedIdentity) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_LINKED_IDENTITY, identity.AccountId, identity.Provider, identity.Subject, identity.Email, identity.CreationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *linkedIdentityRepository) GetLinkedIdentity(provider string, subject string) (LinkedIdentity, error) {
+	return scanLinkedIdentity(repo.db.QueryRow(QUERY_SELECT_LINKED_IDENTITY, provider, subject).Scan)
+}
+
+func (repo *linkedIdentityRepository) GetLinkedIdentitiesByAccount(accountId int) ([]LinkedIdentity, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_LINKED_IDENTITIES_BY_ACCOUNT, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []LinkedIdentity{}
+	for rows.Next() {
+		identity, err := scanLinkedIdentity(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+func (repo *linkedIdentityRepository) DeleteLinkedIdentity(accountId int, provider string) error {
+	row := repo.db.QueryRow(QUERY_DELETE_LINKED_IDENTITY, accountId, provider)
+
+	deletedId := -1
+	return row.Scan(&deletedId)
+}
+
+func scanLinkedIdentity(scan func(dest ...any) error) (LinkedIdentity, error) {
+	var identity LinkedIdentity
+	err := scan(
+		&identity.Id,
+		&identity.AccountId,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreationDate)
+	return identity, err
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type LinkedIdentityRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      LinkedIdentityRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestLinkedIdentityRepository(t *testing.T) {
+	suite.Run(t, new(LinkedIdentityRepositoryTestSuite))
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_LINKED_IDENTITY_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewLinkedIdentityRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) TearDownTest() {
+	for _, query := range []string{"DELETE FROM linked_identities", "DELETE FROM account"} {
+		if _, err := suite.db.Exec(query); err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) TestCreateLinkedIdentityShouldBeFoundByProviderAndSubject() {
+	id, err := suite.repo.CreateLinkedIdentity(LinkedIdentity{AccountId: suite.accountId, Provider: "google", Subject: "1234", Email: "test@test.com", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	identity, err := suite.repo.GetLinkedIdentity("google", "1234")
+	suite.NoError(err)
+	suite.Equal(id, identity.Id)
+	suite.Equal(suite.accountId, identity.AccountId)
+	suite.Equal("test@test.com", identity.Email)
+
+	_, err = suite.repo.GetLinkedIdentity("github", "1234")
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) TestCreateLinkedIdentityShouldRejectDuplicates() {
+	_, err := suite.repo.CreateLinkedIdentity(LinkedIdentity{AccountId: suite.accountId, Provider: "google", Subject: "1234", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	_, err = suite.repo.CreateLinkedIdentity(LinkedIdentity{AccountId: suite.accountId, Provider: "google", Subject: "5678", CreationDate: time.Now()})
+	suite.Error(err)
+}
+
+func (suite *LinkedIdentityRepositoryTestSuite) TestDeleteLinkedIdentityShouldRemoveProviderOfAccount() {
+	_, err := suite.repo.CreateLinkedIdentity(LinkedIdentity{AccountId: suite.accountId, Provider: "google", Subject: "1234", CreationDate: time.Now()})
+	suite.NoError(err)
+	_, err = suite.repo.CreateLinkedIdentity(LinkedIdentity{AccountId: suite.accountId, Provider: "github", Subject: "42", CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.DeleteLinkedIdentity(suite.accountId, "google"))
+	suite.ErrorIs(suite.repo.DeleteLinkedIdentity(suite.accountId, "google"), sql.ErrNoRows)
+
+	identities, err := suite.repo.GetLinkedIdentitiesByAccount(suite.accountId)
+	suite.NoError(err)
+	suite.Len(identities, 1)
+	suite.Equal("github", identities[0].Provider)
+}
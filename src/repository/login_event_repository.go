@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type LoginEvent struct {
+	Id            int
+	AccountId     int
+	Username      string
+	Success       bool
+	Method        string
+	FailureReason string
+	IpAddress     string
+	UserAgent     string
+	CreationDate  time.Time
+}
+
+type LoginEventFilter struct {
+	AccountId int
+	Search    string
+	Result    string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+const (
+	LoginEventResultSuccess = "success"
+	LoginEventResultFailure = "failure"
+)
+
+type LoginEventRepository interface {
+	CreateLoginEvent(event LoginEvent) (int, error)
+	GetLoginEvents(filter LoginEventFilter) ([]LoginEvent, error)
+	CountLoginEvents(filter LoginEventFilter) (int, error)
+}
+
+type loginEventRepository struct {
+	db *sql.DB
+}
+
+func NewLoginEventRepository(config DatabaseConfig) LoginEventRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &loginEventRepository{
+		db: db,
+	}
+}
+
+func (repo *loginEventRepository) CreateLoginEvent(event LoginEvent) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	id := -1
+	row := tx.QueryRow(QUERY_CREATE_LOGIN_EVENT, event.AccountId, event.Username, event.Success, event.Method,
+		event.FailureReason, event.IpAddress, event.UserAgent, event.CreationDate)
+	if err := row.Scan(&id); err != nil {
+		return -1, err
+	}
+
+	if event.Success && event.AccountId != 0 {
+		updatedId := -1
+		row = tx.QueryRow(QUERY_UPDATE_ACCOUNT_LAST_LOGIN, event.AccountId, event.CreationDate)
+		if err := row.Scan(&updatedId); err != nil {
+			return -1, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+func (repo *loginEventRepository) GetLoginEvents(filter LoginEventFilter) ([]LoginEvent, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_LOGIN_EVENTS, filter.AccountId, filter.Search, filter.Result,
+		filter.From, filter.To, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []LoginEvent{}
+	for rows.Next() {
+		event, err := scanLoginEvent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (repo *loginEventRepository) CountLoginEvents(filter LoginEventFilter) (int, error) {
+	row := repo.db.QueryRow(QUERY_COUNT_LOGIN_EVENTS, filter.AccountId, filter.Search, filter.Result, filter.From, filter.To)
+
+	count := 0
+	err := row.Scan(&count)
+	return count, err
+}
+
+func scanLoginEvent(scan func(dest ...any) error) (LoginEvent, error) {
+	var event LoginEvent
+	err := scan(
+		&event.Id,
+		&event.AccountId,
+		&event.Username,
+		&event.Success,
+		&event.Method,
+		&event.FailureReason,
+		&event.IpAddress,
+		&event.UserAgent,
+		&event.CreationDate)
+	return event, err
+}
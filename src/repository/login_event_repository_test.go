@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoginEventRepositoryTestSuite struct {
+	suite.Suite
+	database    *gnomock.Container
+	repo        LoginEventRepository
+	accountRepo AccountRepository
+	db          *sql.DB
+	accountId   int
+}
+
+func TestLoginEventRepository(t *testing.T) {
+	suite.Run(t, new(LoginEventRepositoryTestSuite))
+}
+
+func (suite *LoginEventRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_LOGIN_EVENT_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	config := DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	}
+	suite.repo = NewLoginEventRepository(config)
+	suite.accountRepo = NewAccountRepository(config)
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *LoginEventRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *LoginEventRepositoryTestSuite) TearDownTest() {
+	for _, query := range []string{"DELETE FROM login_event", "DELETE FROM account"} {
+		if _, err := suite.db.Exec(query); err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+func (suite *LoginEventRepositoryTestSuite) createEvent(accountId int, username string, success bool, creationDate time.Time) int {
+	event := LoginEvent{
+		AccountId:    accountId,
+		Username:     username,
+		Success:      success,
+		Method:       "pwd",
+		IpAddress:    "127.0.0.1",
+		UserAgent:    "test",
+		CreationDate: creationDate,
+	}
+	if !success {
+		event.FailureReason = "wrong_password"
+	}
+
+	id, err := suite.repo.CreateLoginEvent(event)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return id
+}
+
+func (suite *LoginEventRepositoryTestSuite) TestCreateLoginEventShouldUpdateLastLogin() {
+	loginDate := time.Now()
+	suite.createEvent(suite.accountId, "test", true, loginDate)
+
+	account, err := suite.accountRepo.GetAccountById(suite.accountId)
+	suite.NoError(err)
+	suite.NotNil(account.LastLoginAt)
+	suite.Equal(loginDate.UnixMilli(), account.LastLoginAt.UnixMilli())
+}
+
+func (suite *LoginEventRepositoryTestSuite) TestCreateLoginEventShouldNotUpdateLastLoginOnFailure() {
+	suite.createEvent(suite.accountId, "test", false, time.Now())
+
+	account, err := suite.accountRepo.GetAccountById(suite.accountId)
+	suite.NoError(err)
+	suite.Nil(account.LastLoginAt)
+}
+
+func (suite *LoginEventRepositoryTestSuite) TestCreateLoginEventShouldAllowUnknownAccount() {
+	id := suite.createEvent(0, "unknown", false, time.Now())
+
+	events, err := suite.repo.GetLoginEvents(LoginEventFilter{Search: "unknown", Limit: 10})
+	suite.NoError(err)
+	suite.Len(events, 1)
+	suite.Equal(id, events[0].Id)
+	suite.Equal(0, events[0].AccountId)
+}
+
+func (suite *LoginEventRepositoryTestSuite) TestGetLoginEventsShouldFilterAndPaginate() {
+	now := time.Now()
+	suite.createEvent(suite.accountId, "test", false, now.Add(-3*time.Hour))
+	suite.createEvent(suite.accountId, "test", true, now.Add(-2*time.Hour))
+	latest := suite.createEvent(suite.accountId, "test", true, now.Add(-time.Hour))
+	suite.createEvent(0, "other", true, now)
+
+	filter := LoginEventFilter{AccountId: suite.accountId, Result: LoginEventResultSuccess, Limit: 1}
+	events, err := suite.repo.GetLoginEvents(filter)
+	suite.NoError(err)
+	suite.Len(events, 1)
+	suite.Equal(latest, events[0].Id)
+
+	count, err := suite.repo.CountLoginEvents(filter)
+	suite.NoError(err)
+	suite.Equal(2, count)
+
+	from := now.Add(-150 * time.Minute)
+	count, err = suite.repo.CountLoginEvents(LoginEventFilter{From: &from})
+	suite.NoError(err)
+	suite.Equal(3, count)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type OneTimePassword struct {
+	Id             int
+	AccountId      int
+	Purpose        string
+	CodeHash       string
+	Attempts       int
+	ExpirationDate time.Time
+	CreationDate   time.Time
+}
+
+type OneTimePasswordUsage struct {
+	Codes    int
+	Attempts int
+}
+
+type OneTimePasswordRepository interface {
+	CreateOneTimePassword(otp OneTimePassword) (int, error)
+	GetLatestOneTimePassword(accountId int, purpose string) (OneTimePassword, error)
+	IncrementOneTimePasswordAttempts(id int) error
+	DeleteOneTimePasswords(accountId int, purpose string) error
+	DeleteOneTimePasswordsBefore(accountId int, purpose string, before time.Time) error
+	GetOneTimePasswordUsage(accountId int, purpose string, since time.Time) (OneTimePasswordUsage, error)
+}
+
+type oneTimePasswordRepository struct {
+	db *sql.DB
+}
+
+func NewOneTimePasswordRepository(config DatabaseConfig) OneTimePasswordRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &oneTimePasswordRepository{
+		db: db,
+	}
+}
+
+func (repo *oneTimePasswordRepository) CreateOneTimePassword(otp OneTimePassword) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_ONE_TIME_PASSWORD, otp.AccountId, otp.Purpose, otp.CodeHash, otp.ExpirationDate, otp.CreationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *oneTimePasswordRepository) GetLatestOneTimePassword(accountId int, purpose string) (OneTimePassword, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_LATEST_ONE_TIME_PASSWORD, accountId, purpose)
+
+	var otp OneTimePassword
+	err := row.Scan(&otp.Id, &otp.AccountId, &otp.Purpose, &otp.CodeHash, &otp.Attempts, &otp.ExpirationDate, &otp.CreationDate)
+	return otp, err
+}
+
+func (repo *oneTimePasswordRepository) IncrementOneTimePasswordAttempts(id int) error {
+	row := repo.db.QueryRow(QUERY_INCREMENT_ONE_TIME_PASSWORD_ATTEMPTS, id)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *oneTimePasswordRepository) DeleteOneTimePasswords(accountId int, purpose string) error {
+	_, err := repo.db.Exec(QUERY_DELETE_ONE_TIME_PASSWORDS, accountId, purpose)
+	return err
+}
+
+func (repo *oneTimePasswordRepository) DeleteOneTimePasswordsBefore(accountId int, purpose string, before time.Time) error {
+	_, err := repo.db.Exec(QUERY_DELETE_ONE_TIME_PASSWORDS_BEFORE, accountId, purpose, before)
+	return err
+}
+
+func (repo *oneTimePasswordRepository) GetOneTimePasswordUsage(accountId int, purpose string, since time.Time) (OneTimePasswordUsage, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ONE_TIME_PASSWORD_USAGE, accountId, purpose, since)
+
+	var usage OneTimePasswordUsage
+	err := row.Scan(&usage.Codes, &usage.Attempts)
+	return usage, err
+}
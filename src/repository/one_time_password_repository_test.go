@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type OneTimePasswordRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      OneTimePasswordRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestOneTimePasswordRepository(t *testing.T) {
+	suite.Run(t, new(OneTimePasswordRepositoryTestSuite))
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_ONE_TIME_PASSWORD_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewOneTimePasswordRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TearDownTest() {
+	_, err := suite.db.Exec("DELETE FROM account")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestCreateOneTimePasswordShouldReturnErrorIfAccountNotExists() {
+	id, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+		AccountId:      -1,
+		Purpose:        "login",
+		CodeHash:       "hash",
+		ExpirationDate: time.Now().Add(time.Minute),
+		CreationDate:   time.Now(),
+	})
+
+	suite.Error(err)
+	suite.Equal(-1, id)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestGetLatestOneTimePasswordShouldReturnNewestCode() {
+	_, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+		AccountId:      suite.accountId,
+		Purpose:        "login",
+		CodeHash:       "old",
+		ExpirationDate: time.Now().Add(time.Minute),
+		CreationDate:   time.Now().Add(-time.Minute),
+	})
+	suite.NoError(err)
+
+	id, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+		AccountId:      suite.accountId,
+		Purpose:        "login",
+		CodeHash:       "new",
+		ExpirationDate: time.Now().Add(time.Minute),
+		CreationDate:   time.Now(),
+	})
+	suite.NoError(err)
+
+	otp, err := suite.repo.GetLatestOneTimePassword(suite.accountId, "login")
+
+	suite.NoError(err)
+	suite.Equal(id, otp.Id)
+	suite.Equal("new", otp.CodeHash)
+	suite.Equal(0, otp.Attempts)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestGetLatestOneTimePasswordShouldReturnErrorIfScanFails() {
+	_, err := suite.repo.GetLatestOneTimePassword(suite.accountId, "login")
+	suite.Error(err)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestIncrementOneTimePasswordAttemptsShouldSucceed() {
+	id, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+		AccountId:      suite.accountId,
+		Purpose:        "login",
+		CodeHash:       "hash",
+		ExpirationDate: time.Now().Add(time.Minute),
+		CreationDate:   time.Now(),
+	})
+	suite.NoError(err)
+
+	err = suite.repo.IncrementOneTimePasswordAttempts(id)
+	suite.NoError(err)
+
+	otp, err := suite.repo.GetLatestOneTimePassword(suite.accountId, "login")
+	suite.NoError(err)
+	suite.Equal(1, otp.Attempts)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestDeleteOneTimePasswordsShouldSucceed() {
+	_, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+		AccountId:      suite.accountId,
+		Purpose:        "login",
+		CodeHash:       "hash",
+		ExpirationDate: time.Now().Add(time.Minute),
+		CreationDate:   time.Now(),
+	})
+	suite.NoError(err)
+
+	err = suite.repo.DeleteOneTimePasswords(suite.accountId, "login")
+	suite.NoError(err)
+
+	_, err = suite.repo.GetLatestOneTimePassword(suite.accountId, "login")
+	suite.Error(err)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestGetOneTimePasswordUsageShouldSumCodesInWindow() {
+	for _, creationDate := range []time.Time{time.Now().Add(-2 * time.Hour), time.Now().Add(-time.Minute), time.Now()} {
+		id, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+			AccountId:      suite.accountId,
+			Purpose:        "login",
+			CodeHash:       "hash",
+			ExpirationDate: creationDate.Add(time.Minute),
+			CreationDate:   creationDate,
+		})
+		suite.NoError(err)
+		suite.NoError(suite.repo.IncrementOneTimePasswordAttempts(id))
+	}
+
+	usage, err := suite.repo.GetOneTimePasswordUsage(suite.accountId, "login", time.Now().Add(-time.Hour))
+
+	suite.NoError(err)
+	suite.Equal(2, usage.Codes)
+	suite.Equal(2, usage.Attempts)
+}
+
+func (suite *OneTimePasswordRepositoryTestSuite) TestDeleteOneTimePasswordsBeforeShouldKeepNewerCodes() {
+	for _, creationDate := range []time.Time{time.Now().Add(-2 * time.Hour), time.Now()} {
+		_, err := suite.repo.CreateOneTimePassword(OneTimePassword{
+			AccountId:      suite.accountId,
+			Purpose:        "login",
+			CodeHash:       "hash",
+			ExpirationDate: creationDate.Add(time.Minute),
+			CreationDate:   creationDate,
+		})
+		suite.NoError(err)
+	}
+
+	err := suite.repo.DeleteOneTimePasswordsBefore(suite.accountId, "login", time.Now().Add(-time.Hour))
+	suite.NoError(err)
+
+	usage, err := suite.repo.GetOneTimePasswordUsage(suite.accountId, "login", time.Time{})
+	suite.NoError(err)
+	suite.Equal(1, usage.Codes)
+}
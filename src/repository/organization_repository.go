@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+const (
+	MembershipRoleOwner  = "owner"
+	MembershipRoleAdmin  = "admin"
+	MembershipRoleMember = "member"
+)
+
+type Organization struct {
+	Id               int
+	Name             string
+	Slug             string
+	ScopedIdentities bool
+	OpenRegistration bool
+	CreationDate     time.Time
+}
+
+type Membership struct {
+	Id               int
+	OrganizationId   int
+	AccountId        int
+	Role             string
+	CreationDate     time.Time
+	OrganizationSlug string
+	OrganizationName string
+	Username         string
+}
+
+type OrganizationRepository interface {
+	CreateOrganization(organization Organization) (int, error)
+	GetOrganizationById(id int) (Organization, error)
+	GetOrganizationBySlug(slug string) (Organization, error)
+	GetOrganizations() ([]Organization, error)
+	DeleteOrganization(id int) error
+	CreateMembership(membership Membership) (int, error)
+	GetMembership(organizationId int, accountId int) (Membership, error)
+	GetMembershipsByAccount(accountId int) ([]Membership, error)
+	GetMembershipsByOrganization(organizationId int) ([]Membership, error)
+	UpdateMembershipRole(organizationId int, accountId int, role string) error
+	DeleteMembership(organizationId int, accountId int) error
+}
+
+type organizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(config DatabaseConfig) OrganizationRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &organizationRepository{
+		db: db,
+	}
+}
+
+func (repo *organizationRepository) CreateOrganization(organization Organization) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_ORGANIZATION, organization.Name, organization.Slug, organization.ScopedIdentities, organization.OpenRegistration, organization.CreationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *organizationRepository) GetOrganizationById(id int) (Organization, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ORGANIZATION_BY_ID, id)
+	return scanOrganization(row.Scan)
+}
+
+func (repo *organizationRepository) GetOrganizationBySlug(slug string) (Organization, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_ORGANIZATION_BY_SLUG, slug)
+	return scanOrganization(row.Scan)
+}
+
+func (repo *organizationRepository) GetOrganizations() ([]Organization, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_ORGANIZATIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []Organization{}
+	for rows.Next() {
+		organization, err := scanOrganization(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		organizations = append(organizations, organization)
+	}
+
+	return organizations, rows.Err()
+}
+
+func (repo *organizationRepository) DeleteOrganization(id int) error {
+	row := repo.db.QueryRow(QUERY_DELETE_ORGANIZATION, id)
+
+	deletedId := -1
+	return row.Scan(&deletedId)
+}
+
+func (repo *organizationRepository) CreateMembership(membership Membership) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_MEMBERSHIP, membership.OrganizationId, membership.AccountId, membership.Role, membership.CreationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *organizationRepository) GetMembership(organizationId int, accountId int) (Membership, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_MEMBERSHIP, organizationId, accountId)
+	return scanMembership(row.Scan)
+}
+
+func (repo *organizationRepository) GetMembershipsByAccount(accountId int) ([]Membership, error) {
+	return repo.queryMemberships(QUERY_SELECT_MEMBERSHIPS_BY_ACCOUNT, accountId)
+}
+
+func (repo *organizationRepository) GetMembershipsByOrganization(organizationId int) ([]Membership, error) {
+	return repo.queryMemberships(QUERY_SELECT_MEMBERSHIPS_BY_ORGANIZATION, organizationId)
+}
+
+func (repo *organizationRepository) UpdateMembershipRole(organizationId int, accountId int, role string) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_MEMBERSHIP_ROLE, organizationId, accountId, role)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *organizationRepository) DeleteMembership(organizationId int, accountId int) error {
+	row := repo.db.QueryRow(QUERY_DELETE_MEMBERSHIP, organizationId, accountId)
+
+	deletedId := -1
+	return row.Scan(&deletedId)
+}
+
+func (repo *organizationRepository) queryMemberships(query string, id int) ([]Membership, error) {
+	rows, err := repo.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []Membership{}
+	for rows.Next() {
+		membership, err := scanMembership(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		memberships = append(memberships, membership)
+	}
+
+	return memberships, rows.Err()
+}
+
+func scanOrganization(scan func(dest ...any) error) (Organization, error) {
+	var organization Organization
+	err := scan(
+		&organization.Id,
+		&organization.Name,
+		&organization.Slug,
+		&organization.ScopedIdentities,
+		&organization.OpenRegistration,
+		&organization.CreationDate)
+	return organization, err
+}
+
+func scanMembership(scan func(dest ...any) error) (Membership, error) {
+	var membership Membership
+	err := scan(
+		&membership.Id,
+		&membership.OrganizationId,
+		&membership.AccountId,
+		&membership.Role,
+		&membership.CreationDate,
+		&membership.OrganizationSlug,
+		&membership.OrganizationName,
+		&membership.Username)
+	return membership, err
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type OrganizationRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      OrganizationRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestOrganizationRepository(t *testing.T) {
+	suite.Run(t, new(OrganizationRepositoryTestSuite))
+}
+
+func (suite *OrganizationRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_ORGANIZATION_TABLE, QUERY_CREATE_MEMBERSHIP_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewOrganizationRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *OrganizationRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *OrganizationRepositoryTestSuite) TearDownTest() {
+	if _, err := suite.db.Exec("DELETE FROM organization"); err != nil {
+		suite.T().Fatal(err)
+	}
+
+	if _, err := suite.db.Exec("DELETE FROM account"); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *OrganizationRepositoryTestSuite) createOrganization(slug string) int {
+	id, err := suite.repo.CreateOrganization(Organization{
+		Name:             "Organization " + slug,
+		Slug:             slug,
+		ScopedIdentities: true,
+		OpenRegistration: true,
+		CreationDate:     time.Now(),
+	})
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return id
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestCreateOrganizationShouldRejectDuplicateSlug() {
+	suite.createOrganization("acme")
+
+	_, err := suite.repo.CreateOrganization(Organization{Name: "Other", Slug: "acme", CreationDate: time.Now()})
+	suite.Error(err)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestGetOrganizationBySlugShouldSucceed() {
+	id := suite.createOrganization("acme")
+
+	organization, err := suite.repo.GetOrganizationBySlug("acme")
+	suite.NoError(err)
+	suite.Equal(id, organization.Id)
+	suite.Equal("Organization acme", organization.Name)
+	suite.True(organization.ScopedIdentities)
+	suite.True(organization.OpenRegistration)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestGetOrganizationByIdShouldReturnErrorIfNotFound() {
+	_, err := suite.repo.GetOrganizationById(1)
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestGetOrganizationsShouldReturnAll() {
+	suite.createOrganization("acme")
+	suite.createOrganization("globex")
+
+	organizations, err := suite.repo.GetOrganizations()
+	suite.NoError(err)
+	suite.Len(organizations, 2)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestDeleteOrganizationShouldDeleteMemberships() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleOwner, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.DeleteOrganization(organizationId))
+
+	memberships, err := suite.repo.GetMembershipsByAccount(suite.accountId)
+	suite.NoError(err)
+	suite.Empty(memberships)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestCreateMembershipShouldRejectDuplicate() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleMember, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	_, err = suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleAdmin, CreationDate: time.Now()})
+	suite.Error(err)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestGetMembershipShouldIncludeOrganization() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleMember, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	membership, err := suite.repo.GetMembership(organizationId, suite.accountId)
+	suite.NoError(err)
+	suite.Equal(MembershipRoleMember, membership.Role)
+	suite.Equal("acme", membership.OrganizationSlug)
+	suite.Equal("test", membership.Username)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestGetMembershipsByOrganizationShouldSucceed() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleMember, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	memberships, err := suite.repo.GetMembershipsByOrganization(organizationId)
+	suite.NoError(err)
+	suite.Len(memberships, 1)
+	suite.Equal(suite.accountId, memberships[0].AccountId)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestUpdateMembershipRoleShouldSucceed() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleMember, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.UpdateMembershipRole(organizationId, suite.accountId, MembershipRoleAdmin))
+
+	membership, err := suite.repo.GetMembership(organizationId, suite.accountId)
+	suite.NoError(err)
+	suite.Equal(MembershipRoleAdmin, membership.Role)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestUpdateMembershipRoleShouldReturnErrorIfNotFound() {
+	organizationId := suite.createOrganization("acme")
+
+	err := suite.repo.UpdateMembershipRole(organizationId, suite.accountId, MembershipRoleAdmin)
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
+
+func (suite *OrganizationRepositoryTestSuite) TestDeleteMembershipShouldSucceed() {
+	organizationId := suite.createOrganization("acme")
+	_, err := suite.repo.CreateMembership(Membership{OrganizationId: organizationId, AccountId: suite.accountId, Role: MembershipRoleMember, CreationDate: time.Now()})
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.DeleteMembership(organizationId, suite.accountId))
+
+	_, err = suite.repo.GetMembership(organizationId, suite.accountId)
+	suite.ErrorIs(err, sql.ErrNoRows)
+}
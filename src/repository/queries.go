@@ -1,37 +1,720 @@
 package repository
 
 const (
+	ACCOUNT_COLUMNS = `id, username, password, email, creation_date, phone_number, phone_verified,
+		email_verified, email_verified_date, verification_sent_date, display_name,
+		deletion_requested_date, deletion_scheduled_date, role, failed_login_attempts,
+		lockout_count, locked_until, password_reset_required, status, status_reason,
+		status_changed_date, tenant_id, username_skeleton, attributes, last_login_at`
+
+	ACCOUNT_FILTER = `
+	($1 = '' OR strpos(lower(username), lower($1)) > 0 OR strpos(lower(email), lower($1)) > 0)
+		AND ($2 = '' OR role = $2)
+		AND ($3 = '' OR status = $3)
+		AND ($4::INTEGER IS NULL OR tenant_id = $4)`
+
 	QUERY_DELETE_ACCOUNTS = `
 	DELETE FROM account`
 
 	QUERY_CREATE_ACCOUNT_TABLE = `
 	CREATE TABLE account (
 		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) UNIQUE NOT NULL,
+		tenant_id INTEGER NOT NULL DEFAULT 0,
+		username VARCHAR(255) NOT NULL,
 		password VARCHAR(64) NOT NULL,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now()
-	)`
+		email VARCHAR(255) NOT NULL,
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		phone_number VARCHAR(32) NOT NULL DEFAULT '',
+		phone_verified BOOLEAN NOT NULL DEFAULT false,
+		email_verified BOOLEAN NOT NULL DEFAULT false,
+		email_verified_date TIMESTAMP WITH TIME ZONE,
+		verification_sent_date TIMESTAMP WITH TIME ZONE,
+		display_name VARCHAR(64) NOT NULL DEFAULT '',
+		deletion_requested_date TIMESTAMP WITH TIME ZONE,
+		deletion_scheduled_date TIMESTAMP WITH TIME ZONE,
+		role VARCHAR(32) NOT NULL DEFAULT 'user',
+		failed_login_attempts INTEGER NOT NULL DEFAULT 0,
+		lockout_count INTEGER NOT NULL DEFAULT 0,
+		locked_until TIMESTAMP WITH TIME ZONE,
+		password_reset_required BOOLEAN NOT NULL DEFAULT false,
+		status VARCHAR(16) NOT NULL DEFAULT 'active',
+		status_reason VARCHAR(255) NOT NULL DEFAULT '',
+		status_changed_date TIMESTAMP WITH TIME ZONE,
+		username_skeleton VARCHAR(255) NOT NULL DEFAULT '',
+		attributes JSONB NOT NULL DEFAULT '{}',
+		last_login_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE UNIQUE INDEX account_username_idx ON account (tenant_id, lower(username));
+	CREATE UNIQUE INDEX account_email_idx ON account (tenant_id, lower(email)) WHERE email <> '';
+	CREATE UNIQUE INDEX account_username_skeleton_idx ON account (tenant_id, username_skeleton) WHERE username_skeleton <> ''`
 
 	QUERY_CREATE_ACCOUNT = `
-	INSERT INTO Account (username, password, email, creation_date)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO Account (username, password, email, creation_date, status, status_changed_date, tenant_id, username_skeleton)
+	VALUES ($1, $2, $3, $4, $5, $4, $6, $7)
+	RETURNING id`
+
+	QUERY_IMPORT_ACCOUNT = `
+	INSERT INTO Account (tenant_id, username, username_skeleton, password, email, email_verified, email_verified_date,
+		display_name, phone_number, role, status, attributes, creation_date, status_changed_date)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
 	RETURNING id`
 
+	QUERY_SAVEPOINT_ACCOUNT_IMPORT = `
+	SAVEPOINT account_import`
+
+	QUERY_ROLLBACK_ACCOUNT_IMPORT = `
+	ROLLBACK TO SAVEPOINT account_import`
+
+	QUERY_RELEASE_ACCOUNT_IMPORT = `
+	RELEASE SAVEPOINT account_import`
+
 	QUERY_SELECT_ACCOUNT_BY_ID = `
-	SELECT id, username, password, email, creation_date
+	SELECT ` + ACCOUNT_COLUMNS + `
 	FROM Account
 	WHERE id = $1
 	LIMIT 1`
 
 	QUERY_SELECT_ACCOUNT_BY_USERNAME = `
-	SELECT id, username, password, email, creation_date
+	SELECT ` + ACCOUNT_COLUMNS + `
+	FROM Account
+	WHERE tenant_id = $1 AND lower(username) = lower($2)
+	LIMIT 1`
+
+	QUERY_SELECT_ACCOUNT_BY_USERNAME_SKELETON = `
+	SELECT ` + ACCOUNT_COLUMNS + `
 	FROM Account
-	WHERE username = $1
+	WHERE tenant_id = $1 AND username_skeleton = $2
 	LIMIT 1`
 
+	QUERY_SELECT_ACCOUNT_BY_EMAIL = `
+	SELECT ` + ACCOUNT_COLUMNS + `
+	FROM Account
+	WHERE tenant_id = $1 AND lower(email) = lower($2)
+	LIMIT 1`
+
+	QUERY_UPDATE_ACCOUNT_EMAIL_VERIFIED = `
+	UPDATE Account
+	SET email_verified = true, email_verified_date = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_VERIFICATION_SENT_DATE = `
+	UPDATE Account
+	SET verification_sent_date = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_PROFILE = `
+	UPDATE Account
+	SET email = $2, display_name = $3, email_verified = $4,
+		email_verified_date = CASE WHEN $4 THEN email_verified_date END,
+		attributes = COALESCE($5::jsonb, attributes)
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_ATTRIBUTES = `
+	UPDATE Account
+	SET attributes = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_PHONE_NUMBER = `
+	UPDATE Account
+	SET phone_number = $2, phone_verified = $3
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_SELECT_ACCOUNTS = `
+	SELECT ` + ACCOUNT_COLUMNS + `
+	FROM Account
+	WHERE ` + ACCOUNT_FILTER + `
+	ORDER BY %s %s, id
+	LIMIT $5 OFFSET $6`
+
+	QUERY_COUNT_ACCOUNTS = `
+	SELECT count(*)
+	FROM Account
+	WHERE ` + ACCOUNT_FILTER
+
+	QUERY_UPDATE_ACCOUNT_STATUS = `
+	UPDATE Account
+	SET status = $3, status_reason = $4, status_changed_date = $5
+	WHERE id = $1 AND status = $2
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_PASSWORD_RESET_REQUIRED = `
+	UPDATE Account
+	SET password_reset_required = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_PASSWORD = `
+	UPDATE Account
+	SET password = $2, password_reset_required = false
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT = `
+	UPDATE Account
+	SET username = $2, username_skeleton = $3, email = $4, display_name = $5, email_verified = $6,
+		email_verified_date = CASE WHEN $6 THEN email_verified_date END,
+		phone_number = $7, phone_verified = $8, password = $9, password_reset_required = $10
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_USERNAME = `
+	UPDATE Account
+	SET username = $2, username_skeleton = $3
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_ROLE = `
+	UPDATE Account
+	SET role = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_INCREMENT_ACCOUNT_FAILED_LOGIN_ATTEMPTS = `
+	UPDATE Account
+	SET failed_login_attempts = failed_login_attempts + 1
+	WHERE id = $1
+	RETURNING failed_login_attempts`
+
+	QUERY_LOCK_ACCOUNT = `
+	UPDATE Account
+	SET failed_login_attempts = 0, lockout_count = lockout_count + 1, locked_until = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_RESET_ACCOUNT_LOGIN_ATTEMPTS = `
+	UPDATE Account
+	SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_SELECT_ACCOUNTS_SCHEDULED_FOR_DELETION = `
+	SELECT ` + ACCOUNT_COLUMNS + `
+	FROM Account
+	WHERE deletion_scheduled_date IS NOT NULL AND deletion_scheduled_date <= $1
+	ORDER BY deletion_scheduled_date`
+
+	QUERY_SCHEDULE_ACCOUNT_DELETION = `
+	UPDATE Account
+	SET deletion_requested_date = $2, deletion_scheduled_date = $3
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_CANCEL_ACCOUNT_DELETION = `
+	UPDATE Account
+	SET deletion_requested_date = NULL, deletion_scheduled_date = NULL
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_ANONYMIZE_ACCOUNT = `
+	UPDATE Account
+	SET username = 'deleted-' || id,
+		password = '',
+		email = 'deleted-' || id || '@invalid',
+		phone_number = '',
+		phone_verified = false,
+		email_verified = false,
+		email_verified_date = NULL,
+		verification_sent_date = NULL,
+		display_name = '',
+		deletion_scheduled_date = NULL,
+		role = 'user',
+		failed_login_attempts = 0,
+		lockout_count = 0,
+		locked_until = NULL,
+		password_reset_required = false,
+		username_skeleton = '',
+		attributes = '{}',
+		last_login_at = NULL
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_DELETE_ONE_TIME_PASSWORDS_BY_ACCOUNT = `
+	DELETE FROM one_time_password
+	WHERE account_id = $1`
+
+	QUERY_DELETE_TRUSTED_DEVICES_BY_ACCOUNT = `
+	DELETE FROM trusted_device
+	WHERE account_id = $1`
+
+	QUERY_DELETE_MEMBERSHIPS_BY_ACCOUNT = `
+	DELETE FROM membership
+	WHERE account_id = $1`
+
+	QUERY_DELETE_INVITATIONS_BY_ACCOUNT = `
+	DELETE FROM invitation
+	WHERE account_id = $1`
+
+	QUERY_DELETE_LOGIN_EVENTS_BY_ACCOUNT = `
+	DELETE FROM login_event
+	WHERE account_id = $1`
+
+	QUERY_DELETE_CONSENTS_BY_ACCOUNT = `
+	DELETE FROM consent
+	WHERE account_id = $1`
+
+	QUERY_DELETE_LINKED_IDENTITIES_BY_ACCOUNT = `
+	DELETE FROM linked_identities
+	WHERE account_id = $1`
+
+	QUERY_DELETE_API_KEYS_BY_ACCOUNT = `
+	DELETE FROM api_key
+	WHERE account_id = $1`
+
 	QUERY_DELETE_ACCOUNT_BY_ID = `
 	DELETE FROM Account
 	WHERE id = $1
 	RETURNING id`
+
+	QUERY_CREATE_ACCOUNT_STATUS_TRANSITION_TABLE = `
+	CREATE TABLE account_status_transition (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER REFERENCES account(id) ON DELETE SET NULL,
+		from_status VARCHAR(16) NOT NULL,
+		to_status VARCHAR(16) NOT NULL,
+		reason VARCHAR(255) NOT NULL DEFAULT '',
+		actor VARCHAR(255) NOT NULL DEFAULT '',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now()
+	)`
+
+	QUERY_CREATE_ACCOUNT_STATUS_TRANSITION = `
+	INSERT INTO account_status_transition (account_id, from_status, to_status, reason, actor, creation_date)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id`
+
+	QUERY_SELECT_ACCOUNT_STATUS_TRANSITIONS = `
+	SELECT id, account_id, from_status, to_status, reason, actor, creation_date
+	FROM account_status_transition
+	WHERE account_id = $1
+	ORDER BY creation_date, id`
+
+	QUERY_CREATE_ONE_TIME_PASSWORD_TABLE = `
+	CREATE TABLE one_time_password (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		purpose VARCHAR(32) NOT NULL,
+		code_hash VARCHAR(64) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		expiration_date TIMESTAMP WITH TIME ZONE NOT NULL,
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now()
+	)`
+
+	QUERY_CREATE_ONE_TIME_PASSWORD = `
+	INSERT INTO one_time_password (account_id, purpose, code_hash, expiration_date, creation_date)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	QUERY_SELECT_LATEST_ONE_TIME_PASSWORD = `
+	SELECT id, account_id, purpose, code_hash, attempts, expiration_date, creation_date
+	FROM one_time_password
+	WHERE account_id = $1 AND purpose = $2
+	ORDER BY creation_date DESC, id DESC
+	LIMIT 1`
+
+	QUERY_INCREMENT_ONE_TIME_PASSWORD_ATTEMPTS = `
+	UPDATE one_time_password
+	SET attempts = attempts + 1
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_DELETE_ONE_TIME_PASSWORDS = `
+	DELETE FROM one_time_password
+	WHERE account_id = $1 AND purpose = $2`
+
+	QUERY_DELETE_ONE_TIME_PASSWORDS_BEFORE = `
+	DELETE FROM one_time_password
+	WHERE account_id = $1 AND purpose = $2 AND creation_date < $3`
+
+	QUERY_SELECT_ONE_TIME_PASSWORD_USAGE = `
+	SELECT COUNT(*), COALESCE(SUM(attempts), 0)
+	FROM one_time_password
+	WHERE account_id = $1 AND purpose = $2 AND creation_date >= $3`
+
+	QUERY_CREATE_TRUSTED_DEVICE_TABLE = `
+	CREATE TABLE trusted_device (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		device_id VARCHAR(64) UNIQUE NOT NULL,
+		name VARCHAR(255) NOT NULL DEFAULT '',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		expiration_date TIMESTAMP WITH TIME ZONE NOT NULL,
+		last_used_date TIMESTAMP WITH TIME ZONE
+	)`
+
+	QUERY_CREATE_TRUSTED_DEVICE = `
+	INSERT INTO trusted_device (account_id, device_id, name, creation_date, expiration_date)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	QUERY_SELECT_TRUSTED_DEVICE = `
+	SELECT id, account_id, device_id, name, creation_date, expiration_date, last_used_date
+	FROM trusted_device
+	WHERE account_id = $1 AND device_id = $2 AND expiration_date > now()
+	LIMIT 1`
+
+	QUERY_SELECT_TRUSTED_DEVICES = `
+	SELECT id, account_id, device_id, name, creation_date, expiration_date, last_used_date
+	FROM trusted_device
+	WHERE account_id = $1 AND expiration_date > now()
+	ORDER BY creation_date DESC`
+
+	QUERY_UPDATE_TRUSTED_DEVICE_LAST_USED = `
+	UPDATE trusted_device
+	SET last_used_date = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_DELETE_TRUSTED_DEVICE = `
+	DELETE FROM trusted_device
+	WHERE account_id = $1 AND id = $2
+	RETURNING id`
+
+	QUERY_CREATE_ORGANIZATION_TABLE = `
+	CREATE TABLE organization (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		slug VARCHAR(64) UNIQUE NOT NULL,
+		scoped_identities BOOLEAN NOT NULL DEFAULT false,
+		open_registration BOOLEAN NOT NULL DEFAULT false,
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now()
+	)`
+
+	QUERY_CREATE_ORGANIZATION = `
+	INSERT INTO organization (name, slug, scoped_identities, open_registration, creation_date)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	QUERY_SELECT_ORGANIZATION_BY_ID = `
+	SELECT id, name, slug, scoped_identities, open_registration, creation_date
+	FROM organization
+	WHERE id = $1`
+
+	QUERY_SELECT_ORGANIZATION_BY_SLUG = `
+	SELECT id, name, slug, scoped_identities, open_registration, creation_date
+	FROM organization
+	WHERE slug = $1`
+
+	QUERY_SELECT_ORGANIZATIONS = `
+	SELECT id, name, slug, scoped_identities, open_registration, creation_date
+	FROM organization
+	ORDER BY name, id`
+
+	QUERY_DELETE_ORGANIZATION = `
+	DELETE FROM organization
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_CREATE_MEMBERSHIP_TABLE = `
+	CREATE TABLE membership (
+		id SERIAL PRIMARY KEY,
+		organization_id INTEGER NOT NULL REFERENCES organization(id) ON DELETE CASCADE,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		role VARCHAR(32) NOT NULL,
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		UNIQUE (organization_id, account_id)
+	)`
+
+	QUERY_CREATE_MEMBERSHIP = `
+	INSERT INTO membership (organization_id, account_id, role, creation_date)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	QUERY_SELECT_MEMBERSHIP = `
+	SELECT m.id, m.organization_id, m.account_id, m.role, m.creation_date, o.slug, o.name, a.username
+	FROM membership m
+	JOIN organization o ON o.id = m.organization_id
+	JOIN account a ON a.id = m.account_id
+	WHERE m.organization_id = $1 AND m.account_id = $2`
+
+	QUERY_SELECT_MEMBERSHIPS_BY_ACCOUNT = `
+	SELECT m.id, m.organization_id, m.account_id, m.role, m.creation_date, o.slug, o.name, a.username
+	FROM membership m
+	JOIN organization o ON o.id = m.organization_id
+	JOIN account a ON a.id = m.account_id
+	WHERE m.account_id = $1
+	ORDER BY o.name, o.id`
+
+	QUERY_SELECT_MEMBERSHIPS_BY_ORGANIZATION = `
+	SELECT m.id, m.organization_id, m.account_id, m.role, m.creation_date, o.slug, o.name, a.username
+	FROM membership m
+	JOIN organization o ON o.id = m.organization_id
+	JOIN account a ON a.id = m.account_id
+	WHERE m.organization_id = $1
+	ORDER BY a.username, m.id`
+
+	QUERY_UPDATE_MEMBERSHIP_ROLE = `
+	UPDATE membership
+	SET role = $3
+	WHERE organization_id = $1 AND account_id = $2
+	RETURNING id`
+
+	QUERY_DELETE_MEMBERSHIP = `
+	DELETE FROM membership
+	WHERE organization_id = $1 AND account_id = $2
+	RETURNING id`
+
+	QUERY_CREATE_INVITATION_TABLE = `
+	CREATE TABLE invitation (
+		id SERIAL PRIMARY KEY,
+		email VARCHAR(255) NOT NULL,
+		role VARCHAR(32) NOT NULL DEFAULT 'user',
+		organization_id INTEGER REFERENCES organization(id) ON DELETE CASCADE,
+		organization_role VARCHAR(32) NOT NULL DEFAULT '',
+		invited_by INTEGER REFERENCES account(id) ON DELETE SET NULL,
+		account_id INTEGER REFERENCES account(id) ON DELETE SET NULL,
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		expiration_date TIMESTAMP WITH TIME ZONE NOT NULL,
+		revoked_date TIMESTAMP WITH TIME ZONE,
+		accepted_date TIMESTAMP WITH TIME ZONE
+	)`
+
+	INVITATION_COLUMNS = `i.id, i.email, i.role, COALESCE(i.organization_id, 0), COALESCE(o.slug, ''),
+		i.organization_role, COALESCE(i.invited_by, 0), COALESCE(i.account_id, 0), i.creation_date,
+		i.expiration_date, i.revoked_date, i.accepted_date`
+
+	QUERY_CREATE_INVITATION = `
+	INSERT INTO invitation (email, role, organization_id, organization_role, invited_by, creation_date, expiration_date)
+	VALUES ($1, $2, NULLIF($3, 0), $4, NULLIF($5, 0), $6, $7)
+	RETURNING id`
+
+	QUERY_SELECT_INVITATION_BY_ID = `
+	SELECT ` + INVITATION_COLUMNS + `
+	FROM invitation i
+	LEFT JOIN organization o ON o.id = i.organization_id
+	WHERE i.id = $1`
+
+	QUERY_SELECT_PENDING_INVITATIONS = `
+	SELECT ` + INVITATION_COLUMNS + `
+	FROM invitation i
+	LEFT JOIN organization o ON o.id = i.organization_id
+	WHERE i.revoked_date IS NULL AND i.accepted_date IS NULL AND i.expiration_date > now()
+	ORDER BY i.creation_date DESC, i.id DESC`
+
+	QUERY_REVOKE_INVITATION = `
+	UPDATE invitation
+	SET revoked_date = $2
+	WHERE id = $1 AND revoked_date IS NULL AND accepted_date IS NULL
+	RETURNING id`
+
+	QUERY_ACCEPT_INVITATION = `
+	UPDATE invitation
+	SET account_id = $2, accepted_date = $3
+	WHERE id = $1 AND revoked_date IS NULL AND accepted_date IS NULL AND expiration_date > $3
+	RETURNING id`
+
+	LOGIN_EVENT_COLUMNS = `id, COALESCE(account_id, 0), username, success, method, failure_reason, ip_address,
+		user_agent, creation_date`
+
+	LOGIN_EVENT_FILTER = `
+	($1 = 0 OR account_id = $1)
+		AND ($2 = '' OR strpos(lower(username), lower($2)) > 0 OR ip_address = $2)
+		AND ($3 = '' OR success = ($3 = 'success'))
+		AND ($4::TIMESTAMP WITH TIME ZONE IS NULL OR creation_date >= $4)
+		AND ($5::TIMESTAMP WITH TIME ZONE IS NULL OR creation_date < $5)`
+
+	QUERY_CREATE_LOGIN_EVENT_TABLE = `
+	CREATE TABLE login_event (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER REFERENCES account(id) ON DELETE CASCADE,
+		username VARCHAR(255) NOT NULL DEFAULT '',
+		success BOOLEAN NOT NULL,
+		method VARCHAR(32) NOT NULL DEFAULT '',
+		failure_reason VARCHAR(64) NOT NULL DEFAULT '',
+		ip_address VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent VARCHAR(512) NOT NULL DEFAULT '',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX login_event_account_idx ON login_event (account_id, creation_date)`
+
+	QUERY_CREATE_LOGIN_EVENT = `
+	INSERT INTO login_event (account_id, username, success, method, failure_reason, ip_address, user_agent, creation_date)
+	VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id`
+
+	QUERY_UPDATE_ACCOUNT_LAST_LOGIN = `
+	UPDATE Account
+	SET last_login_at = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_SELECT_LOGIN_EVENTS = `
+	SELECT ` + LOGIN_EVENT_COLUMNS + `
+	FROM login_event
+	WHERE ` + LOGIN_EVENT_FILTER + `
+	ORDER BY creation_date DESC, id DESC
+	LIMIT $6 OFFSET $7`
+
+	QUERY_COUNT_LOGIN_EVENTS = `
+	SELECT count(*)
+	FROM login_event
+	WHERE ` + LOGIN_EVENT_FILTER
+
+	LEGAL_DOCUMENT_COLUMNS = `id, type, version, url, published_date`
+
+	QUERY_CREATE_LEGAL_DOCUMENT_TABLE = `
+	CREATE TABLE legal_document (
+		id SERIAL PRIMARY KEY,
+		type VARCHAR(32) NOT NULL,
+		version VARCHAR(64) NOT NULL,
+		url VARCHAR(512) NOT NULL DEFAULT '',
+		published_date TIMESTAMP WITH TIME ZONE NOT NULL,
+		UNIQUE (type, version)
+	)`
+
+	QUERY_CREATE_CONSENT_TABLE = `
+	CREATE TABLE consent (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		legal_document_id INTEGER NOT NULL REFERENCES legal_document(id),
+		ip_address VARCHAR(64) NOT NULL DEFAULT '',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		UNIQUE (account_id, legal_document_id)
+	)`
+
+	QUERY_CREATE_LEGAL_DOCUMENT = `
+	INSERT INTO legal_document (type, version, url, published_date)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	QUERY_SELECT_LEGAL_DOCUMENTS = `
+	SELECT ` + LEGAL_DOCUMENT_COLUMNS + `
+	FROM legal_document
+	ORDER BY type, published_date DESC, id DESC`
+
+	QUERY_SELECT_CURRENT_LEGAL_DOCUMENTS = `
+	SELECT DISTINCT ON (type) ` + LEGAL_DOCUMENT_COLUMNS + `
+	FROM legal_document
+	WHERE published_date <= $1
+	ORDER BY type, published_date DESC, id DESC`
+
+	QUERY_SELECT_PENDING_LEGAL_DOCUMENTS = `
+	SELECT ` + LEGAL_DOCUMENT_COLUMNS + `
+	FROM (` + QUERY_SELECT_CURRENT_LEGAL_DOCUMENTS + `) current_document
+	WHERE NOT EXISTS (
+		SELECT 1 FROM consent
+		WHERE consent.legal_document_id = current_document.id AND consent.account_id = $2
+	)
+	ORDER BY type`
+
+	QUERY_CREATE_CONSENT = `
+	INSERT INTO consent (account_id, legal_document_id, ip_address, creation_date)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (account_id, legal_document_id) DO NOTHING`
+
+	QUERY_SELECT_CONSENTS_BY_ACCOUNT = `
+	SELECT consent.id, consent.account_id, consent.legal_document_id, legal_document.type, legal_document.version,
+		consent.ip_address, consent.creation_date
+	FROM consent
+	JOIN legal_document ON legal_document.id = consent.legal_document_id
+	WHERE consent.account_id = $1
+	ORDER BY consent.creation_date DESC, consent.id DESC`
+
+	LINKED_IDENTITY_COLUMNS = `id, account_id, provider, subject, email, creation_date`
+
+	QUERY_CREATE_LINKED_IDENTITY_TABLE = `
+	CREATE TABLE linked_identities (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		provider VARCHAR(64) NOT NULL,
+		subject VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL DEFAULT '',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		UNIQUE (provider, subject),
+		UNIQUE (account_id, provider)
+	)`
+
+	QUERY_CREATE_LINKED_IDENTITY = `
+	INSERT INTO linked_identities (account_id, provider, subject, email, creation_date)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+
+	QUERY_SELECT_LINKED_IDENTITY = `
+	SELECT ` + LINKED_IDENTITY_COLUMNS + `
+	FROM linked_identities
+	WHERE provider = $1 AND subject = $2`
+
+	QUERY_SELECT_LINKED_IDENTITIES_BY_ACCOUNT = `
+	SELECT ` + LINKED_IDENTITY_COLUMNS + `
+	FROM linked_identities
+	WHERE account_id = $1
+	ORDER BY provider`
+
+	QUERY_DELETE_LINKED_IDENTITY = `
+	DELETE FROM linked_identities
+	WHERE account_id = $1 AND provider = $2
+	RETURNING id`
+
+	API_KEY_COLUMNS = `id, account_id, name, key_hash, hint, scopes, creation_date, expiration_date, last_used_date`
+
+	QUERY_CREATE_API_KEY_TABLE = `
+	CREATE TABLE api_key (
+		id SERIAL PRIMARY KEY,
+		account_id INTEGER NOT NULL REFERENCES account(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		key_hash VARCHAR(64) UNIQUE NOT NULL,
+		hint VARCHAR(32) NOT NULL DEFAULT '',
+		scopes TEXT[] NOT NULL DEFAULT '{}',
+		creation_date TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		expiration_date TIMESTAMP WITH TIME ZONE,
+		last_used_date TIMESTAMP WITH TIME ZONE
+	)`
+
+	QUERY_CREATE_API_KEY = `
+	INSERT INTO api_key (account_id, name, key_hash, hint, scopes, creation_date, expiration_date)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id`
+
+	QUERY_SELECT_API_KEY_BY_HASH = `
+	SELECT ` + API_KEY_COLUMNS + `
+	FROM api_key
+	WHERE key_hash = $1 AND (expiration_date IS NULL OR expiration_date > now())`
+
+	QUERY_SELECT_API_KEYS_BY_ACCOUNT = `
+	SELECT ` + API_KEY_COLUMNS + `
+	FROM api_key
+	WHERE account_id = $1
+	ORDER BY creation_date DESC, id DESC`
+
+	QUERY_UPDATE_API_KEY_LAST_USED = `
+	UPDATE api_key
+	SET last_used_date = $2
+	WHERE id = $1
+	RETURNING id`
+
+	QUERY_DELETE_API_KEY = `
+	DELETE FROM api_key
+	WHERE account_id = $1 AND id = $2
+	RETURNING id`
+
+	QUERY_CREATE_RATE_LIMIT_BUCKET_TABLE = `
+	CREATE TABLE rate_limit_bucket (
+		key VARCHAR(512) PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		expiration_date TIMESTAMP WITH TIME ZONE NOT NULL
+	)`
+
+	QUERY_CREATE_RATE_LIMIT_BUCKET = `
+	INSERT INTO rate_limit_bucket (key, tokens, updated_at, expiration_date)
+	VALUES ($1, 0, $2, to_timestamp(0))
+	ON CONFLICT (key) DO NOTHING`
+
+	QUERY_SELECT_RATE_LIMIT_BUCKET_FOR_UPDATE = `
+	SELECT tokens, updated_at, expiration_date
+	FROM rate_limit_bucket
+	WHERE key = $1
+	FOR UPDATE`
+
+	QUERY_UPDATE_RATE_LIMIT_BUCKET = `
+	UPDATE rate_limit_bucket
+	SET tokens = $2, updated_at = $3, expiration_date = $4
+	WHERE key = $1`
+
+	QUERY_DELETE_EXPIRED_RATE_LIMIT_BUCKETS = `
+	DELETE FROM rate_limit_bucket
+	WHERE expiration_date <= $1`
 )
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"flhansen/fitter-login-service/src/ratelimit"
+	"time"
+)
+
+type RateLimitRepository interface {
+	Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error)
+	Prune(now time.Time) error
+}
+
+type rateLimitRepository struct {
+	db *sql.DB
+}
+
+func NewRateLimitRepository(config DatabaseConfig) RateLimitRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &rateLimitRepository{
+		db: db,
+	}
+}
+
+func (repo *rateLimitRepository) Take(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Decision, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return ratelimit.Decision{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(QUERY_CREATE_RATE_LIMIT_BUCKET, key, now); err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	var bucket ratelimit.Bucket
+	var expirationDate time.Time
+	if err := tx.QueryRow(QUERY_SELECT_RATE_LIMIT_BUCKET_FOR_UPDATE, key).Scan(&bucket.Tokens, &bucket.UpdatedAt, &expirationDate); err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	if !now.Before(expirationDate) {
+		bucket = ratelimit.Bucket{}
+	}
+
+	bucket, decision := bucket.Take(limit, now)
+	if _, err := tx.Exec(QUERY_UPDATE_RATE_LIMIT_BUCKET, key, bucket.Tokens, bucket.UpdatedAt, bucket.FullAt(limit)); err != nil {
+		return ratelimit.Decision{}, err
+	}
+
+	return decision, tx.Commit()
+}
+
+func (repo *rateLimitRepository) Prune(now time.Time) error {
+	_, err := repo.db.Exec(QUERY_DELETE_EXPIRED_RATE_LIMIT_BUCKETS, now)
+	return err
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"flhansen/fitter-login-service/src/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitRepositoryTestSuite struct {
+	suite.Suite
+	database *gnomock.Container
+	repo     RateLimitRepository
+	db       *sql.DB
+}
+
+func TestRateLimitRepository(t *testing.T) {
+	suite.Run(t, new(RateLimitRepositoryTestSuite))
+}
+
+func (suite *RateLimitRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_RATE_LIMIT_BUCKET_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewRateLimitRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *RateLimitRepositoryTestSuite) TearDownTest() {
+	if _, err := suite.db.Exec("DELETE FROM rate_limit_bucket"); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *RateLimitRepositoryTestSuite) TestTakeShouldShareBucketBetweenRepositories() {
+	other := NewRateLimitRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+	limit := ratelimit.Limit{Requests: 2, Interval: time.Minute}
+	now := time.Now()
+
+	decision, err := suite.repo.Take("login|ip|1.2.3.4", limit, now)
+	suite.NoError(err)
+	suite.True(decision.Allowed)
+
+	decision, err = other.Take("login|ip|1.2.3.4", limit, now)
+	suite.NoError(err)
+	suite.True(decision.Allowed)
+
+	decision, err = suite.repo.Take("login|ip|1.2.3.4", limit, now)
+	suite.NoError(err)
+	suite.False(decision.Allowed)
+	suite.Greater(decision.RetryAfter, time.Duration(0))
+
+	decision, err = suite.repo.Take("login|ip|1.2.3.4", limit, now.Add(time.Minute))
+	suite.NoError(err)
+	suite.True(decision.Allowed)
+}
+
+func (suite *RateLimitRepositoryTestSuite) TestPruneShouldDeleteFullBuckets() {
+	limit := ratelimit.Limit{Requests: 1, Interval: time.Minute}
+	now := time.Now()
+	_, err := suite.repo.Take("first", limit, now)
+	suite.NoError(err)
+	_, err = suite.repo.Take("second", limit, now.Add(30*time.Second))
+	suite.NoError(err)
+
+	suite.NoError(suite.repo.Prune(now.Add(time.Minute)))
+
+	var count int
+	suite.NoError(suite.db.QueryRow("SELECT count(*) FROM rate_limit_bucket").Scan(&count))
+	suite.Equal(1, count)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"time"
+)
+
+type TrustedDevice struct {
+	Id             int
+	AccountId      int
+	DeviceId       string
+	Name           string
+	CreationDate   time.Time
+	ExpirationDate time.Time
+	LastUsedDate   *time.Time
+}
+
+type TrustedDeviceRepository interface {
+	CreateTrustedDevice(device TrustedDevice) (int, error)
+	GetTrustedDevice(accountId int, deviceId string) (TrustedDevice, error)
+	GetTrustedDevices(accountId int) ([]TrustedDevice, error)
+	UpdateTrustedDeviceLastUsed(id int, lastUsed time.Time) error
+	DeleteTrustedDevice(accountId int, id int) error
+}
+
+type trustedDeviceRepository struct {
+	db *sql.DB
+}
+
+func NewTrustedDeviceRepository(config DatabaseConfig) TrustedDeviceRepository {
+	dsn := database.DataSourceName(config.Host, config.Port, config.Username, config.Password, config.DatabaseName)
+	db, _ := sql.Open("postgres", dsn)
+
+	return &trustedDeviceRepository{
+		db: db,
+	}
+}
+
+func (repo *trustedDeviceRepository) CreateTrustedDevice(device TrustedDevice) (int, error) {
+	row := repo.db.QueryRow(QUERY_CREATE_TRUSTED_DEVICE, device.AccountId, device.DeviceId, device.Name, device.CreationDate, device.ExpirationDate)
+
+	id := -1
+	err := row.Scan(&id)
+	return id, err
+}
+
+func (repo *trustedDeviceRepository) GetTrustedDevice(accountId int, deviceId string) (TrustedDevice, error) {
+	row := repo.db.QueryRow(QUERY_SELECT_TRUSTED_DEVICE, accountId, deviceId)
+	return scanTrustedDevice(row.Scan)
+}
+
+func (repo *trustedDeviceRepository) GetTrustedDevices(accountId int) ([]TrustedDevice, error) {
+	rows, err := repo.db.Query(QUERY_SELECT_TRUSTED_DEVICES, accountId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []TrustedDevice{}
+	for rows.Next() {
+		device, err := scanTrustedDevice(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (repo *trustedDeviceRepository) UpdateTrustedDeviceLastUsed(id int, lastUsed time.Time) error {
+	row := repo.db.QueryRow(QUERY_UPDATE_TRUSTED_DEVICE_LAST_USED, id, lastUsed)
+
+	updatedId := -1
+	return row.Scan(&updatedId)
+}
+
+func (repo *trustedDeviceRepository) DeleteTrustedDevice(accountId int, id int) error {
+	row := repo.db.QueryRow(QUERY_DELETE_TRUSTED_DEVICE, accountId, id)
+
+	deletedId := -1
+	return row.Scan(&deletedId)
+}
+
+func scanTrustedDevice(scan func(dest ...any) error) (TrustedDevice, error) {
+	var device TrustedDevice
+	err := scan(
+		&device.Id,
+		&device.AccountId,
+		&device.DeviceId,
+		&device.Name,
+		&device.CreationDate,
+		&device.ExpirationDate,
+		&device.LastUsedDate)
+	return device, err
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"flhansen/fitter-login-service/src/database"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	"github.com/orlangure/gnomock/preset/postgres"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrustedDeviceRepositoryTestSuite struct {
+	suite.Suite
+	database  *gnomock.Container
+	repo      TrustedDeviceRepository
+	db        *sql.DB
+	accountId int
+}
+
+func TestTrustedDeviceRepository(t *testing.T) {
+	suite.Run(t, new(TrustedDeviceRepositoryTestSuite))
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) SetupSuite() {
+	preset := postgres.Preset(
+		postgres.WithUser("test", "test"),
+		postgres.WithDatabase("test"),
+		postgres.WithQueries(QUERY_CREATE_ACCOUNT_TABLE, QUERY_CREATE_TRUSTED_DEVICE_TABLE))
+	suite.database, _ = gnomock.Start(preset)
+	suite.T().Cleanup(func() { gnomock.Stop(suite.database) })
+
+	suite.repo = NewTrustedDeviceRepository(DatabaseConfig{
+		Host:         suite.database.Host,
+		Port:         suite.database.DefaultPort(),
+		Username:     "test",
+		Password:     "test",
+		DatabaseName: "test",
+	})
+
+	dsn := database.DataSourceName(
+		suite.database.Host,
+		suite.database.DefaultPort(),
+		"test", "test", "test")
+	suite.db, _ = sql.Open("postgres", dsn)
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) SetupTest() {
+	row := suite.db.QueryRow("INSERT INTO account (username, password, email, creation_date) VALUES ($1, $2, $3, $4) RETURNING id",
+		"test", "test", "test@test.com", time.Now())
+	if err := row.Scan(&suite.accountId); err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TearDownTest() {
+	_, err := suite.db.Exec("DELETE FROM account")
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) createDevice(deviceId string, expirationDate time.Time) int {
+	id, err := suite.repo.CreateTrustedDevice(TrustedDevice{
+		AccountId:      suite.accountId,
+		DeviceId:       deviceId,
+		Name:           "laptop",
+		CreationDate:   time.Now(),
+		ExpirationDate: expirationDate,
+	})
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	return id
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestGetTrustedDeviceShouldSucceed() {
+	id := suite.createDevice("device", time.Now().Add(time.Hour))
+
+	device, err := suite.repo.GetTrustedDevice(suite.accountId, "device")
+
+	suite.NoError(err)
+	suite.Equal(id, device.Id)
+	suite.Equal("laptop", device.Name)
+	suite.Nil(device.LastUsedDate)
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestGetTrustedDeviceShouldIgnoreExpiredDevices() {
+	suite.createDevice("device", time.Now().Add(-time.Hour))
+
+	_, err := suite.repo.GetTrustedDevice(suite.accountId, "device")
+
+	suite.Error(err)
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestGetTrustedDevicesShouldSucceed() {
+	suite.createDevice("first", time.Now().Add(time.Hour))
+	suite.createDevice("second", time.Now().Add(time.Hour))
+	suite.createDevice("expired", time.Now().Add(-time.Hour))
+
+	devices, err := suite.repo.GetTrustedDevices(suite.accountId)
+
+	suite.NoError(err)
+	suite.Len(devices, 2)
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestUpdateTrustedDeviceLastUsedShouldSucceed() {
+	id := suite.createDevice("device", time.Now().Add(time.Hour))
+	lastUsed := time.Now()
+
+	err := suite.repo.UpdateTrustedDeviceLastUsed(id, lastUsed)
+	suite.NoError(err)
+
+	device, err := suite.repo.GetTrustedDevice(suite.accountId, "device")
+	suite.NoError(err)
+	suite.Equal(lastUsed.UnixMilli(), device.LastUsedDate.UnixMilli())
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestDeleteTrustedDeviceShouldSucceed() {
+	id := suite.createDevice("device", time.Now().Add(time.Hour))
+
+	err := suite.repo.DeleteTrustedDevice(suite.accountId, id)
+	suite.NoError(err)
+
+	_, err = suite.repo.GetTrustedDevice(suite.accountId, "device")
+	suite.Error(err)
+}
+
+func (suite *TrustedDeviceRepositoryTestSuite) TestDeleteTrustedDeviceShouldReturnErrorIfDeviceOfOtherAccount() {
+	id := suite.createDevice("device", time.Now().Add(time.Hour))
+
+	err := suite.repo.DeleteTrustedDevice(suite.accountId+1, id)
+
+	suite.Error(err)
+}
@@ -0,0 +1,476 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+const (
+	protocolNamespace   = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace  = "urn:oasis:names:tc:SAML:2.0:assertion"
+	metadataNamespace   = "urn:oasis:names:tc:SAML:2.0:metadata"
+	postBinding         = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	statusSuccess       = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerConfirmation  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	defaultNameIdFormat = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	requestIdLength     = 20
+)
+
+var (
+	ErrInvalidCertificate = errors.New("invalid identity provider certificate")
+	ErrInvalidResponse    = errors.New("invalid saml response")
+	ErrMissingSignature   = errors.New("saml assertion is not signed")
+	ErrInvalidSignature   = errors.New("invalid saml signature")
+	ErrStatusNotSuccess   = errors.New("saml response status is not success")
+	ErrInvalidAssertion   = errors.New("invalid saml assertion")
+	ErrAssertionExpired   = errors.New("saml assertion is expired or not yet valid")
+	ErrMissingSubject     = errors.New("saml assertion has no subject")
+)
+
+type Config struct {
+	EntityId          string
+	AcsUrl            string
+	IdpEntityId       string
+	IdpSsoUrl         string
+	IdpCertificate    string
+	NameIdFormat      string
+	UsernameAttribute string
+	EmailAttribute    string
+	GroupAttribute    string
+	ClockSkew         time.Duration
+}
+
+type Identity struct {
+	AssertionId string
+	Subject     string
+	Username    string
+	Email       string
+	Groups      []string
+	ExpiresAt   time.Time
+}
+
+type ServiceProvider interface {
+	Metadata() ([]byte, error)
+	AuthnRequestUrl(requestId string, relayState string) (string, error)
+	ParseResponse(encodedResponse string, requestId string) (Identity, error)
+}
+
+type response struct {
+	XMLName      xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol Response"`
+	Id           string   `xml:"ID,attr"`
+	InResponseTo string   `xml:"InResponseTo,attr"`
+	Destination  string   `xml:"Destination,attr"`
+	Issuer       string   `xml:"Issuer"`
+	Status       struct {
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"StatusCode"`
+	} `xml:"Status"`
+}
+
+type assertion struct {
+	XMLName    xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Assertion"`
+	Id         string   `xml:"ID,attr"`
+	Issuer     string   `xml:"Issuer"`
+	Subject    subject  `xml:"Subject"`
+	Conditions *struct {
+		NotBefore    time.Time `xml:"NotBefore,attr"`
+		NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+		Audiences    []string  `xml:"AudienceRestriction>Audience"`
+	} `xml:"Conditions"`
+	Attributes []attribute `xml:"AttributeStatement>Attribute"`
+}
+
+type subject struct {
+	NameId        string `xml:"NameID"`
+	Confirmations []struct {
+		Method string `xml:"Method,attr"`
+		Data   struct {
+			Recipient    string    `xml:"Recipient,attr"`
+			InResponseTo string    `xml:"InResponseTo,attr"`
+			NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+		} `xml:"SubjectConfirmationData"`
+	} `xml:"SubjectConfirmation"`
+}
+
+type attribute struct {
+	Name         string   `xml:"Name,attr"`
+	FriendlyName string   `xml:"FriendlyName,attr"`
+	Values       []string `xml:"AttributeValue"`
+}
+
+type serviceProvider struct {
+	config      Config
+	certificate *x509.Certificate
+	now         func() time.Time
+}
+
+func NewServiceProvider(config Config) (ServiceProvider, error) {
+	if config.NameIdFormat == "" {
+		config.NameIdFormat = defaultNameIdFormat
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = 2 * time.Minute
+	}
+
+	certificate, err := parseCertificate(config.IdpCertificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &serviceProvider{
+		config:      config,
+		certificate: certificate,
+		now:         time.Now,
+	}, nil
+}
+
+func NewRequestId() (string, error) {
+	bytes := make([]byte, requestIdLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return "_" + hex.EncodeToString(bytes), nil
+}
+
+func parseCertificate(encoded string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+		if err != nil {
+			return nil, ErrInvalidCertificate
+		}
+
+		block = &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	return certificate, nil
+}
+
+func (provider *serviceProvider) Metadata() ([]byte, error) {
+	doc := etree.NewDocument()
+	doc.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+
+	descriptor := doc.CreateElement("md:EntityDescriptor")
+	descriptor.CreateAttr("xmlns:md", metadataNamespace)
+	descriptor.CreateAttr("entityID", provider.config.EntityId)
+
+	spDescriptor := descriptor.CreateElement("md:SPSSODescriptor")
+	spDescriptor.CreateAttr("AuthnRequestsSigned", "false")
+	spDescriptor.CreateAttr("WantAssertionsSigned", "true")
+	spDescriptor.CreateAttr("protocolSupportEnumeration", protocolNamespace)
+	spDescriptor.CreateElement("md:NameIDFormat").SetText(provider.config.NameIdFormat)
+
+	acs := spDescriptor.CreateElement("md:AssertionConsumerService")
+	acs.CreateAttr("Binding", postBinding)
+	acs.CreateAttr("Location", provider.config.AcsUrl)
+	acs.CreateAttr("index", "0")
+	acs.CreateAttr("isDefault", "true")
+
+	doc.Indent(2)
+	return doc.WriteToBytes()
+}
+
+func (provider *serviceProvider) AuthnRequestUrl(requestId string, relayState string) (string, error) {
+	doc := etree.NewDocument()
+	request := doc.CreateElement("samlp:AuthnRequest")
+	request.CreateAttr("xmlns:samlp", protocolNamespace)
+	request.CreateAttr("xmlns:saml", assertionNamespace)
+	request.CreateAttr("ID", requestId)
+	request.CreateAttr("Version", "2.0")
+	request.CreateAttr("IssueInstant", provider.now().UTC().Format(time.RFC3339))
+	request.CreateAttr("Destination", provider.config.IdpSsoUrl)
+	request.CreateAttr("AssertionConsumerServiceURL", provider.config.AcsUrl)
+	request.CreateAttr("ProtocolBinding", postBinding)
+	request.CreateElement("saml:Issuer").SetText(provider.config.EntityId)
+
+	policy := request.CreateElement("samlp:NameIDPolicy")
+	policy.CreateAttr("Format", provider.config.NameIdFormat)
+	policy.CreateAttr("AllowCreate", "true")
+
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		return "", err
+	}
+
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	ssoUrl, err := url.Parse(provider.config.IdpSsoUrl)
+	if err != nil {
+		return "", err
+	}
+
+	query := ssoUrl.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(compressed.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	ssoUrl.RawQuery = query.Encode()
+
+	return ssoUrl.String(), nil
+}
+
+func (provider *serviceProvider) ParseResponse(encodedResponse string, requestId string) (Identity, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encodedResponse), ""))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil || doc.Root() == nil {
+		return Identity{}, ErrInvalidResponse
+	}
+
+	root := doc.Root()
+	if root.Tag != "Response" || root.NamespaceURI() != protocolNamespace {
+		return Identity{}, ErrInvalidResponse
+	}
+
+	validation := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{provider.certificate},
+	})
+
+	responseSigned := hasSignature(root)
+	if responseSigned {
+		if root, err = validation.Validate(root); err != nil {
+			return Identity{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	}
+
+	var parsedResponse response
+	if err := unmarshalElement(root, &parsedResponse); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	if err := provider.checkResponse(parsedResponse, requestId); err != nil {
+		return Identity{}, err
+	}
+
+	assertionElement, err := singleAssertion(root)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if hasSignature(assertionElement) {
+		if assertionElement, err = validation.Validate(assertionElement); err != nil {
+			return Identity{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	} else if !responseSigned {
+		return Identity{}, ErrMissingSignature
+	}
+
+	var parsedAssertion assertion
+	if err := unmarshalElement(assertionElement, &parsedAssertion); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	return provider.checkAssertion(parsedAssertion, requestId)
+}
+
+func hasSignature(el *etree.Element) bool {
+	for _, child := range el.ChildElements() {
+		if child.Tag == dsig.SignatureTag && child.NamespaceURI() == dsig.Namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func singleAssertion(root *etree.Element) (*etree.Element, error) {
+	var found *etree.Element
+	for _, child := range root.ChildElements() {
+		if child.NamespaceURI() != assertionNamespace {
+			continue
+		}
+
+		if child.Tag == "EncryptedAssertion" {
+			return nil, fmt.Errorf("%w: encrypted assertions are not supported", ErrInvalidAssertion)
+		}
+
+		if child.Tag == "Assertion" {
+			if found != nil {
+				return nil, fmt.Errorf("%w: response contains more than one assertion", ErrInvalidAssertion)
+			}
+
+			found = child
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: response contains no assertion", ErrInvalidAssertion)
+	}
+
+	ctx, err := etreeutils.NSBuildParentContext(found)
+	if err != nil {
+		return nil, err
+	}
+
+	return etreeutils.NSDetatch(ctx, found)
+}
+
+func unmarshalElement(el *etree.Element, target interface{}) error {
+	doc := etree.NewDocument()
+	doc.SetRoot(el.Copy())
+
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(raw, target)
+}
+
+func (provider *serviceProvider) checkResponse(parsed response, requestId string) error {
+	if parsed.Status.StatusCode.Value != statusSuccess {
+		return fmt.Errorf("%w: %s", ErrStatusNotSuccess, parsed.Status.StatusCode.Value)
+	}
+
+	if parsed.Destination != "" && parsed.Destination != provider.config.AcsUrl {
+		return fmt.Errorf("%w: destination %q does not match %q", ErrInvalidResponse, parsed.Destination, provider.config.AcsUrl)
+	}
+
+	if parsed.InResponseTo != requestId {
+		return fmt.Errorf("%w: response is not for request %q", ErrInvalidResponse, requestId)
+	}
+
+	if parsed.Issuer != "" && provider.config.IdpEntityId != "" && parsed.Issuer != provider.config.IdpEntityId {
+		return fmt.Errorf("%w: issuer %q does not match %q", ErrInvalidResponse, parsed.Issuer, provider.config.IdpEntityId)
+	}
+
+	return nil
+}
+
+func (provider *serviceProvider) checkAssertion(parsed assertion, requestId string) (Identity, error) {
+	now := provider.now()
+	skew := provider.config.ClockSkew
+
+	if provider.config.IdpEntityId != "" && parsed.Issuer != provider.config.IdpEntityId {
+		return Identity{}, fmt.Errorf("%w: issuer %q does not match %q", ErrInvalidAssertion, parsed.Issuer, provider.config.IdpEntityId)
+	}
+
+	if parsed.Subject.NameId == "" {
+		return Identity{}, ErrMissingSubject
+	}
+
+	if parsed.Conditions == nil {
+		return Identity{}, fmt.Errorf("%w: assertion has no conditions", ErrInvalidAssertion)
+	}
+
+	if !parsed.Conditions.NotBefore.IsZero() && now.Add(skew).Before(parsed.Conditions.NotBefore) {
+		return Identity{}, ErrAssertionExpired
+	}
+
+	if !parsed.Conditions.NotOnOrAfter.IsZero() && !now.Add(-skew).Before(parsed.Conditions.NotOnOrAfter) {
+		return Identity{}, ErrAssertionExpired
+	}
+
+	if !contains(parsed.Conditions.Audiences, provider.config.EntityId) {
+		return Identity{}, fmt.Errorf("%w: audience does not include %q", ErrInvalidAssertion, provider.config.EntityId)
+	}
+
+	expiresAt := parsed.Conditions.NotOnOrAfter
+	confirmed := false
+	for _, confirmation := range parsed.Subject.Confirmations {
+		data := confirmation.Data
+		if confirmation.Method != bearerConfirmation || data.Recipient != provider.config.AcsUrl || data.InResponseTo != requestId {
+			continue
+		}
+
+		if data.NotOnOrAfter.IsZero() || !now.Add(-skew).Before(data.NotOnOrAfter) {
+			continue
+		}
+
+		if expiresAt.IsZero() || data.NotOnOrAfter.Before(expiresAt) {
+			expiresAt = data.NotOnOrAfter
+		}
+
+		confirmed = true
+		break
+	}
+
+	if !confirmed {
+		return Identity{}, fmt.Errorf("%w: no valid bearer subject confirmation", ErrInvalidAssertion)
+	}
+
+	identity := Identity{
+		AssertionId: parsed.Id,
+		Subject:     parsed.Subject.NameId,
+		ExpiresAt:   expiresAt.Add(skew),
+	}
+
+	for _, attribute := range parsed.Attributes {
+		switch {
+		case attribute.matches(provider.config.UsernameAttribute):
+			identity.Username = attribute.first()
+		case attribute.matches(provider.config.EmailAttribute):
+			identity.Email = attribute.first()
+		case attribute.matches(provider.config.GroupAttribute):
+			identity.Groups = append(identity.Groups, attribute.Values...)
+		}
+	}
+
+	return identity, nil
+}
+
+func (attribute attribute) matches(name string) bool {
+	return attribute.Name == name || (attribute.FriendlyName != "" && attribute.FriendlyName == name)
+}
+
+func (attribute attribute) first() string {
+	if len(attribute.Values) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(attribute.Values[0])
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"errors"
+	"flhansen/fitter-login-service/src/testhelper"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEntityId = "https://fitter.test/saml"
+	testAcsUrl   = "https://fitter.test/api/auth/saml/acs"
+	testSsoUrl   = "https://idp.test/sso?tenant=fitter"
+	testIdpId    = "https://idp.test"
+)
+
+func newTestServiceProvider(t *testing.T) (*testhelper.SamlIdentityProvider, *serviceProvider) {
+	idp, err := testhelper.NewSamlIdentityProvider(testIdpId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewServiceProvider(Config{
+		EntityId:       testEntityId,
+		AcsUrl:         testAcsUrl,
+		IdpEntityId:    testIdpId,
+		IdpSsoUrl:      testSsoUrl,
+		IdpCertificate: idp.CertificatePem(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return idp, provider.(*serviceProvider)
+}
+
+func testAssertion() testhelper.SamlAssertion {
+	return testhelper.SamlAssertion{
+		RequestId: "_request-1",
+		Audience:  testEntityId,
+		Recipient: testAcsUrl,
+		Subject:   "alice-id",
+		Attributes: map[string][]string{
+			"uid":      {"alice"},
+			"mail":     {"alice@test.com"},
+			"memberOf": {"cn=staff,ou=groups", "cn=fitter-admins,ou=groups"},
+		},
+	}
+}
+
+func signedResponse(t *testing.T, idp *testhelper.SamlIdentityProvider, assertion testhelper.SamlAssertion) string {
+	response, err := idp.Response(assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func tamper(t *testing.T, encoded string, old string, new string) string {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(strings.Replace(string(raw), old, new, 1)))
+}
+
+func TestNewServiceProviderShouldRejectInvalidCertificate(t *testing.T) {
+	_, err := NewServiceProvider(Config{EntityId: testEntityId, IdpCertificate: "not a certificate"})
+
+	assert.True(t, errors.Is(err, ErrInvalidCertificate))
+}
+
+func TestMetadataShouldDescribeServiceProvider(t *testing.T) {
+	_, provider := newTestServiceProvider(t)
+
+	metadata, err := provider.Metadata()
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(metadata), `entityID="`+testEntityId+`"`)
+	assert.Contains(t, string(metadata), `WantAssertionsSigned="true"`)
+	assert.Contains(t, string(metadata), `Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="`+testAcsUrl+`"`)
+}
+
+func TestAuthnRequestUrlShouldUseRedirectBinding(t *testing.T) {
+	_, provider := newTestServiceProvider(t)
+
+	requestUrl, err := provider.AuthnRequestUrl("_request-1", "relay-state")
+
+	assert.NoError(t, err)
+	parsed, err := url.Parse(requestUrl)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://idp.test/sso", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "fitter", parsed.Query().Get("tenant"))
+	assert.Equal(t, "relay-state", parsed.Query().Get("RelayState"))
+
+	compressed, err := base64.StdEncoding.DecodeString(parsed.Query().Get("SAMLRequest"))
+	assert.NoError(t, err)
+	request, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	assert.NoError(t, err)
+	assert.Contains(t, string(request), `ID="_request-1"`)
+	assert.Contains(t, string(request), `AssertionConsumerServiceURL="`+testAcsUrl+`"`)
+	assert.Contains(t, string(request), `<saml:Issuer>`+testEntityId+`</saml:Issuer>`)
+}
+
+func TestNewRequestIdShouldBeValidXmlId(t *testing.T) {
+	id, err := NewRequestId()
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "_"))
+	assert.Len(t, id, 1+2*requestIdLength)
+}
+
+func TestParseResponseShouldMapSignedAssertion(t *testing.T) {
+	idp, provider := newTestServiceProvider(t)
+
+	identity, err := provider.ParseResponse(signedResponse(t, idp, testAssertion()), "_request-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "_assertion-1", identity.AssertionId)
+	assert.Equal(t, "alice-id", identity.Subject)
+	assert.Equal(t, "alice", identity.Username)
+	assert.Equal(t, "alice@test.com", identity.Email)
+	assert.Equal(t, []string{"cn=staff,ou=groups", "cn=fitter-admins,ou=groups"}, identity.Groups)
+	assert.True(t, identity.ExpiresAt.After(time.Now()))
+}
+
+func TestParseResponseShouldAcceptSignedResponse(t *testing.T) {
+	idp, provider := newTestServiceProvider(t)
+	assertion := testAssertion()
+	assertion.SignResponse = true
+
+	identity, err := provider.ParseResponse(signedResponse(t, idp, assertion), "_request-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "alice-id", identity.Subject)
+}
+
+func TestParseResponseShouldRejectUnsignedAssertion(t *testing.T) {
+	idp, provider := newTestServiceProvider(t)
+	assertion := testAssertion()
+	assertion.Unsigned = true
+
+	_, err := provider.ParseResponse(signedResponse(t, idp, assertion), "_request-1")
+
+	assert.Equal(t, ErrMissingSignature, err)
+}
+
+func TestParseResponseShouldRejectTamperedAssertion(t *testing.T) {
+	idp, provider := newTestServiceProvider(t)
+	response := tamper(t, signedResponse(t, idp, testAssertion()), "alice@test.com", "mallory@test.com")
+
+	_, err := provider.ParseResponse(response, "_request-1")
+
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestParseResponseShouldRejectForeignSigner(t *testing.T) {
+	_, provider := newTestServiceProvider(t)
+	foreign, err := testhelper.NewSamlIdentityProvider(testIdpId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.ParseResponse(signedResponse(t, foreign, testAssertion()), "_request-1")
+
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestParseResponseShouldValidateAssertion(t *testing.T) {
+	cases := map[string]struct {
+		modify    func(assertion *testhelper.SamlAssertion)
+		requestId string
+		err       error
+	}{
+		"request mismatch": {modify: func(assertion *testhelper.SamlAssertion) {}, requestId: "_request-2", err: ErrInvalidResponse},
+		"wrong audience":   {modify: func(assertion *testhelper.SamlAssertion) { assertion.Audience = "https://other.test" }, requestId: "_request-1", err: ErrInvalidAssertion},
+		"wrong recipient": {modify: func(assertion *testhelper.SamlAssertion) {
+			assertion.Recipient = "https://other.test/acs"
+		}, requestId: "_request-1", err: ErrInvalidResponse},
+		"expired": {modify: func(assertion *testhelper.SamlAssertion) {
+			assertion.IssueInstant = time.Now().Add(-time.Hour)
+		}, requestId: "_request-1", err: ErrAssertionExpired},
+		"not successful": {modify: func(assertion *testhelper.SamlAssertion) {
+			assertion.Status = "urn:oasis:names:tc:SAML:2.0:status:Requester"
+		}, requestId: "_request-1", err: ErrStatusNotSuccess},
+		"no assertion": {modify: func(assertion *testhelper.SamlAssertion) { assertion.SkipAssertion = true }, requestId: "_request-1", err: ErrInvalidAssertion},
+		"no subject":   {modify: func(assertion *testhelper.SamlAssertion) { assertion.Subject = "" }, requestId: "_request-1", err: ErrMissingSubject},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			idp, provider := newTestServiceProvider(t)
+			assertion := testAssertion()
+			testCase.modify(&assertion)
+
+			_, err := provider.ParseResponse(signedResponse(t, idp, assertion), testCase.requestId)
+
+			assert.True(t, errors.Is(err, testCase.err), "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestParseResponseShouldRejectInvalidEncoding(t *testing.T) {
+	_, provider := newTestServiceProvider(t)
+
+	_, err := provider.ParseResponse("not base64!", "_request-1")
+
+	assert.True(t, errors.Is(err, ErrInvalidResponse))
+}
@@ -1,12 +1,52 @@
 package security
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	TokenPurposeMfa           = "mfa"
+	TokenPurposeTrustedDevice = "trusted_device"
+	TokenPurposeEmailVerify   = "email_verification"
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeInvitation    = "invitation"
+	TokenPurposeFederation    = "federation"
+
+	AuthMethodPassword    = "pwd"
+	AuthMethodOtp         = "otp"
+	AuthMethodHardwareKey = "hwk"
+	AuthMethodFederated   = "fed"
+	AuthMethodApiKey      = "key"
+
+	AuthContextSingleFactor = "aal1"
+	AuthContextMultiFactor  = "aal2"
+
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+
+	ApiKeyPrefix = "fitter_"
+	apiKeyLength = 32
+
+	DefaultTokenLifetime = 5 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
 type HashEngine interface {
 	HashPassword(password []byte) ([]byte, error)
 }
@@ -15,12 +55,25 @@ type BcryptEngine struct {
 }
 
 type JwtConfig struct {
-	SignKey string
+	SignKey        string
+	StepUpLifetime time.Duration
 }
 
 type JwtClaims struct {
-	UserId   int    `json:"userId"`
-	Username string `json:"username"`
+	UserId        int                    `json:"userId"`
+	Username      string                 `json:"username"`
+	Purpose       string                 `json:"purpose,omitempty"`
+	Email         string                 `json:"email,omitempty"`
+	AuthMethods   []string               `json:"amr,omitempty"`
+	AuthContext   string                 `json:"acr,omitempty"`
+	AuthTime      int64                  `json:"auth_time,omitempty"`
+	Authenticator string                 `json:"authenticator,omitempty"`
+	Role          string                 `json:"role,omitempty"`
+	OrgId         int                    `json:"org_id,omitempty"`
+	OrgRole       string                 `json:"org_role,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Scopes        []string               `json:"scopes,omitempty"`
+	ApiKeyId      int                    `json:"api_key_id,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -33,15 +86,113 @@ func GenerateToken(id int, username string, signingMethod jwt.SigningMethod, key
 		UserId:   id,
 		Username: username,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(5 * time.Hour).Unix(),
+			ExpiresAt: time.Now().Add(DefaultTokenLifetime).Unix(),
 		},
 	}
 
+	return GenerateTokenWithClaims(claims, signingMethod, key)
+}
+
+func GeneratePurposeToken(id int, username string, purpose string, lifetime time.Duration, signingMethod jwt.SigningMethod, key interface{}) (string, error) {
+	claims := JwtClaims{
+		UserId:   id,
+		Username: username,
+		Purpose:  purpose,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(lifetime).Unix(),
+		},
+	}
+
+	return GenerateTokenWithClaims(claims, signingMethod, key)
+}
+
+func GenerateTokenWithClaims(claims JwtClaims, signingMethod jwt.SigningMethod, key interface{}) (string, error) {
 	token := jwt.NewWithClaims(signingMethod, claims)
 	signedToken, err := token.SignedString(key)
 	return signedToken, err
 }
 
+func ParseToken(tokenString string, signingMethod jwt.SigningMethod, key interface{}) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != signingMethod.Alg() {
+			return nil, ErrInvalidToken
+		}
+
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func AuthContextForMethods(methods []string) string {
+	for _, method := range methods {
+		if method == AuthMethodOtp || method == AuthMethodHardwareKey {
+			return AuthContextMultiFactor
+		}
+	}
+
+	return AuthContextSingleFactor
+}
+
+func GenerateNumericCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = byte('0' + n.Int64())
+	}
+
+	return string(code), nil
+}
+
+func GenerateRandomString(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func GenerateApiKey() (string, error) {
+	secret, err := GenerateRandomString(apiKeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return ApiKeyPrefix + secret, nil
+}
+
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (claims *JwtClaims) HasScope(scope string) bool {
+	if claims.ApiKeyId == 0 {
+		return true
+	}
+
+	for _, granted := range claims.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (b *BcryptEngine) HashPassword(password []byte) ([]byte, error) {
 	return bcrypt.GenerateFromPassword(password, 8)
 }
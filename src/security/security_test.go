@@ -1,7 +1,9 @@
 package security
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
@@ -34,3 +36,109 @@ func TestBcryptEngineHashPassword(t *testing.T) {
 
 	assert.NoError(t, err)
 }
+
+func TestGeneratePurposeToken(t *testing.T) {
+	tokenString, err := GeneratePurposeToken(1, "test", TokenPurposeMfa, time.Minute, jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ParseToken(tokenString, jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claims.UserId)
+	assert.Equal(t, "test", claims.Username)
+	assert.Equal(t, TokenPurposeMfa, claims.Purpose)
+}
+
+func TestParseTokenShouldReturnErrorIfSignatureInvalid(t *testing.T) {
+	tokenString, err := GenerateToken(1, "test", jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseToken(tokenString, jwt.SigningMethodHS256, []byte("wrongkey"))
+
+	assert.Error(t, err)
+}
+
+func TestParseTokenShouldReturnErrorIfExpired(t *testing.T) {
+	tokenString, err := GeneratePurposeToken(1, "test", TokenPurposeMfa, -time.Minute, jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseToken(tokenString, jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+
+	assert.Error(t, err)
+}
+
+func TestGenerateNumericCode(t *testing.T) {
+	code, err := GenerateNumericCode(6)
+
+	assert.NoError(t, err)
+	assert.Len(t, code, 6)
+	assert.Regexp(t, "^[0-9]{6}$", code)
+}
+
+func TestGenerateTokenWithClaimsShouldContainAuthenticationClaims(t *testing.T) {
+	authTime := time.Now().Unix()
+	tokenString, err := GenerateTokenWithClaims(JwtClaims{
+		UserId:      1,
+		Username:    "test",
+		AuthMethods: []string{AuthMethodPassword, AuthMethodOtp},
+		AuthContext: AuthContextMultiFactor,
+		AuthTime:    authTime,
+	}, jwt.SigningMethodHS256, []byte("supersecretsignkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return []byte("supersecretsignkey"), nil
+	})
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"pwd", "otp"}, claims["amr"])
+	assert.Equal(t, "aal2", claims["acr"])
+	assert.Equal(t, float64(authTime), claims["auth_time"])
+}
+
+func TestAuthContextForMethods(t *testing.T) {
+	assert.Equal(t, AuthContextSingleFactor, AuthContextForMethods([]string{AuthMethodPassword}))
+	assert.Equal(t, AuthContextMultiFactor, AuthContextForMethods([]string{AuthMethodPassword, AuthMethodOtp}))
+	assert.Equal(t, AuthContextMultiFactor, AuthContextForMethods([]string{AuthMethodPassword, AuthMethodHardwareKey}))
+}
+
+func TestGenerateRandomString(t *testing.T) {
+	first, err := GenerateRandomString(32)
+	assert.NoError(t, err)
+
+	second, err := GenerateRandomString(32)
+	assert.NoError(t, err)
+
+	assert.Len(t, first, 43)
+	assert.NotEqual(t, first, second)
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, err := GenerateApiKey()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, ApiKeyPrefix))
+	assert.Len(t, key, len(ApiKeyPrefix)+43)
+}
+
+func TestHashApiKey(t *testing.T) {
+	assert.Len(t, HashApiKey("fitter_key"), 64)
+	assert.Equal(t, HashApiKey("fitter_key"), HashApiKey("fitter_key"))
+	assert.NotEqual(t, HashApiKey("fitter_key"), HashApiKey("fitter_other"))
+}
+
+func TestHasScope(t *testing.T) {
+	assert.True(t, (&JwtClaims{}).HasScope(ScopeAdmin))
+	assert.True(t, (&JwtClaims{ApiKeyId: 1, Scopes: []string{ScopeRead}}).HasScope(ScopeRead))
+	assert.False(t, (&JwtClaims{ApiKeyId: 1, Scopes: []string{ScopeRead}}).HasScope(ScopeWrite))
+}
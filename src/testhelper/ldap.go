@@ -0,0 +1,290 @@
+package testhelper
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	ldapBindRequest       = 0
+	ldapBindResponse      = 1
+	ldapUnbindRequest     = 2
+	ldapSearchRequest     = 3
+	ldapSearchResultEntry = 4
+	ldapSearchResultDone  = 5
+
+	ldapResultSuccess                 = 0
+	ldapResultProtocolError           = 2
+	ldapResultSizeLimitExceeded       = 4
+	ldapResultInvalidCredentials      = 49
+	ldapResultInsufficientAccessRight = 50
+)
+
+type LdapEntry struct {
+	Dn         string
+	Password   string
+	Attributes map[string][]string
+}
+
+type LdapServer struct {
+	BindDn       string
+	BindPassword string
+	listener     net.Listener
+	mutex        sync.Mutex
+	entries      []LdapEntry
+	binds        []string
+	waitGroup    sync.WaitGroup
+}
+
+func NewLdapServer(bindDn string, bindPassword string, entries ...LdapEntry) (*LdapServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &LdapServer{
+		BindDn:       bindDn,
+		BindPassword: bindPassword,
+		listener:     listener,
+		entries:      entries,
+	}
+
+	server.waitGroup.Add(1)
+	go server.serve()
+
+	return server, nil
+}
+
+func (server *LdapServer) Url() string {
+	return "ldap://" + server.listener.Addr().String()
+}
+
+func (server *LdapServer) Close() {
+	server.listener.Close()
+	server.waitGroup.Wait()
+}
+
+func (server *LdapServer) AddEntry(entry LdapEntry) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.entries = append(server.entries, entry)
+}
+
+func (server *LdapServer) Binds() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]string{}, server.binds...)
+}
+
+func (server *LdapServer) serve() {
+	defer server.waitGroup.Done()
+
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go server.handle(conn)
+	}
+}
+
+func (server *LdapServer) handle(conn net.Conn) {
+	defer conn.Close()
+	boundDn := ""
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageId := packet.Children[0].Value
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldapBindRequest:
+			dn, code := server.bind(request)
+			if code == ldapResultSuccess {
+				boundDn = dn
+			}
+			writeLdapResult(conn, messageId, ldapBindResponse, code)
+		case ldapSearchRequest:
+			if server.BindDn != "" && !strings.EqualFold(boundDn, server.BindDn) {
+				writeLdapResult(conn, messageId, ldapSearchResultDone, ldapResultInsufficientAccessRight)
+				continue
+			}
+			server.search(conn, messageId, request)
+		case ldapUnbindRequest:
+			return
+		default:
+			writeLdapResult(conn, messageId, request.Tag+1, ldapResultProtocolError)
+		}
+	}
+}
+
+func (server *LdapServer) bind(request *ber.Packet) (string, int) {
+	if len(request.Children) < 3 {
+		return "", ldapResultProtocolError
+	}
+
+	dn := fmt.Sprint(request.Children[1].Value)
+	password := request.Children[2].Data.String()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.binds = append(server.binds, dn)
+
+	if dn == "" && password == "" {
+		return "", ldapResultSuccess
+	}
+
+	if server.BindDn != "" && strings.EqualFold(dn, server.BindDn) && password == server.BindPassword {
+		return dn, ldapResultSuccess
+	}
+
+	for _, entry := range server.entries {
+		if strings.EqualFold(entry.Dn, dn) && entry.Password != "" && entry.Password == password {
+			return dn, ldapResultSuccess
+		}
+	}
+
+	return "", ldapResultInvalidCredentials
+}
+
+func (server *LdapServer) search(conn net.Conn, messageId interface{}, request *ber.Packet) {
+	if len(request.Children) < 8 {
+		writeLdapResult(conn, messageId, ldapSearchResultDone, ldapResultProtocolError)
+		return
+	}
+
+	baseDn := strings.ToLower(fmt.Sprint(request.Children[0].Value))
+	sizeLimit, _ := request.Children[3].Value.(int64)
+	filter := request.Children[6]
+
+	var attributes []string
+	for _, attribute := range request.Children[7].Children {
+		attributes = append(attributes, fmt.Sprint(attribute.Value))
+	}
+
+	server.mutex.Lock()
+	var matches []LdapEntry
+	for _, entry := range server.entries {
+		if strings.HasSuffix(strings.ToLower(entry.Dn), baseDn) && matchLdapFilter(entry, filter) {
+			matches = append(matches, entry)
+		}
+	}
+	server.mutex.Unlock()
+
+	for i, entry := range matches {
+		if sizeLimit > 0 && int64(i) >= sizeLimit {
+			writeLdapResult(conn, messageId, ldapSearchResultDone, ldapResultSizeLimitExceeded)
+			return
+		}
+
+		writeLdapEntry(conn, messageId, entry, attributes)
+	}
+
+	writeLdapResult(conn, messageId, ldapSearchResultDone, ldapResultSuccess)
+}
+
+func matchLdapFilter(entry LdapEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case 0:
+		for _, child := range filter.Children {
+			if !matchLdapFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case 1:
+		for _, child := range filter.Children {
+			if matchLdapFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case 2:
+		return len(filter.Children) == 1 && !matchLdapFilter(entry, filter.Children[0])
+	case 3:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		for _, value := range ldapAttributeValues(entry, fmt.Sprint(filter.Children[0].Value)) {
+			if strings.EqualFold(value, fmt.Sprint(filter.Children[1].Value)) {
+				return true
+			}
+		}
+		return false
+	case 7:
+		return len(ldapAttributeValues(entry, filter.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+func ldapAttributeValues(entry LdapEntry, name string) []string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func writeLdapEntry(conn net.Conn, messageId interface{}, entry LdapEntry, attributes []string) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.Dn, "DN"))
+
+	attributeList := ber.NewSequence("Attributes")
+	for name, values := range entry.Attributes {
+		if len(attributes) > 0 && !containsFold(attributes, name) {
+			continue
+		}
+
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributeList.AppendChild(attribute)
+	}
+	response.AppendChild(attributeList)
+
+	writeLdapMessage(conn, messageId, response)
+}
+
+func writeLdapResult(conn net.Conn, messageId interface{}, tag ber.Tag, code int) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+
+	writeLdapMessage(conn, messageId, response)
+}
+
+func writeLdapMessage(conn net.Conn, messageId interface{}, response *ber.Packet) {
+	packet := ber.NewSequence("LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "Message ID"))
+	packet.AppendChild(response)
+	conn.Write(packet.Bytes())
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+
+	return false
+}
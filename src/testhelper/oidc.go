@@ -0,0 +1,234 @@
+package testhelper
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const OidcKeyId = "test-key"
+
+type OidcProvider struct {
+	Server       *httptest.Server
+	ClientId     string
+	ClientSecret string
+	Key          *rsa.PrivateKey
+	KeyId        string
+	mutex        sync.Mutex
+	jwksRequests int
+	user         map[string]interface{}
+	issued       int
+	grants       map[string]map[string]interface{}
+	accessTokens map[string]map[string]interface{}
+}
+
+func NewOidcProvider(clientId string, clientSecret string) (*OidcProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &OidcProvider{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Key:          key,
+		KeyId:        OidcKeyId,
+		user:         map[string]interface{}{},
+		grants:       map[string]map[string]interface{}{},
+		accessTokens: map[string]map[string]interface{}{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discoveryHandler)
+	mux.HandleFunc("/authorize", provider.authorizeHandler)
+	mux.HandleFunc("/token", provider.tokenHandler)
+	mux.HandleFunc("/userinfo", provider.userInfoHandler)
+	mux.HandleFunc("/jwks", provider.jwksHandler)
+	provider.Server = httptest.NewServer(mux)
+
+	return provider, nil
+}
+
+func (provider *OidcProvider) Issuer() string {
+	return provider.Server.URL
+}
+
+func (provider *OidcProvider) Close() {
+	provider.Server.Close()
+}
+
+func (provider *OidcProvider) SetUser(claims map[string]interface{}) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.user = claims
+}
+
+func (provider *OidcProvider) IssueCode(claims map[string]interface{}) string {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.issued++
+	code := fmt.Sprintf("code-%d", provider.issued)
+	provider.grants[code] = claims
+	return code
+}
+
+func (provider *OidcProvider) SignIdToken(claims map[string]interface{}) (string, error) {
+	idClaims := jwt.MapClaims{
+		"iss": provider.Issuer(),
+		"aud": provider.ClientId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+	token.Header["kid"] = provider.KeyId
+	return token.SignedString(provider.Key)
+}
+
+func (provider *OidcProvider) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 provider.Issuer(),
+		"authorization_endpoint": provider.Issuer() + "/authorize",
+		"token_endpoint":         provider.Issuer() + "/token",
+		"userinfo_endpoint":      provider.Issuer() + "/userinfo",
+		"jwks_uri":               provider.Issuer() + "/jwks",
+	})
+}
+
+func (provider *OidcProvider) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != provider.ClientId {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+
+	provider.mutex.Lock()
+	claims := map[string]interface{}{}
+	for name, value := range provider.user {
+		claims[name] = value
+	}
+	provider.mutex.Unlock()
+
+	if nonce := query.Get("nonce"); nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	redirectUrl, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect uri", http.StatusBadRequest)
+		return
+	}
+
+	callbackQuery := redirectUrl.Query()
+	callbackQuery.Set("code", provider.IssueCode(claims))
+	callbackQuery.Set("state", query.Get("state"))
+	redirectUrl.RawQuery = callbackQuery.Encode()
+
+	http.Redirect(w, r, redirectUrl.String(), http.StatusFound)
+}
+
+func (provider *OidcProvider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_request"})
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientId != provider.ClientId || clientSecret != provider.ClientSecret {
+		writeJson(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	provider.mutex.Lock()
+	claims, ok := provider.grants[code]
+	delete(provider.grants, code)
+	provider.mutex.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJson(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := provider.SignIdToken(claims)
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]interface{}{"error": "server_error"})
+		return
+	}
+
+	provider.mutex.Lock()
+	provider.accessTokens["access-"+code] = claims
+	provider.mutex.Unlock()
+
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-" + code,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (provider *OidcProvider) userInfoHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	provider.mutex.Lock()
+	claims, ok := provider.accessTokens[accessToken]
+	provider.mutex.Unlock()
+
+	if !ok {
+		writeJson(w, http.StatusUnauthorized, map[string]interface{}{"error": "invalid_token"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, claims)
+}
+
+// JwksRequests returns how often the key set was fetched.
+func (provider *OidcProvider) JwksRequests() int {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	return provider.jwksRequests
+}
+
+func (provider *OidcProvider) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	provider.mutex.Lock()
+	provider.jwksRequests++
+	provider.mutex.Unlock()
+
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]interface{}{{
+			"kid": OidcKeyId,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(provider.Key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(provider.Key.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
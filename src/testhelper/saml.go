@@ -0,0 +1,189 @@
+package testhelper
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlStatusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+)
+
+type SamlIdentityProvider struct {
+	EntityId    string
+	Key         *rsa.PrivateKey
+	Certificate []byte
+}
+
+type SamlAssertion struct {
+	Id            string
+	RequestId     string
+	Audience      string
+	Recipient     string
+	Subject       string
+	Attributes    map[string][]string
+	Status        string
+	IssueInstant  time.Time
+	NotOnOrAfter  time.Time
+	SignResponse  bool
+	SkipAssertion bool
+	Unsigned      bool
+}
+
+func NewSamlIdentityProvider(entityId string) (*SamlIdentityProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: entityId},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SamlIdentityProvider{
+		EntityId:    entityId,
+		Key:         key,
+		Certificate: certificate,
+	}, nil
+}
+
+func (provider *SamlIdentityProvider) GetKeyPair() (*rsa.PrivateKey, []byte, error) {
+	return provider.Key, provider.Certificate, nil
+}
+
+func (provider *SamlIdentityProvider) CertificatePem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: provider.Certificate}))
+}
+
+func (provider *SamlIdentityProvider) Response(assertion SamlAssertion) (string, error) {
+	now := assertion.IssueInstant
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if assertion.NotOnOrAfter.IsZero() {
+		assertion.NotOnOrAfter = now.Add(5 * time.Minute)
+	}
+	if assertion.Status == "" {
+		assertion.Status = samlStatusSuccess
+	}
+	if assertion.Id == "" {
+		assertion.Id = "_assertion-1"
+	}
+
+	signer := dsig.NewDefaultSigningContext(provider)
+	signer.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	doc := etree.NewDocument()
+	response := doc.CreateElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", samlProtocolNamespace)
+	response.CreateAttr("xmlns:saml", samlAssertionNamespace)
+	response.CreateAttr("ID", "_response-"+assertion.Id)
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", now.UTC().Format(time.RFC3339))
+	response.CreateAttr("Destination", assertion.Recipient)
+	response.CreateAttr("InResponseTo", assertion.RequestId)
+	response.CreateElement("saml:Issuer").SetText(provider.EntityId)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", assertion.Status)
+
+	if !assertion.SkipAssertion {
+		assertionElement := provider.assertionElement(assertion, now)
+		if !assertion.Unsigned && !assertion.SignResponse {
+			signed, err := signer.SignEnveloped(assertionElement)
+			if err != nil {
+				return "", err
+			}
+
+			assertionElement = moveSignatureAfterIssuer(signed)
+		}
+
+		response.AddChild(assertionElement)
+	}
+
+	if assertion.SignResponse && !assertion.Unsigned {
+		signed, err := signer.SignEnveloped(response)
+		if err != nil {
+			return "", err
+		}
+
+		doc.SetRoot(moveSignatureAfterIssuer(signed))
+	}
+
+	raw, err := doc.WriteToBytes()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+func (provider *SamlIdentityProvider) assertionElement(assertion SamlAssertion, now time.Time) *etree.Element {
+	element := etree.NewElement("saml:Assertion")
+	element.CreateAttr("xmlns:saml", samlAssertionNamespace)
+	element.CreateAttr("ID", assertion.Id)
+	element.CreateAttr("Version", "2.0")
+	element.CreateAttr("IssueInstant", now.UTC().Format(time.RFC3339))
+	element.CreateElement("saml:Issuer").SetText(provider.EntityId)
+
+	subject := element.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText(assertion.Subject)
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	confirmationData := confirmation.CreateElement("saml:SubjectConfirmationData")
+	confirmationData.CreateAttr("InResponseTo", assertion.RequestId)
+	confirmationData.CreateAttr("Recipient", assertion.Recipient)
+	confirmationData.CreateAttr("NotOnOrAfter", assertion.NotOnOrAfter.UTC().Format(time.RFC3339))
+
+	conditions := element.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", now.Add(-time.Minute).UTC().Format(time.RFC3339))
+	conditions.CreateAttr("NotOnOrAfter", assertion.NotOnOrAfter.UTC().Format(time.RFC3339))
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(assertion.Audience)
+
+	if len(assertion.Attributes) > 0 {
+		names := []string{}
+		for name := range assertion.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		statement := element.CreateElement("saml:AttributeStatement")
+		for _, name := range names {
+			attribute := statement.CreateElement("saml:Attribute")
+			attribute.CreateAttr("Name", name)
+			for _, value := range assertion.Attributes[name] {
+				attribute.CreateElement("saml:AttributeValue").SetText(value)
+			}
+		}
+	}
+
+	return element
+}
+
+func moveSignatureAfterIssuer(element *etree.Element) *etree.Element {
+	signature := element.Child[len(element.Child)-1]
+	element.Child = element.Child[:len(element.Child)-1]
+	element.InsertChildAt(1, signature)
+	return element
+}